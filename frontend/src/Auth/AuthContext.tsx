@@ -11,10 +11,12 @@ $HISTORY:
 
 Dec-24-2025   Created initial file.
 Dec-30-2025   Incorporated authentication logic with the backend api
+Oct-19-2026   Backend calls go through apiFetch(), which sends the CSRF token
 ------------------------------------------------------------------
 */
 import React, { createContext, useContext, useEffect, useRef, useState } from "react";
 import { useLocation } from "react-router-dom";
+import { apiFetch } from "./apiFetch";
 
 type AuthCtx = {
   authorized: boolean;
@@ -42,7 +44,7 @@ export function AuthProvider({ children }: { children: React.ReactNode }) {
     inFlight.current = controller;
 
     try {
-      const res = await fetch("/api/check_auth", {
+      const res = await apiFetch("/api/check_auth", {
         method: "GET",
         credentials: "include",
         signal: controller.signal,
@@ -0,0 +1,62 @@
+/*
+------------------------------------------------------------------
+FILE NAME:     apiFetch.ts
+PROJECT:       CashflowAnalysis
+Date Created:  Oct-19-2026
+--------------------------------------------------------------------
+DESCRIPTION:
+fetch() wrapper for calls to the backend api. Sends cookies with every
+request and copies the csrf-token cookie into the X-CSRF-Token header of
+state-changing requests, which the backend requires for session
+authenticated calls. When the cookie is missing the token is loaded from
+/api/csrf_token/ first.
+--------------------------------------------------------------------
+$HISTORY:
+
+Oct-19-2026   Created initial file.
+------------------------------------------------------------------
+*/
+
+const CSRF_COOKIE = "csrf-token";
+const CSRF_HEADER = "X-CSRF-Token";
+const SAFE_METHODS = ["GET", "HEAD", "OPTIONS"];
+
+function readCookie(name: string): string | null {
+    for (const part of document.cookie.split(";")) {
+        const [key, ...value] = part.trim().split("=");
+        if (key === name) {
+            return decodeURIComponent(value.join("="));
+        }
+    }
+    return null;
+}
+
+// Returns the sessions CSRF token, or null without a session
+async function csrfToken(): Promise<string | null> {
+    const token = readCookie(CSRF_COOKIE);
+    if (token) {
+        return token;
+    }
+    try {
+        const res = await fetch("/api/csrf_token/", { credentials: "include" });
+        if (!res.ok) {
+            return null;
+        }
+        const data = await res.json();
+        return data.csrf_token ?? null;
+    } catch {
+        return null;
+    }
+}
+
+export async function apiFetch(input: RequestInfo | URL, init: RequestInit = {}): Promise<Response> {
+    const method = (init.method ?? "GET").toUpperCase();
+    const headers = new Headers(init.headers);
+    if (!SAFE_METHODS.includes(method) && !headers.has(CSRF_HEADER)) {
+        const token = await csrfToken();
+        if (token) {
+            headers.set(CSRF_HEADER, token);
+        }
+    }
+    return fetch(input, { credentials: "include", ...init, headers });
+}
@@ -11,12 +11,14 @@ $HISTORY:
 
 Dec-30-2025   Created initial file.
 Dec-30-2025   Added logout()
+Oct-19-2026   Backend calls go through apiFetch(), which sends the CSRF token
 ------------------------------------------------------------------
 */
+import { apiFetch } from "./apiFetch";
 
 export function logout() {
 
-    fetch(`/api/logout/`, { 
+    apiFetch(`/api/logout/`, { 
         method: "POST",
         headers: {
             "Content-Type": "application/json"
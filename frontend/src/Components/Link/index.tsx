@@ -11,6 +11,7 @@ $HISTORY:
 
 Dec-24-2025   Created initial file.
 Dec-30-2025   Added auth to bring username to the backend
+Oct-19-2026   Backend calls go through apiFetch(), which sends the CSRF token
 ------------------------------------------------------------------
 */
 import React, { useEffect, useContext } from "react";
@@ -18,6 +19,7 @@ import { usePlaidLink } from "react-plaid-link";
 import Button from "plaid-threads/Button";
 
 import Context from "../../Context";
+import { apiFetch } from "../../Auth/apiFetch";
 
 const Link = () => {
 
@@ -29,7 +31,7 @@ const Link = () => {
     (public_token: string) => {
       // If the access_token is needed, send public_token to server
       const exchangePublicTokenForAccessToken = async () => {
-        const response = await fetch(`/api/save_user_account/`, {
+        const response = await apiFetch(`/api/save_user_account/`, {
           method: "POST",
           headers: {
             "Content-Type": "application/x-www-form-urlencoded;charset=UTF-8",
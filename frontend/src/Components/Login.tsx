@@ -11,10 +11,12 @@ $HISTORY:
 
 Dec-24-2025   Created initial file.
 Dec-30-2025   Added password to login form and authentication
+Oct-19-2026   Backend calls go through apiFetch(), which sends the CSRF token
 ------------------------------------------------------------------
 */
 import React from "react";
 import { useNavigate } from "react-router-dom";
+import { apiFetch } from "../Auth/apiFetch";
 
 
 function LoginComponent() {
@@ -23,7 +25,7 @@ function LoginComponent() {
     const [password, setPassword] = React.useState("");
     const handleLogin = () => {
         // Implement your login logic here
-        fetch(`/api/login/`, { 
+        apiFetch(`/api/login/`, { 
             method: "POST",
             headers: {
                 "Content-Type": "application/json"
@@ -11,6 +11,7 @@ $HISTORY:
 
 Jan-06-2026   Created initial file.
 Jan-28-2026   Added screen for choosing widget type
+Oct-19-2026   Backend calls go through apiFetch(), which sends the CSRF token
 ------------------------------------------------------------------
 */
 import styles from "./index.module.scss";
@@ -21,6 +22,7 @@ import {CircleErrorX} from "../../CustomTags/Icons/CircleErrorX"
 import {useState, useEffect} from "react"
 import { WidgetData, WidgetTypes } from "../Widget";
 import { PopupBack } from "../../CustomTags/PopupBack";
+import { apiFetch } from "../../../Auth/apiFetch";
 
 //Props for SelectAccountScreen
 interface SelectAccountScreenProps {
@@ -66,7 +68,7 @@ export const SelectAccountScreen = ({
         var accountID = selectedAccount[1]
 
         try {
-        const res = await fetch("/api/SaveWidgetAccount", {
+        const res = await apiFetch("/api/SaveWidgetAccount", {
             method: "POST",
             credentials: "include",
             headers: {
@@ -108,7 +110,7 @@ export const SelectAccountScreen = ({
         const fetchData = async () => {
             try {
                 setLoadingAccounts(true)
-                const response = await fetch("/api/retrieve_user_account/", {
+                const response = await apiFetch("/api/retrieve_user_account/", {
                     method: "GET",
                     credentials: "include",
                 });
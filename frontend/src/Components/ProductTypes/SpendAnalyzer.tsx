@@ -3,6 +3,7 @@ import { PieChart } from '@mui/x-charts/PieChart';  //https://mui.com/x/react-ch
 
 interface Props {
   account_id: string;
+import { apiFetch } from "../../Auth/apiFetch";
   name: string;
   official_name: string;
   subtype: string;
@@ -15,7 +16,7 @@ function SpendAnalyzer() {
   const [accounts, setAccounts] = useState<Props[]>([]);
 
   useEffect(() => {
-    fetch("/api/accounts")
+    apiFetch("/api/accounts")
       .then(res => res.json())
       .then(data => {
         console.log(data);
@@ -11,6 +11,7 @@ $HISTORY:
 
 Dec-24-2025   Created initial file.
 Dec-30-2025   Switched api call from /api/transactions to /api/all-transactions
+Oct-19-2026   Backend calls go through apiFetch(), which sends the CSRF token
 ------------------------------------------------------------------
 */
 import React, { useContext, useEffect, useMemo, useState } from "react";
@@ -20,6 +21,7 @@ import {
   transformTransactionsData,
 } from "../../dataUtilities";
 import Context from "../../Context";
+import { apiFetch } from "../../Auth/apiFetch";
 
 type Transaction = {
   account_id: string;
@@ -48,7 +50,7 @@ function Transactions() {
       try {
         setLoading(true);
         setError(null);
-        const res = await fetch("/api/all-transactions", { signal: ac.signal });
+        const res = await apiFetch("/api/all-transactions", { signal: ac.signal });
         if (!res.ok) throw new Error(`HTTP ${res.status}`);
         const data = await res.json();
         setTransactions(data.latest_transactions ?? []);
@@ -11,6 +11,7 @@ DESCRIPTION:
 $HISTORY:
 
 Jan-28-2026   Created initial file.
+Oct-19-2026   Backend calls go through apiFetch(), which sends the CSRF token
 ------------------------------------------------------------------
 */
 import styles from "./index.module.scss"
@@ -18,6 +19,7 @@ import {useState} from "react";
 import {SelectAccountScreen} from "../../ProductTypes/SelectAccount/index"
 import { CloseXButton } from "../../CustomTags/Buttons/CloseXButton";
 import { PopupBack } from "../../CustomTags/PopupBack";
+import { apiFetch } from "../../../Auth/apiFetch";
 
 export interface WidgetData {
 	WidgetID:      number,
@@ -56,7 +58,7 @@ export const Widget = ({
     const handleDelete = () => {
         const deleteAccount = async () => {
             try {
-            const res = await fetch("/api/DeleteWidgetAccount", {
+            const res = await apiFetch("/api/DeleteWidgetAccount", {
                 method: "POST",
                 credentials: "include",
                 headers: {
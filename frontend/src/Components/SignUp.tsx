@@ -11,23 +11,29 @@ $HISTORY:
 
 Dec-24-2025   Created initial file.
 Dec-30-2025   Added password to sign up form and authentication
+Oct-19-2026   Backend calls go through apiFetch(), which sends the CSRF token
+Oct-19-2026   Added email to the sign up form, the backend requires it for verification
 ------------------------------------------------------------------
 */
 import React from "react";
 import { useNavigate } from "react-router-dom";
+import { apiFetch } from "../Auth/apiFetch";
 
 function SignUpComponent() {
       const navigate = useNavigate();
     const [username, setUsername] = React.useState("");
+    const [email, setEmail] = React.useState("");
     const [password, setPassword] = React.useState("");
+    const [error, setError] = React.useState("");
     const handleSignUp = () => {
-        fetch(`/api/signup/`, { 
+        apiFetch(`/api/signup/`, { 
             method: "POST",
             headers: {
                 "Content-Type": "application/json"
             },
             body: JSON.stringify({ 
                 username: username,
+                email: email,
                 password: password
              }),
             credentials: "include"
@@ -38,6 +44,9 @@ function SignUpComponent() {
                     navigate("/dashboard"); 
                 } else {
                     console.log("signup failed");
+                    response.json()
+                        .then(data => setError(data.message ?? "Sign up failed"))
+                        .catch(() => setError("Sign up failed"));
                 }
             })
             .catch(error => {
@@ -53,11 +62,16 @@ function SignUpComponent() {
                     Username:
                     <input type="text" name="username" value={username} onChange={(e) => setUsername(e.target.value)} />
                 </label>
+                <label>
+                    Email:
+                    <input type="email" name="email" required value={email} onChange={(e) => setEmail(e.target.value)} />
+                </label>
                 <label>
                     Password:
                     <input type="password" name="password" value={password} onChange={(e) => setPassword(e.target.value)} />
                 </label>
                 <br />
+                {error && <p>{error}</p>}
                 <button type="submit">Sign Up</button>
             </form>
         </div>
@@ -13,12 +13,14 @@ Dec-24-2025   Created initial file.
 Dec-30-2025   Disabled Widget Board features do to bugs
 Jan-28-2026   Created new widget board design giving users ability to add/remove rows and widgets
               with three different row types and three different widget sizes.
+Oct-19-2026   Backend calls go through apiFetch(), which sends the CSRF token
 ------------------------------------------------------------------
 */
 import React, {useRef, useEffect, useState} from "react";
 import styles from "./ComponentsCSS/widgetBoard.module.scss"
 import { Widget, WidgetData } from "./ProductTypes/Widget";
 import { CloseXButton } from "./CustomTags/Buttons/CloseXButton";
+import { apiFetch } from "../Auth/apiFetch";
 
 
 //Interface for Singular Widget Board Data
@@ -60,7 +62,7 @@ function WidgetBoard() {
     useEffect(() => {
         const fetchData = async () => {
             try {
-                const response = await fetch("/api/retrieveWidgets/", {
+                const response = await apiFetch("/api/retrieveWidgets/", {
                     method: "GET",
                     credentials: "include",
                 });
@@ -111,7 +113,7 @@ function WidgetBoard() {
               UserID: widgetBoard.UserID,
               WidgetBoardRows: [newRow]
             }
-          const res = await fetch("/api/AddRowToWidgetBoard", {
+          const res = await apiFetch("/api/AddRowToWidgetBoard", {
               method: "POST",
               credentials: "include",
               headers: {
@@ -182,7 +184,7 @@ function WidgetBoard() {
     const handleDelete = () => {
         const deleteRow = async () => {
             try {
-            const res = await fetch("/api/DeleteRowToWidgetBoard", {
+            const res = await apiFetch("/api/DeleteRowToWidgetBoard", {
                 method: "POST",
                 credentials: "include",
                 headers: {
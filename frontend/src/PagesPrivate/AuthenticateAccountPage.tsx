@@ -10,6 +10,8 @@ DESCRIPTION:
 $HISTORY:
 
 Dec-24-2025   Created initial file.
+Oct-19-2026   Backend calls go through apiFetch(), which sends the CSRF token
+Oct-19-2026   The user_token for CRA products is posted as JSON when creating the link token
 ------------------------------------------------------------------
 */
 import React, { useEffect, useContext, useCallback, useState } from "react";
@@ -21,13 +23,14 @@ import Context from "../Context";
 
 import styles from "../App.module.scss";
 import { Products as PlaidProducts } from "plaid";
+import { apiFetch } from "../Auth/apiFetch";
 
 const App = () => {
   const { linkSuccess, isPaymentInitiation, itemId, dispatch } =
     useContext(Context);
 
   const getInfo = useCallback(async () => {
-    const response = await fetch("/api/info", { method: "POST" });
+    const response = await apiFetch("/api/info", { method: "POST" });
     if (!response.ok) {
       dispatch({ type: "SET_STATE", state: { backend: false } });
       return { paymentInitiation: false };
@@ -57,7 +60,7 @@ const App = () => {
   }, [dispatch]);
 
   const generateUserToken = useCallback(async () => {
-    const response = await fetch("api/create_user_token", { method: "POST" });
+    const response = await apiFetch("api/create_user_token", { method: "POST" });
     if (!response.ok) {
       dispatch({ type: "SET_STATE", state: { userToken: null } });
       return;
@@ -80,13 +83,17 @@ const App = () => {
   }, [dispatch]);
 
   const generateToken = useCallback(
-    async (isPaymentInitiation: boolean) => {
+    async (isPaymentInitiation: boolean, userToken?: string) => {
       // Link tokens for 'payment_initiation' use a different creation flow in your backend.
       const path = isPaymentInitiation
         ? "/api/create_link_token_for_payment"
         : "/api/create_link_token";
-      const response = await fetch(path, {
+      const response = await apiFetch(path, {
         method: "POST",
+        headers: {
+          "Content-Type": "application/json",
+        },
+        body: JSON.stringify({ user_token: userToken ?? "" }),
       });
       if (!response.ok) {
         dispatch({ type: "SET_STATE", state: { linkToken: null } });
@@ -127,10 +134,8 @@ const App = () => {
         return;
       }
 
-      if (isUserTokenFlow) {
-        await generateUserToken();
-      }
-      generateToken(paymentInitiation);
+      const userToken = isUserTokenFlow ? await generateUserToken() : undefined;
+      generateToken(paymentInitiation, userToken);
     };
     init();
   }, [dispatch, generateToken, generateUserToken, getInfo]);
@@ -168,10 +173,10 @@ const App = () => {
 
                 // regenerate tokens so Header/Link has a link_token to use
                 const { paymentInitiation, isUserTokenFlow } = await getInfo();
-                if (isUserTokenFlow) {
-                  await generateUserToken();
-                }
-                await generateToken(paymentInitiation);
+                const userToken = isUserTokenFlow
+                  ? await generateUserToken()
+                  : undefined;
+                await generateToken(paymentInitiation, userToken);
               }}
             >
               Verify Another Account
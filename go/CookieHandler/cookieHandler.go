@@ -14,6 +14,8 @@ $HISTORY:
 
 Dec-24-2025   Created initial file.
 Dec-30-2025   Deleted testSetCookie() and testGetCookie(). Cookies now get set with Expire time instead of Max Age
+Oct-19-2026   SetCookie() no longer writes to the response body so several cookies can be set in one response
+Oct-19-2026   The secret key is read from COOKIE_SECRET_KEY instead of the source
 ------------------------------------------------------------------
 */
 package cookiehandler
@@ -24,6 +26,7 @@ import (
 	"fmt"
 	"log"
 	"net/http"
+	"os"
 	"time"
 )
 
@@ -35,13 +38,17 @@ import (
 var (
 	ErrValueTooLong = errors.New("cookie value too long")
 	ErrInvalidValue = errors.New("invalid cookie value")
+	ErrMissingKey   = errors.New("COOKIE_SECRET_KEY is not set or is not a 32 byte hex string")
 	secretKey       []byte
 )
 
 // Creates cookie on the client side
 func SetCookie(w http.ResponseWriter, cookieName string, cookieValue string, expiresAt time.Time) error {
 
-	SetSecretKeyFromHex()
+	if err := SetSecretKeyFromHex(); err != nil {
+		log.Println(err)
+		return err
+	}
 
 	cookie := http.Cookie{
 		Name:     cookieName,
@@ -60,13 +67,14 @@ func SetCookie(w http.ResponseWriter, cookieName string, cookieValue string, exp
 		return err
 	}
 
-	w.Write([]byte("cookie set!"))
 	return nil
 }
 
 // Returns the value of the cookie when given the cookie name
 func GetCookie(r *http.Request, cookieName string) (string, error) {
-	SetSecretKeyFromHex()
+	if err := SetSecretKeyFromHex(); err != nil {
+		return "", fmt.Errorf("server error: %w", err)
+	}
 	value, err := ReadEncrypted(r, cookieName, secretKey)
 	if err != nil {
 		switch {
@@ -82,14 +90,15 @@ func GetCookie(r *http.Request, cookieName string) (string, error) {
 	return value, nil
 }
 
-// SetSecretKeyFromHex initializes the package secretKey from a hex-encoded string.
+// SetSecretKeyFromHex initializes the package secretKey from the hex-encoded
+// COOKIE_SECRET_KEY environment variable.
 // Call this during program startup (or in tests) before using the helpers.
 func SetSecretKeyFromHex() error {
-	var err error
-	secretKey, err = hex.DecodeString("13d6b4dff8f84a10851021ec8608f814570d562c92fe6b5ec4c9f595bcb3234b")
-	if err != nil {
-		return err
+	key, err := hex.DecodeString(os.Getenv("COOKIE_SECRET_KEY"))
+	if err != nil || len(key) != 32 {
+		return ErrMissingKey
 	}
+	secretKey = key
 
 	return nil
 }
@@ -0,0 +1,75 @@
+/*
+------------------------------------------------------------------
+FILE NAME:     csrf.go
+PROJECT:       CashflowAnalysis
+Date Created:  Oct-19-2026
+--------------------------------------------------------------------
+DESCRIPTION:
+CSRF tokens for cookie authenticated requests. Each session gets a random
+token when its cookies are set. Only its hash is stored with the session,
+see HashCSRFToken(). The token is sent to the client in a cookie readable by
+javascript (csrf-token) and must be echoed back in the X-CSRF-Token header on
+state-changing requests.
+--------------------------------------------------------------------
+$HISTORY:
+
+Oct-19-2026   Created initial file.
+Oct-19-2026   Tokens are random per session instead of an HMAC of the session id.
+-             Added NewCSRFToken() and HashCSRFToken(). ValidCSRFToken() takes the stored hash
+------------------------------------------------------------------
+*/
+package cookiehandler
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"net/http"
+	"time"
+)
+
+const (
+	CSRFCookieName = "csrf-token"
+	CSRFHeaderName = "X-CSRF-Token"
+)
+
+// Returns a new random CSRF token for a session
+func NewCSRFToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// Hashes a CSRF token for storage with its session
+func HashCSRFToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// Checks the token sent by the client against the hash stored with the session
+// in constant time
+func ValidCSRFToken(tokenHash string, token string) bool {
+	if tokenHash == "" || token == "" {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(HashCSRFToken(token)), []byte(tokenHash)) == 1
+}
+
+// Sets the csrf-token cookie. Unlike the session cookie this is not HttpOnly
+// so the frontend can read it and copy it into the X-CSRF-Token header.
+// An empty token deletes the cookie
+func SetCSRFCookie(w http.ResponseWriter, token string, expiresAt time.Time) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     CSRFCookieName,
+		Value:    token,
+		Path:     "/",
+		Expires:  expiresAt,
+		HttpOnly: false,
+		Secure:   true,
+		SameSite: http.SameSiteStrictMode,
+	})
+}
@@ -0,0 +1,220 @@
+/*
+------------------------------------------------------------------
+FILE NAME:     client.go
+PROJECT:       CashflowAnalysis
+Date Created:  Oct-19-2026
+--------------------------------------------------------------------
+DESCRIPTION:
+The PlaidClient interface covers the Plaid operations the app uses. The
+plaid-go implementation talks to the Plaid api, FakeClient (PLAID_ENV=fake)
+answers from fixture files so the app runs offline and in tests.
+--------------------------------------------------------------------
+$HISTORY:
+
+Oct-19-2026   Created initial file.
+Oct-19-2026   Added WebhookVerificationKey()
+Oct-19-2026   Added InvestmentsHoldings() and InvestmentsTransactions()
+Oct-19-2026   Added Liabilities()
+Oct-19-2026   Added TransactionsRecurring()
+------------------------------------------------------------------
+*/
+
+package PlaidComponents
+
+import (
+	"context"
+
+	plaid "github.com/plaid/plaid-go/v31/plaid"
+)
+
+// The Plaid operations used by the app
+type PlaidClient interface {
+	CreateLinkToken(request plaid.LinkTokenCreateRequest) (string, error)
+	CreateUser(request plaid.UserCreateRequest) (string, error)
+	CreatePublicToken(item ItemHandle) (string, error)
+	ExchangePublicToken(publicToken string) (ItemHandle, error)
+	Accounts(item ItemHandle) ([]plaid.AccountBase, error)
+	Balances(item ItemHandle) ([]plaid.AccountBase, error)
+	Item(item ItemHandle) (plaid.ItemWithConsentFields, error)
+	Institution(institutionID string, countryCodes []plaid.CountryCode) (plaid.Institution, error)
+	RemoveItem(item ItemHandle) error
+	//count <= 0 uses Plaids default page size
+	TransactionsSync(item ItemHandle, cursor string, count int32) (plaid.TransactionsSyncResponse, error)
+	WebhookVerificationKey(keyID string) (plaid.JWKPublicKey, error)
+	InvestmentsHoldings(item ItemHandle) (plaid.InvestmentsHoldingsGetResponse, error)
+	//Dates are YYYY-MM-DD, count <= 0 uses Plaids default page size
+	InvestmentsTransactions(item ItemHandle, startDate string, endDate string, offset int32, count int32) (plaid.InvestmentsTransactionsGetResponse, error)
+	Liabilities(item ItemHandle) (plaid.LiabilitiesGetResponse, error)
+	TransactionsRecurring(item ItemHandle) (plaid.TransactionsRecurringGetResponse, error)
+}
+
+// The client used by every function in this package
+var plaidClient PlaidClient
+
+// Replaces the Plaid client, i.e. with a FakeClient in tests
+func SetClient(c PlaidClient) {
+	plaidClient = c
+}
+
+// Returns the Plaid client in use
+func Client() PlaidClient {
+	return plaidClient
+}
+
+// PlaidClient backed by the plaid-go api client
+type apiClient struct {
+	api *plaid.APIClient
+}
+
+func (a *apiClient) CreateLinkToken(request plaid.LinkTokenCreateRequest) (string, error) {
+	resp, _, err := a.api.PlaidApi.LinkTokenCreate(context.Background()).LinkTokenCreateRequest(request).Execute()
+	if err != nil {
+		return "", err
+	}
+	return resp.GetLinkToken(), nil
+}
+
+func (a *apiClient) CreateUser(request plaid.UserCreateRequest) (string, error) {
+	resp, _, err := a.api.PlaidApi.UserCreate(context.Background()).UserCreateRequest(request).Execute()
+	if err != nil {
+		return "", err
+	}
+	return resp.GetUserToken(), nil
+}
+
+func (a *apiClient) CreatePublicToken(item ItemHandle) (string, error) {
+	resp, _, err := a.api.PlaidApi.ItemCreatePublicToken(context.Background()).ItemPublicTokenCreateRequest(
+		*plaid.NewItemPublicTokenCreateRequest(item.AccessToken),
+	).Execute()
+	if err != nil {
+		return "", err
+	}
+	return resp.GetPublicToken(), nil
+}
+
+func (a *apiClient) ExchangePublicToken(publicToken string) (ItemHandle, error) {
+	resp, _, err := a.api.PlaidApi.ItemPublicTokenExchange(context.Background()).ItemPublicTokenExchangeRequest(
+		*plaid.NewItemPublicTokenExchangeRequest(publicToken),
+	).Execute()
+	if err != nil {
+		return ItemHandle{}, err
+	}
+	return ItemHandle{AccessToken: resp.GetAccessToken(), ItemID: resp.GetItemId()}, nil
+}
+
+func (a *apiClient) Accounts(item ItemHandle) ([]plaid.AccountBase, error) {
+	resp, _, err := a.api.PlaidApi.AccountsGet(context.Background()).AccountsGetRequest(
+		*plaid.NewAccountsGetRequest(item.AccessToken),
+	).Execute()
+	if err != nil {
+		return nil, err
+	}
+	return resp.GetAccounts(), nil
+}
+
+func (a *apiClient) Balances(item ItemHandle) ([]plaid.AccountBase, error) {
+	resp, _, err := a.api.PlaidApi.AccountsBalanceGet(context.Background()).AccountsBalanceGetRequest(
+		*plaid.NewAccountsBalanceGetRequest(item.AccessToken),
+	).Execute()
+	if err != nil {
+		return nil, err
+	}
+	return resp.GetAccounts(), nil
+}
+
+func (a *apiClient) Item(item ItemHandle) (plaid.ItemWithConsentFields, error) {
+	resp, _, err := a.api.PlaidApi.ItemGet(context.Background()).ItemGetRequest(
+		*plaid.NewItemGetRequest(item.AccessToken),
+	).Execute()
+	if err != nil {
+		return plaid.ItemWithConsentFields{}, err
+	}
+	return resp.GetItem(), nil
+}
+
+func (a *apiClient) Institution(institutionID string, countryCodes []plaid.CountryCode) (plaid.Institution, error) {
+	resp, _, err := a.api.PlaidApi.InstitutionsGetById(context.Background()).InstitutionsGetByIdRequest(
+		*plaid.NewInstitutionsGetByIdRequest(institutionID, countryCodes),
+	).Execute()
+	if err != nil {
+		return plaid.Institution{}, err
+	}
+	return resp.GetInstitution(), nil
+}
+
+func (a *apiClient) RemoveItem(item ItemHandle) error {
+	_, _, err := a.api.PlaidApi.ItemRemove(context.Background()).ItemRemoveRequest(
+		*plaid.NewItemRemoveRequest(item.AccessToken),
+	).Execute()
+	return err
+}
+
+func (a *apiClient) TransactionsSync(item ItemHandle, cursor string, count int32) (plaid.TransactionsSyncResponse, error) {
+	request := plaid.NewTransactionsSyncRequest(item.AccessToken)
+	if cursor != "" {
+		request.SetCursor(cursor)
+	}
+	if count > 0 {
+		request.SetCount(count)
+	}
+	resp, _, err := a.api.PlaidApi.TransactionsSync(context.Background()).TransactionsSyncRequest(*request).Execute()
+	if err != nil {
+		return plaid.TransactionsSyncResponse{}, err
+	}
+	return resp, nil
+}
+
+func (a *apiClient) WebhookVerificationKey(keyID string) (plaid.JWKPublicKey, error) {
+	resp, _, err := a.api.PlaidApi.WebhookVerificationKeyGet(context.Background()).WebhookVerificationKeyGetRequest(
+		*plaid.NewWebhookVerificationKeyGetRequest(keyID),
+	).Execute()
+	if err != nil {
+		return plaid.JWKPublicKey{}, err
+	}
+	return resp.GetKey(), nil
+}
+
+func (a *apiClient) InvestmentsHoldings(item ItemHandle) (plaid.InvestmentsHoldingsGetResponse, error) {
+	resp, _, err := a.api.PlaidApi.InvestmentsHoldingsGet(context.Background()).InvestmentsHoldingsGetRequest(
+		*plaid.NewInvestmentsHoldingsGetRequest(item.AccessToken),
+	).Execute()
+	if err != nil {
+		return plaid.InvestmentsHoldingsGetResponse{}, err
+	}
+	return resp, nil
+}
+
+func (a *apiClient) InvestmentsTransactions(item ItemHandle, startDate string, endDate string, offset int32, count int32) (plaid.InvestmentsTransactionsGetResponse, error) {
+	request := plaid.NewInvestmentsTransactionsGetRequest(item.AccessToken, startDate, endDate)
+	options := plaid.NewInvestmentsTransactionsGetRequestOptions()
+	options.SetOffset(offset)
+	if count > 0 {
+		options.SetCount(count)
+	}
+	request.SetOptions(*options)
+	resp, _, err := a.api.PlaidApi.InvestmentsTransactionsGet(context.Background()).InvestmentsTransactionsGetRequest(*request).Execute()
+	if err != nil {
+		return plaid.InvestmentsTransactionsGetResponse{}, err
+	}
+	return resp, nil
+}
+
+func (a *apiClient) Liabilities(item ItemHandle) (plaid.LiabilitiesGetResponse, error) {
+	resp, _, err := a.api.PlaidApi.LiabilitiesGet(context.Background()).LiabilitiesGetRequest(
+		*plaid.NewLiabilitiesGetRequest(item.AccessToken),
+	).Execute()
+	if err != nil {
+		return plaid.LiabilitiesGetResponse{}, err
+	}
+	return resp, nil
+}
+
+func (a *apiClient) TransactionsRecurring(item ItemHandle) (plaid.TransactionsRecurringGetResponse, error) {
+	resp, _, err := a.api.PlaidApi.TransactionsRecurringGet(context.Background()).TransactionsRecurringGetRequest(
+		*plaid.NewTransactionsRecurringGetRequest(item.AccessToken),
+	).Execute()
+	if err != nil {
+		return plaid.TransactionsRecurringGetResponse{}, err
+	}
+	return resp, nil
+}
@@ -0,0 +1,936 @@
+/*
+------------------------------------------------------------------
+FILE NAME:     fakeClient.go
+PROJECT:       CashflowAnalysis
+Date Created:  Oct-19-2026
+--------------------------------------------------------------------
+DESCRIPTION:
+Deterministic offline PlaidClient used when PLAID_ENV=fake. Items,
+accounts, balances, transactions, recurring streams, investments and
+liabilities are seeded from fixture files in the format of go/data.json
+(username -> linked_institutions). Fixture items keep their access tokens
+so rows already in the database keep working.
+
+Link is simulated with public tokens of the form public-fake-<institution
+id or item id>. Each exchange creates a new item copied from the fixture.
+Transactions sync pages through a change log, so transactions added,
+modified or removed later show up after the cursor like they do on Plaid.
+Plaid error codes can be injected per operation with InjectError or
+PLAID_FAKE_ERRORS (e.g. transactions/sync=ITEM_LOGIN_REQUIRED), or per
+item with the fixtures error_code field. Webhooks are signed with a key
+generated per FakeClient, SignWebhook returns the Plaid-Verification header
+for a body.
+--------------------------------------------------------------------
+$HISTORY:
+
+Oct-19-2026   Created initial file.
+Oct-19-2026   Added WebhookVerificationKey() and SignWebhook()
+Oct-19-2026   Added InvestmentsHoldings() and InvestmentsTransactions() from the fixtures securities,
+-             holdings and investment_transactions. Items with holdings report the investments product
+Oct-19-2026   Added Liabilities() from the fixtures liabilities. Items with liabilities report the liabilities product
+Oct-19-2026   Added TransactionsRecurring() from the fixtures recurring_transactions
+------------------------------------------------------------------
+*/
+
+package PlaidComponents
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	plaid "github.com/plaid/plaid-go/v31/plaid"
+)
+
+// Operation names accepted by InjectError, named after the Plaid endpoints
+const (
+	FakeOpLinkTokenCreate     = "link/token/create"
+	FakeOpUserCreate          = "user/create"
+	FakeOpPublicTokenCreate   = "item/public_token/create"
+	FakeOpPublicTokenExchange = "item/public_token/exchange"
+	FakeOpAccountsGet         = "accounts/get"
+	FakeOpBalanceGet          = "accounts/balance/get"
+	FakeOpItemGet             = "item/get"
+	FakeOpInstitutionGet      = "institutions/get_by_id"
+	FakeOpItemRemove          = "item/remove"
+	FakeOpTransactionsSync    = "transactions/sync"
+	FakeOpWebhookKeyGet       = "webhook_verification_key/get"
+	FakeOpHoldingsGet         = "investments/holdings/get"
+	FakeOpInvestmentTxGet     = "investments/transactions/get"
+	FakeOpLiabilitiesGet      = "liabilities/get"
+	FakeOpRecurringGet        = "transactions/recurring/get"
+)
+
+const (
+	fakePublicTokenPrefix = "public-fake-"
+	fakeCursorPrefix      = "fake-cursor-"
+	fakeSyncPageSize      = 100
+	fakeWebhookKeyID      = "fake-webhook-key"
+)
+
+// Error types Plaid reports for the error codes most likely to be injected.
+// Other codes are reported as ITEM_ERROR
+var fakeErrorTypes = map[string]plaid.PlaidErrorType{
+	"INVALID_ACCESS_TOKEN":                         plaid.PLAIDERRORTYPE_INVALID_INPUT,
+	"INVALID_PUBLIC_TOKEN":                         plaid.PLAIDERRORTYPE_INVALID_INPUT,
+	"INSTITUTION_NOT_FOUND":                        plaid.PLAIDERRORTYPE_INVALID_INPUT,
+	"INVALID_FIELD":                                plaid.PLAIDERRORTYPE_INVALID_REQUEST,
+	"INSTITUTION_DOWN":                             plaid.PLAIDERRORTYPE_INSTITUTION_ERROR,
+	"INSTITUTION_NOT_RESPONDING":                   plaid.PLAIDERRORTYPE_INSTITUTION_ERROR,
+	"RATE_LIMIT":                                   plaid.PLAIDERRORTYPE_RATE_LIMIT_EXCEEDED,
+	"INTERNAL_SERVER_ERROR":                        plaid.PLAIDERRORTYPE_API_ERROR,
+	"TRANSACTIONS_SYNC_MUTATION_DURING_PAGINATION": plaid.PLAIDERRORTYPE_TRANSACTIONS_ERROR,
+}
+
+// Fixture file layout, the same as go/data.json
+type fakeFixtureUser struct {
+	LinkedInstitutions []fakeFixtureInstitution `json:"linked_institutions"`
+}
+
+type fakeFixtureInstitution struct {
+	AccessToken     string                   `json:"access_token"`
+	ItemID          string                   `json:"item_id"`
+	InstitutionName string                   `json:"institution_name"`
+	InstitutionID   string                   `json:"institution_id"`
+	ErrorCode       string                   `json:"error_code"`
+	Accounts        []fakeFixtureAccount     `json:"accounts"`
+	Transactions    []fakeFixtureTransaction `json:"transactions"`
+	//Items with holdings have the investments product
+	Securities             []fakeFixtureSecurity              `json:"securities"`
+	Holdings               []fakeFixtureHolding               `json:"holdings"`
+	InvestmentTransactions []fakeFixtureInvestmentTransaction `json:"investment_transactions"`
+	//In the format of Plaids /liabilities/get, items with liabilities have the liabilities product
+	Liabilities *plaid.LiabilitiesObject `json:"liabilities"`
+	//In the format of Plaids /transactions/recurring/get
+	RecurringTransactions *fakeFixtureRecurring `json:"recurring_transactions"`
+}
+
+type fakeFixtureRecurring struct {
+	InflowStreams  []plaid.TransactionStream `json:"inflow_streams"`
+	OutflowStreams []plaid.TransactionStream `json:"outflow_streams"`
+}
+
+type fakeFixtureAccount struct {
+	AccountID string `json:"account_id"`
+	Balance   struct {
+		Available              *float64 `json:"available"`
+		Current                *float64 `json:"current"`
+		Limit                  *float64 `json:"limit"`
+		IsoCurrencyCode        *string  `json:"iso_currency_code"`
+		UnofficialCurrencyCode *string  `json:"unofficial_currency_code"`
+	} `json:"account_balance"`
+	Mask         *string `json:"mask"`
+	Name         string  `json:"name"`
+	OfficialName *string `json:"official_name"`
+	Subtype      *string `json:"subtype"`
+	Type         string  `json:"type"`
+}
+
+type fakeFixtureTransaction struct {
+	TransactionID   string  `json:"transaction_id"`
+	AccountID       string  `json:"account_id"`
+	Amount          float64 `json:"amount"`
+	IsoCurrencyCode *string `json:"iso_currency_code"`
+	Date            string  `json:"date"`
+	Name            string  `json:"name"`
+	MerchantName    *string `json:"merchant_name"`
+	//Personal finance category, i.e. FOOD_AND_DRINK
+	Category string `json:"category"`
+	Pending  bool   `json:"pending"`
+}
+
+type fakeFixtureSecurity struct {
+	SecurityID       string   `json:"security_id"`
+	Name             *string  `json:"name"`
+	TickerSymbol     *string  `json:"ticker_symbol"`
+	Type             *string  `json:"type"`
+	ISIN             *string  `json:"isin"`
+	CUSIP            *string  `json:"cusip"`
+	ClosePrice       *float64 `json:"close_price"`
+	ClosePriceAsOf   *string  `json:"close_price_as_of"`
+	IsoCurrencyCode  *string  `json:"iso_currency_code"`
+	IsCashEquivalent *bool    `json:"is_cash_equivalent"`
+}
+
+type fakeFixtureHolding struct {
+	AccountID            string   `json:"account_id"`
+	SecurityID           string   `json:"security_id"`
+	Quantity             float64  `json:"quantity"`
+	CostBasis            *float64 `json:"cost_basis"`
+	InstitutionPrice     float64  `json:"institution_price"`
+	InstitutionPriceAsOf *string  `json:"institution_price_as_of"`
+	InstitutionValue     float64  `json:"institution_value"`
+	IsoCurrencyCode      *string  `json:"iso_currency_code"`
+}
+
+type fakeFixtureInvestmentTransaction struct {
+	InvestmentTransactionID string   `json:"investment_transaction_id"`
+	AccountID               string   `json:"account_id"`
+	SecurityID              *string  `json:"security_id"`
+	Date                    string   `json:"date"`
+	Name                    string   `json:"name"`
+	Quantity                float64  `json:"quantity"`
+	Amount                  float64  `json:"amount"`
+	Price                   float64  `json:"price"`
+	Fees                    *float64 `json:"fees"`
+	Type                    string   `json:"type"`
+	Subtype                 string   `json:"subtype"`
+	IsoCurrencyCode         *string  `json:"iso_currency_code"`
+}
+
+// One entry of an items transaction change log. Exactly one field is set
+type fakeChange struct {
+	added    *plaid.Transaction
+	modified *plaid.Transaction
+	removed  *plaid.RemovedTransaction
+}
+
+type fakeItem struct {
+	handle          ItemHandle
+	institutionID   string
+	institutionName string
+	errorCode       string
+	removed         bool
+	fixture         fakeFixtureInstitution
+	accounts        []plaid.AccountBase
+	changes         []fakeChange
+	securities      []plaid.Security
+	holdings        []plaid.Holding
+	investmentTxs   []plaid.InvestmentTransaction
+	liabilities     *plaid.LiabilitiesObject
+	inflowStreams   []plaid.TransactionStream
+	outflowStreams  []plaid.TransactionStream
+}
+
+// Offline PlaidClient seeded from fixture files
+type FakeClient struct {
+	mu sync.Mutex
+	//Items by access token
+	items map[string]*fakeItem
+	//Fixture items by item id and by institution id, used to resolve public tokens
+	templates map[string]*fakeItem
+	//Injected error codes by operation
+	errors       map[string]string
+	linkTokens   int
+	userTokens   int
+	exchanges    int
+	publicTokens map[string]*fakeItem
+	webhookKey   *ecdsa.PrivateKey
+}
+
+// Creates a FakeClient seeded from the given fixture files
+func NewFakeClient(fixturePaths ...string) (*FakeClient, error) {
+	f := &FakeClient{
+		items:        map[string]*fakeItem{},
+		templates:    map[string]*fakeItem{},
+		errors:       map[string]string{},
+		publicTokens: map[string]*fakeItem{},
+	}
+	var err error
+	if f.webhookKey, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader); err != nil {
+		return nil, err
+	}
+	for _, path := range fixturePaths {
+		if err := f.LoadFixture(path); err != nil {
+			return nil, err
+		}
+	}
+	return f, nil
+}
+
+// Adds the items of a fixture file. Users are read in name order so item
+// order does not depend on map iteration
+func (f *FakeClient) LoadFixture(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("could not read plaid fixture %s: %w", path, err)
+	}
+	var users map[string]fakeFixtureUser
+	if err := json.Unmarshal(data, &users); err != nil {
+		return fmt.Errorf("could not parse plaid fixture %s: %w", path, err)
+	}
+	names := make([]string, 0, len(users))
+	for name := range users {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, name := range names {
+		for _, ins := range users[name].LinkedInstitutions {
+			if ins.AccessToken == "" || ins.ItemID == "" {
+				return fmt.Errorf("plaid fixture %s: institution %q needs an access_token and item_id", path, ins.InstitutionName)
+			}
+			item := newFakeItem(ItemHandle{AccessToken: ins.AccessToken, ItemID: ins.ItemID}, ins, "")
+			f.items[ins.AccessToken] = item
+			f.templates[ins.ItemID] = item
+			if _, ok := f.templates[ins.InstitutionID]; !ok {
+				f.templates[ins.InstitutionID] = item
+			}
+		}
+	}
+	return nil
+}
+
+// Makes every later call of the operation fail with the Plaid error code.
+// An empty code clears the injected error
+func (f *FakeClient) InjectError(operation string, errorCode string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if errorCode == "" {
+		delete(f.errors, operation)
+		return
+	}
+	f.errors[operation] = errorCode
+}
+
+// Removes every injected error
+func (f *FakeClient) ClearErrors() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.errors = map[string]string{}
+}
+
+// Puts the item into an error state (i.e. ITEM_LOGIN_REQUIRED). The code is
+// reported by item/get and returned by the items data calls. "" clears it
+func (f *FakeClient) SetItemError(item ItemHandle, errorCode string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	fi, ok := f.items[item.AccessToken]
+	if !ok {
+		return fakeError("INVALID_ACCESS_TOKEN")
+	}
+	fi.errorCode = errorCode
+	return nil
+}
+
+// Adds transactions to the items change log
+func (f *FakeClient) AddTransactions(item ItemHandle, transactions ...plaid.Transaction) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	fi, ok := f.items[item.AccessToken]
+	if !ok {
+		return fakeError("INVALID_ACCESS_TOKEN")
+	}
+	for i := range transactions {
+		tx := transactions[i]
+		fi.changes = append(fi.changes, fakeChange{added: &tx})
+	}
+	return nil
+}
+
+// Records changed versions of transactions already on the item
+func (f *FakeClient) ModifyTransactions(item ItemHandle, transactions ...plaid.Transaction) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	fi, ok := f.items[item.AccessToken]
+	if !ok {
+		return fakeError("INVALID_ACCESS_TOKEN")
+	}
+	for i := range transactions {
+		tx := transactions[i]
+		fi.changes = append(fi.changes, fakeChange{modified: &tx})
+	}
+	return nil
+}
+
+// Records that transactions were removed from the item
+func (f *FakeClient) RemoveTransactions(item ItemHandle, accountID string, transactionIDs ...string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	fi, ok := f.items[item.AccessToken]
+	if !ok {
+		return fakeError("INVALID_ACCESS_TOKEN")
+	}
+	for _, id := range transactionIDs {
+		fi.changes = append(fi.changes, fakeChange{removed: plaid.NewRemovedTransaction(id, accountID)})
+	}
+	return nil
+}
+
+func (f *FakeClient) CreateLinkToken(request plaid.LinkTokenCreateRequest) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.injected(FakeOpLinkTokenCreate); err != nil {
+		return "", err
+	}
+	f.linkTokens++
+	return "link-fake-" + strconv.Itoa(f.linkTokens), nil
+}
+
+func (f *FakeClient) CreateUser(request plaid.UserCreateRequest) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.injected(FakeOpUserCreate); err != nil {
+		return "", err
+	}
+	f.userTokens++
+	return "user-fake-" + strconv.Itoa(f.userTokens), nil
+}
+
+// Returns a public token that exchanges for a new copy of the items fixture
+func (f *FakeClient) CreatePublicToken(item ItemHandle) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.injected(FakeOpPublicTokenCreate); err != nil {
+		return "", err
+	}
+	fi, err := f.item(item)
+	if err != nil {
+		return "", err
+	}
+	token := fakePublicTokenPrefix + fi.handle.ItemID
+	f.publicTokens[token] = fi
+	return token, nil
+}
+
+// Exchanges public-fake-<institution id or item id> for a new item
+func (f *FakeClient) ExchangePublicToken(publicToken string) (ItemHandle, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.injected(FakeOpPublicTokenExchange); err != nil {
+		return ItemHandle{}, err
+	}
+	template, ok := f.publicTokens[publicToken]
+	if !ok && strings.HasPrefix(publicToken, fakePublicTokenPrefix) {
+		template, ok = f.templates[strings.TrimPrefix(publicToken, fakePublicTokenPrefix)]
+	}
+	if !ok {
+		return ItemHandle{}, fakeError("INVALID_PUBLIC_TOKEN")
+	}
+
+	f.exchanges++
+	suffix := "-" + strconv.Itoa(f.exchanges)
+	handle := ItemHandle{
+		AccessToken: "access-fake-" + template.fixture.ItemID + suffix,
+		ItemID:      template.fixture.ItemID + suffix,
+	}
+	f.items[handle.AccessToken] = newFakeItem(handle, template.fixture, suffix)
+	return handle, nil
+}
+
+func (f *FakeClient) Accounts(item ItemHandle) ([]plaid.AccountBase, error) {
+	return f.accounts(FakeOpAccountsGet, item)
+}
+
+func (f *FakeClient) Balances(item ItemHandle) ([]plaid.AccountBase, error) {
+	return f.accounts(FakeOpBalanceGet, item)
+}
+
+func (f *FakeClient) Item(item ItemHandle) (plaid.ItemWithConsentFields, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.injected(FakeOpItemGet); err != nil {
+		return plaid.ItemWithConsentFields{}, err
+	}
+	fi, err := f.item(item)
+	if err != nil {
+		return plaid.ItemWithConsentFields{}, err
+	}
+
+	var itemError plaid.NullablePlaidError
+	if fi.errorCode != "" {
+		plaidErr := fakePlaidError(fi.errorCode)
+		itemError.Set(&plaidErr)
+	}
+	institutionID := fi.institutionID
+	result := plaid.NewItemWithConsentFields(
+		fi.handle.ItemID,
+		*plaid.NewNullableString(nil),
+		itemError,
+		[]plaid.Products{},
+		fi.products(),
+		*plaid.NewNullableTime(nil),
+		"background",
+	)
+	result.SetInstitutionId(institutionID)
+	return *result, nil
+}
+
+func (f *FakeClient) Institution(institutionID string, countryCodes []plaid.CountryCode) (plaid.Institution, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.injected(FakeOpInstitutionGet); err != nil {
+		return plaid.Institution{}, err
+	}
+	template, ok := f.templates[institutionID]
+	if !ok || template.institutionID != institutionID {
+		return plaid.Institution{}, fakeError("INSTITUTION_NOT_FOUND")
+	}
+	return *plaid.NewInstitution(
+		institutionID,
+		template.institutionName,
+		[]plaid.Products{plaid.PRODUCTS_TRANSACTIONS},
+		countryCodes,
+		[]string{},
+		false,
+	), nil
+}
+
+func (f *FakeClient) RemoveItem(item ItemHandle) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.injected(FakeOpItemRemove); err != nil {
+		return err
+	}
+	fi, ok := f.items[item.AccessToken]
+	if !ok {
+		return fakeError("INVALID_ACCESS_TOKEN")
+	}
+	if fi.removed {
+		return fakeError("ITEM_NOT_FOUND")
+	}
+	fi.removed = true
+	return nil
+}
+
+// Returns the change log entries after the cursor. The cursor is the number of
+// entries already returned, so an empty cursor starts from the beginning
+func (f *FakeClient) TransactionsSync(item ItemHandle, cursor string, count int32) (plaid.TransactionsSyncResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.injected(FakeOpTransactionsSync); err != nil {
+		return plaid.TransactionsSyncResponse{}, err
+	}
+	fi, err := f.dataItem(item)
+	if err != nil {
+		return plaid.TransactionsSyncResponse{}, err
+	}
+
+	start := 0
+	if cursor != "" {
+		start, err = strconv.Atoi(strings.TrimPrefix(cursor, fakeCursorPrefix))
+		if err != nil || !strings.HasPrefix(cursor, fakeCursorPrefix) || start < 0 || start > len(fi.changes) {
+			return plaid.TransactionsSyncResponse{}, fakeError("INVALID_FIELD")
+		}
+	}
+	if count <= 0 {
+		count = fakeSyncPageSize
+	}
+	end := start + int(count)
+	if end > len(fi.changes) {
+		end = len(fi.changes)
+	}
+
+	added := []plaid.Transaction{}
+	modified := []plaid.Transaction{}
+	removed := []plaid.RemovedTransaction{}
+	for _, change := range fi.changes[start:end] {
+		switch {
+		case change.added != nil:
+			added = append(added, *change.added)
+		case change.modified != nil:
+			modified = append(modified, *change.modified)
+		case change.removed != nil:
+			removed = append(removed, *change.removed)
+		}
+	}
+	return *plaid.NewTransactionsSyncResponse(
+		plaid.TRANSACTIONSUPDATESTATUS_HISTORICAL_UPDATE_COMPLETE,
+		append([]plaid.AccountBase{}, fi.accounts...),
+		added,
+		modified,
+		removed,
+		fakeCursorPrefix+strconv.Itoa(end),
+		end < len(fi.changes),
+		"fake-request",
+	), nil
+}
+
+// Returns the items holdings and securities. Items without holdings fail like
+// an item that was not linked with the investments product
+func (f *FakeClient) InvestmentsHoldings(item ItemHandle) (plaid.InvestmentsHoldingsGetResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.injected(FakeOpHoldingsGet); err != nil {
+		return plaid.InvestmentsHoldingsGetResponse{}, err
+	}
+	fi, err := f.investmentItem(item)
+	if err != nil {
+		return plaid.InvestmentsHoldingsGetResponse{}, err
+	}
+	return *plaid.NewInvestmentsHoldingsGetResponse(
+		append([]plaid.AccountBase{}, fi.accounts...),
+		append([]plaid.Holding{}, fi.holdings...),
+		append([]plaid.Security{}, fi.securities...),
+		*plaid.NewItem(fi.handle.ItemID, *plaid.NewNullableString(nil), *plaid.NewNullablePlaidError(nil), []plaid.Products{}, fi.products(), *plaid.NewNullableTime(nil), "background"),
+		"fake-request",
+	), nil
+}
+
+// Returns one page of the items investment transactions dated between startDate and endDate
+func (f *FakeClient) InvestmentsTransactions(item ItemHandle, startDate string, endDate string, offset int32, count int32) (plaid.InvestmentsTransactionsGetResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.injected(FakeOpInvestmentTxGet); err != nil {
+		return plaid.InvestmentsTransactionsGetResponse{}, err
+	}
+	fi, err := f.investmentItem(item)
+	if err != nil {
+		return plaid.InvestmentsTransactionsGetResponse{}, err
+	}
+	if offset < 0 || startDate > endDate {
+		return plaid.InvestmentsTransactionsGetResponse{}, fakeError("INVALID_FIELD")
+	}
+
+	//Dates are YYYY-MM-DD so they compare as strings
+	var matching []plaid.InvestmentTransaction
+	for _, tx := range fi.investmentTxs {
+		if tx.Date >= startDate && tx.Date <= endDate {
+			matching = append(matching, tx)
+		}
+	}
+	if count <= 0 {
+		count = 100
+	}
+	start := int(offset)
+	if start > len(matching) {
+		start = len(matching)
+	}
+	end := start + int(count)
+	if end > len(matching) {
+		end = len(matching)
+	}
+	return *plaid.NewInvestmentsTransactionsGetResponse(
+		*plaid.NewItem(fi.handle.ItemID, *plaid.NewNullableString(nil), *plaid.NewNullablePlaidError(nil), []plaid.Products{}, fi.products(), *plaid.NewNullableTime(nil), "background"),
+		append([]plaid.AccountBase{}, fi.accounts...),
+		append([]plaid.Security{}, fi.securities...),
+		append([]plaid.InvestmentTransaction{}, matching[start:end]...),
+		int32(len(matching)),
+		"fake-request",
+	), nil
+}
+
+// Returns the items liabilities. Items without liabilities fail like an item
+// that was not linked with the liabilities product
+func (f *FakeClient) Liabilities(item ItemHandle) (plaid.LiabilitiesGetResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.injected(FakeOpLiabilitiesGet); err != nil {
+		return plaid.LiabilitiesGetResponse{}, err
+	}
+	fi, err := f.dataItem(item)
+	if err != nil {
+		return plaid.LiabilitiesGetResponse{}, err
+	}
+	if fi.liabilities == nil {
+		return plaid.LiabilitiesGetResponse{}, fakeError("PRODUCTS_NOT_SUPPORTED")
+	}
+	return *plaid.NewLiabilitiesGetResponse(
+		append([]plaid.AccountBase{}, fi.accounts...),
+		*plaid.NewItem(fi.handle.ItemID, *plaid.NewNullableString(nil), *plaid.NewNullablePlaidError(nil), []plaid.Products{}, fi.products(), *plaid.NewNullableTime(nil), "background"),
+		*fi.liabilities,
+		"fake-request",
+	), nil
+}
+
+// Returns the items recurring streams. Items without them in the fixture have none
+func (f *FakeClient) TransactionsRecurring(item ItemHandle) (plaid.TransactionsRecurringGetResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.injected(FakeOpRecurringGet); err != nil {
+		return plaid.TransactionsRecurringGetResponse{}, err
+	}
+	fi, err := f.dataItem(item)
+	if err != nil {
+		return plaid.TransactionsRecurringGetResponse{}, err
+	}
+	return *plaid.NewTransactionsRecurringGetResponse(
+		append([]plaid.TransactionStream{}, fi.inflowStreams...),
+		append([]plaid.TransactionStream{}, fi.outflowStreams...),
+		time.Now().UTC(),
+		"fake-request",
+	), nil
+}
+
+// Returns the public half of the fakes webhook signing key
+func (f *FakeClient) WebhookVerificationKey(keyID string) (plaid.JWKPublicKey, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.injected(FakeOpWebhookKeyGet); err != nil {
+		return plaid.JWKPublicKey{}, err
+	}
+	if keyID != fakeWebhookKeyID {
+		return plaid.JWKPublicKey{}, fakeError("INVALID_FIELD")
+	}
+	pub := f.webhookKey.PublicKey
+	return *plaid.NewJWKPublicKey(
+		"ES256",
+		"P-256",
+		fakeWebhookKeyID,
+		"EC",
+		"sig",
+		base64.RawURLEncoding.EncodeToString(pub.X.FillBytes(make([]byte, 32))),
+		base64.RawURLEncoding.EncodeToString(pub.Y.FillBytes(make([]byte, 32))),
+		0,
+		*plaid.NewNullableInt32(nil),
+	), nil
+}
+
+// Returns the Plaid-Verification header Plaid would send with the webhook body
+func (f *FakeClient) SignWebhook(body []byte) (string, error) {
+	sum := sha256.Sum256(body)
+	token := jwt.NewWithClaims(jwt.SigningMethodES256, jwt.MapClaims{
+		"iat":                 time.Now().Unix(),
+		"request_body_sha256": hex.EncodeToString(sum[:]),
+	})
+	token.Header["kid"] = fakeWebhookKeyID
+	return token.SignedString(f.webhookKey)
+}
+
+func (f *FakeClient) accounts(operation string, item ItemHandle) ([]plaid.AccountBase, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.injected(operation); err != nil {
+		return nil, err
+	}
+	fi, err := f.dataItem(item)
+	if err != nil {
+		return nil, err
+	}
+	return append([]plaid.AccountBase{}, fi.accounts...), nil
+}
+
+// Returns the injected error for the operation, if any
+func (f *FakeClient) injected(operation string) error {
+	if code, ok := f.errors[operation]; ok {
+		return fakeError(code)
+	}
+	return nil
+}
+
+// Looks up a live item by its access token
+func (f *FakeClient) item(item ItemHandle) (*fakeItem, error) {
+	fi, ok := f.items[item.AccessToken]
+	if !ok {
+		return nil, fakeError("INVALID_ACCESS_TOKEN")
+	}
+	if fi.removed {
+		return nil, fakeError("ITEM_NOT_FOUND")
+	}
+	return fi, nil
+}
+
+// Like item() but also fails while the item is in an error state
+func (f *FakeClient) dataItem(item ItemHandle) (*fakeItem, error) {
+	fi, err := f.item(item)
+	if err != nil {
+		return nil, err
+	}
+	if fi.errorCode != "" {
+		return nil, fakeError(fi.errorCode)
+	}
+	return fi, nil
+}
+
+// Like dataItem() but also fails for items without the investments product
+func (f *FakeClient) investmentItem(item ItemHandle) (*fakeItem, error) {
+	fi, err := f.dataItem(item)
+	if err != nil {
+		return nil, err
+	}
+	if len(fi.holdings) == 0 {
+		return nil, fakeError("PRODUCTS_NOT_SUPPORTED")
+	}
+	return fi, nil
+}
+
+// The products the item was linked with
+func (fi *fakeItem) products() []plaid.Products {
+	products := []plaid.Products{plaid.PRODUCTS_TRANSACTIONS}
+	if len(fi.holdings) > 0 {
+		products = append(products, plaid.PRODUCTS_INVESTMENTS)
+	}
+	if fi.liabilities != nil {
+		products = append(products, plaid.PRODUCTS_LIABILITIES)
+	}
+	return products
+}
+
+// Builds an item from a fixture institution. suffix is added to account and
+// transaction ids so copies made by exchanges don't share ids
+func newFakeItem(handle ItemHandle, ins fakeFixtureInstitution, suffix string) *fakeItem {
+	item := &fakeItem{
+		handle:          handle,
+		institutionID:   ins.InstitutionID,
+		institutionName: ins.InstitutionName,
+		errorCode:       ins.ErrorCode,
+		fixture:         ins,
+	}
+	for _, acc := range ins.Accounts {
+		var subtype plaid.NullableAccountSubtype
+		if acc.Subtype != nil {
+			s := plaid.AccountSubtype(*acc.Subtype)
+			subtype.Set(&s)
+		}
+		item.accounts = append(item.accounts, *plaid.NewAccountBase(
+			acc.AccountID+suffix,
+			*plaid.NewAccountBalance(
+				*plaid.NewNullableFloat64(acc.Balance.Available),
+				*plaid.NewNullableFloat64(acc.Balance.Current),
+				*plaid.NewNullableFloat64(acc.Balance.Limit),
+				*plaid.NewNullableString(acc.Balance.IsoCurrencyCode),
+				*plaid.NewNullableString(acc.Balance.UnofficialCurrencyCode),
+			),
+			*plaid.NewNullableString(acc.Mask),
+			acc.Name,
+			*plaid.NewNullableString(acc.OfficialName),
+			plaid.AccountType(acc.Type),
+			subtype,
+		))
+	}
+	for _, tx := range ins.Transactions {
+		transaction := plaid.Transaction{
+			AccountId:       tx.AccountID + suffix,
+			Amount:          tx.Amount,
+			IsoCurrencyCode: *plaid.NewNullableString(tx.IsoCurrencyCode),
+			Category:        []string{},
+			Date:            tx.Date,
+			Name:            tx.Name,
+			MerchantName:    *plaid.NewNullableString(tx.MerchantName),
+			Pending:         tx.Pending,
+			TransactionId:   tx.TransactionID + suffix,
+			PaymentChannel:  "other",
+		}
+		if tx.Category != "" {
+			transaction.PersonalFinanceCategory.Set(plaid.NewPersonalFinanceCategory(tx.Category, tx.Category))
+		}
+		item.changes = append(item.changes, fakeChange{added: &transaction})
+	}
+	//Securities are shared between items like on Plaid, so their ids keep no suffix
+	for _, sec := range ins.Securities {
+		item.securities = append(item.securities, *plaid.NewSecurity(
+			sec.SecurityID,
+			*plaid.NewNullableString(sec.ISIN),
+			*plaid.NewNullableString(sec.CUSIP),
+			*plaid.NewNullableString(nil),
+			*plaid.NewNullableString(nil),
+			*plaid.NewNullableString(nil),
+			*plaid.NewNullableString(nil),
+			*plaid.NewNullableString(sec.Name),
+			*plaid.NewNullableString(sec.TickerSymbol),
+			*plaid.NewNullableBool(sec.IsCashEquivalent),
+			*plaid.NewNullableString(sec.Type),
+			*plaid.NewNullableFloat64(sec.ClosePrice),
+			*plaid.NewNullableString(sec.ClosePriceAsOf),
+			*plaid.NewNullableString(sec.IsoCurrencyCode),
+			*plaid.NewNullableString(nil),
+			*plaid.NewNullableString(nil),
+			*plaid.NewNullableString(nil),
+			*plaid.NewNullableString(nil),
+			*plaid.NewNullableOptionContract(nil),
+		))
+	}
+	for _, h := range ins.Holdings {
+		holding := plaid.NewHolding(
+			h.AccountID+suffix,
+			h.SecurityID,
+			h.InstitutionPrice,
+			h.InstitutionValue,
+			*plaid.NewNullableFloat64(h.CostBasis),
+			h.Quantity,
+			*plaid.NewNullableString(h.IsoCurrencyCode),
+			*plaid.NewNullableString(nil),
+		)
+		holding.InstitutionPriceAsOf = *plaid.NewNullableString(h.InstitutionPriceAsOf)
+		item.holdings = append(item.holdings, *holding)
+	}
+	for _, tx := range ins.InvestmentTransactions {
+		item.investmentTxs = append(item.investmentTxs, *plaid.NewInvestmentTransaction(
+			tx.InvestmentTransactionID+suffix,
+			tx.AccountID+suffix,
+			*plaid.NewNullableString(tx.SecurityID),
+			tx.Date,
+			tx.Name,
+			tx.Quantity,
+			tx.Amount,
+			tx.Price,
+			*plaid.NewNullableFloat64(tx.Fees),
+			plaid.InvestmentTransactionType(tx.Type),
+			plaid.InvestmentTransactionSubtype(tx.Subtype),
+			*plaid.NewNullableString(tx.IsoCurrencyCode),
+			*plaid.NewNullableString(nil),
+		))
+	}
+	if ins.Liabilities != nil {
+		item.liabilities = &plaid.LiabilitiesObject{}
+		for _, credit := range ins.Liabilities.Credit {
+			if id := credit.AccountId.Get(); id != nil {
+				credit.AccountId.Set(plaid.PtrString(*id + suffix))
+			}
+			item.liabilities.Credit = append(item.liabilities.Credit, credit)
+		}
+		for _, student := range ins.Liabilities.Student {
+			if id := student.AccountId.Get(); id != nil {
+				student.AccountId.Set(plaid.PtrString(*id + suffix))
+			}
+			item.liabilities.Student = append(item.liabilities.Student, student)
+		}
+		for _, mortgage := range ins.Liabilities.Mortgage {
+			mortgage.AccountId += suffix
+			item.liabilities.Mortgage = append(item.liabilities.Mortgage, mortgage)
+		}
+	}
+	if ins.RecurringTransactions != nil {
+		item.inflowStreams = fakeStreams(ins.RecurringTransactions.InflowStreams, suffix)
+		item.outflowStreams = fakeStreams(ins.RecurringTransactions.OutflowStreams, suffix)
+	}
+	return item
+}
+
+// Copies fixture streams with suffix added to their stream, account and transaction ids
+func fakeStreams(fixture []plaid.TransactionStream, suffix string) []plaid.TransactionStream {
+	var streams []plaid.TransactionStream
+	for _, stream := range fixture {
+		stream.StreamId += suffix
+		stream.AccountId += suffix
+		transactionIDs := make([]string, len(stream.TransactionIds))
+		for i, id := range stream.TransactionIds {
+			transactionIDs[i] = id + suffix
+		}
+		stream.TransactionIds = transactionIDs
+		streams = append(streams, stream)
+	}
+	return streams
+}
+
+// Builds the error plaid-go returns for a failed request, so plaid.ToPlaidError works on it
+func fakeError(errorCode string) error {
+	plaidErr := fakePlaidError(errorCode)
+	body, _ := json.Marshal(plaidErr)
+	return plaid.MakeGenericOpenAPIError(body, strconv.Itoa(http.StatusBadRequest)+" "+http.StatusText(http.StatusBadRequest), plaidErr)
+}
+
+func fakePlaidError(errorCode string) plaid.PlaidError {
+	errorType, ok := fakeErrorTypes[errorCode]
+	if !ok {
+		errorType = plaid.PLAIDERRORTYPE_ITEM_ERROR
+	}
+	return *plaid.NewPlaidError(errorType, errorCode, "fake plaid error "+errorCode, *plaid.NewNullableString(nil))
+}
+
+// Parses PLAID_FAKE_ERRORS, a comma separated list of operation=ERROR_CODE
+func parseFakeErrors(value string) map[string]string {
+	injected := map[string]string{}
+	for _, entry := range strings.Split(value, ",") {
+		operation, code, ok := strings.Cut(strings.TrimSpace(entry), "=")
+		if ok && operation != "" && code != "" {
+			injected[strings.TrimSpace(operation)] = strings.TrimSpace(code)
+		}
+	}
+	return injected
+}
@@ -0,0 +1,335 @@
+/*
+------------------------------------------------------------------
+FILE NAME:     fakeClient_test.go
+PROJECT:       CashflowAnalysis
+Date Created:  Oct-19-2026
+--------------------------------------------------------------------
+DESCRIPTION:
+FakeClient against a small fixture written per test. Covers linking an
+item (public token exchange then accounts), paging through the transaction
+change log with the sync cursor, changes made after the last page, injected
+error codes from InjectError, PLAID_FAKE_ERRORS and item errors.
+--------------------------------------------------------------------
+$HISTORY:
+
+Oct-19-2026   Created initial file.
+------------------------------------------------------------------
+*/
+package PlaidComponents
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	plaid "github.com/plaid/plaid-go/v31/plaid"
+)
+
+const testFixture = `{
+  "test": {
+    "linked_institutions": [
+      {
+        "access_token": "access-fixture",
+        "item_id": "item-fixture",
+        "institution_name": "Test Bank",
+        "institution_id": "ins_test",
+        "accounts": [
+          {"account_id": "acc-checking", "account_balance": {"available": 100, "current": 110}, "name": "Checking", "subtype": "checking", "type": "depository"},
+          {"account_id": "acc-savings", "account_balance": {"available": 200, "current": 210}, "name": "Savings", "subtype": "savings", "type": "depository"}
+        ],
+        "transactions": [
+          {"transaction_id": "tx-1", "account_id": "acc-checking", "amount": 12.5, "date": "2026-10-01", "name": "Coffee", "category": "FOOD_AND_DRINK"},
+          {"transaction_id": "tx-2", "account_id": "acc-checking", "amount": 40, "date": "2026-10-02", "name": "Groceries"},
+          {"transaction_id": "tx-3", "account_id": "acc-savings", "amount": -500, "date": "2026-10-03", "name": "Transfer"}
+        ]
+      }
+    ]
+  }
+}`
+
+// Writes the test fixture to a temporary file and returns its path
+func writeTestFixture(t *testing.T) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "fixture.json")
+	if err := os.WriteFile(path, []byte(testFixture), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// Creates a FakeClient and links a new copy of the fixture item
+func newLinkedFakeClient(t *testing.T) (*FakeClient, ItemHandle) {
+	t.Helper()
+	fake, err := NewFakeClient(writeTestFixture(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	item, err := fake.ExchangePublicToken(fakePublicTokenPrefix + "ins_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return fake, item
+}
+
+// Returns the Plaid error code of err, failing the test when it is not a Plaid error
+func plaidErrorCode(t *testing.T, err error) string {
+	t.Helper()
+	if err == nil {
+		t.Fatal("expected a Plaid error, got nil")
+	}
+	plaidErr, convErr := plaid.ToPlaidError(err)
+	if convErr != nil {
+		t.Fatalf("expected a Plaid error, got %v", err)
+	}
+	return plaidErr.ErrorCode
+}
+
+func transactionIDs(transactions []plaid.Transaction) []string {
+	ids := []string{}
+	for _, tx := range transactions {
+		ids = append(ids, tx.TransactionId)
+	}
+	return ids
+}
+
+func TestFakeExchangeCreatesNewItemWithAccounts(t *testing.T) {
+	fake, item := newLinkedFakeClient(t)
+	if item.AccessToken == "access-fixture" || item.ItemID == "item-fixture" {
+		t.Fatalf("exchange returned the fixture item %+v instead of a new one", item)
+	}
+
+	accounts, err := fake.Accounts(item)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ids []string
+	for _, acc := range accounts {
+		ids = append(ids, acc.AccountId)
+	}
+	if want := []string{"acc-checking-1", "acc-savings-1"}; !reflect.DeepEqual(ids, want) {
+		t.Fatalf("account ids = %v, want %v", ids, want)
+	}
+	if balance := accounts[0].Balances.GetCurrent(); balance != 110 {
+		t.Fatalf("current balance = %v, want 110", balance)
+	}
+
+	//A second exchange is another item with its own ids
+	second, err := fake.ExchangePublicToken(fakePublicTokenPrefix + "item-fixture")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if second == item {
+		t.Fatal("second exchange returned the same item")
+	}
+	if _, err := fake.ExchangePublicToken("public-sandbox-unknown"); plaidErrorCode(t, err) != "INVALID_PUBLIC_TOKEN" {
+		t.Fatalf("unknown public token: %v", err)
+	}
+}
+
+func TestFakeTransactionsSyncPagesThroughChangeLog(t *testing.T) {
+	fake, item := newLinkedFakeClient(t)
+
+	first, err := fake.TransactionsSync(item, "", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := transactionIDs(first.Added); !reflect.DeepEqual(got, []string{"tx-1-1", "tx-2-1"}) {
+		t.Fatalf("first page added %v", got)
+	}
+	if !first.HasMore {
+		t.Fatal("first page should have more")
+	}
+
+	second, err := fake.TransactionsSync(item, first.NextCursor, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := transactionIDs(second.Added); !reflect.DeepEqual(got, []string{"tx-3-1"}) {
+		t.Fatalf("second page added %v", got)
+	}
+	if second.HasMore {
+		t.Fatal("second page should be the last")
+	}
+
+	//Nothing new after the last cursor
+	empty, err := fake.TransactionsSync(item, second.NextCursor, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(empty.Added)+len(empty.Modified)+len(empty.Removed) != 0 || empty.HasMore || empty.NextCursor != second.NextCursor {
+		t.Fatalf("sync at the end returned changes: %+v", empty)
+	}
+
+	//Changes made later show up after the stored cursor in order
+	modified := first.Added[0]
+	modified.Amount = 13
+	if err := fake.AddTransactions(item, plaid.Transaction{TransactionId: "tx-4-1", AccountId: "acc-checking-1", Amount: 5}); err != nil {
+		t.Fatal(err)
+	}
+	if err := fake.ModifyTransactions(item, modified); err != nil {
+		t.Fatal(err)
+	}
+	if err := fake.RemoveTransactions(item, "acc-checking-1", "tx-2-1"); err != nil {
+		t.Fatal(err)
+	}
+	changes, err := fake.TransactionsSync(item, second.NextCursor, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := transactionIDs(changes.Added); !reflect.DeepEqual(got, []string{"tx-4-1"}) {
+		t.Fatalf("added after cursor %v", got)
+	}
+	if len(changes.Modified) != 1 || changes.Modified[0].TransactionId != "tx-1-1" || changes.Modified[0].Amount != 13 {
+		t.Fatalf("modified after cursor %+v", changes.Modified)
+	}
+	if len(changes.Removed) != 1 || changes.Removed[0].GetTransactionId() != "tx-2-1" {
+		t.Fatalf("removed after cursor %+v", changes.Removed)
+	}
+
+	//Replaying an older cursor returns the same page again
+	again, err := fake.TransactionsSync(item, first.NextCursor, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := transactionIDs(again.Added); !reflect.DeepEqual(got, []string{"tx-3-1", "tx-4-1"}) {
+		t.Fatalf("replayed page added %v", got)
+	}
+
+	for _, cursor := range []string{"not-a-cursor", fakeCursorPrefix + "-1", fakeCursorPrefix + "99"} {
+		if _, err := fake.TransactionsSync(item, cursor, 2); plaidErrorCode(t, err) != "INVALID_FIELD" {
+			t.Fatalf("cursor %q: %v", cursor, err)
+		}
+	}
+}
+
+func TestFakeInjectedErrors(t *testing.T) {
+	tests := []struct {
+		operation string
+		code      string
+		errorType plaid.PlaidErrorType
+		call      func(*FakeClient, ItemHandle) error
+	}{
+		{FakeOpTransactionsSync, "ITEM_LOGIN_REQUIRED", plaid.PLAIDERRORTYPE_ITEM_ERROR, func(f *FakeClient, item ItemHandle) error {
+			_, err := f.TransactionsSync(item, "", 0)
+			return err
+		}},
+		{FakeOpAccountsGet, "INSTITUTION_DOWN", plaid.PLAIDERRORTYPE_INSTITUTION_ERROR, func(f *FakeClient, item ItemHandle) error {
+			_, err := f.Accounts(item)
+			return err
+		}},
+		{FakeOpItemGet, "INTERNAL_SERVER_ERROR", plaid.PLAIDERRORTYPE_API_ERROR, func(f *FakeClient, item ItemHandle) error {
+			_, err := f.Item(item)
+			return err
+		}},
+		{FakeOpPublicTokenExchange, "INVALID_PUBLIC_TOKEN", plaid.PLAIDERRORTYPE_INVALID_INPUT, func(f *FakeClient, item ItemHandle) error {
+			_, err := f.ExchangePublicToken(fakePublicTokenPrefix + "ins_test")
+			return err
+		}},
+		{FakeOpTransactionsSync, "TRANSACTIONS_SYNC_MUTATION_DURING_PAGINATION", plaid.PLAIDERRORTYPE_TRANSACTIONS_ERROR, func(f *FakeClient, item ItemHandle) error {
+			_, err := f.TransactionsSync(item, "", 0)
+			return err
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.operation+"="+tt.code, func(t *testing.T) {
+			fake, item := newLinkedFakeClient(t)
+			fake.InjectError(tt.operation, tt.code)
+
+			err := tt.call(fake, item)
+			plaidErr, convErr := plaid.ToPlaidError(err)
+			if convErr != nil {
+				t.Fatalf("expected a Plaid error, got %v", err)
+			}
+			if plaidErr.ErrorCode != tt.code || plaidErr.ErrorType != tt.errorType {
+				t.Fatalf("got %s/%s, want %s/%s", plaidErr.ErrorType, plaidErr.ErrorCode, tt.errorType, tt.code)
+			}
+
+			//Only the injected operation fails
+			if _, err := fake.Balances(item); err != nil {
+				t.Fatalf("balances failed with an error injected for %s: %v", tt.operation, err)
+			}
+
+			fake.InjectError(tt.operation, "")
+			if err := tt.call(fake, item); err != nil {
+				t.Fatalf("error still returned after clearing it: %v", err)
+			}
+		})
+	}
+}
+
+func TestFakeItemError(t *testing.T) {
+	fake, item := newLinkedFakeClient(t)
+	if err := fake.SetItemError(item, "ITEM_LOGIN_REQUIRED"); err != nil {
+		t.Fatal(err)
+	}
+
+	//item/get still answers and reports the error, data calls fail with it
+	got, err := fake.Item(item)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if itemErr := got.GetError(); itemErr.ErrorCode != "ITEM_LOGIN_REQUIRED" {
+		t.Fatalf("item error = %q", itemErr.ErrorCode)
+	}
+	if _, err := fake.TransactionsSync(item, "", 0); plaidErrorCode(t, err) != "ITEM_LOGIN_REQUIRED" {
+		t.Fatalf("sync: %v", err)
+	}
+
+	if err := fake.SetItemError(item, ""); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fake.TransactionsSync(item, "", 0); err != nil {
+		t.Fatalf("sync after repair: %v", err)
+	}
+
+	if err := fake.RemoveItem(item); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fake.Accounts(item); plaidErrorCode(t, err) != "ITEM_NOT_FOUND" {
+		t.Fatalf("accounts of a removed item: %v", err)
+	}
+}
+
+func TestParseFakeErrors(t *testing.T) {
+	tests := []struct {
+		value string
+		want  map[string]string
+	}{
+		{"", map[string]string{}},
+		{"transactions/sync=ITEM_LOGIN_REQUIRED", map[string]string{"transactions/sync": "ITEM_LOGIN_REQUIRED"}},
+		{" transactions/sync = ITEM_LOGIN_REQUIRED , item/get=INTERNAL_SERVER_ERROR", map[string]string{
+			"transactions/sync": "ITEM_LOGIN_REQUIRED",
+			"item/get":          "INTERNAL_SERVER_ERROR",
+		}},
+		{"accounts/get,=RATE_LIMIT,item/get=,item/remove=ITEM_NOT_FOUND", map[string]string{"item/remove": "ITEM_NOT_FOUND"}},
+	}
+	for _, tt := range tests {
+		if got := parseFakeErrors(tt.value); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseFakeErrors(%q) = %v, want %v", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestFakeClientFromEnv(t *testing.T) {
+	t.Setenv("PLAID_FIXTURES", writeTestFixture(t)+", ")
+	t.Setenv("PLAID_FAKE_ERRORS", "transactions/sync=ITEM_LOGIN_REQUIRED")
+	fake, err := newFakeClientFromEnv()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	//Fixture items keep their access tokens
+	fixtureItem := ItemHandle{AccessToken: "access-fixture", ItemID: "item-fixture"}
+	if _, err := fake.Accounts(fixtureItem); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fake.TransactionsSync(fixtureItem, "", 0); plaidErrorCode(t, err) != "ITEM_LOGIN_REQUIRED" {
+		t.Fatalf("sync with PLAID_FAKE_ERRORS: %v", err)
+	}
+
+	t.Setenv("PLAID_FIXTURES", filepath.Join(t.TempDir(), "missing.json"))
+	if _, err := newFakeClientFromEnv(); err == nil {
+		t.Fatal("expected an error for a missing fixture file")
+	}
+}
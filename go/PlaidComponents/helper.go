@@ -11,6 +11,11 @@ $HISTORY:
 
 Jan-02-2026  Created initial file.
 Jan-04-2026  Added all plaid helper functions
+Oct-19-2026  linkTokenCreate() and userTokenCreate() take the users id and user token instead of
+-            reading package globals
+Oct-19-2026  linkTokenCreate() and userTokenCreate() go through the PlaidClient interface
+Oct-19-2026  linkTokenCreate() registers PLAID_WEBHOOK_URL for the new item
+Oct-19-2026  linkTokenCreate() can open Link in update mode for an existing item
 ------------------------------------------------------------------
 */
 
@@ -36,21 +41,27 @@ import (
 //	return originalErr.Error()
 //}
 
+// Opens Link for an existing item so the user can log in again or share more accounts
+type linkUpdateMode struct {
+	item             ItemHandle
+	accountSelection bool
+}
+
 // linkTokenCreate creates a link token using the specified parameters
 func linkTokenCreate(
+	clientUserID string,
+	userToken string,
+	update *linkUpdateMode,
 	paymentInitiation *plaid.LinkTokenCreateRequestPaymentInitiation,
 ) (string, error) {
-	ctx := context.Background()
-
 	// Institutions from all listed countries will be shown.
 	countryCodes := convertCountryCodes(strings.Split(PLAID_COUNTRY_CODES, ","))
 	redirectURI := PLAID_REDIRECT_URI
 
 	// This should correspond to a unique id for the current user.
-	// Typically, this will be a user ID number from your application.
 	// Personally identifiable information, such as an email address or phone number, should not be used here.
 	user := plaid.LinkTokenCreateRequestUser{
-		ClientUserId: time.Now().String(),
+		ClientUserId: clientUserID,
 	}
 
 	request := plaid.NewLinkTokenCreateRequest(
@@ -61,7 +72,14 @@ func linkTokenCreate(
 	)
 
 	products := convertProducts(strings.Split(PLAID_PRODUCTS, ","))
-	if paymentInitiation != nil {
+	if update != nil {
+		//Update mode keeps the products the item was linked with
+		request.SetAccessToken(update.item.AccessToken)
+		if update.accountSelection {
+			request.SetUpdate(plaid.LinkTokenCreateRequestUpdate{AccountSelectionEnabled: plaid.PtrBool(true)})
+		}
+		products = nil
+	} else if paymentInitiation != nil {
 		request.SetPaymentInitiation(*paymentInitiation)
 		// The 'payment_initiation' product has to be the only element in the 'products' list.
 		request.SetProducts([]plaid.Products{plaid.PRODUCTS_PAYMENT_INITIATION})
@@ -89,24 +107,18 @@ func linkTokenCreate(
 		request.SetRedirectUri(redirectURI)
 	}
 
-	linkTokenCreateResp, _, err := client.PlaidApi.LinkTokenCreate(ctx).LinkTokenCreateRequest(*request).Execute()
-
-	if err != nil {
-		return "", err
+	//Items only send webhooks to the url given when they were linked
+	if PLAID_WEBHOOK_URL != "" {
+		request.SetWebhook(PLAID_WEBHOOK_URL)
 	}
 
-	return linkTokenCreateResp.GetLinkToken(), nil
+	return plaidClient.CreateLinkToken(*request)
 }
 
 // Create a user token which can be used for Plaid Check, Income, or Multi-Item link flows
 // https://plaid.com/docs/api/users/#usercreate
-func userTokenCreate() (string, error) {
-	ctx := context.Background()
-
-	request := plaid.NewUserCreateRequest(
-		// Typically this will be a user ID number from your application.
-		time.Now().String(),
-	)
+func userTokenCreate(clientUserID string) (string, error) {
+	request := plaid.NewUserCreateRequest(clientUserID)
 
 	products := convertProducts(strings.Split(PLAID_PRODUCTS, ","))
 	if containsProduct(products, plaid.PRODUCTS_CRA_BASE_REPORT) ||
@@ -134,15 +146,7 @@ func userTokenCreate() (string, error) {
 		))
 	}
 
-	userCreateResp, _, err := client.PlaidApi.UserCreate(ctx).UserCreateRequest(*request).Execute()
-
-	if err != nil {
-		return "", err
-	}
-
-	userToken = userCreateResp.GetUserToken()
-
-	return userCreateResp.GetUserToken(), nil
+	return plaidClient.CreateUser(*request)
 }
 
 func convertCountryCodes(countryCodeStrs []string) []plaid.CountryCode {
@@ -183,8 +187,8 @@ func pollForAssetReport(ctx context.Context, client *plaid.APIClient, assetRepor
 	}, 1000, 20)
 }
 
-// Since this quickstart does not support webhooks, this function can be used to poll
-// an API that would otherwise be triggered by a webhook.
+// Only transactions and item webhooks are handled (see webhook.go), this function
+// can be used to poll an API that would otherwise be triggered by a webhook.
 // For a webhook example, see
 // https://github.com/plaid/tutorial-resources or
 // https://github.com/plaid/pattern
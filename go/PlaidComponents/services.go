@@ -11,6 +11,21 @@ $HISTORY:
 
 Jan-02-2026  Created initial file.
 Jan-04-2026  Added all plaid components
+Oct-19-2026  Added ItemHealth()
+Oct-19-2026  Added RemoveItem()
+Oct-19-2026  Removed the package-global access token, item id, user token and payment id.
+-            Every item call now takes an ItemHandle loaded for the requesting user
+Oct-19-2026  Calls go through the PlaidClient interface. PLAID_ENV=fake uses the offline FakeClient
+-            seeded from PLAID_FIXTURES and the Plaid keys are only required for the real api
+Oct-19-2026  Replaced Transactions() with TransactionsSync(), which returns a single page for a stored cursor
+Oct-19-2026  Added PLAID_WEBHOOK_URL
+Oct-19-2026  Added CreateUpdateLinkToken()
+Oct-19-2026  Replaced holdings() and investmentTransactions() with InvestmentHoldings() and
+-            InvestmentTransactions(), which go through the PlaidClient. Added ItemProducts()
+Oct-19-2026  Added Liabilities()
+Oct-19-2026  Added RecurringTransactions()
+Oct-19-2026  A missing Plaid configuration is reported by ClientSetupError() instead of exiting
+Oct-19-2026  InvestmentTransactions() returns each security once when it is referenced on several pages
 ------------------------------------------------------------------
 */
 
@@ -20,31 +35,22 @@ import (
 	"bufio"
 	"context"
 	"encoding/base64"
+	"errors"
 	"fmt"
 	"io"
-	"log"
 	"os"
-	"sort"
 	"strings"
-	"time"
 
 	"github.com/joho/godotenv"
 	plaid "github.com/plaid/plaid-go/v31/plaid"
 )
 
-// We store the access_token and user_token in memory - in production, store it in a secure
-// persistent data store.
-var accessToken string
-var userToken string
-var itemID string
-
-var paymentID string
-
-// The authorizationID is only relevant for the Transfer ACH product.
-// We store the authorizationID in memory - in production, store it in a secure
-// persistent data store
-var authorizationID string
-var accountID string
+// A linked Plaid item. Load it from DB_LinkedInstitutions for the user making
+// the request and pass it to every call that works on the item
+type ItemHandle struct {
+	AccessToken string
+	ItemID      string
+}
 
 var (
 	PLAID_CLIENT_ID                      = ""
@@ -53,6 +59,7 @@ var (
 	PLAID_PRODUCTS                       = ""
 	PLAID_COUNTRY_CODES                  = ""
 	PLAID_REDIRECT_URI                   = ""
+	PLAID_WEBHOOK_URL                    = ""
 	APP_PORT                             = ""
 	client              *plaid.APIClient = nil
 )
@@ -62,6 +69,27 @@ var environments = map[string]plaid.Environment{
 	"production": plaid.Production,
 }
 
+// Transaction updates requested per /transactions/sync page (Plaid allows up to 500)
+const TransactionsSyncPageSize = 500
+
+// Investment transactions requested per /investments/transactions/get page (Plaid allows up to 500)
+const InvestmentTransactionsPageSize = 500
+
+// PLAID_ENV value that selects the offline FakeClient
+const FakeEnvironment = "fake"
+
+// Fixture files used by the FakeClient when PLAID_FIXTURES is not set. The server runs from go/Server
+const defaultFakeFixtures = "../data.json"
+
+// Why init() could not set up the Plaid client, nil when it could
+var clientSetupErr error
+
+// Returns why the Plaid client could not be set up from the environment, nil
+// when it was. Calls made without a client panic
+func ClientSetupError() error {
+	return clientSetupErr
+}
+
 func init() {
 	// load env vars from .env file
 	err := godotenv.Load()
@@ -72,15 +100,11 @@ func init() {
 	// set constants from env
 	PLAID_CLIENT_ID = os.Getenv("PLAID_CLIENT_ID")
 	PLAID_SECRET = os.Getenv("PLAID_SECRET")
-
-	if PLAID_CLIENT_ID == "" || PLAID_SECRET == "" {
-		log.Fatal("Error: PLAID_SECRET or PLAID_CLIENT_ID is not set. Did you copy .env.example to .env and fill it out?")
-	}
-
 	PLAID_ENV = os.Getenv("PLAID_ENV")
 	PLAID_PRODUCTS = os.Getenv("PLAID_PRODUCTS")
 	PLAID_COUNTRY_CODES = os.Getenv("PLAID_COUNTRY_CODES")
 	PLAID_REDIRECT_URI = os.Getenv("PLAID_REDIRECT_URI")
+	PLAID_WEBHOOK_URL = os.Getenv("PLAID_WEBHOOK_URL")
 
 	// set defaults
 	if PLAID_PRODUCTS == "" {
@@ -93,11 +117,21 @@ func init() {
 		PLAID_ENV = "sandbox"
 	}
 
-	if PLAID_CLIENT_ID == "" {
-		log.Fatal("PLAID_CLIENT_ID is not set. Make sure to fill out the .env file")
+	if PLAID_ENV == FakeEnvironment {
+		fake, err := newFakeClientFromEnv()
+		if err != nil {
+			clientSetupErr = fmt.Errorf("could not load the fake Plaid fixtures: %w", err)
+			return
+		}
+		plaidClient = fake
+		return
 	}
-	if PLAID_SECRET == "" {
-		log.Fatal("PLAID_SECRET is not set. Make sure to fill out the .env file")
+
+	//Not fatal so packages importing this one load without Plaid credentials,
+	//the server checks ClientSetupError() before serving
+	if PLAID_CLIENT_ID == "" || PLAID_SECRET == "" {
+		clientSetupErr = errors.New("PLAID_SECRET or PLAID_CLIENT_ID is not set. Did you copy .env.example to .env and fill it out? Set PLAID_ENV=fake to run without Plaid")
+		return
 	}
 
 	// create Plaid client
@@ -106,188 +140,212 @@ func init() {
 	configuration.AddDefaultHeader("PLAID-SECRET", PLAID_SECRET)
 	configuration.UseEnvironment(environments[PLAID_ENV])
 	client = plaid.NewAPIClient(configuration)
+	plaidClient = &apiClient{api: client}
 }
 
-func Info() (string, string, []string) {
-	return accessToken, itemID, strings.Split(PLAID_PRODUCTS, ",")
+// Creates the FakeClient from PLAID_FIXTURES (comma separated paths) and
+// PLAID_FAKE_ERRORS (comma separated operation=ERROR_CODE)
+func newFakeClientFromEnv() (*FakeClient, error) {
+	fixtures := os.Getenv("PLAID_FIXTURES")
+	if fixtures == "" {
+		fixtures = defaultFakeFixtures
+	}
+	var paths []string
+	for _, path := range strings.Split(fixtures, ",") {
+		if path = strings.TrimSpace(path); path != "" {
+			paths = append(paths, path)
+		}
+	}
+	fake, err := NewFakeClient(paths...)
+	if err != nil {
+		return nil, err
+	}
+	for operation, code := range parseFakeErrors(os.Getenv("PLAID_FAKE_ERRORS")) {
+		fake.InjectError(operation, code)
+	}
+	return fake, nil
 }
 
-func CreateLinkToken() (string, error) {
-	linkToken, err := linkTokenCreate(nil)
+// Returns the Plaid products the server is configured for
+func Info() []string {
+	return strings.Split(PLAID_PRODUCTS, ",")
+}
+
+// Creates a link token for the user. userToken is only needed for the CRA products
+func CreateLinkToken(clientUserID string, userToken string) (string, error) {
+	linkToken, err := linkTokenCreate(clientUserID, userToken, nil, nil)
 	if err != nil {
 		return "", err
 	}
 	return linkToken, nil
 }
 
-func CreatePublicToken() (string, error) {
-	ctx := context.Background()
+// Creates a link token that opens Link in update mode for the item, i.e. after
+// ITEM_LOGIN_REQUIRED. accountSelection lets the user share accounts added at the bank
+func CreateUpdateLinkToken(clientUserID string, item ItemHandle, accountSelection bool) (string, error) {
+	return linkTokenCreate(clientUserID, "", &linkUpdateMode{item: item, accountSelection: accountSelection}, nil)
+}
 
-	// Create a one-time use public_token for the Item.
-	// This public_token can be used to initialize Link in update mode for a user
-	publicTokenCreateResp, _, err := client.PlaidApi.ItemCreatePublicToken(ctx).ItemPublicTokenCreateRequest(
-		*plaid.NewItemPublicTokenCreateRequest(accessToken),
-	).Execute()
+// Creates a one-time use public_token for the Item.
+// This public_token can be used to initialize Link in update mode for a user
+func CreatePublicToken(item ItemHandle) (string, error) {
+	return plaidClient.CreatePublicToken(item)
+}
 
+func CreateUserToken(clientUserID string) (string, error) {
+	userToken, err := userTokenCreate(clientUserID)
 	if err != nil {
 		return "", err
 	}
+	return userToken, nil
+}
 
-	return publicTokenCreateResp.GetPublicToken(), nil
+// Exchanges the public token from Link for the new items access token and item id
+func GetAccessToken(publicToken string) (ItemHandle, error) {
+	return plaidClient.ExchangePublicToken(publicToken)
 }
 
-func CreateUserToken() (string, error) {
-	userToken, err := userTokenCreate()
-	if err != nil {
-		return "", err
-	}
-	return userToken, nil
+func Accounts(item ItemHandle) ([]plaid.AccountBase, error) {
+	return plaidClient.Accounts(item)
 }
 
-func GetAccessToken(publicToken string) (string, error) {
-	ctx := context.Background()
+func Balance(item ItemHandle) ([]plaid.AccountBase, error) {
+	return plaidClient.Balances(item)
+}
 
-	// exchange the public_token for an access_token
-	exchangePublicTokenResp, _, err := client.PlaidApi.ItemPublicTokenExchange(ctx).ItemPublicTokenExchangeRequest(
-		*plaid.NewItemPublicTokenExchangeRequest(publicToken),
-	).Execute()
+func Item(item ItemHandle) (plaid.ItemWithConsentFields, plaid.Institution, error) {
+	plaidItem, err := plaidClient.Item(item)
 	if err != nil {
-		return "", err
+		return plaid.ItemWithConsentFields{}, plaid.Institution{}, err
 	}
 
-	accessToken = exchangePublicTokenResp.GetAccessToken()
-	itemID = exchangePublicTokenResp.GetItemId()
-
-	fmt.Println("public token: " + publicToken)
-	fmt.Println("access token: " + accessToken)
-	fmt.Println("item ID: " + itemID)
+	institution, err := plaidClient.Institution(
+		plaidItem.GetInstitutionId(),
+		convertCountryCodes(strings.Split(PLAID_COUNTRY_CODES, ",")),
+	)
+	if err != nil {
+		return plaid.ItemWithConsentFields{}, plaid.Institution{}, err
+	}
 
-	//c.JSON(http.StatusOK, gin.H{
-	//	"access_token": accessToken,
-	//	"item_id":      itemID,
-	//})
-	return accessToken, nil
+	return plaidItem, institution, nil
 }
 
-func Accounts() ([]plaid.AccountBase, error) {
-	ctx := context.Background()
-
-	accountsGetResp, _, err := client.PlaidApi.AccountsGet(ctx).AccountsGetRequest(
-		*plaid.NewAccountsGetRequest(accessToken),
-	).Execute()
+// Returns the products the item was linked with or has been billed for, comma separated
+func ItemProducts(plaidItem plaid.ItemWithConsentFields) string {
+	seen := map[plaid.Products]bool{}
+	var products []string
+	for _, product := range append(plaidItem.GetProducts(), plaidItem.GetBilledProducts()...) {
+		if !seen[product] {
+			seen[product] = true
+			products = append(products, string(product))
+		}
+	}
+	return strings.Join(products, ",")
+}
 
+// Loads the items products from /item/get, see ItemProducts()
+func LoadItemProducts(item ItemHandle) (string, error) {
+	plaidItem, err := plaidClient.Item(item)
 	if err != nil {
-		return nil, err
+		return "", err
 	}
-	return accountsGetResp.GetAccounts(), nil
+	return ItemProducts(plaidItem), nil
 }
 
-func Balance() ([]plaid.AccountBase, error) {
-	ctx := context.Background()
-
-	balancesGetResp, _, err := client.PlaidApi.AccountsBalanceGet(ctx).AccountsBalanceGetRequest(
-		*plaid.NewAccountsBalanceGetRequest(accessToken),
-	).Execute()
-
+// Returns the error code Plaid currently reports for the item ("" when healthy)
+// Used by support staff to diagnose broken connections
+func ItemHealth(item ItemHandle) (string, error) {
+	plaidItem, err := plaidClient.Item(item)
 	if err != nil {
-		return nil, err
+		if plaidErr, convErr := plaid.ToPlaidError(err); convErr == nil {
+			return plaidErr.ErrorCode, nil
+		}
+		return "", err
 	}
 
-	//c.JSON(http.StatusOK, gin.H{
-	//	"accounts": balancesGetResp.GetAccounts(),
-	//})
-	return balancesGetResp.GetAccounts(), nil
+	if plaidItem.Error.IsSet() && plaidItem.Error.Get() != nil {
+		return plaidItem.Error.Get().ErrorCode, nil
+	}
+	return "", nil
 }
 
-func Item() (plaid.ItemWithConsentFields, plaid.Institution, error) {
-	ctx := context.Background()
-
-	itemGetResp, _, err := client.PlaidApi.ItemGet(ctx).ItemGetRequest(
-		*plaid.NewItemGetRequest(accessToken),
-	).Execute()
-
+// Calls /item/remove so Plaid invalidates the access token and stops billing for the item
+// An item Plaid no longer knows about counts as removed
+func RemoveItem(item ItemHandle) error {
+	err := plaidClient.RemoveItem(item)
 	if err != nil {
-		return plaid.ItemWithConsentFields{}, plaid.Institution{}, err
+		if plaidErr, convErr := plaid.ToPlaidError(err); convErr == nil {
+			switch plaidErr.ErrorCode {
+			case "ITEM_NOT_FOUND", "INVALID_ACCESS_TOKEN":
+				return nil
+			}
+		}
+		return err
 	}
+	return nil
+}
 
-	institutionGetByIdResp, _, err := client.PlaidApi.InstitutionsGetById(ctx).InstitutionsGetByIdRequest(
-		*plaid.NewInstitutionsGetByIdRequest(
-			*itemGetResp.GetItem().InstitutionId.Get(),
-			convertCountryCodes(strings.Split(PLAID_COUNTRY_CODES, ",")),
-		),
-	).Execute()
+// Returns one page of transaction updates since cursor ("" for all history).
+// Keep calling with NextCursor while HasMore is true. An empty NextCursor means
+// Plaid has not finished pulling the items transactions yet
+func TransactionsSync(item ItemHandle, cursor string) (plaid.TransactionsSyncResponse, error) {
+	return plaidClient.TransactionsSync(item, cursor, TransactionsSyncPageSize)
+}
 
-	if err != nil {
-		return plaid.ItemWithConsentFields{}, plaid.Institution{}, err
-	}
+// Returns the recurring inflow and outflow streams Plaid detected in the items transactions
+func RecurringTransactions(item ItemHandle) (plaid.TransactionsRecurringGetResponse, error) {
+	return plaidClient.TransactionsRecurring(item)
+}
 
-	return itemGetResp.GetItem(), institutionGetByIdResp.GetInstitution(), nil
+// Returns true when the error means the items transactions changed while paging
+// and the sync has to start over from the cursor it started with
+func IsSyncMutationError(err error) bool {
+	plaidErr, convErr := plaid.ToPlaidError(err)
+	return convErr == nil && plaidErr.ErrorCode == "TRANSACTIONS_SYNC_MUTATION_DURING_PAGINATION"
 }
 
-func Transactions() (string, []plaid.Transaction, error) {
-	ctx := context.Background()
+// Returns the items investment accounts, their holdings and the securities held
+func InvestmentHoldings(item ItemHandle) (plaid.InvestmentsHoldingsGetResponse, error) {
+	return plaidClient.InvestmentsHoldings(item)
+}
 
-	// Set cursor to empty to receive all historical updates
-	var cursor *string
-
-	// New transaction updates since "cursor"
-	var added []plaid.Transaction
-	var modified []plaid.Transaction
-	var removed []plaid.RemovedTransaction // Removed transaction ids
-	hasMore := true
-	// Iterate through each page of new transaction updates for item
-	for hasMore {
-		request := plaid.NewTransactionsSyncRequest(accessToken)
-		if cursor != nil {
-			request.SetCursor(*cursor)
-		}
-		resp, _, err := client.PlaidApi.TransactionsSync(
-			ctx,
-		).TransactionsSyncRequest(*request).Execute()
+// Returns every investment transaction of the item between the dates (YYYY-MM-DD)
+// and the securities they reference, paging through Plaids results. Every page
+// lists the securities of its own transactions, so a security is kept once
+func InvestmentTransactions(item ItemHandle, startDate string, endDate string) ([]plaid.InvestmentTransaction, []plaid.Security, error) {
+	var transactions []plaid.InvestmentTransaction
+	var securities []plaid.Security
+	seen := map[string]bool{}
+	for {
+		resp, err := plaidClient.InvestmentsTransactions(item, startDate, endDate, int32(len(transactions)), InvestmentTransactionsPageSize)
 		if err != nil {
-			return "", nil, err
+			return nil, nil, err
 		}
-
-		// Update cursor to the next cursor
-		nextCursor := resp.GetNextCursor()
-		cursor = &nextCursor
-
-		// If no transactions are available yet, wait and poll the endpoint.
-		// Normally, we would listen for a webhook, but the Quickstart doesn't
-		// support webhooks. For a webhook example, see
-		// https://github.com/plaid/tutorial-resources or
-		// https://github.com/plaid/pattern
-
-		if *cursor == "" {
-			time.Sleep(2 * time.Second)
-			continue
+		transactions = append(transactions, resp.GetInvestmentTransactions()...)
+		for _, security := range resp.GetSecurities() {
+			if !seen[security.SecurityId] {
+				seen[security.SecurityId] = true
+				securities = append(securities, security)
+			}
+		}
+		if len(resp.GetInvestmentTransactions()) == 0 || len(transactions) >= int(resp.GetTotalInvestmentTransactions()) {
+			return transactions, securities, nil
 		}
-
-		// Add this page of results
-		added = append(added, resp.GetAdded()...)
-		modified = append(modified, resp.GetModified()...)
-		removed = append(removed, resp.GetRemoved()...)
-		hasMore = resp.GetHasMore()
 	}
+}
 
-	sort.Slice(added, func(i, j int) bool {
-		return added[i].GetDate() < added[j].GetDate()
-	})
-	_ = added[len(added)-9:]
-	//latestTransactions := added[len(added)-9:]
-
-	//c.JSON(http.StatusOK, gin.H{
-	//	"latest_transactions": latestTransactions,
-	//})
-	return *cursor, added, nil
+// Returns the items accounts with the credit card, student loan and mortgage details of its liability accounts
+func Liabilities(item ItemHandle) (plaid.LiabilitiesGetResponse, error) {
+	return plaidClient.Liabilities(item)
 }
 
 /*--------------PLAID FUNCTIONS NOT USED YET--------------------------*/
-func auth() error {
+//These call the plaid-go client directly, so they are not available with PLAID_ENV=fake
+func auth(item ItemHandle) error {
 	ctx := context.Background()
 
 	authGetResp, _, err := client.PlaidApi.AuthGet(ctx).AuthGetRequest(
-		*plaid.NewAuthGetRequest(accessToken),
+		*plaid.NewAuthGetRequest(item.AccessToken),
 	).Execute()
 
 	if err != nil {
@@ -302,11 +360,11 @@ func auth() error {
 	return nil
 }
 
-func identity() error {
+func identity(item ItemHandle) error {
 	ctx := context.Background()
 
 	identityGetResp, _, err := client.PlaidApi.IdentityGet(ctx).IdentityGetRequest(
-		*plaid.NewIdentityGetRequest(accessToken),
+		*plaid.NewIdentityGetRequest(item.AccessToken),
 	).Execute()
 	if err != nil {
 		return err
@@ -319,49 +377,11 @@ func identity() error {
 	return nil
 }
 
-// Currently dont return anything. Review commented c.JSON for what to expect return to be//
-func investmentTransactions() error {
-	ctx := context.Background()
-
-	endDate := time.Now().Local().Format("2006-01-02")
-	startDate := time.Now().Local().Add(-30 * 24 * time.Hour).Format("2006-01-02")
-
-	request := plaid.NewInvestmentsTransactionsGetRequest(accessToken, startDate, endDate)
-	invTxResp, _, err := client.PlaidApi.InvestmentsTransactionsGet(ctx).InvestmentsTransactionsGetRequest(*request).Execute()
-
-	if err != nil {
-		return err
-	}
-
-	//c.JSON(http.StatusOK, gin.H{
-	//	"investments_transactions": invTxResp,
-	//})
-	fmt.Println(invTxResp)
-	return nil
-}
-
-func holdings() error {
-	ctx := context.Background()
-
-	holdingsGetResp, _, err := client.PlaidApi.InvestmentsHoldingsGet(ctx).InvestmentsHoldingsGetRequest(
-		*plaid.NewInvestmentsHoldingsGetRequest(accessToken),
-	).Execute()
-	if err != nil {
-		return err
-	}
-
-	//c.JSON(http.StatusOK, gin.H{
-	//	"holdings": holdingsGetResp,
-	//})
-	fmt.Println(holdingsGetResp)
-	return nil
-}
-
-func assets() error {
+func assets(item ItemHandle) error {
 	ctx := context.Background()
 
 	createRequest := plaid.NewAssetReportCreateRequest(10)
-	createRequest.SetAccessTokens([]string{accessToken})
+	createRequest.SetAccessTokens([]string{item.AccessToken})
 
 	// create the asset report
 	assetReportCreateResp, _, err := client.PlaidApi.AssetReportCreate(ctx).AssetReportCreateRequest(
@@ -407,24 +427,24 @@ func assets() error {
 // This functionality is only relevant for the ACH Transfer product.
 // Create Transfer for a specified Authorization ID
 
-func transferAuthorize() error {
+func transferAuthorize(item ItemHandle) (string, string, error) {
 	ctx := context.Background()
 	accountsGetResp, _, err := client.PlaidApi.AccountsGet(ctx).AccountsGetRequest(
-		*plaid.NewAccountsGetRequest(accessToken),
+		*plaid.NewAccountsGetRequest(item.AccessToken),
 	).Execute()
 
 	if err != nil {
-		return err
+		return "", "", err
 	}
 
-	accountID = accountsGetResp.GetAccounts()[0].AccountId
+	accountID := accountsGetResp.GetAccounts()[0].AccountId
 	transferType, err := plaid.NewTransferTypeFromValue("debit")
 	transferNetwork, err := plaid.NewTransferNetworkFromValue("ach")
 	ACHClass, err := plaid.NewACHClassFromValue("ppd")
 
 	transferAuthorizationCreateUser := plaid.NewTransferAuthorizationUserInRequest("FirstName LastName")
 	transferAuthorizationCreateRequest := plaid.NewTransferAuthorizationCreateRequest(
-		accessToken,
+		item.AccessToken,
 		accountID,
 		*transferType,
 		*transferNetwork,
@@ -435,21 +455,19 @@ func transferAuthorize() error {
 	transferAuthorizationCreateResp, _, err := client.PlaidApi.TransferAuthorizationCreate(ctx).TransferAuthorizationCreateRequest(*transferAuthorizationCreateRequest).Execute()
 
 	if err != nil {
-		return err
+		return "", "", err
 	}
 
-	authorizationID = transferAuthorizationCreateResp.GetAuthorization().Id
-
 	//c.JSON(http.StatusOK, transferAuthorizationCreateResp)
 	fmt.Println(transferAuthorizationCreateResp)
-	return nil
+	return accountID, transferAuthorizationCreateResp.GetAuthorization().Id, nil
 }
 
-func transferCreate() error {
+func transferCreate(item ItemHandle, accountID string, authorizationID string) error {
 	ctx := context.Background()
 
 	transferCreateRequest := plaid.NewTransferCreateRequest(
-		accessToken,
+		item.AccessToken,
 		accountID,
 		authorizationID,
 		"Debit",
@@ -466,20 +484,20 @@ func transferCreate() error {
 	return nil
 }
 
-func signalEvaluate() error {
+func signalEvaluate(item ItemHandle) error {
 	ctx := context.Background()
 	accountsGetResp, _, err := client.PlaidApi.AccountsGet(ctx).AccountsGetRequest(
-		*plaid.NewAccountsGetRequest(accessToken),
+		*plaid.NewAccountsGetRequest(item.AccessToken),
 	).Execute()
 
 	if err != nil {
 		return err
 	}
 
-	accountID = accountsGetResp.GetAccounts()[0].AccountId
+	accountID := accountsGetResp.GetAccounts()[0].AccountId
 
 	signalEvaluateRequest := plaid.NewSignalEvaluateRequest(
-		accessToken,
+		item.AccessToken,
 		accountID,
 		"txn1234",
 		100.00)
@@ -495,15 +513,15 @@ func signalEvaluate() error {
 	return nil
 }
 
-func statements() error {
+func statements(item ItemHandle) error {
 	ctx := context.Background()
 	statementsListResp, _, err := client.PlaidApi.StatementsList(ctx).StatementsListRequest(
-		*plaid.NewStatementsListRequest(accessToken),
+		*plaid.NewStatementsListRequest(item.AccessToken),
 	).Execute()
 	statementId := statementsListResp.GetAccounts()[0].GetStatements()[0].StatementId
 
 	statementsDownloadResp, _, err := client.PlaidApi.StatementsDownload(ctx).StatementsDownloadRequest(
-		*plaid.NewStatementsDownloadRequest(accessToken, statementId),
+		*plaid.NewStatementsDownloadRequest(item.AccessToken, statementId),
 	).Execute()
 	if err != nil {
 		return err
@@ -529,7 +547,7 @@ func statements() error {
 
 // Retrieve CRA Partner Insights
 // https://plaid.com/docs/check/api/#cracheck_reportpartner_insightsget
-func getCraPartnerInsightsHandler() error {
+func getCraPartnerInsightsHandler(userToken string) error {
 	ctx := context.Background()
 	getResponse, err := getCraPartnerInsightsWithRetries(ctx, userToken)
 	if err != nil {
@@ -546,7 +564,7 @@ func getCraPartnerInsightsHandler() error {
 // Retrieve CRA Income Insights and PDF with Insights
 // Income insights: https://plaid.com/docs/check/api/#cracheck_reportincome_insightsget
 // PDF w/ income insights: https://plaid.com/docs/check/api/#cracheck_reportpdfget
-func getCraIncomeInsightsHandler() error {
+func getCraIncomeInsightsHandler(userToken string) error {
 	ctx := context.Background()
 	getResponse, err := getCraIncomeInsightsWithRetries(ctx, userToken)
 	if err != nil {
@@ -582,7 +600,7 @@ func getCraIncomeInsightsHandler() error {
 // Retrieve CRA Base Report and PDF
 // Base report: https://plaid.com/docs/check/api/#cracheck_reportbase_reportget
 // PDF: https://plaid.com/docs/check/api/#cracheck_reportpdfget
-func getCraBaseReportHandler() error {
+func getCraBaseReportHandler(userToken string) error {
 	ctx := context.Background()
 	getResponse, err := getCraBaseReportWithRetries(ctx, userToken)
 	if err != nil {
@@ -622,7 +640,7 @@ func getCraBaseReportHandler() error {
 // See:
 // - https://plaid.com/docs/payment-initiation/
 // - https://plaid.com/docs/#payment-initiation-create-link-token-request
-func createLinkTokenForPayment() error {
+func createLinkTokenForPayment(clientUserID string) error {
 	ctx := context.Background()
 
 	// Create payment recipient
@@ -650,15 +668,15 @@ func createLinkTokenForPayment() error {
 		return err
 	}
 
-	// We store the payment_id in memory for demo purposes - in production, store it in a secure
-	// persistent data store along with the Payment metadata, such as userId.
-	paymentID = paymentCreateResp.GetPaymentId()
+	// Store the payment_id along with the Payment metadata, such as userId, and pass
+	// it to payment() to look the payment up later
+	paymentID := paymentCreateResp.GetPaymentId()
 	fmt.Println("payment id: " + paymentID)
 
 	// Create the link_token
 	linkTokenCreateReqPaymentInitiation := plaid.NewLinkTokenCreateRequestPaymentInitiation()
 	linkTokenCreateReqPaymentInitiation.SetPaymentId(paymentID)
-	linkToken, err := linkTokenCreate(linkTokenCreateReqPaymentInitiation)
+	linkToken, err := linkTokenCreate(clientUserID, "", nil, linkTokenCreateReqPaymentInitiation)
 	if err != nil {
 		return err
 	}
@@ -671,7 +689,7 @@ func createLinkTokenForPayment() error {
 
 // This functionality is only relevant for the UK Payment Initiation product.
 // Retrieve Payment for a specified Payment ID
-func payment() error {
+func payment(paymentID string) error {
 	ctx := context.Background()
 
 	paymentGetResp, _, err := client.PlaidApi.PaymentInitiationPaymentGet(ctx).PaymentInitiationPaymentGetRequest(
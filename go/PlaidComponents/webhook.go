@@ -0,0 +1,281 @@
+/*
+------------------------------------------------------------------
+FILE NAME:     webhook.go
+PROJECT:       CashflowAnalysis
+Date Created:  Oct-19-2026
+--------------------------------------------------------------------
+DESCRIPTION:
+Plaid webhook verification and parsing. Every webhook carries a
+Plaid-Verification header, an ES256 JWT whose request_body_sha256 claim is
+the sha256 of the body. The signing key is fetched from
+/webhook_verification_key/get by the JWTs key id and cached. Webhooks older
+than five minutes are rejected so a captured request can't be replayed.
+Key ids Plaid has no key for are remembered for a minute and key fetches
+are rate limited, so forged webhooks can't make the app flood Plaid.
+Link tokens register PLAID_WEBHOOK_URL so Plaid knows where to send them.
+--------------------------------------------------------------------
+$HISTORY:
+
+Oct-19-2026   Created initial file.
+Oct-19-2026   Added LOGIN_REPAIRED
+Oct-19-2026   Added the HOLDINGS and INVESTMENTS_TRANSACTIONS webhook types
+Oct-19-2026   Added the LIABILITIES webhook type
+Oct-19-2026   Added RECURRING_TRANSACTIONS_UPDATE
+Oct-19-2026   Verification keys are fetched outside the cache lock, once per key id at a time.
+-             Failed lookups are cached briefly and fetches from Plaid are rate limited
+------------------------------------------------------------------
+*/
+
+package PlaidComponents
+
+import (
+	helper "cashflowanalysis/Services/Helpers"
+	"crypto/ecdsa"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	plaid "github.com/plaid/plaid-go/v31/plaid"
+)
+
+// Webhook types and codes the app handles
+const (
+	WebhookTypeTransactions = "TRANSACTIONS"
+	WebhookTypeItem         = "ITEM"
+	//These only send DEFAULT_UPDATE
+	WebhookTypeHoldings                = "HOLDINGS"
+	WebhookTypeInvestmentsTransactions = "INVESTMENTS_TRANSACTIONS"
+	WebhookTypeLiabilities             = "LIABILITIES"
+
+	WebhookSyncUpdatesAvailable  = "SYNC_UPDATES_AVAILABLE"
+	WebhookDefaultUpdate         = "DEFAULT_UPDATE"
+	WebhookItemError             = "ERROR"
+	WebhookPendingExpiration     = "PENDING_EXPIRATION"
+	WebhookUserPermissionRevoked = "USER_PERMISSION_REVOKED"
+	WebhookNewAccountsAvailable  = "NEW_ACCOUNTS_AVAILABLE"
+	WebhookLoginRepaired         = "LOGIN_REPAIRED"
+	//Sent with the TRANSACTIONS type when Plaid updated the items recurring streams
+	WebhookRecurringTransactionsUpdate = "RECURRING_TRANSACTIONS_UPDATE"
+)
+
+const (
+	//Plaid signs webhooks with ES256 only
+	webhookSigningMethod = "ES256"
+	//Webhooks signed longer ago than this are rejected
+	webhookMaxAge = 5 * time.Minute
+	//Cached keys are fetched again after this, so keys Plaid expired stop being accepted
+	webhookKeyTTL = 24 * time.Hour
+	//Failed key lookups are not retried for this long
+	webhookKeyMissTTL = time.Minute
+	//At most this many keys are fetched from Plaid per window
+	webhookKeyFetchLimit  = 10
+	webhookKeyFetchWindow = time.Minute
+)
+
+var (
+	ErrInvalidWebhook        = errors.New("invalid plaid webhook")
+	errWebhookKeyRateLimited = errors.New("too many webhook verification key fetches")
+)
+
+// The fields of a Plaid webhook body the app uses
+type Webhook struct {
+	WebhookType string        `json:"webhook_type"`
+	WebhookCode string        `json:"webhook_code"`
+	ItemID      string        `json:"item_id"`
+	Error       *WebhookError `json:"error"`
+	//Set on PENDING_EXPIRATION, RFC 3339
+	ConsentExpirationTime *string `json:"consent_expiration_time"`
+	Environment           string  `json:"environment"`
+}
+
+type WebhookError struct {
+	ErrorType    string `json:"error_type"`
+	ErrorCode    string `json:"error_code"`
+	ErrorMessage string `json:"error_message"`
+}
+
+type webhookClaims struct {
+	jwt.RegisteredClaims
+	RequestBodySHA256 string `json:"request_body_sha256"`
+}
+
+type webhookKey struct {
+	key     *ecdsa.PublicKey
+	expired bool
+	//Set when the key could not be fetched, the miss is cached for webhookKeyMissTTL
+	err       error
+	fetchedAt time.Time
+}
+
+// A fetch in progress, other requests for the same key id wait on done
+type webhookKeyFetch struct {
+	done  chan struct{}
+	entry webhookKey
+}
+
+// Verification keys by key id
+var webhookKeyCache struct {
+	sync.Mutex
+	keys     map[string]webhookKey
+	inFlight map[string]*webhookKeyFetch
+	//Fetches started since windowStart, for rate limiting
+	windowStart time.Time
+	fetches     int
+}
+
+// Checks the Plaid-Verification JWT of a webhook against the raw request body
+func VerifyWebhook(body []byte, verification string) error {
+	if verification == "" {
+		return fmt.Errorf("%w: missing Plaid-Verification header", ErrInvalidWebhook)
+	}
+	parser := jwt.NewParser(
+		jwt.WithValidMethods([]string{webhookSigningMethod}),
+		jwt.WithIssuedAt(),
+	)
+	claims := &webhookClaims{}
+	_, err := parser.ParseWithClaims(verification, claims, func(t *jwt.Token) (interface{}, error) {
+		kid, _ := t.Header["kid"].(string)
+		return webhookVerificationKey(kid)
+	})
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidWebhook, err)
+	}
+	if claims.IssuedAt == nil || time.Since(claims.IssuedAt.Time) > webhookMaxAge {
+		return fmt.Errorf("%w: webhook is too old", ErrInvalidWebhook)
+	}
+	sum := sha256.Sum256(body)
+	if subtle.ConstantTimeCompare([]byte(hex.EncodeToString(sum[:])), []byte(claims.RequestBodySHA256)) != 1 {
+		return fmt.Errorf("%w: body does not match signature", ErrInvalidWebhook)
+	}
+	return nil
+}
+
+// Parses a verified webhook body
+func ParseWebhook(body []byte) (Webhook, error) {
+	var webhook Webhook
+	if err := json.Unmarshal(body, &webhook); err != nil {
+		return Webhook{}, fmt.Errorf("%w: %v", ErrInvalidWebhook, err)
+	}
+	if webhook.WebhookType == "" || webhook.WebhookCode == "" {
+		return Webhook{}, fmt.Errorf("%w: missing webhook_type or webhook_code", ErrInvalidWebhook)
+	}
+	return webhook, nil
+}
+
+// Returns the cached verification key, fetching it from Plaid when it is unknown
+// or was cached too long ago
+func webhookVerificationKey(kid string) (*ecdsa.PublicKey, error) {
+	if kid == "" {
+		return nil, errors.New("webhook jwt has no key id")
+	}
+	cached, err := cachedWebhookKey(kid)
+	if err != nil {
+		return nil, err
+	}
+	if cached.err != nil {
+		return nil, cached.err
+	}
+	if cached.expired {
+		return nil, fmt.Errorf("webhook key %s has expired", kid)
+	}
+	return cached.key, nil
+}
+
+// Returns the cache entry for the key id. Only one request fetches a missing or stale
+// key, the others wait for its result. The lock is not held while calling Plaid
+func cachedWebhookKey(kid string) (webhookKey, error) {
+	webhookKeyCache.Lock()
+	cached, ok := webhookKeyCache.keys[kid]
+	if ok && cached.fresh() {
+		webhookKeyCache.Unlock()
+		return cached, nil
+	}
+	if fetch, ok := webhookKeyCache.inFlight[kid]; ok {
+		webhookKeyCache.Unlock()
+		<-fetch.done
+		return fetch.entry, nil
+	}
+	if !allowWebhookKeyFetch(time.Now()) {
+		webhookKeyCache.Unlock()
+		//A stale key is better than none while fetches are limited
+		if ok && cached.err == nil {
+			return cached, nil
+		}
+		return webhookKey{}, errWebhookKeyRateLimited
+	}
+	fetch := &webhookKeyFetch{done: make(chan struct{})}
+	if webhookKeyCache.inFlight == nil {
+		webhookKeyCache.inFlight = map[string]*webhookKeyFetch{}
+	}
+	webhookKeyCache.inFlight[kid] = fetch
+	webhookKeyCache.Unlock()
+
+	fetch.entry = fetchWebhookKey(kid)
+
+	webhookKeyCache.Lock()
+	if webhookKeyCache.keys == nil {
+		webhookKeyCache.keys = map[string]webhookKey{}
+	}
+	webhookKeyCache.keys[kid] = fetch.entry
+	delete(webhookKeyCache.inFlight, kid)
+	webhookKeyCache.Unlock()
+	close(fetch.done)
+	return fetch.entry, nil
+}
+
+// Counts a fetch against the rate limit, false when the limit is reached.
+// Must be called with webhookKeyCache locked
+func allowWebhookKeyFetch(now time.Time) bool {
+	if now.Sub(webhookKeyCache.windowStart) > webhookKeyFetchWindow {
+		webhookKeyCache.windowStart = now
+		webhookKeyCache.fetches = 0
+	}
+	if webhookKeyCache.fetches >= webhookKeyFetchLimit {
+		return false
+	}
+	webhookKeyCache.fetches++
+	return true
+}
+
+// Fetches the key from Plaid. A failure is returned in the entry so it gets cached
+func fetchWebhookKey(kid string) webhookKey {
+	now := time.Now()
+	jwk, err := plaidClient.WebhookVerificationKey(kid)
+	if err != nil {
+		return webhookKey{err: err, fetchedAt: now}
+	}
+	key, err := ecdsaKey(jwk)
+	if err != nil {
+		return webhookKey{err: err, fetchedAt: now}
+	}
+	return webhookKey{key: key, expired: jwk.ExpiredAt.Get() != nil, fetchedAt: now}
+}
+
+// Reports whether the entry can be used without fetching the key again
+func (k webhookKey) fresh() bool {
+	if k.err != nil {
+		return time.Since(k.fetchedAt) <= webhookKeyMissTTL
+	}
+	return time.Since(k.fetchedAt) <= webhookKeyTTL
+}
+
+func ecdsaKey(jwk plaid.JWKPublicKey) (*ecdsa.PublicKey, error) {
+	if jwk.Alg != webhookSigningMethod {
+		return nil, fmt.Errorf("webhook key %s: unexpected algorithm %q", jwk.Kid, jwk.Alg)
+	}
+	key, err := helper.JWK{Kty: jwk.Kty, Kid: jwk.Kid, Alg: jwk.Alg, Use: jwk.Use, Crv: jwk.Crv, X: jwk.X, Y: jwk.Y}.PublicKey()
+	if err != nil {
+		return nil, err
+	}
+	ecKey, ok := key.(*ecdsa.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("webhook key %s is not an EC key", jwk.Kid)
+	}
+	return ecKey, nil
+}
@@ -0,0 +1,283 @@
+/*
+------------------------------------------------------------------
+FILE NAME:     webhook_test.go
+PROJECT:       CashflowAnalysis
+Date Created:  Oct-19-2026
+--------------------------------------------------------------------
+DESCRIPTION:
+VerifyWebhook against webhooks signed by the FakeClient. Covers a valid
+signature, the wrong signing algorithm, a stale iat, a body that doesn't
+match its hash, an unknown key id and the rate limit on key fetches.
+--------------------------------------------------------------------
+$HISTORY:
+
+Oct-19-2026   Created initial file.
+------------------------------------------------------------------
+*/
+package PlaidComponents
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	plaid "github.com/plaid/plaid-go/v31/plaid"
+)
+
+const testWebhookBody = `{"webhook_type":"TRANSACTIONS","webhook_code":"SYNC_UPDATES_AVAILABLE","item_id":"item-fixture"}`
+
+// PlaidClient counting the verification keys fetched from it
+type keyCountingClient struct {
+	PlaidClient
+	keyFetches int
+}
+
+func (c *keyCountingClient) WebhookVerificationKey(keyID string) (plaid.JWKPublicKey, error) {
+	c.keyFetches++
+	return c.PlaidClient.WebhookVerificationKey(keyID)
+}
+
+// Uses a FakeClient for the test with an empty verification key cache
+func useWebhookFake(t *testing.T) (*FakeClient, *keyCountingClient) {
+	t.Helper()
+	fake, err := NewFakeClient(writeTestFixture(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := &keyCountingClient{PlaidClient: fake}
+	previous := plaidClient
+	SetClient(client)
+	resetWebhookKeyCache()
+	t.Cleanup(func() {
+		SetClient(previous)
+		resetWebhookKeyCache()
+	})
+	return fake, client
+}
+
+func resetWebhookKeyCache() {
+	webhookKeyCache.Lock()
+	defer webhookKeyCache.Unlock()
+	webhookKeyCache.keys = nil
+	webhookKeyCache.inFlight = nil
+	webhookKeyCache.windowStart = time.Time{}
+	webhookKeyCache.fetches = 0
+}
+
+// Signs claims for the body with the fakes webhook key, like SignWebhook() but with
+// the issue time and key id chosen by the test
+func signTestWebhook(t *testing.T, fake *FakeClient, body string, issuedAt time.Time, kid string) string {
+	t.Helper()
+	sum := sha256.Sum256([]byte(body))
+	token := jwt.NewWithClaims(jwt.SigningMethodES256, jwt.MapClaims{
+		"iat":                 issuedAt.Unix(),
+		"request_body_sha256": hex.EncodeToString(sum[:]),
+	})
+	token.Header["kid"] = kid
+	signed, err := token.SignedString(fake.webhookKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed
+}
+
+func TestVerifyWebhook(t *testing.T) {
+	tests := []struct {
+		name string
+		//Returns the body and Plaid-Verification header to verify
+		request func(t *testing.T, fake *FakeClient) (string, string)
+		wantErr string
+	}{
+		{
+			name: "signed by the fake",
+			request: func(t *testing.T, fake *FakeClient) (string, string) {
+				header, err := fake.SignWebhook([]byte(testWebhookBody))
+				if err != nil {
+					t.Fatal(err)
+				}
+				return testWebhookBody, header
+			},
+		},
+		{
+			name: "missing header",
+			request: func(t *testing.T, fake *FakeClient) (string, string) {
+				return testWebhookBody, ""
+			},
+			wantErr: "missing Plaid-Verification header",
+		},
+		{
+			name: "HS256 instead of ES256",
+			request: func(t *testing.T, fake *FakeClient) (string, string) {
+				sum := sha256.Sum256([]byte(testWebhookBody))
+				token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+					"iat":                 time.Now().Unix(),
+					"request_body_sha256": hex.EncodeToString(sum[:]),
+				})
+				token.Header["kid"] = fakeWebhookKeyID
+				header, err := token.SignedString([]byte("not-a-plaid-key"))
+				if err != nil {
+					t.Fatal(err)
+				}
+				return testWebhookBody, header
+			},
+			wantErr: "signing method HS256 is invalid",
+		},
+		{
+			name: "unsigned",
+			request: func(t *testing.T, fake *FakeClient) (string, string) {
+				token := jwt.NewWithClaims(jwt.SigningMethodNone, jwt.MapClaims{"iat": time.Now().Unix()})
+				token.Header["kid"] = fakeWebhookKeyID
+				header, err := token.SignedString(jwt.UnsafeAllowNoneSignatureType)
+				if err != nil {
+					t.Fatal(err)
+				}
+				return testWebhookBody, header
+			},
+			wantErr: "signing method none is invalid",
+		},
+		{
+			name: "issued before the maximum age",
+			request: func(t *testing.T, fake *FakeClient) (string, string) {
+				issuedAt := time.Now().Add(-webhookMaxAge - time.Minute)
+				return testWebhookBody, signTestWebhook(t, fake, testWebhookBody, issuedAt, fakeWebhookKeyID)
+			},
+			wantErr: "webhook is too old",
+		},
+		{
+			name: "issued in the future",
+			request: func(t *testing.T, fake *FakeClient) (string, string) {
+				issuedAt := time.Now().Add(time.Hour)
+				return testWebhookBody, signTestWebhook(t, fake, testWebhookBody, issuedAt, fakeWebhookKeyID)
+			},
+			wantErr: "token used before issued",
+		},
+		{
+			name: "body changed after signing",
+			request: func(t *testing.T, fake *FakeClient) (string, string) {
+				header, err := fake.SignWebhook([]byte(testWebhookBody))
+				if err != nil {
+					t.Fatal(err)
+				}
+				return strings.Replace(testWebhookBody, "item-fixture", "item-other", 1), header
+			},
+			wantErr: "body does not match signature",
+		},
+		{
+			name: "unknown key id",
+			request: func(t *testing.T, fake *FakeClient) (string, string) {
+				return testWebhookBody, signTestWebhook(t, fake, testWebhookBody, time.Now(), "unknown-key")
+			},
+			wantErr: "error while executing keyfunc",
+		},
+		{
+			name: "no key id",
+			request: func(t *testing.T, fake *FakeClient) (string, string) {
+				return testWebhookBody, signTestWebhook(t, fake, testWebhookBody, time.Now(), "")
+			},
+			wantErr: "webhook jwt has no key id",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake, _ := useWebhookFake(t)
+			body, header := tt.request(t, fake)
+			err := VerifyWebhook([]byte(body), header)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("VerifyWebhook: %v", err)
+				}
+				return
+			}
+			if !errors.Is(err, ErrInvalidWebhook) {
+				t.Fatalf("got %v, want ErrInvalidWebhook", err)
+			}
+			if !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("got %q, want it to mention %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestVerifyWebhookCachesKeys(t *testing.T) {
+	fake, client := useWebhookFake(t)
+
+	for i := 0; i < 3; i++ {
+		header, err := fake.SignWebhook([]byte(testWebhookBody))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := VerifyWebhook([]byte(testWebhookBody), header); err != nil {
+			t.Fatal(err)
+		}
+	}
+	//An unknown key id is looked up once, then the miss is cached
+	forged := signTestWebhook(t, fake, testWebhookBody, time.Now(), "unknown-key")
+	for i := 0; i < 3; i++ {
+		if err := VerifyWebhook([]byte(testWebhookBody), forged); !errors.Is(err, ErrInvalidWebhook) {
+			t.Fatalf("got %v, want ErrInvalidWebhook", err)
+		}
+	}
+	if client.keyFetches != 2 {
+		t.Fatalf("fetched %d keys from Plaid, want 2", client.keyFetches)
+	}
+}
+
+func TestVerifyWebhookRateLimitsKeyFetches(t *testing.T) {
+	fake, client := useWebhookFake(t)
+
+	//Forged webhooks with a new key id each time
+	for i := 0; i < webhookKeyFetchLimit+5; i++ {
+		forged := signTestWebhook(t, fake, testWebhookBody, time.Now(), fmt.Sprintf("forged-%d", i))
+		err := VerifyWebhook([]byte(testWebhookBody), forged)
+		if !errors.Is(err, ErrInvalidWebhook) {
+			t.Fatalf("forged webhook %d: got %v, want ErrInvalidWebhook", i, err)
+		}
+		if limited := strings.Contains(err.Error(), errWebhookKeyRateLimited.Error()); limited != (i >= webhookKeyFetchLimit) {
+			t.Fatalf("forged webhook %d: %v", i, err)
+		}
+	}
+	if client.keyFetches != webhookKeyFetchLimit {
+		t.Fatalf("fetched %d keys from Plaid, want %d", client.keyFetches, webhookKeyFetchLimit)
+	}
+
+	//The real key isn't cached yet, so it waits for the next window too
+	header, err := fake.SignWebhook([]byte(testWebhookBody))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := VerifyWebhook([]byte(testWebhookBody), header); err == nil || !strings.Contains(err.Error(), errWebhookKeyRateLimited.Error()) {
+		t.Fatalf("got %v, want the key fetch rate limited", err)
+	}
+	webhookKeyCache.Lock()
+	webhookKeyCache.windowStart = time.Now().Add(-webhookKeyFetchWindow - time.Second)
+	webhookKeyCache.Unlock()
+	if err := VerifyWebhook([]byte(testWebhookBody), header); err != nil {
+		t.Fatalf("VerifyWebhook after the window: %v", err)
+	}
+}
+
+func TestVerifyWebhookKeyFetchError(t *testing.T) {
+	fake, client := useWebhookFake(t)
+	fake.InjectError(FakeOpWebhookKeyGet, "INTERNAL_SERVER_ERROR")
+
+	header, err := fake.SignWebhook([]byte(testWebhookBody))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := VerifyWebhook([]byte(testWebhookBody), header); !errors.Is(err, ErrInvalidWebhook) {
+		t.Fatalf("got %v, want ErrInvalidWebhook", err)
+	}
+	//The failure is cached for webhookKeyMissTTL, not retried on every webhook
+	fake.InjectError(FakeOpWebhookKeyGet, "")
+	if err := VerifyWebhook([]byte(testWebhookBody), header); err == nil {
+		t.Fatal("failed key lookup was not cached")
+	}
+	if client.keyFetches != 1 {
+		t.Fatalf("fetched %d keys from Plaid, want 1", client.keyFetches)
+	}
+}
@@ -0,0 +1,81 @@
+/*
+------------------------------------------------------------------
+FILE NAME:     accessTokens.go
+PROJECT:       CashflowAnalysis
+Date Created:  Oct-19-2026
+--------------------------------------------------------------------
+DESCRIPTION:
+Handles api calls for creating, listing and revoking personal access tokens
+--------------------------------------------------------------------
+$HISTORY:
+
+Oct-19-2026   Initial file created.
+------------------------------------------------------------------
+*/
+package main
+
+import (
+	userauth "cashflowanalysis/UserAuth"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Lists the users tokens without the token values
+func listAccessTokens(c *gin.Context) {
+	tokens, err := userauth.ListAccessTokens(principal(c).UserID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not load access tokens"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"tokens": tokens, "available_scopes": userauth.AccessTokenScopes})
+}
+
+// Creates a token. The token value is only returned in this response
+func createAccessToken(c *gin.Context) {
+	var recBody struct {
+		Name          string   `json:"name"`
+		Scopes        []string `json:"scopes"`
+		ExpiresInDays int      `json:"expires_in_days"`
+	}
+	if err := c.ShouldBindJSON(&recBody); err != nil {
+		renderError(c, err)
+		return
+	}
+
+	token, info, err := userauth.CreateAccessToken(principal(c).UserID, recBody.Name, recBody.Scopes, recBody.ExpiresInDays)
+	if err != nil {
+		renderAccessTokenError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"token": token, "token_info": info})
+}
+
+func revokeAccessToken(c *gin.Context) {
+	var recBody struct {
+		TokenId int `json:"token_id"`
+	}
+	if err := c.ShouldBindJSON(&recBody); err != nil {
+		renderError(c, err)
+		return
+	}
+	if err := userauth.RevokeAccessToken(principal(c).UserID, recBody.TokenId); err != nil {
+		renderAccessTokenError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Access token revoked"})
+}
+
+func renderAccessTokenError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, userauth.ErrInvalidScope),
+		errors.Is(err, userauth.ErrInvalidTokenName),
+		errors.Is(err, userauth.ErrInvalidTokenExpiry):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, userauth.ErrTokenNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not update access tokens"})
+	}
+}
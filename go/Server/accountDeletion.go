@@ -0,0 +1,86 @@
+/*
+------------------------------------------------------------------
+FILE NAME:     accountDeletion.go
+PROJECT:       CashflowAnalysis
+Date Created:  Oct-19-2026
+--------------------------------------------------------------------
+DESCRIPTION:
+Handles api calls for scheduling, checking and cancelling deletion of the
+logged in users account
+--------------------------------------------------------------------
+$HISTORY:
+
+Oct-19-2026   Initial file created.
+Oct-19-2026   The password can be left out right after a passkey or single sign-on login
+Oct-19-2026   Locked out second factors return 429
+------------------------------------------------------------------
+*/
+package main
+
+import (
+	userauth "cashflowanalysis/UserAuth"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Returns the pending deletion of the logged in user, if any
+func accountDeletionStatus(c *gin.Context) {
+	pending, err := userauth.AccountDeletionStatus(principal(c).UserID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not load account deletion"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"scheduled": pending != nil, "deletion": pending})
+}
+
+// Schedules deletion of the logged in users account after re-authenticating
+// with their password and 2FA code, or without them right after logging in
+func deleteAccount(c *gin.Context) {
+	var recBody struct {
+		Password string `json:"password"`
+		Code     string `json:"code"`
+	}
+	if err := c.ShouldBindJSON(&recBody); err != nil {
+		renderError(c, err)
+		return
+	}
+
+	deletion, receipt, err := userauth.ScheduleAccountDeletion(c.Request, c.Writer, recBody.Password, recBody.Code)
+	if err != nil {
+		renderAccountDeletionError(c, err)
+		return
+	}
+	if receipt != nil {
+		c.JSON(http.StatusOK, gin.H{"message": "Account deleted", "receipt": receipt})
+		return
+	}
+	c.JSON(http.StatusAccepted, gin.H{"message": "Account deletion scheduled", "deletion": deletion})
+}
+
+// Cancels the logged in users pending deletion during the grace period
+func cancelAccountDeletion(c *gin.Context) {
+	if err := userauth.CancelAccountDeletion(principal(c).UserID); err != nil {
+		renderAccountDeletionError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Account deletion cancelled"})
+}
+
+func renderAccountDeletionError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, userauth.ErrNotAuthorized):
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Not authorized"})
+	case errors.Is(err, userauth.ErrInvalidCredentials),
+		errors.Is(err, userauth.ErrReauthenticationRequired):
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+	case errors.Is(err, userauth.ErrMFALocked):
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": err.Error()})
+	case errors.Is(err, userauth.ErrDeletionAlreadyScheduled),
+		errors.Is(err, userauth.ErrNoDeletionScheduled):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not delete account"})
+	}
+}
@@ -0,0 +1,151 @@
+/*
+------------------------------------------------------------------
+FILE NAME:     admin.go
+PROJECT:       CashflowAnalysis
+Date Created:  Oct-19-2026
+--------------------------------------------------------------------
+DESCRIPTION:
+Handles api calls for the /api/admin route group. Support staff can search
+users and view their linked institutions, administrators can also
+deactivate/reactivate accounts, force logouts and change roles.
+--------------------------------------------------------------------
+$HISTORY:
+
+Oct-19-2026   Initial file created.
+------------------------------------------------------------------
+*/
+package main
+
+import (
+	userauth "cashflowanalysis/UserAuth"
+	accData "cashflowanalysis/UserBankAccountData"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Lists users, optionally filtered by ?search= on username or email
+func adminListUsers(c *gin.Context) {
+	limit, _ := strconv.Atoi(c.Query("limit"))
+	offset, _ := strconv.Atoi(c.Query("offset"))
+
+	users, err := userauth.ListUsers(c.Query("search"), limit, offset)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not load users"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"users": users})
+}
+
+func adminGetUser(c *gin.Context) {
+	userID, ok := userIDParam(c)
+	if !ok {
+		return
+	}
+	user, err := userauth.GetUserSummary(userID)
+	if err != nil {
+		renderAdminError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"user": user})
+}
+
+func adminDeactivateUser(c *gin.Context) {
+	userID, ok := userIDParam(c)
+	if !ok {
+		return
+	}
+	if err := userauth.SetUserDisabled(principal(c).UserID, userID, true); err != nil {
+		renderAdminError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "User deactivated"})
+}
+
+func adminReactivateUser(c *gin.Context) {
+	userID, ok := userIDParam(c)
+	if !ok {
+		return
+	}
+	if err := userauth.SetUserDisabled(principal(c).UserID, userID, false); err != nil {
+		renderAdminError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "User reactivated"})
+}
+
+// Revokes every session of the user
+func adminForceLogout(c *gin.Context) {
+	userID, ok := userIDParam(c)
+	if !ok {
+		return
+	}
+	revoked, err := userauth.ForceLogout(userID)
+	if err != nil {
+		renderAdminError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "User logged out", "revoked": revoked})
+}
+
+func adminSetUserRole(c *gin.Context) {
+	userID, ok := userIDParam(c)
+	if !ok {
+		return
+	}
+	var recBody struct {
+		Role string `json:"role"`
+	}
+	if err := c.ShouldBindJSON(&recBody); err != nil {
+		renderError(c, err)
+		return
+	}
+	if err := userauth.SetUserRole(principal(c).UserID, userID, recBody.Role); err != nil {
+		renderAdminError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Role updated"})
+}
+
+// Lists the users linked institutions with the current health of each Plaid item
+func adminUserInstitutions(c *gin.Context) {
+	userID, ok := userIDParam(c)
+	if !ok {
+		return
+	}
+	if _, err := userauth.GetUserSummary(userID); err != nil {
+		renderAdminError(c, err)
+		return
+	}
+	institutions, err := accData.RetrieveInstitutionHealth(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not load institutions"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"institutions": institutions})
+}
+
+// Reads the :id route parameter, responds with 400 if it isn't a number
+func userIDParam(c *gin.Context) (int, bool) {
+	userID, err := strconv.Atoi(c.Param("id"))
+	if err != nil || userID <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user id"})
+		return 0, false
+	}
+	return userID, true
+}
+
+func renderAdminError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, userauth.ErrUserNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, userauth.ErrInvalidRole):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, userauth.ErrCannotTargetSelf):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not update user"})
+	}
+}
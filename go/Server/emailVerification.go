@@ -0,0 +1,85 @@
+/*
+------------------------------------------------------------------
+FILE NAME:     emailVerification.go
+PROJECT:       CashflowAnalysis
+Date Created:  Oct-19-2026
+--------------------------------------------------------------------
+DESCRIPTION:
+Handles api calls for confirming a users email address and resending
+the verification link
+--------------------------------------------------------------------
+$HISTORY:
+
+Oct-19-2026   Initial file created.
+------------------------------------------------------------------
+*/
+package main
+
+import (
+	userauth "cashflowanalysis/UserAuth"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Returns the email of the logged in user and whether it has been verified
+func emailVerificationStatus(c *gin.Context) {
+	info, err := userauth.EmailVerificationStatus(c.Request)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Not authorized"})
+		return
+	}
+	c.JSON(http.StatusOK, info)
+}
+
+// Confirms the email address with the token from the verification link
+func verifyEmail(c *gin.Context) {
+	var recBody struct {
+		Token string `json:"token"`
+	}
+	if err := c.ShouldBindJSON(&recBody); err != nil {
+		renderError(c, err)
+		return
+	}
+
+	err := userauth.VerifyEmail(c.Request, recBody.Token)
+	switch {
+	case err == nil:
+		c.JSON(http.StatusOK, gin.H{"message": "Email verified"})
+	case errors.Is(err, userauth.ErrInvalidVerificationToken):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		log.Println("email verification failed:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not verify email"})
+	}
+}
+
+// Sends a new verification link. An email can be given to correct the address
+// while it is still unverified
+func resendVerificationEmail(c *gin.Context) {
+	var recBody struct {
+		Email string `json:"email"`
+	}
+	if err := c.ShouldBindJSON(&recBody); err != nil {
+		renderError(c, err)
+		return
+	}
+
+	err := userauth.ResendVerificationEmail(c.Request, recBody.Email)
+	switch {
+	case err == nil:
+		c.JSON(http.StatusOK, gin.H{"message": "Verification email sent"})
+	case errors.Is(err, userauth.ErrNotAuthorized):
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Not authorized"})
+	case errors.Is(err, userauth.ErrVerificationRateLimited):
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": err.Error()})
+	case errors.Is(err, userauth.ErrEmailAlreadyVerified), errors.Is(err, userauth.ErrEmailRequired),
+		errors.Is(err, userauth.ErrInvalidEmail), errors.Is(err, userauth.ErrEmailInUse):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		log.Println("could not resend verification email:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not send verification email"})
+	}
+}
@@ -10,25 +10,45 @@ Handles api calls for users financial account services
 $HISTORY:
 
 Jan-28-2026   Initial file created.
+Oct-19-2026   RetrieveAccountData() returns the users access level for each institution
+Oct-19-2026   GetAllTransactions() returns the transactions of one linked institution the user can access
+Oct-19-2026   GetAllTransactions() reads the synced transactions from the database. Added SyncTransactions()
+Oct-19-2026   Added UnlinkInstitution()
+Oct-19-2026   Added GetBalanceHistory()
+Oct-19-2026   Added GetWidgetHoldings() and GetPortfolioValue(). The series query parameters are read by seriesQuery()
+Oct-19-2026   Added GetUpcomingPayments()
+Oct-19-2026   Added GetRecurringStreams() and UpdateRecurringStream()
+Oct-19-2026   StoreAccountData() returns an error when the institution could not be linked
 ------------------------------------------------------------------
 */
 package main
 
 import (
-	plaidServices "cashflowanalysis/PlaidComponents"
 	accData "cashflowanalysis/UserBankAccountData"
+	"errors"
 	"fmt"
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
 
+const (
+	defaultUpcomingDays = 30
+	//Largest ?days= accepted, a year ahead
+	maxUpcomingDays = 366
+)
+
 // Given the public token, store the account data brought in from the users
 // plaid choice
 func StoreAccountData(c *gin.Context) {
 	publicToken := c.PostForm("public_token")
 
-	accData.StoreUserPlaidData(c.Request, publicToken)
+	if !accData.StoreUserPlaidData(c.Request, publicToken) {
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Could not link the institution, please try again"})
+		return
+	}
 
 	c.JSON(http.StatusOK, gin.H{"message": "Signup successful"})
 }
@@ -36,23 +56,248 @@ func StoreAccountData(c *gin.Context) {
 // Retrieve all institution and account data tied to the users id and return
 // in json call
 func RetrieveAccountData(c *gin.Context) {
-	institutions, accounts, accountBalances, err := accData.RetrieveAllUserAccountData(c.Request)
+	institutions, accounts, accountBalances, accessLevels, err := accData.RetrieveAllUserAccountData(c.Request)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
 	}
 	c.JSON(http.StatusOK, gin.H{
 		"institutions":     institutions,
 		"accounts":         accounts,
 		"account_balances": accountBalances,
+		"access_levels":    accessLevels,
 	})
 }
 
+// Returns the synced transactions the user can see, newest first. Pass
+// ?linked_institution_id= for a single institution
 func GetAllTransactions(c *gin.Context) {
+	linkedInstitutionID := 0
+	if raw := c.Query("linked_institution_id"); raw != "" {
+		id, err := strconv.Atoi(raw)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "linked_institution_id must be a number"})
+			return
+		}
+		linkedInstitutionID = id
+	}
+
+	transactions, err := accData.RetrieveTransactions(principal(c).UserID, linkedInstitutionID)
+	if err != nil {
+		renderPlaidItemError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"transactions": transactions})
+}
+
+// Pulls new transactions from Plaid and reports how many were added, modified
+// and removed. linked_institution_id 0 syncs every institution the user can manage
+func SyncTransactions(c *gin.Context) {
+	var recBody struct {
+		LinkedInstitutionID int `json:"linked_institution_id"`
+	}
+	if err := c.ShouldBindJSON(&recBody); err != nil {
+		renderError(c, err)
+		return
+	}
+
+	userID := principal(c).UserID
+	var results []accData.SyncResult
+	var err error
+	if recBody.LinkedInstitutionID == 0 {
+		results, err = accData.SyncAllUserTransactions(userID)
+	} else {
+		var result accData.SyncResult
+		result, err = accData.SyncUserInstitutionTransactions(userID, recBody.LinkedInstitutionID)
+		results = []accData.SyncResult{result}
+	}
+	switch {
+	case err == nil:
+		c.JSON(http.StatusOK, gin.H{"results": results})
+	case errors.Is(err, accData.ErrSyncConflict):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, accData.ErrInstitutionNotFound), errors.Is(err, accData.ErrManageAccessRequired):
+		renderPlaidItemError(c, err)
+	default:
+		renderError(c, err)
+	}
+}
 
-	cursor, transactions, _ := plaidServices.Transactions()
-	fmt.Println("Cursor:", cursor)
-	fmt.Println("Transactions:")
-	for _, tx := range transactions {
-		fmt.Printf(" - %s: %s\n", tx.GetDate(), tx.GetName())
+// Returns the recurring income and bills Plaid detected on the accounts the user
+// can access. Dismissed streams are left out unless ?include_dismissed=true
+func GetRecurringStreams(c *gin.Context) {
+	includeDismissed := c.Query("include_dismissed") == "true"
+	streams, err := accData.RetrieveRecurringStreams(principal(c).UserID, includeDismissed)
+	if err != nil {
+		renderError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, streams)
+}
+
+// Renames, confirms or dismisses a recurring stream. An empty display_name goes
+// back to the merchant name, review_status is unreviewed, confirmed or dismissed
+func UpdateRecurringStream(c *gin.Context) {
+	var recBody struct {
+		RecurringStreamID int     `json:"recurring_stream_id"`
+		DisplayName       *string `json:"display_name"`
+		ReviewStatus      *string `json:"review_status"`
+	}
+	if err := c.ShouldBindJSON(&recBody); err != nil || recBody.RecurringStreamID == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "recurring_stream_id is required"})
+		return
+	}
+
+	stream, err := accData.UpdateRecurringStream(principal(c).UserID, recBody.RecurringStreamID, accData.StreamUpdate{
+		DisplayName:  recBody.DisplayName,
+		ReviewStatus: recBody.ReviewStatus,
+	})
+	switch {
+	case err == nil:
+		c.JSON(http.StatusOK, stream)
+	case errors.Is(err, accData.ErrInvalidStreamUpdate):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "set display_name (at most 100 characters) or review_status (unreviewed, confirmed or dismissed)"})
+	case errors.Is(err, accData.ErrStreamNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, accData.ErrManageAccessRequired):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	default:
+		renderError(c, err)
 	}
 }
+
+// Removes a linked institution the user owns from Plaid and deletes its accounts,
+// balances and transactions. Responds with the users widgets that lost accounts
+func UnlinkInstitution(c *gin.Context) {
+	var recBody struct {
+		LinkedInstitutionID int `json:"linked_institution_id"`
+	}
+	if err := c.ShouldBindJSON(&recBody); err != nil || recBody.LinkedInstitutionID == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "linked_institution_id is required"})
+		return
+	}
+	result, err := accData.UnlinkInstitution(c.Request, principal(c).UserID, recBody.LinkedInstitutionID)
+	switch {
+	case err == nil:
+		c.JSON(http.StatusOK, result)
+	case errors.Is(err, accData.ErrInstitutionNotFound), errors.Is(err, accData.ErrOwnerRequired):
+		renderPlaidItemError(c, err)
+	default:
+		renderError(c, err)
+	}
+}
+
+// Returns an accounts balance series. Takes ?account_id= and the series query parameters, see seriesQuery()
+func GetBalanceHistory(c *gin.Context) {
+	accountID, err := strconv.Atoi(c.Query("account_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "account_id must be a number"})
+		return
+	}
+	from, to, resolution, ok := seriesQuery(c)
+	if !ok {
+		return
+	}
+
+	series, err := accData.BalanceHistory(principal(c).UserID, accountID, from, to, resolution)
+	switch {
+	case err == nil:
+		c.JSON(http.StatusOK, series)
+	case errors.Is(err, accData.ErrInvalidHistoryRange):
+		renderInvalidRange(c)
+	case errors.Is(err, accData.ErrAccountNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	default:
+		renderError(c, err)
+	}
+}
+
+// Returns the latest holdings of the accounts linked to an investment widget. Takes ?widget_id=
+func GetWidgetHoldings(c *gin.Context) {
+	widgetID, err := strconv.Atoi(c.Query("widget_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "widget_id must be a number"})
+		return
+	}
+	holdings, err := accData.RetrieveWidgetHoldings(principal(c).UserID, widgetID)
+	switch {
+	case err == nil:
+		c.JSON(http.StatusOK, holdings)
+	case errors.Is(err, accData.ErrWidgetNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	default:
+		renderError(c, err)
+	}
+}
+
+// Returns the value over time of the holdings of the accounts linked to an
+// investment widget. Takes ?widget_id= and the series query parameters, see seriesQuery()
+func GetPortfolioValue(c *gin.Context) {
+	widgetID, err := strconv.Atoi(c.Query("widget_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "widget_id must be a number"})
+		return
+	}
+	from, to, resolution, ok := seriesQuery(c)
+	if !ok {
+		return
+	}
+
+	series, err := accData.RetrievePortfolioValue(principal(c).UserID, widgetID, from, to, resolution)
+	switch {
+	case err == nil:
+		c.JSON(http.StatusOK, series)
+	case errors.Is(err, accData.ErrInvalidHistoryRange):
+		renderInvalidRange(c)
+	case errors.Is(err, accData.ErrWidgetNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	default:
+		renderError(c, err)
+	}
+}
+
+// Returns the payments due on the users credit cards, student loans and
+// mortgages within ?days= (default 30), overdue ones included
+func GetUpcomingPayments(c *gin.Context) {
+	days := defaultUpcomingDays
+	if raw := c.Query("days"); raw != "" {
+		var err error
+		if days, err = strconv.Atoi(raw); err != nil || days < 0 || days > maxUpcomingDays {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("days must be a number from 0 to %d", maxUpcomingDays)})
+			return
+		}
+	}
+
+	payments, err := accData.UpcomingPayments(principal(c).UserID, days)
+	if err != nil {
+		renderError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"days": days, "payments": payments})
+}
+
+// Reads the optional from and to (YYYY-MM-DD, default the last 90 days) and
+// resolution (daily, weekly or monthly, default daily) of a series request.
+// Responds with 400 and returns false when a date is malformed
+func seriesQuery(c *gin.Context) (time.Time, time.Time, string, bool) {
+	var err error
+	to := time.Now().UTC()
+	if raw := c.Query("to"); raw != "" {
+		if to, err = time.Parse(time.DateOnly, raw); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "to must be a date (YYYY-MM-DD)"})
+			return time.Time{}, time.Time{}, "", false
+		}
+	}
+	from := to.AddDate(0, 0, -90)
+	if raw := c.Query("from"); raw != "" {
+		if from, err = time.Parse(time.DateOnly, raw); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "from must be a date (YYYY-MM-DD)"})
+			return time.Time{}, time.Time{}, "", false
+		}
+	}
+	return from, to, c.DefaultQuery("resolution", accData.ResolutionDaily), true
+}
+
+func renderInvalidRange(c *gin.Context) {
+	c.JSON(http.StatusBadRequest, gin.H{"error": "from must not be after to, resolution must be daily, weekly or monthly and the range at most 1000 points"})
+}
@@ -0,0 +1,85 @@
+/*
+------------------------------------------------------------------
+FILE NAME:     impersonation.go
+PROJECT:       CashflowAnalysis
+Date Created:  Oct-19-2026
+--------------------------------------------------------------------
+DESCRIPTION:
+Handles api calls for admins starting and ending a read-only
+impersonation of a user, and for the frontend to show the
+impersonation banner
+--------------------------------------------------------------------
+$HISTORY:
+
+Oct-19-2026   Initial file created.
+------------------------------------------------------------------
+*/
+package main
+
+import (
+	userauth "cashflowanalysis/UserAuth"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Starts a read-only session as the user. The reason is kept in the audit trail
+func adminImpersonateUser(c *gin.Context) {
+	userID, ok := userIDParam(c)
+	if !ok {
+		return
+	}
+	var recBody struct {
+		Reason string `json:"reason"`
+	}
+	if err := c.ShouldBindJSON(&recBody); err != nil {
+		renderError(c, err)
+		return
+	}
+
+	info, err := userauth.StartImpersonation(c.Request, c.Writer, userID, recBody.Reason)
+	switch {
+	case err == nil:
+		c.JSON(http.StatusOK, gin.H{"message": "Impersonation started", "impersonation": info})
+	case errors.Is(err, userauth.ErrUserNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, userauth.ErrImpersonationReason), errors.Is(err, userauth.ErrAlreadyImpersonating):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, userauth.ErrCannotImpersonate), errors.Is(err, userauth.ErrNotAuthorized):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	default:
+		log.Println("could not start impersonation:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not start impersonation"})
+	}
+}
+
+// Returns who is being impersonated, by whom and until when
+func impersonationStatus(c *gin.Context) {
+	info, err := userauth.CurrentImpersonation(principal(c))
+	if errors.Is(err, userauth.ErrNotImpersonating) {
+		c.JSON(http.StatusOK, gin.H{"impersonating": false})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not load impersonation"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"impersonating": true, "impersonation": info})
+}
+
+// Ends the impersonation and switches back to the admins own session
+func endImpersonation(c *gin.Context) {
+	err := userauth.EndImpersonation(c.Request, c.Writer)
+	if errors.Is(err, userauth.ErrNotImpersonating) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		log.Println("could not end impersonation:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not end impersonation"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Impersonation ended"})
+}
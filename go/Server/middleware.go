@@ -0,0 +1,260 @@
+/*
+------------------------------------------------------------------
+FILE NAME:     middleware.go
+PROJECT:       CashflowAnalysis
+Date Created:  Oct-19-2026
+--------------------------------------------------------------------
+DESCRIPTION:
+Gin middleware applied to the api routes.
+
+requireAuth() resolves the session-id cookie or an Authorization: Bearer
+personal access token to a principal and rejects unauthenticated or disabled
+users. requireRole() limits a route group to users with at least the given role.
+requireSession() only allows cookie sessions and requireScope() checks a
+token has the scope needed for the route. requireVerifiedEmail() keeps users
+who have not confirmed their email away from bank linking.
+impersonationGuard() makes admin impersonation sessions read-only and
+records every request they make.
+
+csrfProtection() checks every state-changing (non GET/HEAD/OPTIONS) request:
+ 1. The Origin (or Referer) header must be a trusted origin when present
+ 2. Requests authenticated by the session-id cookie must send the csrf-token
+    value in the X-CSRF-Token header. It is a random token per session, checked
+    against the hash stored with the session. Login and signup routes are exempt,
+    they run before there is a session to bind the token to and a stale
+    session-id cookie must not lock the user out of logging in again
+
+CSRF_ENABLED=false turns the checks off for local development and
+CSRF_TRUSTED_ORIGINS is a comma separated list of allowed origins
+(defaults to APP_BASE_URL)
+--------------------------------------------------------------------
+$HISTORY:
+
+Oct-19-2026   Initial file created. Added csrfProtection()
+Oct-19-2026   Added requireAuth() and requireRole()
+Oct-19-2026   requireAuth() accepts personal access tokens. Added requireSession() and requireScope()
+Oct-19-2026   Added requireVerifiedEmail()
+Oct-19-2026   Added impersonationGuard()
+Oct-19-2026   csrfProtection() skips the token check on the login and signup routes
+Oct-19-2026   CSRF tokens are checked against the hash stored with the session.
+-             /api/csrf_token/ issues a new token instead of deriving it
+------------------------------------------------------------------
+*/
+package main
+
+import (
+	cookies "cashflowanalysis/CookieHandler"
+	helper "cashflowanalysis/Services/Helpers"
+	userauth "cashflowanalysis/UserAuth"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Requires a valid session. The principal is stored on the request context so
+// helper.GetUserID() and the handlers can read it
+func requireAuth() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		p, err := userauth.AuthenticatePrincipal(c.Request)
+		if err != nil {
+			if errors.Is(err, userauth.ErrAccountDisabled) {
+				c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Account is disabled"})
+				return
+			}
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Not authorized"})
+			return
+		}
+		c.Request = helper.WithPrincipal(c.Request, p)
+		c.Next()
+	}
+}
+
+// Requires the principal to have at least the given role. Must run after requireAuth()
+func requireRole(minimum string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		p, ok := helper.GetPrincipal(c.Request)
+		if !ok || !userauth.HasRole(p, minimum) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Forbidden"})
+			return
+		}
+		c.Next()
+	}
+}
+
+// Only allows principals authenticated by the session-id cookie. Used for account
+// management routes that personal access tokens must never reach
+func requireSession() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if principal(c).AuthMethod != helper.AuthMethodSession {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "This route requires a browser session"})
+			return
+		}
+		c.Next()
+	}
+}
+
+// Requires personal access tokens to have the given scope. Sessions have every scope
+func requireScope(scope string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !principal(c).HasScope(scope) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Token is missing the " + scope + " scope"})
+			return
+		}
+		c.Next()
+	}
+}
+
+// Requires the user to have verified their email address. Must run after requireAuth()
+func requireVerifiedEmail() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		verified, err := userauth.EmailVerified(principal(c).UserID)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Could not check email verification"})
+			return
+		}
+		if !verified {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Verify your email address before linking a bank", "email_verification_required": true})
+			return
+		}
+		c.Next()
+	}
+}
+
+// Routes that change data even though they are GET requests
+var impersonationBlockedGets = map[string]bool{
+	"/api/create_public_token": true,
+}
+
+// Impersonation sessions may only read. Every request is recorded in the security
+// event log, including the ones that are blocked. Must run after requireAuth()
+func impersonationGuard() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		p := principal(c)
+		if !p.IsImpersonation() {
+			c.Next()
+			return
+		}
+		readOnly := c.Request.Method == http.MethodGet || c.Request.Method == http.MethodHead || c.Request.Method == http.MethodOptions
+		allowed := readOnly && !impersonationBlockedGets[c.Request.URL.Path]
+		userauth.RecordImpersonatedRequest(c.Request, p, allowed)
+		if !allowed {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Impersonation sessions are read-only", "impersonation": true})
+			return
+		}
+		c.Next()
+	}
+}
+
+// Returns the principal set by requireAuth()
+func principal(c *gin.Context) helper.Principal {
+	p, _ := helper.GetPrincipal(c.Request)
+	return p
+}
+
+// Routes that create a session. They still get the origin check but not the
+// token check, the session-id cookie they may carry is the one being replaced
+var csrfTokenExemptPaths = map[string]bool{
+	"/api/login/":                 true,
+	"/api/login/mfa/":             true,
+	"/api/signup/":                true,
+	"/api/passkeys/login/begin/":  true,
+	"/api/passkeys/login/finish/": true,
+	"/api/forgot_password/":       true,
+	"/api/reset_password/":        true,
+}
+
+// Rejects cross-site state-changing requests. Configuration is read once when
+// the middleware is created (after the .env file is loaded)
+func csrfProtection() gin.HandlerFunc {
+	enabled := !strings.EqualFold(os.Getenv("CSRF_ENABLED"), "false")
+	if !enabled {
+		fmt.Println("WARNING: CSRF protection is disabled (CSRF_ENABLED=false)")
+	}
+	trustedOrigins := csrfTrustedOrigins()
+
+	return func(c *gin.Context) {
+		if !enabled {
+			c.Next()
+			return
+		}
+		switch c.Request.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			c.Next()
+			return
+		}
+
+		if !originAllowed(c.Request, trustedOrigins) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Cross-site request rejected"})
+			return
+		}
+
+		//Only cookie authenticated requests carry ambient credentials that need a token
+		sessionID, err := cookies.GetCookie(c.Request, "session-id")
+		if err == nil && sessionID != "" && !csrfTokenExemptPaths[c.Request.URL.Path] {
+			if !userauth.ValidCSRFToken(c.Request, c.GetHeader(cookies.CSRFHeaderName)) {
+				c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Missing or invalid CSRF token"})
+				return
+			}
+		}
+		c.Next()
+	}
+}
+
+// Issues a new CSRF token for the current session so the frontend can recover
+// if the csrf-token cookie was lost
+func csrfToken(c *gin.Context) {
+	token, err := userauth.RenewCSRFToken(c.Request, c.Writer)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Not authorized"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"csrf_token": token})
+}
+
+// Checks the Origin header, falling back to the Referer header. Requests with
+// neither (non browser clients) are allowed through to the token check
+func originAllowed(r *http.Request, trustedOrigins map[string]bool) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" || origin == "null" {
+		referer := r.Header.Get("Referer")
+		if referer == "" {
+			return origin == ""
+		}
+		u, err := url.Parse(referer)
+		if err != nil {
+			return false
+		}
+		origin = u.Scheme + "://" + u.Host
+	}
+	origin = strings.ToLower(strings.TrimRight(origin, "/"))
+	if trustedOrigins[origin] {
+		return true
+	}
+
+	//Same origin requests made directly to the api
+	u, err := url.Parse(origin)
+	return err == nil && strings.EqualFold(u.Host, r.Host)
+}
+
+func csrfTrustedOrigins() map[string]bool {
+	list := os.Getenv("CSRF_TRUSTED_ORIGINS")
+	if list == "" {
+		list = os.Getenv("APP_BASE_URL")
+	}
+	if list == "" {
+		list = "http://localhost:3000"
+	}
+	origins := map[string]bool{}
+	for _, o := range strings.Split(list, ",") {
+		o = strings.ToLower(strings.TrimRight(strings.TrimSpace(o), "/"))
+		if o != "" {
+			origins[o] = true
+		}
+	}
+	return origins
+}
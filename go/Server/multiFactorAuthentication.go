@@ -0,0 +1,132 @@
+/*
+------------------------------------------------------------------
+FILE NAME:     multiFactorAuthentication.go
+PROJECT:       CashflowAnalysis
+Date Created:  Oct-19-2026
+--------------------------------------------------------------------
+DESCRIPTION:
+Handles api calls for TOTP two-factor enrollment, disabling 2FA and
+regenerating recovery codes
+--------------------------------------------------------------------
+$HISTORY:
+
+Oct-19-2026   Initial file created.
+Oct-19-2026   Locked out second factors return 429
+------------------------------------------------------------------
+*/
+package main
+
+import (
+	userauth "cashflowanalysis/UserAuth"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Starts TOTP enrollment for the logged in user. Returns the secret and the
+// otpauth URI which the client renders as a QR code
+func enrollTOTP(c *gin.Context) {
+	user, err := userauth.AuthenticatedUser(c.Request)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Not authorized"})
+		return
+	}
+
+	secret, uri, err := userauth.BeginTOTPEnrollment(user.UserId)
+	if err != nil {
+		renderMFAError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"secret":      secret,
+		"otpauth_uri": uri,
+		"qr_payload":  uri,
+	})
+}
+
+// Confirms enrollment with the first code from the authenticator app and
+// returns the one-time recovery codes
+func confirmTOTP(c *gin.Context) {
+	var recBody struct {
+		Code string `json:"code"`
+	}
+	if err := c.ShouldBindJSON(&recBody); err != nil {
+		renderError(c, err)
+		return
+	}
+	user, err := userauth.AuthenticatedUser(c.Request)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Not authorized"})
+		return
+	}
+
+	codes, err := userauth.ConfirmTOTPEnrollment(user.UserId, recBody.Code)
+	if err != nil {
+		renderMFAError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Two-factor authentication enabled", "recovery_codes": codes})
+}
+
+// Disables 2FA after re-authenticating with password and a current code
+func disableTOTP(c *gin.Context) {
+	var recBody struct {
+		Password string `json:"password"`
+		Code     string `json:"code"`
+	}
+	if err := c.ShouldBindJSON(&recBody); err != nil {
+		renderError(c, err)
+		return
+	}
+	user, err := userauth.AuthenticatedUser(c.Request)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Not authorized"})
+		return
+	}
+
+	if err := userauth.DisableTOTP(user.UserId, recBody.Password, recBody.Code); err != nil {
+		renderMFAError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Two-factor authentication disabled"})
+}
+
+// Replaces all recovery codes after re-authenticating with password and a current code
+func regenerateRecoveryCodes(c *gin.Context) {
+	var recBody struct {
+		Password string `json:"password"`
+		Code     string `json:"code"`
+	}
+	if err := c.ShouldBindJSON(&recBody); err != nil {
+		renderError(c, err)
+		return
+	}
+	user, err := userauth.AuthenticatedUser(c.Request)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Not authorized"})
+		return
+	}
+
+	codes, err := userauth.RegenerateRecoveryCodes(user.UserId, recBody.Password, recBody.Code)
+	if err != nil {
+		renderMFAError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"recovery_codes": codes})
+}
+
+func renderMFAError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, userauth.ErrInvalidCredentials):
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+	case errors.Is(err, userauth.ErrMFALocked):
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": err.Error()})
+	case errors.Is(err, userauth.ErrMFAAlreadyEnabled),
+		errors.Is(err, userauth.ErrMFANotEnabled),
+		errors.Is(err, userauth.ErrMFAEnrollmentNotStarted):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not update two-factor authentication"})
+	}
+}
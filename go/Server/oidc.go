@@ -0,0 +1,101 @@
+/*
+------------------------------------------------------------------
+FILE NAME:     oidc.go
+PROJECT:       CashflowAnalysis
+Date Created:  Oct-19-2026
+--------------------------------------------------------------------
+DESCRIPTION:
+Handles api calls for single sign-on with an OpenID Connect identity provider
+and for linking provider identities to the logged in user
+--------------------------------------------------------------------
+$HISTORY:
+
+Oct-19-2026   Initial file created.
+Oct-19-2026   oidcLogin() accepts ?remember_me=true
+------------------------------------------------------------------
+*/
+package main
+
+import (
+	userauth "cashflowanalysis/UserAuth"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Redirects the browser to the identity provider to log in. ?remember_me=true asks
+// for a longer lived session
+func oidcLogin(c *gin.Context) {
+	authURL, err := userauth.BeginOIDCLogin(c.Writer, 0, c.Query("remember_me") == "true")
+	if err != nil {
+		if errors.Is(err, userauth.ErrOIDCNotConfigured) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		fmt.Println("oidc login:", err)
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Identity provider is unavailable"})
+		return
+	}
+	c.Redirect(http.StatusFound, authURL)
+}
+
+// Identity provider redirects back here with the authorization code
+func oidcCallback(c *gin.Context) {
+	result, err := userauth.CompleteOIDCLogin(c.Request, c.Writer)
+	if err != nil {
+		fmt.Println("oidc callback:", err)
+		c.Redirect(http.StatusFound, userauth.OIDCErrorURL(oidcErrorReason(err)))
+		return
+	}
+	c.Redirect(http.StatusFound, result.RedirectURL)
+}
+
+// Starts linking an identity provider account to the logged in user.
+// Returns the url the frontend should send the browser to
+func oidcLink(c *gin.Context) {
+	authURL, err := userauth.BeginOIDCLogin(c.Writer, principal(c).UserID, false)
+	if err != nil {
+		if errors.Is(err, userauth.ErrOIDCNotConfigured) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		fmt.Println("oidc link:", err)
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Identity provider is unavailable"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"authorization_url": authURL})
+}
+
+// Lists the identity provider accounts linked to the logged in user
+func listIdentities(c *gin.Context) {
+	identities, err := userauth.ListIdentities(principal(c).UserID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not load linked identities"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"identities": identities})
+}
+
+// Short reason passed to the frontend login page, details are only logged
+func oidcErrorReason(err error) string {
+	switch {
+	case errors.Is(err, userauth.ErrOIDCNotConfigured):
+		return "not_configured"
+	case errors.Is(err, userauth.ErrOIDCInvalidState):
+		return "invalid_request"
+	case errors.Is(err, userauth.ErrOIDCProviderError):
+		return "provider_error"
+	case errors.Is(err, userauth.ErrOIDCIdentityNotLinked):
+		return "not_linked"
+	case errors.Is(err, userauth.ErrOIDCIdentityInUse):
+		return "identity_in_use"
+	case errors.Is(err, userauth.ErrOIDCEmailInUse):
+		return "email_in_use"
+	case errors.Is(err, userauth.ErrAccountDisabled):
+		return "account_disabled"
+	default:
+		return "login_failed"
+	}
+}
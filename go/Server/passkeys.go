@@ -0,0 +1,141 @@
+/*
+------------------------------------------------------------------
+FILE NAME:     passkeys.go
+PROJECT:       CashflowAnalysis
+Date Created:  Oct-19-2026
+--------------------------------------------------------------------
+DESCRIPTION:
+Handles api calls for passkey (WebAuthn) sign-in and for registering,
+renaming and removing a users passkeys. The finish calls take the
+browsers credential response as the request body.
+--------------------------------------------------------------------
+$HISTORY:
+
+Oct-19-2026   Initial file created.
+------------------------------------------------------------------
+*/
+package main
+
+import (
+	userauth "cashflowanalysis/UserAuth"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Returns the options for navigator.credentials.get(). Pass ?remember_me=true
+// for a longer lived session
+func beginPasskeyLogin(c *gin.Context) {
+	assertion, err := userauth.BeginPasskeyLogin(c.Writer, c.Query("remember_me") == "true")
+	if err != nil {
+		renderPasskeyError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, assertion)
+}
+
+// Verifies the passkey assertion and logs the user in
+func finishPasskeyLogin(c *gin.Context) {
+	if err := userauth.FinishPasskeyLogin(c.Request, c.Writer); err != nil {
+		if errors.Is(err, userauth.ErrAccountDisabled) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Account is disabled"})
+			return
+		}
+		if errors.Is(err, userauth.ErrPasskeyRejected) || errors.Is(err, userauth.ErrPasskeyCloned) {
+			c.JSON(http.StatusUnauthorized, gin.H{"message": "Login failed"})
+			return
+		}
+		renderPasskeyError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Login successful"})
+}
+
+// Lists the passkeys of the logged in user
+func listPasskeys(c *gin.Context) {
+	passkeys, err := userauth.ListPasskeys(c.Request)
+	if err != nil {
+		renderPasskeyError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"passkeys": passkeys})
+}
+
+// Returns the options for navigator.credentials.create()
+func beginPasskeyRegistration(c *gin.Context) {
+	var recBody struct {
+		Name string `json:"name"`
+	}
+	if err := c.ShouldBindJSON(&recBody); err != nil {
+		renderError(c, err)
+		return
+	}
+	creation, err := userauth.BeginPasskeyRegistration(c.Request, c.Writer, recBody.Name)
+	if err != nil {
+		renderPasskeyError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, creation)
+}
+
+// Verifies the new credential and stores it
+func finishPasskeyRegistration(c *gin.Context) {
+	passkey, err := userauth.FinishPasskeyRegistration(c.Request, c.Writer)
+	if err != nil {
+		renderPasskeyError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Passkey added", "passkey": passkey})
+}
+
+// Changes the name of a passkey
+func renamePasskey(c *gin.Context) {
+	var recBody struct {
+		PasskeyId int    `json:"passkey_id"`
+		Name      string `json:"name"`
+	}
+	if err := c.ShouldBindJSON(&recBody); err != nil {
+		renderError(c, err)
+		return
+	}
+	if err := userauth.RenamePasskey(c.Request, recBody.PasskeyId, recBody.Name); err != nil {
+		renderPasskeyError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Passkey renamed"})
+}
+
+// Removes a passkey
+func removePasskey(c *gin.Context) {
+	var recBody struct {
+		PasskeyId int `json:"passkey_id"`
+	}
+	if err := c.ShouldBindJSON(&recBody); err != nil {
+		renderError(c, err)
+		return
+	}
+	if err := userauth.RemovePasskey(c.Request, recBody.PasskeyId); err != nil {
+		renderPasskeyError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Passkey removed"})
+}
+
+func renderPasskeyError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, userauth.ErrNotAuthorized):
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Not authorized"})
+	case errors.Is(err, userauth.ErrPasskeyNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, userauth.ErrPasskeyCeremonyExpired), errors.Is(err, userauth.ErrPasskeyRejected),
+		errors.Is(err, userauth.ErrPasskeyInUse), errors.Is(err, userauth.ErrPasskeyNameRequired):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, userauth.ErrLastSignInMethod):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		log.Println("passkey request failed:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not complete the passkey request"})
+	}
+}
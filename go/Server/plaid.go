@@ -10,29 +10,51 @@ Handles api calls for plaid services
 $HISTORY:
 
 Jan-28-2026   Initial file created.
+Oct-19-2026   Plaid calls use the item of a linked institution the user can access.
+-             /api/info no longer returns an access token or item id
+Oct-19-2026   Added plaidWebhook()
+Oct-19-2026   Added createUpdateLinkToken() and completeItemUpdate() for Link update mode
+Oct-19-2026   renderPlaidItemError() handles ErrOwnerRequired
+Oct-19-2026   createLinkToken() binds user_token from the JSON body
 ------------------------------------------------------------------
 */
 package main
 
 import (
 	plaidServices "cashflowanalysis/PlaidComponents"
+	accData "cashflowanalysis/UserBankAccountData"
+	"errors"
+	"io"
+	"log"
 	"net/http"
+	"strconv"
 
 	"github.com/gin-gonic/gin"
 )
 
-func info(c *gin.Context) {
-	accessToken, itemID, products := plaidServices.Info()
+// Largest webhook body read, Plaid webhooks are a few hundred bytes
+const maxWebhookBodyBytes = 64 << 10
 
+func info(c *gin.Context) {
 	c.JSON(http.StatusOK, map[string]interface{}{
-		"item_id":      itemID,
-		"access_token": accessToken,
-		"products":     products,
+		"products": plaidServices.Info(),
 	})
 }
 
+// Creates a public token for ?linked_institution_id= so Link can be opened in
+// update mode. Requires owner or manage access to the institution
 func createPublicToken(c *gin.Context) {
-	publicToken, err := plaidServices.CreatePublicToken()
+	linkedInstitutionID, err := strconv.Atoi(c.Query("linked_institution_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "linked_institution_id is required"})
+		return
+	}
+	item, err := accData.LoadManagedPlaidItem(principal(c).UserID, linkedInstitutionID)
+	if err != nil {
+		renderPlaidItemError(c, err)
+		return
+	}
+	publicToken, err := plaidServices.CreatePublicToken(item)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -43,8 +65,18 @@ func createPublicToken(c *gin.Context) {
 	})
 }
 
+// Creates a link token for the logged in user. A user_token from
+// /api/create_user_token is only needed for the CRA products
 func createLinkToken(c *gin.Context) {
-	linkToken, err := plaidServices.CreateLinkToken()
+	var recBody struct {
+		UserToken string `json:"user_token"`
+	}
+	//The body is optional, a request without one gets a link token without a user_token
+	if err := c.ShouldBindJSON(&recBody); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+	linkToken, err := plaidServices.CreateLinkToken(strconv.Itoa(principal(c).UserID), recBody.UserToken)
 	if err != nil {
 		renderError(c, err)
 		return
@@ -52,11 +84,93 @@ func createLinkToken(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"link_token": linkToken})
 }
 
+// Creates a link token that opens Link in update mode for a broken or expiring
+// item. Requires owner or manage access to the institution
+func createUpdateLinkToken(c *gin.Context) {
+	var recBody struct {
+		LinkedInstitutionID int  `json:"linked_institution_id"`
+		AccountSelection    bool `json:"account_selection"`
+	}
+	if err := c.ShouldBindJSON(&recBody); err != nil || recBody.LinkedInstitutionID == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "linked_institution_id is required"})
+		return
+	}
+	linkToken, err := accData.CreateUpdateLinkToken(principal(c).UserID, recBody.LinkedInstitutionID, recBody.AccountSelection)
+	switch {
+	case err == nil:
+		c.JSON(http.StatusOK, gin.H{"link_token": linkToken})
+	case errors.Is(err, accData.ErrInstitutionNotFound), errors.Is(err, accData.ErrManageAccessRequired):
+		renderPlaidItemError(c, err)
+	default:
+		renderError(c, err)
+	}
+}
+
+// Called once Link update mode finished. The item keeps its access token, so
+// there is no public token to exchange, the institutions accounts are refreshed
+// in place and its transactions synced
+func completeItemUpdate(c *gin.Context) {
+	var recBody struct {
+		LinkedInstitutionID int `json:"linked_institution_id"`
+	}
+	if err := c.ShouldBindJSON(&recBody); err != nil || recBody.LinkedInstitutionID == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "linked_institution_id is required"})
+		return
+	}
+	result, err := accData.CompleteItemUpdate(principal(c).UserID, recBody.LinkedInstitutionID)
+	switch {
+	case err == nil:
+		c.JSON(http.StatusOK, result)
+	case errors.Is(err, accData.ErrInstitutionNotFound), errors.Is(err, accData.ErrManageAccessRequired):
+		renderPlaidItemError(c, err)
+	default:
+		renderError(c, err)
+	}
+}
+
 func createUserToken(c *gin.Context) {
-	userToken, err := plaidServices.CreateUserToken()
+	userToken, err := plaidServices.CreateUserToken(strconv.Itoa(principal(c).UserID))
 	if err != nil {
 		renderError(c, err)
 		return
 	}
 	c.JSON(http.StatusOK, gin.H{"user_token": userToken})
 }
+
+// Receives Plaid webhooks. The Plaid-Verification signature is checked against the
+// raw body before anything is queued, the work itself is done by the webhook worker
+func plaidWebhook(c *gin.Context) {
+	body, err := io.ReadAll(io.LimitReader(c.Request.Body, maxWebhookBodyBytes))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Could not read webhook"})
+		return
+	}
+	if err := plaidServices.VerifyWebhook(body, c.GetHeader("Plaid-Verification")); err != nil {
+		log.Println("rejected plaid webhook:", err)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid webhook signature"})
+		return
+	}
+	webhook, err := plaidServices.ParseWebhook(body)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	queued, err := accData.EnqueuePlaidWebhook(body, webhook)
+	if err != nil {
+		//Plaid retries webhooks that are not answered with 200
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not queue webhook"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"queued": queued})
+}
+
+func renderPlaidItemError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, accData.ErrInstitutionNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, accData.ErrManageAccessRequired), errors.Is(err, accData.ErrOwnerRequired):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not load the linked institution"})
+	}
+}
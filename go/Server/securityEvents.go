@@ -0,0 +1,112 @@
+/*
+------------------------------------------------------------------
+FILE NAME:     securityEvents.go
+PROJECT:       CashflowAnalysis
+Date Created:  Oct-19-2026
+--------------------------------------------------------------------
+DESCRIPTION:
+Handles api calls for viewing the security event log. Users can see their
+own events, admins can filter across every user
+--------------------------------------------------------------------
+$HISTORY:
+
+Oct-19-2026   Initial file created.
+------------------------------------------------------------------
+*/
+package main
+
+import (
+	helper "cashflowanalysis/Services/Helpers"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Lists the logged in users own security events, newest first
+func listSecurityEvents(c *gin.Context) {
+	filter, err := securityEventFilter(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	filter.UserID = principal(c).UserID
+	filter.IPAddress = ""
+
+	events, err := helper.ListSecurityEvents(filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not load security events"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"events": events})
+}
+
+// Lists security events across all users. Filters: ?user_id= &type= &outcome= &ip= &from= &to=
+func adminListSecurityEvents(c *gin.Context) {
+	filter, err := securityEventFilter(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if userID := c.Query("user_id"); userID != "" {
+		filter.UserID, err = strconv.Atoi(userID)
+		if err != nil || filter.UserID <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user id"})
+			return
+		}
+	}
+
+	events, err := helper.ListSecurityEvents(filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not load security events"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"events": events})
+}
+
+// Reads the shared query string filters. from and to accept RFC 3339 times or dates (YYYY-MM-DD)
+func securityEventFilter(c *gin.Context) (helper.SecurityEventFilter, error) {
+	filter := helper.SecurityEventFilter{
+		EventType: c.Query("type"),
+		Outcome:   c.Query("outcome"),
+		IPAddress: c.Query("ip"),
+	}
+	filter.Limit, _ = strconv.Atoi(c.Query("limit"))
+	filter.Offset, _ = strconv.Atoi(c.Query("offset"))
+
+	if filter.EventType != "" {
+		valid := false
+		for _, t := range helper.SecurityEventTypes {
+			if t == filter.EventType {
+				valid = true
+			}
+		}
+		if !valid {
+			return filter, errors.New("unknown event type")
+		}
+	}
+	if filter.Outcome != "" && filter.Outcome != helper.OutcomeSuccess && filter.Outcome != helper.OutcomeFailure {
+		return filter, errors.New("outcome must be success or failure")
+	}
+
+	var err error
+	if filter.From, err = parseFilterTime(c.Query("from")); err != nil {
+		return filter, errors.New("invalid from time")
+	}
+	if filter.To, err = parseFilterTime(c.Query("to")); err != nil {
+		return filter, errors.New("invalid to time")
+	}
+	return filter, nil
+}
+
+func parseFilterTime(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	return time.Parse("2006-01-02", value)
+}
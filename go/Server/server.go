@@ -16,15 +16,47 @@ Dec-25-2025   Updated login() and signup(). Added logout() and checkAuthorizatio
 Jan-04-2025   Moved all plaid handlers and components, added /api/retrieve_user_account/
 Jan-06-2025   Added /api/SaveWidgetAccount/ with SaveWidgetAccount()
 Jan-28-2026   Moved all api methods to seperate files under the same package main
+Oct-19-2026   Added /api/login/mfa/ and /api/mfa/ two-factor authentication calls
+Oct-19-2026   Applies pending database migrations before serving
+Oct-19-2026   Added /api/change_password/, /api/forgot_password/ and /api/reset_password/
+Oct-19-2026   Added /api/sessions/ session management calls
+Oct-19-2026   Added csrfProtection() middleware and /api/csrf_token/
+Oct-19-2026   Protected routes now go through requireAuth(). Added /api/admin route group
+Oct-19-2026   Added /api/tokens/ personal access token calls. Data routes check token scopes,
+-             account management routes require a browser session
+Oct-19-2026   Added /api/oidc/ single sign-on calls
+Oct-19-2026   Added /api/account/delete/ calls and start the account deletion worker
+Oct-19-2026   Added /api/sharing/ household sharing calls
+Oct-19-2026   Added /api/security_events/ and /api/admin/security_events
+Oct-19-2026   Added /api/verify_email/ calls. Bank linking requires a verified email
+Oct-19-2026   Added /api/passkeys/ sign-in and passkey management calls
+Oct-19-2026   Added admin impersonation calls. Impersonation sessions are read-only
+Oct-19-2026   Added /api/transactions/sync/
+Oct-19-2026   Added /api/plaid/webhook and start the Plaid webhook worker
+Oct-19-2026   Added /api/create_update_link_token and /api/complete_item_update for Link update mode
+Oct-19-2026   Added /api/institutions/unlink/
+Oct-19-2026   Added /api/balances/history/ and start the balance refresh worker
+Oct-19-2026   Added /api/investments/ calls and start the investment sync worker
+Oct-19-2026   Added /api/liabilities/upcoming/ and start the liability sync worker
+Oct-19-2026   Added /api/recurring/ and /api/recurring/update/
+Oct-19-2026   Exits at startup when the Plaid client could not be set up
+Oct-19-2026   Exits at startup when COOKIE_SECRET_KEY is missing
 
 ------------------------------------------------------------------
 */
 package main
 
 import (
+	cookies "cashflowanalysis/CookieHandler"
+	plaidServices "cashflowanalysis/PlaidComponents"
+	services "cashflowanalysis/Services/DBContext"
+	userauth "cashflowanalysis/UserAuth"
+	accData "cashflowanalysis/UserBankAccountData"
 	"fmt"
+	"log"
 	"net/http"
 	"os"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/joho/godotenv"
@@ -33,6 +65,20 @@ import (
 
 var APP_PORT = ""
 
+const AccountDeletionInterval = 15 * time.Minute
+
+// Queued webhooks that failed are retried this often, new ones are processed right away
+const PlaidWebhookInterval = 5 * time.Minute
+
+// Items are refreshed from /accounts/balance/get this often, Plaid bills every call
+const BalanceRefreshInterval = 12 * time.Hour
+
+// Plaid updates holdings once a day, webhooks sync changes in between
+const InvestmentSyncInterval = 24 * time.Hour
+
+// Plaid refreshes liabilities about once a day, webhooks sync changes in between
+const LiabilitySyncInterval = 24 * time.Hour
+
 func init() {
 	// load env vars from .env file
 	err := godotenv.Load()
@@ -46,31 +92,146 @@ func init() {
 }
 
 func main() {
+	//The workers and handlers expect the current schema
+	if err := services.MigrateDB(); err != nil {
+		log.Fatal("could not migrate database: ", err)
+	}
+
+	//Plaid calls need a client, stop here instead of failing on the first request
+	if err := plaidServices.ClientSetupError(); err != nil {
+		log.Fatal(err)
+	}
+	//Every login sets encrypted cookies
+	if err := cookies.SetSecretKeyFromHex(); err != nil {
+		log.Fatal(err)
+	}
+
 	r := gin.Default()
+	r.Use(csrfProtection())
 
-	//Plaid Calls
-	r.POST("/api/info", info)
-	r.GET("/api/create_public_token", createPublicToken)
-	r.POST("/api/create_link_token", createLinkToken)
-	r.POST("/api/create_user_token", createUserToken)
+	//Erases accounts whose deletion grace period has ended
+	go userauth.RunAccountDeletionWorker(AccountDeletionInterval)
+	//Processes queued Plaid webhooks
+	go accData.RunPlaidWebhookWorker(PlaidWebhookInterval)
+	//Adds current balances to the balance history
+	go accData.RunBalanceRefreshWorker(BalanceRefreshInterval)
+	//Stores holdings snapshots and investment transactions
+	go accData.RunInvestmentSyncWorker(InvestmentSyncInterval)
+	//Stores credit card, student loan and mortgage details
+	go accData.RunLiabilitySyncWorker(LiabilitySyncInterval)
 
 	//User Account/Auth Calls
 	r.POST("/api/login/", login)
 	r.POST("/api/logout/", logout)
 	r.POST("/api/signup/", signup)
 	r.GET("/api/check_auth/", checkAuthorization)
+	r.GET("/api/csrf_token/", csrfToken)
+	r.POST("/api/login/mfa/", loginMFA)
+	r.POST("/api/forgot_password/", forgotPassword)
+	r.POST("/api/reset_password/", resetPassword)
+	r.POST("/api/verify_email/", verifyEmail)
+	r.GET("/api/oidc/login/", oidcLogin)
+	r.GET("/api/oidc/callback/", oidcCallback)
+	r.POST("/api/passkeys/login/begin/", beginPasskeyLogin)
+	r.POST("/api/passkeys/login/finish/", finishPasskeyLogin)
+	r.POST("/api/impersonation/end/", endImpersonation)
+	//Called by Plaid, authenticated by the Plaid-Verification signature
+	r.POST("/api/plaid/webhook", plaidWebhook)
+
+	//Everything below requires a logged in user or a personal access token
+	auth := r.Group("", requireAuth(), impersonationGuard())
+
+	//Routes reachable with a personal access token that has the matching scope
+	auth.GET("/api/retrieve_user_account/", requireScope(userauth.ScopeAccountsRead), RetrieveAccountData)
+	auth.GET("/api/all-transactions/", requireScope(userauth.ScopeTransactionsRead), GetAllTransactions)
+	auth.GET("/api/balances/history/", requireScope(userauth.ScopeAccountsRead), GetBalanceHistory)
+	auth.GET("/api/investments/holdings/", requireScope(userauth.ScopeAccountsRead), GetWidgetHoldings)
+	auth.GET("/api/investments/portfolio_value/", requireScope(userauth.ScopeAccountsRead), GetPortfolioValue)
+	auth.GET("/api/liabilities/upcoming/", requireScope(userauth.ScopeAccountsRead), GetUpcomingPayments)
+	auth.GET("/api/recurring/", requireScope(userauth.ScopeTransactionsRead), GetRecurringStreams)
+	auth.GET("/api/retrieveWidgets", requireScope(userauth.ScopeWidgetsRead), RetrieveWidgets)
+	auth.POST("/api/SaveWidgetAccount", requireScope(userauth.ScopeWidgetsWrite), SaveWidgetAccount)
+	auth.POST("/api/DeleteWidgetAccount", requireScope(userauth.ScopeWidgetsWrite), DeleteWidgetAccount)
+	auth.POST("/api/AddRowToWidgetBoard", requireScope(userauth.ScopeWidgetsWrite), AddRowToWidgetBoard)
+	auth.POST("/api/DeleteRowToWidgetBoard", requireScope(userauth.ScopeWidgetsWrite), DeleteRowToWidgetBoard)
+	auth.GET("/api/impersonation/", impersonationStatus)
+
+	//Everything below requires a browser session
+	session := auth.Group("", requireSession())
+
+	//Plaid Calls
+	session.POST("/api/info", info)
+	session.GET("/api/create_public_token", createPublicToken)
+	session.POST("/api/create_link_token", requireVerifiedEmail(), createLinkToken)
+	session.POST("/api/create_user_token", createUserToken)
+	session.POST("/api/create_update_link_token", createUpdateLinkToken)
+	session.POST("/api/complete_item_update", completeItemUpdate)
 
 	//User Bank Account Data Calls
-	r.POST("/api/save_user_account/", StoreAccountData)
-	r.GET("/api/retrieve_user_account/", RetrieveAccountData)
-	r.GET("/api/all-transactions/", GetAllTransactions)
-
-	//Widget Board Calls
-	r.POST("/api/SaveWidgetAccount", SaveWidgetAccount)
-	r.POST("/api/DeleteWidgetAccount", DeleteWidgetAccount)
-	r.POST("/api/AddRowToWidgetBoard", AddRowToWidgetBoard)
-	r.POST("/api/DeleteRowToWidgetBoard", DeleteRowToWidgetBoard)
-	r.GET("/api/retrieveWidgets", RetrieveWidgets)
+	session.POST("/api/save_user_account/", requireVerifiedEmail(), StoreAccountData)
+	session.POST("/api/transactions/sync/", SyncTransactions)
+	session.POST("/api/institutions/unlink/", UnlinkInstitution)
+	session.POST("/api/recurring/update/", UpdateRecurringStream)
+
+	session.POST("/api/change_password/", changePassword)
+
+	//Email Verification Calls
+	session.GET("/api/verify_email/", emailVerificationStatus)
+	session.POST("/api/verify_email/resend/", resendVerificationEmail)
+
+	//Session Management Calls
+	session.GET("/api/sessions/", listSessions)
+	session.POST("/api/sessions/revoke/", revokeSession)
+	session.POST("/api/sessions/revoke_others/", revokeOtherSessions)
+
+	//Two-Factor Authentication Calls
+	session.POST("/api/mfa/totp/enroll/", enrollTOTP)
+	session.POST("/api/mfa/totp/confirm/", confirmTOTP)
+	session.POST("/api/mfa/totp/disable/", disableTOTP)
+	session.POST("/api/mfa/recovery_codes/", regenerateRecoveryCodes)
+
+	session.GET("/api/security_events/", listSecurityEvents)
+
+	//Household Sharing Calls
+	session.GET("/api/sharing/", listGrants)
+	session.POST("/api/sharing/invite/", inviteToInstitution)
+	session.POST("/api/sharing/respond/", respondToGrant)
+	session.POST("/api/sharing/revoke/", revokeGrant)
+
+	//Account Deletion Calls
+	session.GET("/api/account/delete/", accountDeletionStatus)
+	session.POST("/api/account/delete/", deleteAccount)
+	session.POST("/api/account/delete/cancel/", cancelAccountDeletion)
+
+	//Single Sign-On Identity Calls
+	session.GET("/api/oidc/identities/", listIdentities)
+	session.POST("/api/oidc/link/", oidcLink)
+
+	//Passkey Calls
+	session.GET("/api/passkeys/", listPasskeys)
+	session.POST("/api/passkeys/register/begin/", beginPasskeyRegistration)
+	session.POST("/api/passkeys/register/finish/", finishPasskeyRegistration)
+	session.POST("/api/passkeys/rename/", renamePasskey)
+	session.POST("/api/passkeys/remove/", removePasskey)
+
+	//Personal Access Token Calls
+	session.GET("/api/tokens/", listAccessTokens)
+	session.POST("/api/tokens/", createAccessToken)
+	session.POST("/api/tokens/revoke/", revokeAccessToken)
+
+	//Admin Calls, support staff can view and admins can make changes
+	support := session.Group("/api/admin", requireRole(userauth.RoleSupport))
+	support.GET("/users", adminListUsers)
+	support.GET("/users/:id", adminGetUser)
+	support.GET("/users/:id/institutions", adminUserInstitutions)
+
+	admin := session.Group("/api/admin", requireRole(userauth.RoleAdmin))
+	admin.POST("/users/:id/deactivate", adminDeactivateUser)
+	admin.POST("/users/:id/reactivate", adminReactivateUser)
+	admin.POST("/users/:id/logout", adminForceLogout)
+	admin.POST("/users/:id/role", adminSetUserRole)
+	admin.GET("/security_events", adminListSecurityEvents)
+	admin.POST("/users/:id/impersonate", adminImpersonateUser)
 
 	err := r.Run(":" + APP_PORT)
 	if err != nil {
@@ -0,0 +1,70 @@
+/*
+------------------------------------------------------------------
+FILE NAME:     sessionManagement.go
+PROJECT:       CashflowAnalysis
+Date Created:  Oct-19-2026
+--------------------------------------------------------------------
+DESCRIPTION:
+Handles api calls for listing and revoking the users sessions
+--------------------------------------------------------------------
+$HISTORY:
+
+Oct-19-2026   Initial file created.
+------------------------------------------------------------------
+*/
+package main
+
+import (
+	userauth "cashflowanalysis/UserAuth"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Lists the active sessions of the logged in user with device, IP and user agent
+func listSessions(c *gin.Context) {
+	sessions, err := userauth.ListActiveSessions(c.Request)
+	if err != nil {
+		renderSessionError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"sessions": sessions})
+}
+
+// Revokes one of the logged in users sessions
+func revokeSession(c *gin.Context) {
+	var recBody struct {
+		SessionId int `json:"session_id"`
+	}
+	if err := c.ShouldBindJSON(&recBody); err != nil {
+		renderError(c, err)
+		return
+	}
+	if err := userauth.RevokeSession(c.Request, recBody.SessionId); err != nil {
+		renderSessionError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Session revoked"})
+}
+
+// Revokes every session of the logged in user except the current one
+func revokeOtherSessions(c *gin.Context) {
+	revoked, err := userauth.RevokeOtherSessions(c.Request)
+	if err != nil {
+		renderSessionError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Other sessions revoked", "revoked": revoked})
+}
+
+func renderSessionError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, userauth.ErrNotAuthorized):
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Not authorized"})
+	case errors.Is(err, userauth.ErrSessionNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not update sessions"})
+	}
+}
@@ -0,0 +1,114 @@
+/*
+------------------------------------------------------------------
+FILE NAME:     sharing.go
+PROJECT:       CashflowAnalysis
+Date Created:  Oct-19-2026
+--------------------------------------------------------------------
+DESCRIPTION:
+Handles api calls for sharing linked institutions and accounts with
+other users (household sharing)
+--------------------------------------------------------------------
+$HISTORY:
+
+Oct-19-2026   Initial file created.
+------------------------------------------------------------------
+*/
+package main
+
+import (
+	accData "cashflowanalysis/UserBankAccountData"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Lists the grants the user has given and the grants and invitations they have received
+func listGrants(c *gin.Context) {
+	userID := principal(c).UserID
+	given, err := accData.ListGrantsGiven(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not load shared access"})
+		return
+	}
+	received, err := accData.ListGrantsReceived(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not load shared access"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"given": given, "received": received})
+}
+
+// Invites another user to view or manage one of the users institutions or some of its accounts
+func inviteToInstitution(c *gin.Context) {
+	var recBody struct {
+		Invitee             string `json:"invitee"`
+		LinkedInstitutionID int    `json:"linked_institution_id"`
+		AccountIDs          []int  `json:"account_ids"`
+		AccessLevel         string `json:"access_level"`
+	}
+	if err := c.ShouldBindJSON(&recBody); err != nil {
+		renderError(c, err)
+		return
+	}
+	grants, err := accData.InviteToInstitution(principal(c).UserID, recBody.Invitee, recBody.LinkedInstitutionID, recBody.AccountIDs, recBody.AccessLevel)
+	if err != nil {
+		renderSharingError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"message": "Invitation sent", "grants": grants})
+}
+
+// Accepts or declines an invitation sent to the user
+func respondToGrant(c *gin.Context) {
+	var recBody struct {
+		GrantId int  `json:"grant_id"`
+		Accept  bool `json:"accept"`
+	}
+	if err := c.ShouldBindJSON(&recBody); err != nil {
+		renderError(c, err)
+		return
+	}
+	if err := accData.RespondToGrant(principal(c).UserID, recBody.GrantId, recBody.Accept); err != nil {
+		renderSharingError(c, err)
+		return
+	}
+	if recBody.Accept {
+		c.JSON(http.StatusOK, gin.H{"message": "Invitation accepted"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Invitation declined"})
+}
+
+// Revokes a grant the user gave or received. Takes effect on the next request
+func revokeGrant(c *gin.Context) {
+	var recBody struct {
+		GrantId int `json:"grant_id"`
+	}
+	if err := c.ShouldBindJSON(&recBody); err != nil {
+		renderError(c, err)
+		return
+	}
+	if err := accData.RevokeGrant(principal(c).UserID, recBody.GrantId); err != nil {
+		renderSharingError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Access revoked"})
+}
+
+func renderSharingError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, accData.ErrInstitutionNotFound),
+		errors.Is(err, accData.ErrAccountNotFound),
+		errors.Is(err, accData.ErrInviteeNotFound),
+		errors.Is(err, accData.ErrGrantNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, accData.ErrInvalidAccessLevel),
+		errors.Is(err, accData.ErrCannotShareWithSelf):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, accData.ErrGrantExists):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not update shared access"})
+	}
+}
@@ -10,32 +10,45 @@ Handles api calls for user authentication and authorization
 $HISTORY:
 
 Jan-28-2026   Initial file created.
+Oct-19-2026   login() responds with mfa_required when a second factor is needed. Added loginMFA()
+Oct-19-2026   signup() accepts an email. Added changePassword(), forgotPassword() and resetPassword()
+Oct-19-2026   login() accepts remember_me. checkAuthorization() reports sessions that timed out as unauthorized
+Oct-19-2026   signup() requires an email and sends a verification link
+Oct-19-2026   signup() reports a password that is too short as a bad request
 ------------------------------------------------------------------
 */
 package main
 
 import (
 	userauth "cashflowanalysis/UserAuth"
+	"errors"
+	"log"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
 )
 
 // Creates a new user and store their credentials in the database
-// Authorizes user on sign up
+// Authorizes user on sign up. A verification link is emailed to the user
 func signup(c *gin.Context) {
 	var recBody struct {
 		Username string `json:"username"`
+		Email    string `json:"email"`
 		Password string `json:"password"`
 	}
 	if err := c.ShouldBindJSON(&recBody); err != nil {
 		renderError(c, err)
 		return
 	}
-	userauth.CreateNewUser(recBody.Username, recBody.Password)
+	err := userauth.CreateNewUser(recBody.Username, recBody.Email, recBody.Password)
+	if errors.Is(err, userauth.ErrInvalidEmail) || errors.Is(err, userauth.ErrEmailRequired) || errors.Is(err, userauth.ErrEmailInUse) ||
+		errors.Is(err, userauth.ErrWeakPassword) {
+		c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
 
-	if userauth.AuthorizeUser(c.Writer, recBody.Username, recBody.Password) {
-		c.JSON(http.StatusOK, gin.H{"message": "Signup successful"})
+	if userauth.AuthorizeUser(c.Request, c.Writer, recBody.Username, recBody.Password, false) == userauth.AuthSucceeded {
+		c.JSON(http.StatusOK, gin.H{"message": "Signup successful", "email_verification_required": true})
 	} else {
 		c.JSON(http.StatusUnauthorized, gin.H{"message": "Signup failed"})
 	}
@@ -45,14 +58,35 @@ func signup(c *gin.Context) {
 // Authorizes user on login
 func login(c *gin.Context) {
 	var recBody struct {
-		Username string `json:"username"`
-		Password string `json:"password"`
+		Username   string `json:"username"`
+		Password   string `json:"password"`
+		RememberMe bool   `json:"remember_me"`
+	}
+	if err := c.ShouldBindJSON(&recBody); err != nil {
+		renderError(c, err)
+		return
+	}
+	switch userauth.AuthorizeUser(c.Request, c.Writer, recBody.Username, recBody.Password, recBody.RememberMe) {
+	case userauth.AuthSucceeded:
+		c.JSON(http.StatusOK, gin.H{"message": "Login successful"})
+	case userauth.AuthMFARequired:
+		c.JSON(http.StatusOK, gin.H{"message": "Two-factor code required", "mfa_required": true})
+	default:
+		c.JSON(http.StatusUnauthorized, gin.H{"message": "Login failed"})
+	}
+}
+
+// Second login step for users with 2FA enabled. Accepts a TOTP code or a recovery code
+// and requires the pending-MFA cookie issued by login()
+func loginMFA(c *gin.Context) {
+	var recBody struct {
+		Code string `json:"code"`
 	}
 	if err := c.ShouldBindJSON(&recBody); err != nil {
 		renderError(c, err)
 		return
 	}
-	if userauth.AuthorizeUser(c.Writer, recBody.Username, recBody.Password) {
+	if userauth.CompleteMFALogin(c.Request, c.Writer, recBody.Code) {
 		c.JSON(http.StatusOK, gin.H{"message": "Login successful"})
 	} else {
 		c.JSON(http.StatusUnauthorized, gin.H{"message": "Login failed"})
@@ -78,14 +112,72 @@ func logout(c *gin.Context) {
 // Review the cookie from the client side to check if its still active
 func checkAuthorization(c *gin.Context) {
 	authorized, err := userauth.CheckUserAuthorization(c.Request, c.Writer)
-	if err != nil {
+	if err != nil || !authorized {
 		c.JSON(http.StatusUnauthorized, gin.H{"authorized": false})
 		return
-	} else {
-		if authorized {
-			c.JSON(http.StatusOK, gin.H{"authorized": true})
-		} else {
-			c.JSON(http.StatusOK, gin.H{"authorized": true})
-		}
+	}
+	c.JSON(http.StatusOK, gin.H{"authorized": true})
+}
+
+// Changes the password of the logged in user and logs out their other sessions
+func changePassword(c *gin.Context) {
+	var recBody struct {
+		CurrentPassword string `json:"current_password"`
+		NewPassword     string `json:"new_password"`
+	}
+	if err := c.ShouldBindJSON(&recBody); err != nil {
+		renderError(c, err)
+		return
+	}
+
+	err := userauth.ChangePassword(c.Request, recBody.CurrentPassword, recBody.NewPassword)
+	switch {
+	case err == nil:
+		c.JSON(http.StatusOK, gin.H{"message": "Password changed"})
+	case errors.Is(err, userauth.ErrNotAuthorized), errors.Is(err, userauth.ErrInvalidCredentials):
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Current password is incorrect"})
+	case errors.Is(err, userauth.ErrWeakPassword):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not change password"})
+	}
+}
+
+// Sends a password reset email. Always responds the same way so it can't be used
+// to find out which usernames or emails exist
+func forgotPassword(c *gin.Context) {
+	var recBody struct {
+		Username string `json:"username"`
+	}
+	if err := c.ShouldBindJSON(&recBody); err != nil {
+		renderError(c, err)
+		return
+	}
+
+	if err := userauth.RequestPasswordReset(recBody.Username); err != nil {
+		log.Println("password reset request failed:", err)
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "If an account with that username or email exists, a reset link has been sent"})
+}
+
+// Sets a new password with the token from the reset email
+func resetPassword(c *gin.Context) {
+	var recBody struct {
+		Token       string `json:"token"`
+		NewPassword string `json:"new_password"`
+	}
+	if err := c.ShouldBindJSON(&recBody); err != nil {
+		renderError(c, err)
+		return
+	}
+
+	err := userauth.ResetPassword(c.Request, recBody.Token, recBody.NewPassword)
+	switch {
+	case err == nil:
+		c.JSON(http.StatusOK, gin.H{"message": "Password reset"})
+	case errors.Is(err, userauth.ErrInvalidResetToken), errors.Is(err, userauth.ErrWeakPassword):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not reset password"})
 	}
 }
@@ -10,6 +10,8 @@ Handles api calls for widget board operations
 $HISTORY:
 
 Jan-28-2026   Initial file created.
+Oct-19-2026   SaveWidgetAccount() rejects accounts the user has no access to
+Oct-19-2026   Widget and row changes are limited to the users own widget board, other boards get a 404
 ------------------------------------------------------------------
 */
 package main
@@ -18,6 +20,7 @@ import (
 	services "cashflowanalysis/Services/DBContext"
 	accData "cashflowanalysis/UserBankAccountData"
 	"encoding/json"
+	"errors"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
@@ -45,7 +48,15 @@ func SaveWidgetAccount(c *gin.Context) {
 		})
 	}
 
-	err := accData.SaveWidgetData(body.WidgetID, body.WidgetType, liAccs)
+	err := accData.SaveWidgetData(principal(c).UserID, body.WidgetID, body.WidgetType, liAccs)
+	if errors.Is(err, accData.ErrAccountNotFound) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You do not have access to one or more of those accounts."})
+		return
+	}
+	if errors.Is(err, accData.ErrWidgetNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not save account to widget."})
 		return
@@ -64,7 +75,11 @@ func DeleteWidgetAccount(c *gin.Context) {
 		return
 	}
 
-	err := accData.DeleteWidgetData(body.WidgetID)
+	err := accData.DeleteWidgetData(principal(c).UserID, body.WidgetID)
+	if errors.Is(err, accData.ErrWidgetNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not delete account from widget."})
 		return
@@ -96,9 +111,14 @@ func AddRowToWidgetBoard(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "No data for rows received"})
 		return
 	}
-	success := accData.CreateWidgetRow(&body.Board.WidgetBoardRows[0])
-	if !success {
+	err := accData.CreateWidgetRow(principal(c).UserID, &body.Board.WidgetBoardRows[0])
+	if errors.Is(err, accData.ErrWidgetBoardNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not add row to widget."})
+		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{"message": "Added row to widget successfully.", "ReturnedRow": body.Board.WidgetBoardRows[0]})
@@ -113,9 +133,14 @@ func DeleteRowToWidgetBoard(c *gin.Context) {
 		return
 	}
 
-	success := accData.DeleteWidgetRow(body.RowID)
-	if !success {
+	err := accData.DeleteWidgetRow(principal(c).UserID, body.RowID)
+	if errors.Is(err, accData.ErrWidgetRowNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not delete row from widget."})
+		return
 	}
 	c.JSON(http.StatusOK, gin.H{"message": "Deleted row from widget successfully."})
 }
@@ -19,6 +19,14 @@ Jan-28-2026   Added UpdateObjectDB(), changed parameters to accept maps of colum
 
 	and added map for conditions
 
+Oct-19-2026   Added QueryObjectDB() for filtered/ordered loads. Moved row scanning to scanRows()
+Oct-19-2026   Added RunInTransactionDB() and TxDB so several changes can be committed together.
+-             The CRUD functions run against either the connection pool or a transaction
+Oct-19-2026   Added QueryObjectTx()
+Oct-19-2026   Added EraseRowsTx(), moved from the account deletion code so every erasure shares it
+Oct-19-2026   initializeDB() opens the connection pool once and reuses it on later calls
+Oct-19-2026   Added TakeObjectDB() for single use rows
+Oct-19-2026   Added UpdateWhereDB() for conditional updates
 ------------------------------------------------------------------
 */
 package services
@@ -30,6 +38,7 @@ import (
 	"os"
 	"reflect"
 	"strings"
+	"sync"
 
 	"github.com/microsoft/go-mssqldb/azuread"
 )
@@ -37,9 +46,28 @@ import (
 var db *sql.DB
 var DATABASE_CONNECTION = ""
 
-// Creates connection to the azure sql database
+// Makes sure the connection pool is only opened once
+var dbOnce sync.Once
+
+// The parts of *sql.DB and *sql.Tx used by the CRUD functions
+type dbExecutor interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
+// A database transaction started by RunInTransactionDB
+type TxDB struct {
+	tx *sql.Tx
+}
+
+// Creates connection to the azure sql database on the first call. Every call
+// after that reuses the same connection pool
 func initializeDB() {
+	dbOnce.Do(openDB)
+}
 
+func openDB() {
 	DATABASE_CONNECTION = os.Getenv("DATABASE_CONNECTION")
 	if DATABASE_CONNECTION == "" {
 		panic("DATABASE_CONNECTION not set in environment")
@@ -66,11 +94,14 @@ func initializeDB() {
 
 // Creates a new row of data for the given "table" interface
 func CreateObjectDB(entity interface{}) (int, error) {
+	initializeDB()
+	return createObject(db, entity)
+}
+
+func createObject(ex dbExecutor, entity interface{}) (int, error) {
 	ctx := context.Background()
 	var err error
 
-	initializeDB()
-
 	tableName, fields, err := InspectInterface(entity)
 	if err != nil {
 		return -1, err
@@ -103,15 +134,8 @@ func CreateObjectDB(entity interface{}) (int, error) {
       SELECT ISNULL(CAST(SCOPE_IDENTITY() AS INT), -1);
     `, tableName, strings.Join(fieldNames, ","), strings.Join(placeholders, ","))
 
-	//Prepare sql connection
-	stmt, err := db.Prepare(tsql)
-	if err != nil {
-		return -1, err
-	}
-	defer stmt.Close()
-
 	//Call database to store
-	row := stmt.QueryRowContext(ctx, args...)
+	row := ex.QueryRowContext(ctx, tsql, args...)
 	var newID int
 	err = row.Scan(&newID)
 	if err != nil {
@@ -123,15 +147,16 @@ func CreateObjectDB(entity interface{}) (int, error) {
 
 // Loads one row of data dependant on the conditions given
 func LoadObjectDB[T any](entity *T, conditions ...string) ([]T, error) {
-
-	ctx := context.Background()
-	var result []T
-
 	if len(conditions) == 0 {
-		return result, fmt.Errorf("LoadObjectDB: at least one condition field must be specified")
+		return nil, fmt.Errorf("LoadObjectDB: at least one condition field must be specified")
 	}
-
 	initializeDB()
+	return loadObject(db, entity, conditions...)
+}
+
+func loadObject[T any](ex dbExecutor, entity *T, conditions ...string) ([]T, error) {
+	ctx := context.Background()
+	var result []T
 
 	tableName, fields, err := InspectInterface(entity)
 	if err != nil {
@@ -158,12 +183,58 @@ func LoadObjectDB[T any](entity *T, conditions ...string) ([]T, error) {
 	tsql := fmt.Sprintf("SELECT %s FROM %s WHERE %s;", strings.Join(fieldNames, ","), tableName, whereString)
 
 	//prepare sql connection
-	rows, err := db.QueryContext(ctx, tsql, args...)
+	rows, err := ex.QueryContext(ctx, tsql, args...)
 	if err != nil {
 		return result, err
 	}
 	defer rows.Close()
 
+	return scanRows(rows, entity, fieldNames)
+}
+
+// Loads rows matching a custom where clause. The clause may also contain ORDER BY
+// and OFFSET/FETCH and must only reference values through named parameters,
+// e.g. QueryObjectDB(&DB_Users{}, "Username LIKE @Search ORDER BY UserId", sql.Named("Search", "a%"))
+func QueryObjectDB[T any](entity *T, clause string, args ...interface{}) ([]T, error) {
+	if strings.TrimSpace(clause) == "" {
+		return nil, fmt.Errorf("QueryObjectDB: a where clause must be specified")
+	}
+
+	initializeDB()
+	return queryObject(db, entity, clause, args...)
+}
+
+func queryObject[T any](ex dbExecutor, entity *T, clause string, args ...interface{}) ([]T, error) {
+	ctx := context.Background()
+	var result []T
+
+	tableName, fields, err := InspectInterface(entity)
+	if err != nil {
+		return result, err
+	}
+
+	var fieldNames []string
+	for _, field := range fields {
+		if hasDBTag(entity, field.Name) {
+			fieldNames = append(fieldNames, field.Name)
+		}
+	}
+
+	tsql := fmt.Sprintf("SELECT %s FROM %s WHERE %s;", strings.Join(fieldNames, ","), tableName, clause)
+
+	rows, err := ex.QueryContext(ctx, tsql, args...)
+	if err != nil {
+		return result, err
+	}
+	defer rows.Close()
+
+	return scanRows(rows, entity, fieldNames)
+}
+
+// Scans each row into a new T, fieldNames must be in the same order as the selected columns
+func scanRows[T any](rows *sql.Rows, entity *T, fieldNames []string) ([]T, error) {
+	var result []T
+
 	// Ensure caller passed a pointer-to-struct type for entity
 	entityType := reflect.TypeOf(entity)
 	if entityType.Kind() != reflect.Ptr || entityType.Elem().Kind() != reflect.Struct {
@@ -198,19 +269,20 @@ func LoadObjectDB[T any](entity *T, conditions ...string) ([]T, error) {
 		//return out, nil
 	}
 
-	return result, nil
+	return result, rows.Err()
 }
 
 // Updates one row of data based on the conditions given
 func UpdateObjectDB(entity interface{}, setValues []string, conditions []string) error {
-
-	ctx := context.Background()
-
 	if len(conditions) == 0 {
 		return fmt.Errorf("UpdateObjectDB: at least one condition field must be specified")
 	}
-
 	initializeDB()
+	return updateObject(db, entity, setValues, conditions)
+}
+
+func updateObject(ex dbExecutor, entity interface{}, setValues []string, conditions []string) error {
+	ctx := context.Background()
 
 	tableName, fields, err := InspectInterface(entity)
 	if err != nil {
@@ -260,26 +332,73 @@ func UpdateObjectDB(entity interface{}, setValues []string, conditions []string)
 	tsql := fmt.Sprintf("UPDATE %s SET %s WHERE %s;", tableName, strings.Join(fieldNamesFinal, ","), whereString)
 
 	//Call sql database
-	_, err = db.ExecContext(ctx, tsql, args...)
+	_, err = ex.ExecContext(ctx, tsql, args...)
 	if err != nil {
 		return err
 	}
 	return nil
 }
 
-// Deletes a row of data based on the conditions given
-func DeleteObjectDB(entity interface{}, conditions ...string) error {
+// Updates the setValues fields of the rows matching a custom where clause and
+// returns how many rows changed. Every field of the entity can be referenced in
+// the clause as @FieldName, other values must be passed as named parameters.
+// Used for updates only one of several racing callers may make, e.g.
+// UpdateWhereDB(code, []string{"UsedAt"}, "RecoveryCodeId = @RecoveryCodeId AND UsedAt IS NULL")
+func UpdateWhereDB(entity interface{}, setValues []string, clause string, args ...interface{}) (int64, error) {
+	if len(setValues) == 0 || strings.TrimSpace(clause) == "" {
+		return 0, fmt.Errorf("UpdateWhereDB: set fields and a where clause must be specified")
+	}
+	initializeDB()
+	return updateWhere(db, entity, setValues, clause, args...)
+}
+
+func updateWhere(ex dbExecutor, entity interface{}, setValues []string, clause string, args ...interface{}) (int64, error) {
 	ctx := context.Background()
 
+	tableName, fields, err := InspectInterface(entity)
+	if err != nil {
+		return 0, err
+	}
+	idTag, _ := FieldNameByDBTag(entity, "id")
+
+	var sets []string
+	params := make([]interface{}, 0, len(fields)+len(args))
+	for _, field := range fields {
+		if !hasDBTag(entity, field.Name) {
+			continue
+		}
+		if field.Name != idTag && contains(setValues, field.Name) {
+			sets = append(sets, fmt.Sprintf("%s = @%s", field.Name, field.Name))
+		}
+		params = append(params, sql.Named(field.Name, field.Value))
+	}
+	params = append(params, args...)
+
+	tsql := fmt.Sprintf("UPDATE %s SET %s WHERE %s;", tableName, strings.Join(sets, ","), clause)
+
+	result, err := ex.ExecContext(ctx, tsql, params...)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// Deletes a row of data based on the conditions given
+func DeleteObjectDB(entity interface{}, conditions ...string) error {
 	if len(conditions) == 0 {
 		return fmt.Errorf("UpdateObjectDB: at least one condition field must be specified")
 	}
-
 	initializeDB()
+	_, err := deleteObject(db, entity, conditions...)
+	return err
+}
+
+func deleteObject(ex dbExecutor, entity interface{}, conditions ...string) (int64, error) {
+	ctx := context.Background()
 
 	tableName, fields, err := InspectInterface(entity)
 	if err != nil {
-		return err
+		return 0, err
 	}
 
 	fieldNames := make([]string, len(fields))
@@ -302,9 +421,111 @@ func DeleteObjectDB(entity interface{}, conditions ...string) error {
 	tsql := fmt.Sprintf("DELETE FROM %s WHERE %s;", tableName, whereString)
 
 	//Call sql database
-	_, err = db.ExecContext(ctx, tsql, args...)
+	result, err := ex.ExecContext(ctx, tsql, args...)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// Loads the rows matching the conditions and deletes them in one transaction.
+// When two callers race for the same rows only the one whose delete removes
+// them gets them back, the other gets none
+func TakeObjectDB[T any](entity *T, conditions ...string) ([]T, error) {
+	if len(conditions) == 0 {
+		return nil, fmt.Errorf("TakeObjectDB: at least one condition field must be specified")
+	}
+	var taken []T
+	err := RunInTransactionDB(func(tx *TxDB) error {
+		rows, err := loadObject(tx.tx, entity, conditions...)
+		if err != nil || len(rows) == 0 {
+			return err
+		}
+		deleted, err := deleteObject(tx.tx, *entity, conditions...)
+		if err != nil {
+			return err
+		}
+		if deleted == int64(len(rows)) {
+			taken = rows
+		}
+		return nil
+	})
+	return taken, err
+}
+
+// Runs fn inside a database transaction. The transaction is committed when fn
+// returns nil and rolled back when it returns an error or panics
+func RunInTransactionDB(fn func(tx *TxDB) error) (err error) {
+	initializeDB()
+	tx, err := db.BeginTx(context.Background(), nil)
 	if err != nil {
 		return err
 	}
+	defer func() {
+		if p := recover(); p != nil {
+			_ = tx.Rollback()
+			panic(p)
+		}
+	}()
+
+	if err = fn(&TxDB{tx: tx}); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// CreateObjectDB() inside the transaction
+func (t *TxDB) CreateObject(entity interface{}) (int, error) {
+	return createObject(t.tx, entity)
+}
+
+// UpdateObjectDB() inside the transaction
+func (t *TxDB) UpdateObject(entity interface{}, setValues []string, conditions []string) error {
+	if len(conditions) == 0 {
+		return fmt.Errorf("UpdateObjectDB: at least one condition field must be specified")
+	}
+	return updateObject(t.tx, entity, setValues, conditions)
+}
+
+// DeleteObjectDB() inside the transaction
+func (t *TxDB) DeleteObject(entity interface{}, conditions ...string) error {
+	if len(conditions) == 0 {
+		return fmt.Errorf("UpdateObjectDB: at least one condition field must be specified")
+	}
+	_, err := deleteObject(t.tx, entity, conditions...)
+	return err
+}
+
+// LoadObjectDB() inside the transaction, so rows written earlier in it are seen
+func LoadObjectTx[T any](tx *TxDB, entity *T, conditions ...string) ([]T, error) {
+	if len(conditions) == 0 {
+		return nil, fmt.Errorf("LoadObjectDB: at least one condition field must be specified")
+	}
+	return loadObject(tx.tx, entity, conditions...)
+}
+
+// QueryObjectDB() inside the transaction
+func QueryObjectTx[T any](tx *TxDB, entity *T, clause string, args ...interface{}) ([]T, error) {
+	if strings.TrimSpace(clause) == "" {
+		return nil, fmt.Errorf("QueryObjectDB: a where clause must be specified")
+	}
+	return queryObject(tx.tx, entity, clause, args...)
+}
+
+// Deletes the rows matching the conditions inside the transaction and adds how
+// many there were to counts under table
+func EraseRowsTx[T any](tx *TxDB, counts map[string]int, table string, entity *T, conditions ...string) error {
+	rows, err := LoadObjectTx(tx, entity, conditions...)
+	if err != nil {
+		return err
+	}
+	if len(rows) == 0 {
+		return nil
+	}
+	if err := tx.DeleteObject(*entity, conditions...); err != nil {
+		return err
+	}
+	counts[table] += len(rows)
 	return nil
 }
@@ -18,6 +18,34 @@ Jan-28-2026   Added DB_WidgetBoard{}, DB_WidgetBoardRows{}, DB_Widgets{}, DB_Wid
 	Deleted DB_UserWidgets{}
 	Also added `db` tags to all structs for mapping purposes
 
+Oct-19-2026   Added TOTP columns to DB_Users{}. Added DB_RecoveryCodes{} and DB_MFAChallenges{}
+Oct-19-2026   Added Email to DB_Users{}. Added DB_PasswordResetTokens{}
+Oct-19-2026   Added IPAddress, UserAgent and DeviceName to DB_Sessions{}
+Oct-19-2026   Added Role and DisabledAt to DB_Users{}
+Oct-19-2026   Added DB_PersonalAccessTokens{}
+Oct-19-2026   Added DB_UserIdentities{}
+Oct-19-2026   Added DB_AccountDeletions{}
+Oct-19-2026   Added DB_InstitutionGrants{}
+Oct-19-2026   Added DB_SecurityEvents{}
+Oct-19-2026   Added LastSeenAt, AbsoluteExpiresAt and RememberMe to DB_Sessions{}. Added RememberMe to DB_MFAChallenges{}
+Oct-19-2026   Added email verification columns to DB_Users{}
+Oct-19-2026   Added DB_WebAuthnCredentials{} and WebAuthnUserHandle to DB_Users{}
+Oct-19-2026   Added ImpersonatorUserId and ImpersonationReason to DB_Sessions{}
+Oct-19-2026   Added DB_Transactions{}, the transactions sync cursor to DB_LinkedInstitutions{} and
+-             PlaidAccountID to DB_LinkedAccounts{}
+Oct-19-2026   Added DB_PlaidWebhooks{}
+Oct-19-2026   Added item health columns to DB_LinkedInstitutions{}
+Oct-19-2026   Added DB_BalanceSnapshots{} and BalancesRefreshedAt to DB_LinkedInstitutions{}
+Oct-19-2026   Added DB_Securities{}, DB_Holdings{} and DB_InvestmentTransactions{}. Added Products and
+-             InvestmentsSyncedAt to DB_LinkedInstitutions{}
+Oct-19-2026   Added DB_CreditCardLiabilities{}, DB_CreditCardAPRs{}, DB_StudentLoans{} and DB_Mortgages{}.
+-             Added LiabilitiesSyncedAt to DB_LinkedInstitutions{}
+Oct-19-2026   Added DB_RecurringStreams{}
+Oct-19-2026   Added ClaimedAt to DB_PlaidWebhooks{}
+Oct-19-2026   Added DB_WebAuthnChallenges{}
+Oct-19-2026   Added CSRFTokenHash to DB_Sessions{}
+Oct-19-2026   Added MFAFailedAttempts and MFALockedUntil to DB_Users{}
+
 ------------------------------------------------------------------
 */
 package services
@@ -33,26 +61,209 @@ type DB_Sessions struct {
 	CreatedAt time.Time    `db:"CreatedAt"`
 	ExpiresAt time.Time    `db:"ExpiresAt"`
 	RevokedAt sql.NullTime `db:"RevokedAt"`
+	//Captured at login so users can recognise their sessions, NULL for sessions created before
+	IPAddress  sql.NullString `db:"IPAddress"`
+	UserAgent  sql.NullString `db:"UserAgent"`
+	DeviceName sql.NullString `db:"DeviceName"`
+	//ExpiresAt is the idle deadline and moves with activity, AbsoluteExpiresAt never moves
+	LastSeenAt        time.Time `db:"LastSeenAt"`
+	AbsoluteExpiresAt time.Time `db:"AbsoluteExpiresAt"`
+	RememberMe        bool      `db:"RememberMe"`
+	//Set on read-only sessions an admin opened as this user, 0 for the users own sessions
+	ImpersonatorUserId  int            `db:"ImpersonatorUserId"`
+	ImpersonationReason sql.NullString `db:"ImpersonationReason"`
+	//SHA-256 of the random CSRF token issued with the session cookie
+	CSRFTokenHash sql.NullString `db:"CSRFTokenHash"`
 }
 
 type DB_Users struct {
-	UserId       int       `db:"id"`
-	Username     string    `db:"Username"`
-	PasswordHash string    `db:"PasswordHash"`
-	IsActive     bool      `db:"IsActive"`
-	CreatedAt    time.Time `db:"CreatedAt"`
-	UpdatedAt    time.Time `db:"UpdatedAt"`
+	UserId       int            `db:"id"`
+	Username     string         `db:"Username"`
+	Email        sql.NullString `db:"Email"`
+	PasswordHash string         `db:"PasswordHash"`
+	IsActive     bool           `db:"IsActive"`
+	CreatedAt    time.Time      `db:"CreatedAt"`
+	UpdatedAt    time.Time      `db:"UpdatedAt"`
+	//TOTP secret is stored encrypted, TOTPLastUsedStep prevents a code being replayed
+	TOTPSecret       sql.NullString `db:"TOTPSecret"`
+	TOTPEnabled      bool           `db:"TOTPEnabled"`
+	TOTPLastUsedStep int64          `db:"TOTPLastUsedStep"`
+	//Second factor attempts since the last success. Reaching the limit sets MFALockedUntil
+	MFAFailedAttempts int          `db:"MFAFailedAttempts"`
+	MFALockedUntil    sql.NullTime `db:"MFALockedUntil"`
+	//user, support or admin. DisabledAt is set when an admin deactivates the account
+	Role       string       `db:"Role"`
+	DisabledAt sql.NullTime `db:"DisabledAt"`
+	//Set when the user follows the emailed verification link. Verification emails sent
+	//since VerificationWindowStart are counted for rate limiting
+	EmailVerifiedAt         sql.NullTime `db:"EmailVerifiedAt"`
+	VerificationSentAt      sql.NullTime `db:"VerificationSentAt"`
+	VerificationWindowStart sql.NullTime `db:"VerificationWindowStart"`
+	VerificationSendCount   int          `db:"VerificationSendCount"`
+	//Random WebAuthn user handle (base64url) given to authenticators instead of the UserId
+	WebAuthnUserHandle sql.NullString `db:"WebAuthnUserHandle"`
+}
+
+// Tokens for scripted api access. Only the hash is stored, TokenPrefix is kept
+// so users can tell their tokens apart. Scopes is a comma separated list
+type DB_PersonalAccessTokens struct {
+	TokenId     int          `db:"id"`
+	UserId      int          `db:"UserId"`
+	Name        string       `db:"Name"`
+	TokenPrefix string       `db:"TokenPrefix"`
+	TokenHash   string       `db:"TokenHash"`
+	Scopes      string       `db:"Scopes"`
+	CreatedAt   time.Time    `db:"CreatedAt"`
+	ExpiresAt   time.Time    `db:"ExpiresAt"`
+	LastUsedAt  sql.NullTime `db:"LastUsedAt"`
+	RevokedAt   sql.NullTime `db:"RevokedAt"`
+}
+
+// Identity provider accounts linked to a user for single sign-on.
+// Issuer and Subject together identify the account at the provider
+type DB_UserIdentities struct {
+	IdentityId  int          `db:"id"`
+	UserId      int          `db:"UserId"`
+	Issuer      string       `db:"Issuer"`
+	Subject     string       `db:"Subject"`
+	Email       string       `db:"Email"`
+	CreatedAt   time.Time    `db:"CreatedAt"`
+	LastLoginAt sql.NullTime `db:"LastLoginAt"`
+}
+
+// Passkeys (WebAuthn credentials) registered by a user. CredentialID and
+// PublicKey (COSE encoded) are base64url. SignCount is the last counter value
+// the authenticator reported and is used to detect cloned authenticators
+type DB_WebAuthnCredentials struct {
+	PasskeyId       int          `db:"id"`
+	UserId          int          `db:"UserId"`
+	Name            string       `db:"Name"`
+	CredentialID    string       `db:"CredentialID"`
+	PublicKey       string       `db:"PublicKey"`
+	AttestationType string       `db:"AttestationType"`
+	AAGUID          string       `db:"AAGUID"`
+	Transports      string       `db:"Transports"`
+	SignCount       int64        `db:"SignCount"`
+	BackupEligible  bool         `db:"BackupEligible"`
+	BackupState     bool         `db:"BackupState"`
+	CreatedAt       time.Time    `db:"CreatedAt"`
+	LastUsedAt      sql.NullTime `db:"LastUsedAt"`
+}
+
+// A passkey ceremony waiting for its Finish call. The row is deleted when the
+// ceremony is finished, so each challenge can be answered once. UserId is 0 for
+// sign-in ceremonies
+type DB_WebAuthnChallenges struct {
+	ChallengeId int       `db:"id"`
+	UserId      int       `db:"UserId"`
+	Ceremony    string    `db:"Ceremony"`
+	SessionData string    `db:"SessionData"`
+	CreatedAt   time.Time `db:"CreatedAt"`
+	ExpiresAt   time.Time `db:"ExpiresAt"`
+}
+
+// Self-service account deletion requests. The row outlives the user so it only
+// keeps the numeric UserId and the deletion receipt (JSON) once erasure completes
+type DB_AccountDeletions struct {
+	DeletionId   int          `db:"id"`
+	UserId       int          `db:"UserId"`
+	RequestedAt  time.Time    `db:"RequestedAt"`
+	ScheduledFor time.Time    `db:"ScheduledFor"`
+	CancelledAt  sql.NullTime `db:"CancelledAt"`
+	CompletedAt  sql.NullTime `db:"CompletedAt"`
+	Attempts     int          `db:"Attempts"`
+	LastError    string       `db:"LastError"`
+	ReceiptId    string       `db:"ReceiptId"`
+	Receipt      string       `db:"Receipt"`
+}
+
+// Append-only log of authentication and bank-link activity. UserId is 0 when the
+// user is unknown (i.e. a failed login for a username that does not exist)
+type DB_SecurityEvents struct {
+	EventId   int       `db:"id"`
+	UserId    int       `db:"UserId"`
+	Username  string    `db:"Username"`
+	EventType string    `db:"EventType"`
+	Outcome   string    `db:"Outcome"`
+	Detail    string    `db:"Detail"`
+	IPAddress string    `db:"IPAddress"`
+	UserAgent string    `db:"UserAgent"`
+	CreatedAt time.Time `db:"CreatedAt"`
+}
+
+// Only the hash of the emailed token is stored
+type DB_PasswordResetTokens struct {
+	ResetTokenId int          `db:"id"`
+	UserId       int          `db:"UserId"`
+	TokenHash    string       `db:"TokenHash"`
+	CreatedAt    time.Time    `db:"CreatedAt"`
+	ExpiresAt    time.Time    `db:"ExpiresAt"`
+	UsedAt       sql.NullTime `db:"UsedAt"`
+}
+
+type DB_RecoveryCodes struct {
+	RecoveryCodeId int          `db:"id"`
+	UserId         int          `db:"UserId"`
+	CodeHash       string       `db:"CodeHash"`
+	CreatedAt      time.Time    `db:"CreatedAt"`
+	UsedAt         sql.NullTime `db:"UsedAt"`
 }
 
+// Short lived challenge issued after a correct password when the user has 2FA enabled
+type DB_MFAChallenges struct {
+	ChallengeId int          `db:"id"`
+	UserId      int          `db:"UserId"`
+	Attempts    int          `db:"Attempts"`
+	CreatedAt   time.Time    `db:"CreatedAt"`
+	ExpiresAt   time.Time    `db:"ExpiresAt"`
+	ConsumedAt  sql.NullTime `db:"ConsumedAt"`
+	RememberMe  bool         `db:"RememberMe"`
+}
+
+// TransactionsCursor is the next_cursor of the last applied /transactions/sync,
+// nil until the first sync
 type DB_LinkedInstitutions struct {
-	LinkedInstitutionID int       `db:"id"`
-	UserID              int       `db:"UserID"`
-	AccessToken         string    `db:"AccessToken"`
-	ItemID              string    `db:"ItemID"`
-	InstitutionName     string    `db:"InstitutionName"`
-	InstitutionID       string    `db:"InstitutionID"`
-	CreatedAt           time.Time `db:"CreatedAt"`
-	UpdatedAt           time.Time `db:"UpdatedAt"`
+	LinkedInstitutionID  int          `db:"id"`
+	UserID               int          `db:"UserID"`
+	AccessToken          string       `db:"AccessToken"`
+	ItemID               string       `db:"ItemID"`
+	InstitutionName      string       `db:"InstitutionName"`
+	InstitutionID        string       `db:"InstitutionID"`
+	CreatedAt            time.Time    `db:"CreatedAt"`
+	UpdatedAt            time.Time    `db:"UpdatedAt"`
+	TransactionsCursor   *string      `db:"TransactionsCursor"`
+	TransactionsSyncedAt sql.NullTime `db:"TransactionsSyncedAt"`
+	//healthy, login_required, pending_expiration, revoked or error, set from Plaid errors and item
+	//webhooks, NULL for items stored before status tracking. LastErrorCode is the last Plaid
+	//error code seen for the item, nil after a repair
+	ItemStatus          sql.NullString `db:"ItemStatus"`
+	LastErrorCode       *string        `db:"LastErrorCode"`
+	ItemStatusUpdatedAt sql.NullTime   `db:"ItemStatusUpdatedAt"`
+	ConsentExpiresAt    sql.NullTime   `db:"ConsentExpiresAt"`
+	//Plaid found accounts the user has not shared yet, Link update mode with account selection adds them
+	NewAccountsAvailable bool `db:"NewAccountsAvailable"`
+	//Last run of the scheduled balance refresh for the item
+	BalancesRefreshedAt sql.NullTime `db:"BalancesRefreshedAt"`
+	//Comma separated Plaid products of the item, nil for items linked before products were stored
+	Products            *string      `db:"Products"`
+	InvestmentsSyncedAt sql.NullTime `db:"InvestmentsSyncedAt"`
+	LiabilitiesSyncedAt sql.NullTime `db:"LiabilitiesSyncedAt"`
+}
+
+// Access to a linked institution shared by its owner with another user.
+// LinkedAccountID 0 grants every account of the institution. AccessLevel is
+// view or manage, Status is pending, accepted, declined or revoked
+type DB_InstitutionGrants struct {
+	GrantId             int          `db:"id"`
+	OwnerUserId         int          `db:"OwnerUserId"`
+	GranteeUserId       int          `db:"GranteeUserId"`
+	LinkedInstitutionID int          `db:"LinkedInstitutionID"`
+	LinkedAccountID     int          `db:"LinkedAccountID"`
+	AccessLevel         string       `db:"AccessLevel"`
+	Status              string       `db:"Status"`
+	InvitedAt           time.Time    `db:"InvitedAt"`
+	RespondedAt         sql.NullTime `db:"RespondedAt"`
+	RevokedAt           sql.NullTime `db:"RevokedAt"`
 }
 
 type DB_LinkedAccounts struct {
@@ -67,6 +278,85 @@ type DB_LinkedAccounts struct {
 	HolderCategory      *string   `db:"HolderCategory"`
 	CreatedAt           time.Time `db:"CreatedAt"`
 	UpdatedAt           time.Time `db:"UpdatedAt"`
+	//Plaids account_id, nil for accounts linked before it was stored
+	PlaidAccountID *string `db:"PlaidAccountID"`
+}
+
+// Transactions synced from Plaid. TransactionID is Plaids transaction_id and is
+// unique. AccountID is the DB_LinkedAccounts row, 0 when the Plaid account is not
+// linked. Category and CategoryDetailed are Plaids personal finance category
+type DB_Transactions struct {
+	TransactionRowID       int        `db:"id"`
+	LinkedInstitutionID    int        `db:"LinkedInstitutionID"`
+	AccountID              int        `db:"AccountID"`
+	PlaidAccountID         string     `db:"PlaidAccountID"`
+	TransactionID          string     `db:"TransactionID"`
+	Amount                 float64    `db:"Amount"`
+	ISOCurrencyCode        *string    `db:"ISOCurrencyCode"`
+	UnofficialCurrencyCode *string    `db:"UnofficialCurrencyCode"`
+	TransactionDate        time.Time  `db:"TransactionDate"`
+	AuthorizedDate         *time.Time `db:"AuthorizedDate"`
+	Name                   string     `db:"Name"`
+	MerchantName           *string    `db:"MerchantName"`
+	Category               *string    `db:"Category"`
+	CategoryDetailed       *string    `db:"CategoryDetailed"`
+	PaymentChannel         string     `db:"PaymentChannel"`
+	Pending                bool       `db:"Pending"`
+	PendingTransactionID   *string    `db:"PendingTransactionID"`
+	CreatedAt              time.Time  `db:"CreatedAt"`
+	UpdatedAt              time.Time  `db:"UpdatedAt"`
+}
+
+// A recurring inflow or outflow stream Plaid detected, keyed by Plaids stream_id.
+// Syncs only write the Plaid columns, DisplayName and ReviewStatus are the users
+type DB_RecurringStreams struct {
+	RecurringStreamID   int    `db:"id"`
+	LinkedInstitutionID int    `db:"LinkedInstitutionID"`
+	AccountID           int    `db:"AccountID"`
+	PlaidStreamID       string `db:"PlaidStreamID"`
+	//inflow or outflow
+	Direction              string     `db:"Direction"`
+	Description            string     `db:"Description"`
+	MerchantName           *string    `db:"MerchantName"`
+	Category               *string    `db:"Category"`
+	CategoryDetailed       *string    `db:"CategoryDetailed"`
+	Frequency              string     `db:"Frequency"`
+	AverageAmount          *float64   `db:"AverageAmount"`
+	LastAmount             *float64   `db:"LastAmount"`
+	ISOCurrencyCode        *string    `db:"ISOCurrencyCode"`
+	UnofficialCurrencyCode *string    `db:"UnofficialCurrencyCode"`
+	FirstDate              time.Time  `db:"FirstDate"`
+	LastDate               time.Time  `db:"LastDate"`
+	PredictedNextDate      *time.Time `db:"PredictedNextDate"`
+	//false once Plaid reports the stream inactive or stops returning it
+	IsActive bool `db:"IsActive"`
+	//Plaids status: UNKNOWN, MATURE, EARLY_DETECTION or TOMBSTONED
+	Status string `db:"Status"`
+	//Set by the user, nil shows the merchant name or description
+	DisplayName *string `db:"DisplayName"`
+	//unreviewed, confirmed or dismissed
+	ReviewStatus    string       `db:"ReviewStatus"`
+	ReviewUpdatedAt sql.NullTime `db:"ReviewUpdatedAt"`
+	CreatedAt       time.Time    `db:"CreatedAt"`
+	UpdatedAt       time.Time    `db:"UpdatedAt"`
+}
+
+// Verified Plaid webhooks queued for background processing. DedupKey is the sha256
+// of the body, Plaid redelivers the same body on retries and it is only queued once
+type DB_PlaidWebhooks struct {
+	WebhookID   int          `db:"id"`
+	DedupKey    string       `db:"DedupKey"`
+	WebhookType string       `db:"WebhookType"`
+	WebhookCode string       `db:"WebhookCode"`
+	ItemID      string       `db:"ItemID"`
+	ErrorCode   string       `db:"ErrorCode"`
+	Body        string       `db:"Body"`
+	ReceivedAt  time.Time    `db:"ReceivedAt"`
+	ProcessedAt sql.NullTime `db:"ProcessedAt"`
+	Attempts    int          `db:"Attempts"`
+	LastError   string       `db:"LastError"`
+	//Set while the worker processes the webhook, cleared again when processing fails
+	ClaimedAt sql.NullTime `db:"ClaimedAt"`
 }
 
 type DB_AccountBalance struct {
@@ -83,6 +373,159 @@ type DB_AccountBalance struct {
 	UpdatedAt              time.Time  `db:"UpdatedAt"`
 }
 
+// Balance history of an account. A snapshot is only added when the balance
+// changed, so each one holds until the next snapshot of the account
+type DB_BalanceSnapshots struct {
+	SnapshotID             int       `db:"id"`
+	LinkedInstitutionID    int       `db:"LinkedInstitutionID"`
+	AccountID              int       `db:"AccountID"`
+	Available              *float64  `db:"Available"`
+	CurrentAmount          *float64  `db:"CurrentAmount"`
+	LimitAmount            *float64  `db:"LimitAmount"`
+	ISOCurrencyCode        *string   `db:"ISOCurrencyCode"`
+	UnofficialCurrencyCode *string   `db:"UnofficialCurrencyCode"`
+	CapturedAt             time.Time `db:"CapturedAt"`
+}
+
+// A security held or traded in an investment account. Securities are market
+// data shared by every user, PlaidSecurityID is unique
+type DB_Securities struct {
+	SecurityID             int       `db:"id"`
+	PlaidSecurityID        string    `db:"PlaidSecurityID"`
+	Name                   *string   `db:"Name"`
+	TickerSymbol           *string   `db:"TickerSymbol"`
+	Type                   *string   `db:"Type"`
+	ISIN                   *string   `db:"ISIN"`
+	CUSIP                  *string   `db:"CUSIP"`
+	IsCashEquivalent       bool      `db:"IsCashEquivalent"`
+	ClosePrice             *float64  `db:"ClosePrice"`
+	ClosePriceAsOf         *string   `db:"ClosePriceAsOf"`
+	ISOCurrencyCode        *string   `db:"ISOCurrencyCode"`
+	UnofficialCurrencyCode *string   `db:"UnofficialCurrencyCode"`
+	UpdatedAt              time.Time `db:"UpdatedAt"`
+}
+
+// A holding of an investment account on SnapshotDate (UTC date). Each
+// investments sync replaces the days snapshot, earlier days are kept as history
+type DB_Holdings struct {
+	HoldingID              int       `db:"id"`
+	LinkedInstitutionID    int       `db:"LinkedInstitutionID"`
+	AccountID              int       `db:"AccountID"`
+	SecurityID             int       `db:"SecurityID"`
+	SnapshotDate           time.Time `db:"SnapshotDate"`
+	Quantity               float64   `db:"Quantity"`
+	CostBasis              *float64  `db:"CostBasis"`
+	InstitutionPrice       float64   `db:"InstitutionPrice"`
+	InstitutionPriceAsOf   *string   `db:"InstitutionPriceAsOf"`
+	InstitutionValue       float64   `db:"InstitutionValue"`
+	ISOCurrencyCode        *string   `db:"ISOCurrencyCode"`
+	UnofficialCurrencyCode *string   `db:"UnofficialCurrencyCode"`
+	CreatedAt              time.Time `db:"CreatedAt"`
+}
+
+// A buy, sell, dividend, fee or cash movement of an investment account.
+// SecurityID is nil for transactions without a security, i.e. contributions
+type DB_InvestmentTransactions struct {
+	InvestmentTransactionID      int       `db:"id"`
+	LinkedInstitutionID          int       `db:"LinkedInstitutionID"`
+	AccountID                    int       `db:"AccountID"`
+	PlaidInvestmentTransactionID string    `db:"PlaidInvestmentTransactionID"`
+	SecurityID                   *int      `db:"SecurityID"`
+	Date                         time.Time `db:"Date"`
+	Name                         string    `db:"Name"`
+	Quantity                     float64   `db:"Quantity"`
+	Amount                       float64   `db:"Amount"`
+	Price                        float64   `db:"Price"`
+	Fees                         *float64  `db:"Fees"`
+	Type                         string    `db:"Type"`
+	Subtype                      string    `db:"Subtype"`
+	ISOCurrencyCode              *string   `db:"ISOCurrencyCode"`
+	UnofficialCurrencyCode       *string   `db:"UnofficialCurrencyCode"`
+	CreatedAt                    time.Time `db:"CreatedAt"`
+	UpdatedAt                    time.Time `db:"UpdatedAt"`
+}
+
+// Statement and payment details of a credit card account, one row per account
+type DB_CreditCardLiabilities struct {
+	CreditCardLiabilityID  int        `db:"id"`
+	LinkedInstitutionID    int        `db:"LinkedInstitutionID"`
+	AccountID              int        `db:"AccountID"`
+	IsOverdue              *bool      `db:"IsOverdue"`
+	LastPaymentAmount      *float64   `db:"LastPaymentAmount"`
+	LastPaymentDate        *time.Time `db:"LastPaymentDate"`
+	LastStatementBalance   *float64   `db:"LastStatementBalance"`
+	LastStatementIssueDate *time.Time `db:"LastStatementIssueDate"`
+	MinimumPaymentAmount   *float64   `db:"MinimumPaymentAmount"`
+	NextPaymentDueDate     *time.Time `db:"NextPaymentDueDate"`
+	UpdatedAt              time.Time  `db:"UpdatedAt"`
+}
+
+// The APRs of a credit card account (purchase_apr, cash_apr, balance_transfer_apr
+// or special), replaced on every liabilities sync
+type DB_CreditCardAPRs struct {
+	CreditCardAPRID      int      `db:"id"`
+	LinkedInstitutionID  int      `db:"LinkedInstitutionID"`
+	AccountID            int      `db:"AccountID"`
+	APRType              string   `db:"APRType"`
+	APRPercentage        float64  `db:"APRPercentage"`
+	BalanceSubjectToAPR  *float64 `db:"BalanceSubjectToAPR"`
+	InterestChargeAmount *float64 `db:"InterestChargeAmount"`
+}
+
+// A student loan account, one row per account
+type DB_StudentLoans struct {
+	StudentLoanID              int        `db:"id"`
+	LinkedInstitutionID        int        `db:"LinkedInstitutionID"`
+	AccountID                  int        `db:"AccountID"`
+	LoanName                   *string    `db:"LoanName"`
+	LoanStatus                 *string    `db:"LoanStatus"`
+	LoanStatusEndDate          *time.Time `db:"LoanStatusEndDate"`
+	RepaymentPlan              *string    `db:"RepaymentPlan"`
+	RepaymentPlanDescription   *string    `db:"RepaymentPlanDescription"`
+	Guarantor                  *string    `db:"Guarantor"`
+	InterestRatePercentage     float64    `db:"InterestRatePercentage"`
+	IsOverdue                  *bool      `db:"IsOverdue"`
+	LastPaymentAmount          *float64   `db:"LastPaymentAmount"`
+	LastPaymentDate            *time.Time `db:"LastPaymentDate"`
+	LastStatementBalance       *float64   `db:"LastStatementBalance"`
+	LastStatementIssueDate     *time.Time `db:"LastStatementIssueDate"`
+	MinimumPaymentAmount       *float64   `db:"MinimumPaymentAmount"`
+	NextPaymentDueDate         *time.Time `db:"NextPaymentDueDate"`
+	OriginationDate            *time.Time `db:"OriginationDate"`
+	OriginationPrincipalAmount *float64   `db:"OriginationPrincipalAmount"`
+	OutstandingInterestAmount  *float64   `db:"OutstandingInterestAmount"`
+	ExpectedPayoffDate         *time.Time `db:"ExpectedPayoffDate"`
+	YtdInterestPaid            *float64   `db:"YtdInterestPaid"`
+	YtdPrincipalPaid           *float64   `db:"YtdPrincipalPaid"`
+	UpdatedAt                  time.Time  `db:"UpdatedAt"`
+}
+
+// A mortgage account, one row per account
+type DB_Mortgages struct {
+	MortgageID                 int        `db:"id"`
+	LinkedInstitutionID        int        `db:"LinkedInstitutionID"`
+	AccountID                  int        `db:"AccountID"`
+	LoanTypeDescription        *string    `db:"LoanTypeDescription"`
+	LoanTerm                   *string    `db:"LoanTerm"`
+	InterestRatePercentage     *float64   `db:"InterestRatePercentage"`
+	InterestRateType           *string    `db:"InterestRateType"`
+	OriginationDate            *time.Time `db:"OriginationDate"`
+	OriginationPrincipalAmount *float64   `db:"OriginationPrincipalAmount"`
+	MaturityDate               *time.Time `db:"MaturityDate"`
+	EscrowBalance              *float64   `db:"EscrowBalance"`
+	HasPMI                     *bool      `db:"HasPMI"`
+	HasPrepaymentPenalty       *bool      `db:"HasPrepaymentPenalty"`
+	NextMonthlyPayment         *float64   `db:"NextMonthlyPayment"`
+	NextPaymentDueDate         *time.Time `db:"NextPaymentDueDate"`
+	LastPaymentAmount          *float64   `db:"LastPaymentAmount"`
+	LastPaymentDate            *time.Time `db:"LastPaymentDate"`
+	PastDueAmount              *float64   `db:"PastDueAmount"`
+	CurrentLateFee             *float64   `db:"CurrentLateFee"`
+	YtdInterestPaid            *float64   `db:"YtdInterestPaid"`
+	YtdPrincipalPaid           *float64   `db:"YtdPrincipalPaid"`
+	UpdatedAt                  time.Time  `db:"UpdatedAt"`
+}
+
 type DB_WidgetBoard struct {
 	WidgetBoardID   int `db:"id"`
 	UserID          int `db:"UserID"`
@@ -0,0 +1,143 @@
+/*
+------------------------------------------------------------------
+FILE NAME:     Migrations.go
+PROJECT:       CashflowAnalysis
+Date Created:  Oct-19-2026
+--------------------------------------------------------------------
+DESCRIPTION:
+Applies the schema changes in the migrations folder. The .sql files are
+embedded in the binary and run in file name order, each in its own
+transaction. Applied files are recorded in dbo.CFA_SchemaMigrations so every
+migration runs once. Like sqlcmd, a line holding only GO ends a batch, so a
+column added in one batch can be used in the next.
+--------------------------------------------------------------------
+$HISTORY:
+
+Oct-19-2026   Created initial file.
+------------------------------------------------------------------
+*/
+package services
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"embed"
+	"fmt"
+	"io/fs"
+	"log"
+	"sort"
+	"strings"
+	"time"
+)
+
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+const createMigrationsTable = `
+IF OBJECT_ID('dbo.CFA_SchemaMigrations', 'U') IS NULL
+CREATE TABLE dbo.CFA_SchemaMigrations (
+    Name NVARCHAR(255) NOT NULL PRIMARY KEY,
+    AppliedAt DATETIME2 NOT NULL
+);`
+
+// Runs every migration that has not been applied yet. Stops at the first one that fails
+func MigrateDB() error {
+	initializeDB()
+	ctx := context.Background()
+	if _, err := db.ExecContext(ctx, createMigrationsTable); err != nil {
+		return err
+	}
+
+	applied, err := appliedMigrations(ctx)
+	if err != nil {
+		return err
+	}
+	names, err := fs.Glob(migrationFiles, "migrations/*.sql")
+	if err != nil {
+		return err
+	}
+	sort.Strings(names)
+
+	for _, path := range names {
+		name := strings.TrimPrefix(path, "migrations/")
+		if applied[name] {
+			continue
+		}
+		script, err := migrationFiles.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		if err := applyMigration(ctx, name, string(script)); err != nil {
+			return fmt.Errorf("migration %s: %w", name, err)
+		}
+		log.Println("Applied migration", name)
+	}
+	return nil
+}
+
+// Runs the batches of one migration and records it in one transaction, so a
+// failed migration leaves nothing behind and runs again on the next start
+func applyMigration(ctx context.Context, name string, script string) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	for _, batch := range splitBatches(script) {
+		if _, err := tx.ExecContext(ctx, batch); err != nil {
+			_ = tx.Rollback()
+			return err
+		}
+	}
+	_, err = tx.ExecContext(ctx, "INSERT INTO dbo.CFA_SchemaMigrations (Name, AppliedAt) VALUES (@Name, @AppliedAt);",
+		sql.Named("Name", name), sql.Named("AppliedAt", time.Now().UTC()))
+	if err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// Returns the names of the migrations already applied
+func appliedMigrations(ctx context.Context) (map[string]bool, error) {
+	rows, err := db.QueryContext(ctx, "SELECT Name FROM dbo.CFA_SchemaMigrations;")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := map[string]bool{}
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		applied[name] = true
+	}
+	return applied, rows.Err()
+}
+
+// Splits a script into the batches separated by GO lines, dropping empty batches
+func splitBatches(script string) []string {
+	var batches []string
+	var current strings.Builder
+	flush := func() {
+		if batch := strings.TrimSpace(current.String()); batch != "" {
+			batches = append(batches, batch)
+		}
+		current.Reset()
+	}
+
+	scanner := bufio.NewScanner(strings.NewReader(script))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.EqualFold(strings.TrimSpace(line), "GO") {
+			flush()
+			continue
+		}
+		current.WriteString(line)
+		current.WriteString("\n")
+	}
+	flush()
+	return batches
+}
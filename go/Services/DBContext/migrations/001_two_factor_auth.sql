@@ -0,0 +1,25 @@
+-- TOTP two-factor authentication with recovery codes
+ALTER TABLE dbo.CFA_Users ADD
+    TOTPSecret NVARCHAR(255) NULL,
+    TOTPEnabled BIT NOT NULL CONSTRAINT DF_CFA_Users_TOTPEnabled DEFAULT 0,
+    TOTPLastUsedStep BIGINT NOT NULL CONSTRAINT DF_CFA_Users_TOTPLastUsedStep DEFAULT 0;
+GO
+
+CREATE TABLE dbo.CFA_RecoveryCodes (
+    RecoveryCodeId INT IDENTITY(1,1) NOT NULL PRIMARY KEY,
+    UserId INT NOT NULL,
+    CodeHash NVARCHAR(255) NOT NULL,
+    CreatedAt DATETIME2 NOT NULL,
+    UsedAt DATETIME2 NULL
+);
+CREATE INDEX IX_CFA_RecoveryCodes_UserId ON dbo.CFA_RecoveryCodes (UserId);
+
+CREATE TABLE dbo.CFA_MFAChallenges (
+    ChallengeId INT IDENTITY(1,1) NOT NULL PRIMARY KEY,
+    UserId INT NOT NULL,
+    Attempts INT NOT NULL,
+    CreatedAt DATETIME2 NOT NULL,
+    ExpiresAt DATETIME2 NOT NULL,
+    ConsumedAt DATETIME2 NULL
+);
+CREATE INDEX IX_CFA_MFAChallenges_UserId ON dbo.CFA_MFAChallenges (UserId);
@@ -0,0 +1,16 @@
+-- Email addresses and password reset tokens
+ALTER TABLE dbo.CFA_Users ADD Email NVARCHAR(320) NULL;
+GO
+
+CREATE UNIQUE INDEX UX_CFA_Users_Email ON dbo.CFA_Users (Email) WHERE Email IS NOT NULL;
+
+CREATE TABLE dbo.CFA_PasswordResetTokens (
+    ResetTokenId INT IDENTITY(1,1) NOT NULL PRIMARY KEY,
+    UserId INT NOT NULL,
+    TokenHash NVARCHAR(255) NOT NULL,
+    CreatedAt DATETIME2 NOT NULL,
+    ExpiresAt DATETIME2 NOT NULL,
+    UsedAt DATETIME2 NULL
+);
+CREATE UNIQUE INDEX UX_CFA_PasswordResetTokens_TokenHash ON dbo.CFA_PasswordResetTokens (TokenHash);
+CREATE INDEX IX_CFA_PasswordResetTokens_UserId ON dbo.CFA_PasswordResetTokens (UserId);
@@ -0,0 +1,8 @@
+-- The device a session was created from. Sessions created before have NULL
+ALTER TABLE dbo.CFA_Sessions ADD
+    IPAddress NVARCHAR(64) NULL,
+    UserAgent NVARCHAR(512) NULL,
+    DeviceName NVARCHAR(255) NULL;
+GO
+
+CREATE INDEX IX_CFA_Sessions_UserId ON dbo.CFA_Sessions (UserId);
@@ -0,0 +1,4 @@
+-- User roles and admin deactivation. Existing users get the user role
+ALTER TABLE dbo.CFA_Users ADD
+    Role NVARCHAR(20) NOT NULL CONSTRAINT DF_CFA_Users_Role DEFAULT 'user',
+    DisabledAt DATETIME2 NULL;
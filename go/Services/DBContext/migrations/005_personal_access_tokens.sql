@@ -0,0 +1,15 @@
+-- Scoped personal access tokens
+CREATE TABLE dbo.CFA_PersonalAccessTokens (
+    TokenId INT IDENTITY(1,1) NOT NULL PRIMARY KEY,
+    UserId INT NOT NULL,
+    Name NVARCHAR(100) NOT NULL,
+    TokenPrefix NVARCHAR(32) NOT NULL,
+    TokenHash NVARCHAR(255) NOT NULL,
+    Scopes NVARCHAR(500) NOT NULL,
+    CreatedAt DATETIME2 NOT NULL,
+    ExpiresAt DATETIME2 NOT NULL,
+    LastUsedAt DATETIME2 NULL,
+    RevokedAt DATETIME2 NULL
+);
+CREATE UNIQUE INDEX UX_CFA_PersonalAccessTokens_TokenHash ON dbo.CFA_PersonalAccessTokens (TokenHash);
+CREATE INDEX IX_CFA_PersonalAccessTokens_UserId ON dbo.CFA_PersonalAccessTokens (UserId);
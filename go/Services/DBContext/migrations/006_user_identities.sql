@@ -0,0 +1,12 @@
+-- Identity provider accounts linked for single sign-on
+CREATE TABLE dbo.CFA_UserIdentities (
+    IdentityId INT IDENTITY(1,1) NOT NULL PRIMARY KEY,
+    UserId INT NOT NULL,
+    Issuer NVARCHAR(255) NOT NULL,
+    Subject NVARCHAR(255) NOT NULL,
+    Email NVARCHAR(320) NOT NULL,
+    CreatedAt DATETIME2 NOT NULL,
+    LastLoginAt DATETIME2 NULL
+);
+CREATE UNIQUE INDEX UX_CFA_UserIdentities_Issuer_Subject ON dbo.CFA_UserIdentities (Issuer, Subject);
+CREATE INDEX IX_CFA_UserIdentities_UserId ON dbo.CFA_UserIdentities (UserId);
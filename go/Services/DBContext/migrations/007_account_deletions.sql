@@ -0,0 +1,15 @@
+-- Self-service account deletion requests and receipts
+CREATE TABLE dbo.CFA_AccountDeletions (
+    DeletionId INT IDENTITY(1,1) NOT NULL PRIMARY KEY,
+    UserId INT NOT NULL,
+    RequestedAt DATETIME2 NOT NULL,
+    ScheduledFor DATETIME2 NOT NULL,
+    CancelledAt DATETIME2 NULL,
+    CompletedAt DATETIME2 NULL,
+    Attempts INT NOT NULL,
+    LastError NVARCHAR(MAX) NOT NULL,
+    ReceiptId NVARCHAR(64) NOT NULL,
+    Receipt NVARCHAR(MAX) NOT NULL
+);
+CREATE INDEX IX_CFA_AccountDeletions_UserId ON dbo.CFA_AccountDeletions (UserId);
+CREATE INDEX IX_CFA_AccountDeletions_ScheduledFor ON dbo.CFA_AccountDeletions (ScheduledFor);
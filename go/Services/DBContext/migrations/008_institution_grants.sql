@@ -0,0 +1,16 @@
+-- Household sharing of linked institutions and accounts
+CREATE TABLE dbo.CFA_InstitutionGrants (
+    GrantId INT IDENTITY(1,1) NOT NULL PRIMARY KEY,
+    OwnerUserId INT NOT NULL,
+    GranteeUserId INT NOT NULL,
+    LinkedInstitutionID INT NOT NULL,
+    LinkedAccountID INT NOT NULL,
+    AccessLevel NVARCHAR(20) NOT NULL,
+    Status NVARCHAR(20) NOT NULL,
+    InvitedAt DATETIME2 NOT NULL,
+    RespondedAt DATETIME2 NULL,
+    RevokedAt DATETIME2 NULL
+);
+CREATE INDEX IX_CFA_InstitutionGrants_OwnerUserId ON dbo.CFA_InstitutionGrants (OwnerUserId);
+CREATE INDEX IX_CFA_InstitutionGrants_GranteeUserId ON dbo.CFA_InstitutionGrants (GranteeUserId);
+CREATE INDEX IX_CFA_InstitutionGrants_LinkedInstitutionID ON dbo.CFA_InstitutionGrants (LinkedInstitutionID);
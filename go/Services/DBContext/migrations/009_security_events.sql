@@ -0,0 +1,14 @@
+-- Security event log
+CREATE TABLE dbo.CFA_SecurityEvents (
+    EventId INT IDENTITY(1,1) NOT NULL PRIMARY KEY,
+    UserId INT NOT NULL,
+    Username NVARCHAR(255) NOT NULL,
+    EventType NVARCHAR(50) NOT NULL,
+    Outcome NVARCHAR(20) NOT NULL,
+    Detail NVARCHAR(MAX) NOT NULL,
+    IPAddress NVARCHAR(64) NOT NULL,
+    UserAgent NVARCHAR(512) NOT NULL,
+    CreatedAt DATETIME2 NOT NULL
+);
+CREATE INDEX IX_CFA_SecurityEvents_UserId_CreatedAt ON dbo.CFA_SecurityEvents (UserId, CreatedAt);
+CREATE INDEX IX_CFA_SecurityEvents_CreatedAt ON dbo.CFA_SecurityEvents (CreatedAt);
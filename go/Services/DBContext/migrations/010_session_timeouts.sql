@@ -0,0 +1,13 @@
+-- Idle and absolute session timeouts with remember-me. Existing sessions were
+-- last seen when created and end at their current expiry
+ALTER TABLE dbo.CFA_Sessions ADD
+    LastSeenAt DATETIME2 NULL,
+    AbsoluteExpiresAt DATETIME2 NULL,
+    RememberMe BIT NOT NULL CONSTRAINT DF_CFA_Sessions_RememberMe DEFAULT 0;
+ALTER TABLE dbo.CFA_MFAChallenges ADD
+    RememberMe BIT NOT NULL CONSTRAINT DF_CFA_MFAChallenges_RememberMe DEFAULT 0;
+GO
+
+UPDATE dbo.CFA_Sessions SET LastSeenAt = CreatedAt, AbsoluteExpiresAt = ExpiresAt;
+ALTER TABLE dbo.CFA_Sessions ALTER COLUMN LastSeenAt DATETIME2 NOT NULL;
+ALTER TABLE dbo.CFA_Sessions ALTER COLUMN AbsoluteExpiresAt DATETIME2 NOT NULL;
@@ -0,0 +1,6 @@
+-- Email verification
+ALTER TABLE dbo.CFA_Users ADD
+    EmailVerifiedAt DATETIME2 NULL,
+    VerificationSentAt DATETIME2 NULL,
+    VerificationWindowStart DATETIME2 NULL,
+    VerificationSendCount INT NOT NULL CONSTRAINT DF_CFA_Users_VerificationSendCount DEFAULT 0;
@@ -0,0 +1,22 @@
+-- Passkeys (WebAuthn credentials)
+ALTER TABLE dbo.CFA_Users ADD WebAuthnUserHandle NVARCHAR(100) NULL;
+GO
+
+CREATE UNIQUE INDEX UX_CFA_Users_WebAuthnUserHandle ON dbo.CFA_Users (WebAuthnUserHandle) WHERE WebAuthnUserHandle IS NOT NULL;
+
+CREATE TABLE dbo.CFA_WebAuthnCredentials (
+    PasskeyId INT IDENTITY(1,1) NOT NULL PRIMARY KEY,
+    UserId INT NOT NULL,
+    Name NVARCHAR(100) NOT NULL,
+    CredentialID NVARCHAR(1400) NOT NULL,
+    PublicKey NVARCHAR(MAX) NOT NULL,
+    AttestationType NVARCHAR(50) NOT NULL,
+    AAGUID NVARCHAR(64) NOT NULL,
+    Transports NVARCHAR(255) NOT NULL,
+    SignCount BIGINT NOT NULL,
+    BackupEligible BIT NOT NULL,
+    BackupState BIT NOT NULL,
+    CreatedAt DATETIME2 NOT NULL,
+    LastUsedAt DATETIME2 NULL
+);
+CREATE INDEX IX_CFA_WebAuthnCredentials_UserId ON dbo.CFA_WebAuthnCredentials (UserId);
@@ -0,0 +1,5 @@
+-- Admin impersonation sessions. A user's own sessions have ImpersonatorUserId 0
+-- and no reason
+ALTER TABLE dbo.CFA_Sessions ADD
+    ImpersonatorUserId INT NOT NULL CONSTRAINT DF_CFA_Sessions_ImpersonatorUserId DEFAULT 0,
+    ImpersonationReason NVARCHAR(200) NULL;
@@ -0,0 +1,30 @@
+-- Synced transactions and the per-item sync cursor
+ALTER TABLE dbo.CFA_LinkedInstitutions ADD
+    TransactionsCursor NVARCHAR(MAX) NULL,
+    TransactionsSyncedAt DATETIME2 NULL;
+ALTER TABLE dbo.CFA_LinkedAccounts ADD PlaidAccountID NVARCHAR(100) NULL;
+GO
+
+CREATE TABLE dbo.CFA_Transactions (
+    TransactionRowID INT IDENTITY(1,1) NOT NULL PRIMARY KEY,
+    LinkedInstitutionID INT NOT NULL,
+    AccountID INT NOT NULL,
+    PlaidAccountID NVARCHAR(100) NOT NULL,
+    TransactionID NVARCHAR(100) NOT NULL,
+    Amount FLOAT NOT NULL,
+    ISOCurrencyCode NVARCHAR(10) NULL,
+    UnofficialCurrencyCode NVARCHAR(10) NULL,
+    TransactionDate DATE NOT NULL,
+    AuthorizedDate DATE NULL,
+    Name NVARCHAR(500) NOT NULL,
+    MerchantName NVARCHAR(255) NULL,
+    Category NVARCHAR(100) NULL,
+    CategoryDetailed NVARCHAR(100) NULL,
+    PaymentChannel NVARCHAR(20) NOT NULL,
+    Pending BIT NOT NULL,
+    PendingTransactionID NVARCHAR(100) NULL,
+    CreatedAt DATETIME2 NOT NULL,
+    UpdatedAt DATETIME2 NOT NULL
+);
+CREATE UNIQUE INDEX UX_CFA_Transactions_TransactionID ON dbo.CFA_Transactions (TransactionID);
+CREATE INDEX IX_CFA_Transactions_LinkedInstitutionID_TransactionDate ON dbo.CFA_Transactions (LinkedInstitutionID, TransactionDate);
@@ -0,0 +1,17 @@
+-- Queue of verified Plaid webhooks
+CREATE TABLE dbo.CFA_PlaidWebhooks (
+    WebhookID INT IDENTITY(1,1) NOT NULL PRIMARY KEY,
+    DedupKey NVARCHAR(64) NOT NULL,
+    WebhookType NVARCHAR(50) NOT NULL,
+    WebhookCode NVARCHAR(50) NOT NULL,
+    ItemID NVARCHAR(100) NOT NULL,
+    ErrorCode NVARCHAR(100) NOT NULL,
+    Body NVARCHAR(MAX) NOT NULL,
+    ReceivedAt DATETIME2 NOT NULL,
+    ProcessedAt DATETIME2 NULL,
+    Attempts INT NOT NULL,
+    LastError NVARCHAR(MAX) NOT NULL
+);
+CREATE UNIQUE INDEX UX_CFA_PlaidWebhooks_DedupKey ON dbo.CFA_PlaidWebhooks (DedupKey);
+CREATE INDEX IX_CFA_PlaidWebhooks_Pending ON dbo.CFA_PlaidWebhooks (ReceivedAt) WHERE ProcessedAt IS NULL;
+CREATE INDEX IX_CFA_PlaidWebhooks_ItemID ON dbo.CFA_PlaidWebhooks (ItemID);
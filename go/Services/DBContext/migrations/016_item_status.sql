@@ -0,0 +1,7 @@
+-- Plaid item health. Items linked before have a NULL status, which counts as healthy
+ALTER TABLE dbo.CFA_LinkedInstitutions ADD
+    ItemStatus NVARCHAR(30) NULL,
+    LastErrorCode NVARCHAR(100) NULL,
+    ItemStatusUpdatedAt DATETIME2 NULL,
+    ConsentExpiresAt DATETIME2 NULL,
+    NewAccountsAvailable BIT NOT NULL CONSTRAINT DF_CFA_LinkedInstitutions_NewAccountsAvailable DEFAULT 0;
@@ -0,0 +1,17 @@
+-- Balance history and the scheduled balance refresh
+ALTER TABLE dbo.CFA_LinkedInstitutions ADD BalancesRefreshedAt DATETIME2 NULL;
+GO
+
+CREATE TABLE dbo.CFA_BalanceSnapshots (
+    SnapshotID INT IDENTITY(1,1) NOT NULL PRIMARY KEY,
+    LinkedInstitutionID INT NOT NULL,
+    AccountID INT NOT NULL,
+    Available FLOAT NULL,
+    CurrentAmount FLOAT NULL,
+    LimitAmount FLOAT NULL,
+    ISOCurrencyCode NVARCHAR(10) NULL,
+    UnofficialCurrencyCode NVARCHAR(10) NULL,
+    CapturedAt DATETIME2 NOT NULL
+);
+CREATE INDEX IX_CFA_BalanceSnapshots_AccountID_CapturedAt ON dbo.CFA_BalanceSnapshots (AccountID, CapturedAt);
+CREATE INDEX IX_CFA_BalanceSnapshots_LinkedInstitutionID ON dbo.CFA_BalanceSnapshots (LinkedInstitutionID);
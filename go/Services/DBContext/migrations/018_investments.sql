@@ -0,0 +1,62 @@
+-- Securities, holdings and investment transactions. Products is NULL for items
+-- linked before products were stored
+ALTER TABLE dbo.CFA_LinkedInstitutions ADD
+    Products NVARCHAR(500) NULL,
+    InvestmentsSyncedAt DATETIME2 NULL;
+GO
+
+CREATE TABLE dbo.CFA_Securities (
+    SecurityID INT IDENTITY(1,1) NOT NULL PRIMARY KEY,
+    PlaidSecurityID NVARCHAR(100) NOT NULL,
+    Name NVARCHAR(255) NULL,
+    TickerSymbol NVARCHAR(50) NULL,
+    Type NVARCHAR(50) NULL,
+    ISIN NVARCHAR(20) NULL,
+    CUSIP NVARCHAR(20) NULL,
+    IsCashEquivalent BIT NOT NULL,
+    ClosePrice FLOAT NULL,
+    ClosePriceAsOf NVARCHAR(20) NULL,
+    ISOCurrencyCode NVARCHAR(10) NULL,
+    UnofficialCurrencyCode NVARCHAR(10) NULL,
+    UpdatedAt DATETIME2 NOT NULL
+);
+CREATE UNIQUE INDEX UX_CFA_Securities_PlaidSecurityID ON dbo.CFA_Securities (PlaidSecurityID);
+
+CREATE TABLE dbo.CFA_Holdings (
+    HoldingID INT IDENTITY(1,1) NOT NULL PRIMARY KEY,
+    LinkedInstitutionID INT NOT NULL,
+    AccountID INT NOT NULL,
+    SecurityID INT NOT NULL,
+    SnapshotDate DATE NOT NULL,
+    Quantity FLOAT NOT NULL,
+    CostBasis FLOAT NULL,
+    InstitutionPrice FLOAT NOT NULL,
+    InstitutionPriceAsOf NVARCHAR(20) NULL,
+    InstitutionValue FLOAT NOT NULL,
+    ISOCurrencyCode NVARCHAR(10) NULL,
+    UnofficialCurrencyCode NVARCHAR(10) NULL,
+    CreatedAt DATETIME2 NOT NULL
+);
+CREATE INDEX IX_CFA_Holdings_LinkedInstitutionID_SnapshotDate ON dbo.CFA_Holdings (LinkedInstitutionID, SnapshotDate);
+
+CREATE TABLE dbo.CFA_InvestmentTransactions (
+    InvestmentTransactionID INT IDENTITY(1,1) NOT NULL PRIMARY KEY,
+    LinkedInstitutionID INT NOT NULL,
+    AccountID INT NOT NULL,
+    PlaidInvestmentTransactionID NVARCHAR(100) NOT NULL,
+    SecurityID INT NULL,
+    Date DATE NOT NULL,
+    Name NVARCHAR(500) NOT NULL,
+    Quantity FLOAT NOT NULL,
+    Amount FLOAT NOT NULL,
+    Price FLOAT NOT NULL,
+    Fees FLOAT NULL,
+    Type NVARCHAR(30) NOT NULL,
+    Subtype NVARCHAR(50) NOT NULL,
+    ISOCurrencyCode NVARCHAR(10) NULL,
+    UnofficialCurrencyCode NVARCHAR(10) NULL,
+    CreatedAt DATETIME2 NOT NULL,
+    UpdatedAt DATETIME2 NOT NULL
+);
+CREATE UNIQUE INDEX UX_CFA_InvestmentTransactions_PlaidInvestmentTransactionID ON dbo.CFA_InvestmentTransactions (PlaidInvestmentTransactionID);
+CREATE INDEX IX_CFA_InvestmentTransactions_LinkedInstitutionID_Date ON dbo.CFA_InvestmentTransactions (LinkedInstitutionID, Date);
@@ -0,0 +1,87 @@
+-- Credit card, student loan and mortgage details
+ALTER TABLE dbo.CFA_LinkedInstitutions ADD LiabilitiesSyncedAt DATETIME2 NULL;
+GO
+
+CREATE TABLE dbo.CFA_CreditCardLiabilities (
+    CreditCardLiabilityID INT IDENTITY(1,1) NOT NULL PRIMARY KEY,
+    LinkedInstitutionID INT NOT NULL,
+    AccountID INT NOT NULL,
+    IsOverdue BIT NULL,
+    LastPaymentAmount FLOAT NULL,
+    LastPaymentDate DATE NULL,
+    LastStatementBalance FLOAT NULL,
+    LastStatementIssueDate DATE NULL,
+    MinimumPaymentAmount FLOAT NULL,
+    NextPaymentDueDate DATE NULL,
+    UpdatedAt DATETIME2 NOT NULL
+);
+CREATE UNIQUE INDEX UX_CFA_CreditCardLiabilities_AccountID ON dbo.CFA_CreditCardLiabilities (AccountID);
+CREATE INDEX IX_CFA_CreditCardLiabilities_LinkedInstitutionID ON dbo.CFA_CreditCardLiabilities (LinkedInstitutionID);
+
+CREATE TABLE dbo.CFA_CreditCardAPRs (
+    CreditCardAPRID INT IDENTITY(1,1) NOT NULL PRIMARY KEY,
+    LinkedInstitutionID INT NOT NULL,
+    AccountID INT NOT NULL,
+    APRType NVARCHAR(30) NOT NULL,
+    APRPercentage FLOAT NOT NULL,
+    BalanceSubjectToAPR FLOAT NULL,
+    InterestChargeAmount FLOAT NULL
+);
+CREATE INDEX IX_CFA_CreditCardAPRs_AccountID ON dbo.CFA_CreditCardAPRs (AccountID);
+CREATE INDEX IX_CFA_CreditCardAPRs_LinkedInstitutionID ON dbo.CFA_CreditCardAPRs (LinkedInstitutionID);
+
+CREATE TABLE dbo.CFA_StudentLoans (
+    StudentLoanID INT IDENTITY(1,1) NOT NULL PRIMARY KEY,
+    LinkedInstitutionID INT NOT NULL,
+    AccountID INT NOT NULL,
+    LoanName NVARCHAR(255) NULL,
+    LoanStatus NVARCHAR(50) NULL,
+    LoanStatusEndDate DATE NULL,
+    RepaymentPlan NVARCHAR(100) NULL,
+    RepaymentPlanDescription NVARCHAR(255) NULL,
+    Guarantor NVARCHAR(255) NULL,
+    InterestRatePercentage FLOAT NOT NULL,
+    IsOverdue BIT NULL,
+    LastPaymentAmount FLOAT NULL,
+    LastPaymentDate DATE NULL,
+    LastStatementBalance FLOAT NULL,
+    LastStatementIssueDate DATE NULL,
+    MinimumPaymentAmount FLOAT NULL,
+    NextPaymentDueDate DATE NULL,
+    OriginationDate DATE NULL,
+    OriginationPrincipalAmount FLOAT NULL,
+    OutstandingInterestAmount FLOAT NULL,
+    ExpectedPayoffDate DATE NULL,
+    YtdInterestPaid FLOAT NULL,
+    YtdPrincipalPaid FLOAT NULL,
+    UpdatedAt DATETIME2 NOT NULL
+);
+CREATE UNIQUE INDEX UX_CFA_StudentLoans_AccountID ON dbo.CFA_StudentLoans (AccountID);
+CREATE INDEX IX_CFA_StudentLoans_LinkedInstitutionID ON dbo.CFA_StudentLoans (LinkedInstitutionID);
+
+CREATE TABLE dbo.CFA_Mortgages (
+    MortgageID INT IDENTITY(1,1) NOT NULL PRIMARY KEY,
+    LinkedInstitutionID INT NOT NULL,
+    AccountID INT NOT NULL,
+    LoanTypeDescription NVARCHAR(255) NULL,
+    LoanTerm NVARCHAR(50) NULL,
+    InterestRatePercentage FLOAT NULL,
+    InterestRateType NVARCHAR(20) NULL,
+    OriginationDate DATE NULL,
+    OriginationPrincipalAmount FLOAT NULL,
+    MaturityDate DATE NULL,
+    EscrowBalance FLOAT NULL,
+    HasPMI BIT NULL,
+    HasPrepaymentPenalty BIT NULL,
+    NextMonthlyPayment FLOAT NULL,
+    NextPaymentDueDate DATE NULL,
+    LastPaymentAmount FLOAT NULL,
+    LastPaymentDate DATE NULL,
+    PastDueAmount FLOAT NULL,
+    CurrentLateFee FLOAT NULL,
+    YtdInterestPaid FLOAT NULL,
+    YtdPrincipalPaid FLOAT NULL,
+    UpdatedAt DATETIME2 NOT NULL
+);
+CREATE UNIQUE INDEX UX_CFA_Mortgages_AccountID ON dbo.CFA_Mortgages (AccountID);
+CREATE INDEX IX_CFA_Mortgages_LinkedInstitutionID ON dbo.CFA_Mortgages (LinkedInstitutionID);
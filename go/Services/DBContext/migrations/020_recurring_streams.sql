@@ -0,0 +1,28 @@
+-- Recurring transaction streams detected by Plaid
+CREATE TABLE dbo.CFA_RecurringStreams (
+    RecurringStreamID INT IDENTITY(1,1) NOT NULL PRIMARY KEY,
+    LinkedInstitutionID INT NOT NULL,
+    AccountID INT NOT NULL,
+    PlaidStreamID NVARCHAR(100) NOT NULL,
+    Direction NVARCHAR(10) NOT NULL,
+    Description NVARCHAR(500) NOT NULL,
+    MerchantName NVARCHAR(255) NULL,
+    Category NVARCHAR(100) NULL,
+    CategoryDetailed NVARCHAR(100) NULL,
+    Frequency NVARCHAR(20) NOT NULL,
+    AverageAmount FLOAT NULL,
+    LastAmount FLOAT NULL,
+    ISOCurrencyCode NVARCHAR(10) NULL,
+    UnofficialCurrencyCode NVARCHAR(10) NULL,
+    FirstDate DATE NOT NULL,
+    LastDate DATE NOT NULL,
+    PredictedNextDate DATE NULL,
+    IsActive BIT NOT NULL,
+    Status NVARCHAR(20) NOT NULL,
+    DisplayName NVARCHAR(100) NULL,
+    ReviewStatus NVARCHAR(20) NOT NULL,
+    ReviewUpdatedAt DATETIME2 NULL,
+    CreatedAt DATETIME2 NOT NULL,
+    UpdatedAt DATETIME2 NOT NULL
+);
+CREATE UNIQUE INDEX UX_CFA_RecurringStreams_LinkedInstitutionID_PlaidStreamID ON dbo.CFA_RecurringStreams (LinkedInstitutionID, PlaidStreamID);
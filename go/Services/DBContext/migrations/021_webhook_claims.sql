@@ -0,0 +1,2 @@
+-- Webhooks the worker is processing right now
+ALTER TABLE dbo.CFA_PlaidWebhooks ADD ClaimedAt DATETIME2 NULL;
@@ -0,0 +1,10 @@
+-- Passkey ceremonies waiting for their Finish call
+CREATE TABLE dbo.CFA_WebAuthnChallenges (
+    ChallengeId INT IDENTITY(1,1) NOT NULL PRIMARY KEY,
+    UserId INT NOT NULL,
+    Ceremony NVARCHAR(50) NOT NULL,
+    SessionData NVARCHAR(MAX) NOT NULL,
+    CreatedAt DATETIME2 NOT NULL,
+    ExpiresAt DATETIME2 NOT NULL
+);
+CREATE INDEX IX_CFA_WebAuthnChallenges_UserId ON dbo.CFA_WebAuthnChallenges (UserId);
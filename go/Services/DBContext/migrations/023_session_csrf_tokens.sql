@@ -0,0 +1,4 @@
+-- Random CSRF token issued with each session, stored as a SHA-256 hash.
+-- Sessions created before have NULL and get a token the next time their cookies are set
+ALTER TABLE dbo.CFA_Sessions ADD
+    CSRFTokenHash NVARCHAR(64) NULL;
@@ -0,0 +1,4 @@
+-- Second factor attempts per user. Reaching the limit locks the second factor until MFALockedUntil
+ALTER TABLE dbo.CFA_Users ADD
+    MFAFailedAttempts INT NOT NULL CONSTRAINT DF_CFA_Users_MFAFailedAttempts DEFAULT 0,
+    MFALockedUntil DATETIME2 NULL;
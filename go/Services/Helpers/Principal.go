@@ -0,0 +1,75 @@
+/*
+------------------------------------------------------------------
+FILE NAME:     Principal.go
+PROJECT:       CashflowAnalysis
+Date Created:  Oct-19-2026
+--------------------------------------------------------------------
+DESCRIPTION:
+The authenticated caller of a request. Set on the request context by the
+auth middleware so handlers and services don't need to re-read the session cookie
+--------------------------------------------------------------------
+$HISTORY:
+
+Oct-19-2026   Created initial file.
+Oct-19-2026   Added AuthMethod, TokenID and Scopes for personal access tokens
+Oct-19-2026   Added ImpersonatorID and ImpersonatorUsername for admin impersonation
+------------------------------------------------------------------
+*/
+
+package helpers
+
+import (
+	"context"
+	"net/http"
+)
+
+const (
+	AuthMethodSession = "session"
+	AuthMethodToken   = "token"
+)
+
+type Principal struct {
+	UserID     int    `json:"user_id"`
+	Username   string `json:"username"`
+	Role       string `json:"role"`
+	AuthMethod string `json:"auth_method"`
+	//Set when authenticated by the session-id cookie
+	SessionID int `json:"session_id"`
+	//Set when authenticated by a personal access token
+	TokenID int      `json:"token_id"`
+	Scopes  []string `json:"scopes"`
+	//Set when an admin is viewing the app as this user. Such sessions are read-only
+	ImpersonatorID       int    `json:"impersonator_id,omitempty"`
+	ImpersonatorUsername string `json:"impersonator_username,omitempty"`
+}
+
+// True when the request comes from an admin impersonating the user
+func (p Principal) IsImpersonation() bool {
+	return p.ImpersonatorID != 0
+}
+
+// Cookie sessions can do anything the user can, tokens only what their scopes allow
+func (p Principal) HasScope(scope string) bool {
+	if p.AuthMethod != AuthMethodToken {
+		return true
+	}
+	for _, s := range p.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+type principalKey struct{}
+
+// Returns a copy of the request carrying the principal
+func WithPrincipal(r *http.Request, p Principal) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), principalKey{}, p))
+}
+
+// Returns the principal set by the auth middleware, false if the request is unauthenticated
+func GetPrincipal(r *http.Request) (Principal, bool) {
+	p, ok := r.Context().Value(principalKey{}).(Principal)
+	return p, ok
+}
@@ -0,0 +1,86 @@
+/*
+------------------------------------------------------------------
+FILE NAME:     RequestInfo.go
+PROJECT:       CashflowAnalysis
+Date Created:  Oct-19-2026
+--------------------------------------------------------------------
+DESCRIPTION:
+Helper methods to describe where a request came from (IP address,
+user agent and a readable device name)
+--------------------------------------------------------------------
+$HISTORY:
+
+Oct-19-2026   Created initial file.
+------------------------------------------------------------------
+*/
+
+package helpers
+
+import (
+	"net"
+	"net/http"
+	"os"
+	"strings"
+)
+
+const maxUserAgentLength = 512
+
+// Returns the clients IP address. X-Forwarded-For is only trusted when
+// TRUST_PROXY_HEADERS=true (i.e. running behind a reverse proxy)
+func ClientIP(r *http.Request) string {
+	if strings.EqualFold(os.Getenv("TRUST_PROXY_HEADERS"), "true") {
+		if forwarded := r.Header.Get("X-Forwarded-For"); forwarded != "" {
+			first, _, _ := strings.Cut(forwarded, ",")
+			return strings.TrimSpace(first)
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// Returns the user agent truncated to fit the database column
+func UserAgent(r *http.Request) string {
+	ua := r.UserAgent()
+	if len(ua) > maxUserAgentLength {
+		ua = ua[:maxUserAgentLength]
+	}
+	return ua
+}
+
+// Builds a short readable name like "Chrome on Windows" from a user agent
+func DeviceName(userAgent string) string {
+	browser := "Unknown browser"
+	switch {
+	case strings.Contains(userAgent, "Edg/"):
+		browser = "Edge"
+	case strings.Contains(userAgent, "OPR/"):
+		browser = "Opera"
+	case strings.Contains(userAgent, "Firefox/"):
+		browser = "Firefox"
+	case strings.Contains(userAgent, "Chrome/"):
+		browser = "Chrome"
+	case strings.Contains(userAgent, "Safari/"):
+		browser = "Safari"
+	case strings.Contains(userAgent, "curl/"):
+		browser = "curl"
+	}
+
+	platform := "Unknown OS"
+	switch {
+	case strings.Contains(userAgent, "iPhone"), strings.Contains(userAgent, "iPad"):
+		platform = "iOS"
+	case strings.Contains(userAgent, "Android"):
+		platform = "Android"
+	case strings.Contains(userAgent, "Windows"):
+		platform = "Windows"
+	case strings.Contains(userAgent, "Mac OS X"):
+		platform = "macOS"
+	case strings.Contains(userAgent, "Linux"):
+		platform = "Linux"
+	}
+
+	return browser + " on " + platform
+}
@@ -0,0 +1,156 @@
+/*
+------------------------------------------------------------------
+FILE NAME:     SecurityEvents.go
+PROJECT:       CashflowAnalysis
+Date Created:  Oct-19-2026
+--------------------------------------------------------------------
+DESCRIPTION:
+Records security events (logins, logouts, password changes, bank links, ...)
+to the append-only DB_SecurityEvents table and queries them. Events are
+only ever inserted, there is no update or delete.
+--------------------------------------------------------------------
+$HISTORY:
+
+Oct-19-2026   Created initial file.
+Oct-19-2026   Added EventEmailVerification
+Oct-19-2026   Added passkey login, registration and removal events
+Oct-19-2026   Added impersonation events
+Oct-19-2026   Added EventItemStatus for item webhooks from Plaid
+------------------------------------------------------------------
+*/
+package helpers
+
+import (
+	services "cashflowanalysis/Services/DBContext"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	EventLogin               = "login"
+	EventLoginMFA            = "login_mfa"
+	EventLoginSSO            = "login_sso"
+	EventLogout              = "logout"
+	EventSessionRefresh      = "session_refresh"
+	EventPasswordChange      = "password_change"
+	EventPasswordReset       = "password_reset"
+	EventPublicTokenExchange = "public_token_exchange"
+	EventItemRemoval         = "item_removal"
+	EventEmailVerification   = "email_verification"
+	EventLoginPasskey        = "login_passkey"
+	EventPasskeyRegistration = "passkey_registration"
+	EventPasskeyRemoval      = "passkey_removal"
+	EventImpersonationStart  = "impersonation_start"
+	EventImpersonationEnd    = "impersonation_end"
+	EventImpersonatedRequest = "impersonated_request"
+	EventItemStatus          = "item_status"
+
+	OutcomeSuccess = "success"
+	OutcomeFailure = "failure"
+
+	maxEventDetailLen = 500
+)
+
+var SecurityEventTypes = []string{
+	EventLogin, EventLoginMFA, EventLoginSSO, EventLogout, EventSessionRefresh,
+	EventPasswordChange, EventPasswordReset, EventPublicTokenExchange, EventItemRemoval,
+	EventEmailVerification, EventLoginPasskey, EventPasskeyRegistration, EventPasskeyRemoval,
+	EventImpersonationStart, EventImpersonationEnd, EventImpersonatedRequest, EventItemStatus,
+}
+
+// Event to record. IP address and user agent are taken from the request
+type SecurityEvent struct {
+	UserID   int
+	Username string
+	Type     string
+	Success  bool
+	Detail   string
+}
+
+// Filters for ListSecurityEvents, zero values are ignored
+type SecurityEventFilter struct {
+	UserID    int
+	EventType string
+	Outcome   string
+	IPAddress string
+	From      time.Time
+	To        time.Time
+	Limit     int
+	Offset    int
+}
+
+// Appends the event to the security log. r may be nil for events raised outside
+// of a request (i.e. background jobs). Failures are logged, never returned, so
+// recording an event can't break the action being recorded
+func RecordSecurityEvent(r *http.Request, event SecurityEvent) {
+	outcome := OutcomeFailure
+	if event.Success {
+		outcome = OutcomeSuccess
+	}
+	detail := event.Detail
+	if len(detail) > maxEventDetailLen {
+		detail = detail[:maxEventDetailLen]
+	}
+	row := services.DB_SecurityEvents{
+		UserId:    event.UserID,
+		Username:  event.Username,
+		EventType: event.Type,
+		Outcome:   outcome,
+		Detail:    detail,
+		CreatedAt: time.Now().UTC(),
+	}
+	if r != nil {
+		row.IPAddress = ClientIP(r)
+		row.UserAgent = UserAgent(r)
+	}
+	if _, err := services.CreateObjectDB(row); err != nil {
+		fmt.Println("could not record security event", event.Type, err)
+	}
+}
+
+// Lists events matching the filter, newest first
+func ListSecurityEvents(filter SecurityEventFilter) ([]services.DB_SecurityEvents, error) {
+	if filter.Limit <= 0 || filter.Limit > 200 {
+		filter.Limit = 50
+	}
+	if filter.Offset < 0 {
+		filter.Offset = 0
+	}
+
+	conditions := []string{"1 = 1"}
+	args := []interface{}{sql.Named("Limit", filter.Limit), sql.Named("Offset", filter.Offset)}
+	if filter.UserID != 0 {
+		conditions = append(conditions, "UserId = @UserId")
+		args = append(args, sql.Named("UserId", filter.UserID))
+	}
+	if filter.EventType != "" {
+		conditions = append(conditions, "EventType = @EventType")
+		args = append(args, sql.Named("EventType", filter.EventType))
+	}
+	if filter.Outcome != "" {
+		conditions = append(conditions, "Outcome = @Outcome")
+		args = append(args, sql.Named("Outcome", filter.Outcome))
+	}
+	if filter.IPAddress != "" {
+		conditions = append(conditions, "IPAddress = @IPAddress")
+		args = append(args, sql.Named("IPAddress", filter.IPAddress))
+	}
+	if !filter.From.IsZero() {
+		conditions = append(conditions, "CreatedAt >= @From")
+		args = append(args, sql.Named("From", filter.From.UTC()))
+	}
+	if !filter.To.IsZero() {
+		conditions = append(conditions, "CreatedAt < @To")
+		args = append(args, sql.Named("To", filter.To.UTC()))
+	}
+	clause := strings.Join(conditions, " AND ") + " ORDER BY CreatedAt DESC, EventId DESC OFFSET @Offset ROWS FETCH NEXT @Limit ROWS ONLY"
+
+	events, err := services.QueryObjectDB(&services.DB_SecurityEvents{}, clause, args...)
+	if events == nil {
+		events = []services.DB_SecurityEvents{}
+	}
+	return events, err
+}
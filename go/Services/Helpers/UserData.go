@@ -11,6 +11,7 @@ $HISTORY:
 
 Dec-24-2025   Created initial file.
 Jan-06-2025   Added GetUserID()
+Oct-19-2026   GetUserID() uses the principal set by the auth middleware when present
 ------------------------------------------------------------------
 */
 
@@ -23,8 +24,12 @@ import (
 	"strconv"
 )
 
-// Retrieves the users ID from the session-id cookie
+// Retrieves the users ID from the request principal, falling back to the session-id cookie
 func GetUserID(r *http.Request) int {
+	if p, ok := GetPrincipal(r); ok {
+		return p.UserID
+	}
+
 	//Load cookie session ID
 	sessionID, err := cookies.GetCookie(r, "session-id")
 	if err != nil {
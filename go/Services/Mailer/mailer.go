@@ -0,0 +1,135 @@
+/*
+------------------------------------------------------------------
+FILE NAME:     mailer.go
+PROJECT:       CashflowAnalysis
+Date Created:  Oct-19-2026
+--------------------------------------------------------------------
+DESCRIPTION:
+Pluggable email delivery. The Mailer interface is implemented by an SMTP
+mailer for real delivery and a file outbox mailer that writes each message
+to disk so email flows can be exercised locally.
+
+MAILER=smtp uses SMTP_HOST, SMTP_PORT, SMTP_USERNAME and SMTP_PASSWORD
+MAILER=file (default) writes .eml files to MAIL_OUTBOX_DIR
+--------------------------------------------------------------------
+$HISTORY:
+
+Oct-19-2026   Created initial file.
+------------------------------------------------------------------
+*/
+package mailer
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/smtp"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+type Message struct {
+	To      []string
+	Subject string
+	Body    string
+}
+
+type Mailer interface {
+	Send(msg Message) error
+}
+
+// Sends mail through an SMTP relay. Uses STARTTLS when the server supports it
+type SMTPMailer struct {
+	Host     string
+	Port     string
+	Username string
+	Password string
+	From     string
+}
+
+// Writes each message as an .eml file into Dir instead of sending it
+type FileOutboxMailer struct {
+	Dir  string
+	From string
+}
+
+var (
+	defaultMailer Mailer
+	defaultOnce   sync.Once
+)
+
+// Returns the mailer configured by the environment. Created once on first use
+func Default() Mailer {
+	defaultOnce.Do(func() {
+		defaultMailer = NewFromEnv()
+	})
+	return defaultMailer
+}
+
+// Builds a mailer from the MAILER environment variable
+func NewFromEnv() Mailer {
+	from := os.Getenv("MAIL_FROM")
+	if from == "" {
+		from = "no-reply@cashflowanalysis.local"
+	}
+
+	if strings.EqualFold(os.Getenv("MAILER"), "smtp") {
+		port := os.Getenv("SMTP_PORT")
+		if port == "" {
+			port = "587"
+		}
+		return &SMTPMailer{
+			Host:     os.Getenv("SMTP_HOST"),
+			Port:     port,
+			Username: os.Getenv("SMTP_USERNAME"),
+			Password: os.Getenv("SMTP_PASSWORD"),
+			From:     from,
+		}
+	}
+
+	dir := os.Getenv("MAIL_OUTBOX_DIR")
+	if dir == "" {
+		dir = "outbox"
+	}
+	return &FileOutboxMailer{Dir: dir, From: from}
+}
+
+func (m *SMTPMailer) Send(msg Message) error {
+	if m.Host == "" {
+		return fmt.Errorf("SMTP_HOST is not set")
+	}
+	var auth smtp.Auth
+	if m.Username != "" {
+		auth = smtp.PlainAuth("", m.Username, m.Password, m.Host)
+	}
+	return smtp.SendMail(m.Host+":"+m.Port, auth, m.From, msg.To, formatMessage(m.From, msg))
+}
+
+func (m *FileOutboxMailer) Send(msg Message) error {
+	if err := os.MkdirAll(m.Dir, 0o700); err != nil {
+		return err
+	}
+	suffix := make([]byte, 4)
+	if _, err := rand.Read(suffix); err != nil {
+		return err
+	}
+	name := fmt.Sprintf("%s-%s.eml", time.Now().UTC().Format("20060102T150405"), hex.EncodeToString(suffix))
+	return os.WriteFile(filepath.Join(m.Dir, name), formatMessage(m.From, msg), 0o600)
+}
+
+// Formats the message with RFC 5322 headers and CRLF line endings
+func formatMessage(from string, msg Message) []byte {
+	var b strings.Builder
+	b.WriteString("From: " + from + "\r\n")
+	b.WriteString("To: " + strings.Join(msg.To, ", ") + "\r\n")
+	b.WriteString("Subject: " + msg.Subject + "\r\n")
+	b.WriteString("Date: " + time.Now().UTC().Format(time.RFC1123Z) + "\r\n")
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	b.WriteString("\r\n")
+	b.WriteString(strings.ReplaceAll(msg.Body, "\n", "\r\n"))
+	return []byte(b.String())
+}
@@ -0,0 +1,220 @@
+/*
+------------------------------------------------------------------
+FILE NAME:     accessTokens.go
+PROJECT:       CashflowAnalysis
+Date Created:  Oct-19-2026
+--------------------------------------------------------------------
+DESCRIPTION:
+Personal access tokens for scripted read-only (or widget) api access.
+Tokens are shown to the user once when created, only a hash is stored.
+Each token has a name, a set of scopes and an expiry and can be revoked.
+--------------------------------------------------------------------
+$HISTORY:
+
+Oct-19-2026   Created initial file.
+------------------------------------------------------------------
+*/
+package userauth
+
+import (
+	services "cashflowanalysis/Services/DBContext"
+	helper "cashflowanalysis/Services/Helpers"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	ScopeAccountsRead     = "accounts:read"
+	ScopeTransactionsRead = "transactions:read"
+	ScopeWidgetsRead      = "widgets:read"
+	ScopeWidgetsWrite     = "widgets:write"
+
+	accessTokenPrefix       = "cfa_"
+	DefaultAccessTokenDays  = 90
+	MaxAccessTokenDays      = 365
+	maxAccessTokenNameLen   = 100
+	accessTokenUsedInterval = time.Minute
+)
+
+var AccessTokenScopes = []string{ScopeAccountsRead, ScopeTransactionsRead, ScopeWidgetsRead, ScopeWidgetsWrite}
+
+var (
+	ErrInvalidScope       = fmt.Errorf("scopes must be one or more of %s", strings.Join(AccessTokenScopes, ", "))
+	ErrInvalidTokenName   = fmt.Errorf("token name is required and must be at most %d characters", maxAccessTokenNameLen)
+	ErrInvalidTokenExpiry = fmt.Errorf("expires_in_days must be between 1 and %d", MaxAccessTokenDays)
+	ErrTokenNotFound      = errors.New("access token not found")
+)
+
+// Token details returned to the user, never includes the token or its hash
+type AccessTokenInfo struct {
+	TokenId     int        `json:"token_id"`
+	Name        string     `json:"name"`
+	TokenPrefix string     `json:"token_prefix"`
+	Scopes      []string   `json:"scopes"`
+	CreatedAt   time.Time  `json:"created_at"`
+	ExpiresAt   time.Time  `json:"expires_at"`
+	LastUsedAt  *time.Time `json:"last_used_at"`
+	Revoked     bool       `json:"revoked"`
+}
+
+// Creates a new token for the user. Returns the plain token, which is only available now
+func CreateAccessToken(userID int, name string, scopes []string, expiresInDays int) (string, AccessTokenInfo, error) {
+	name = strings.TrimSpace(name)
+	if name == "" || len(name) > maxAccessTokenNameLen {
+		return "", AccessTokenInfo{}, ErrInvalidTokenName
+	}
+	if expiresInDays == 0 {
+		expiresInDays = DefaultAccessTokenDays
+	}
+	if expiresInDays < 0 || expiresInDays > MaxAccessTokenDays {
+		return "", AccessTokenInfo{}, ErrInvalidTokenExpiry
+	}
+	scopes, err := normalizeScopes(scopes)
+	if err != nil {
+		return "", AccessTokenInfo{}, err
+	}
+
+	secret, err := randomToken(32)
+	if err != nil {
+		return "", AccessTokenInfo{}, err
+	}
+	token := accessTokenPrefix + secret
+
+	now := time.Now().UTC()
+	pat := services.DB_PersonalAccessTokens{
+		UserId:      userID,
+		Name:        name,
+		TokenPrefix: token[:len(accessTokenPrefix)+6],
+		TokenHash:   hashToken(token),
+		Scopes:      strings.Join(scopes, ","),
+		CreatedAt:   now,
+		ExpiresAt:   now.AddDate(0, 0, expiresInDays),
+		LastUsedAt:  sql.NullTime{Valid: false},
+		RevokedAt:   sql.NullTime{Valid: false},
+	}
+	pat.TokenId, err = services.CreateObjectDB(pat)
+	if err != nil {
+		return "", AccessTokenInfo{}, err
+	}
+	return token, accessTokenInfo(pat), nil
+}
+
+// Lists all of the users tokens, including revoked and expired ones
+func ListAccessTokens(userID int) ([]AccessTokenInfo, error) {
+	pats, err := services.LoadObjectDB(&services.DB_PersonalAccessTokens{UserId: userID}, "UserId")
+	if err != nil {
+		return nil, err
+	}
+	infos := []AccessTokenInfo{}
+	for _, pat := range pats {
+		infos = append(infos, accessTokenInfo(pat))
+	}
+	return infos, nil
+}
+
+// Revokes one of the users tokens
+func RevokeAccessToken(userID int, tokenID int) error {
+	pats, err := services.LoadObjectDB(&services.DB_PersonalAccessTokens{TokenId: tokenID, UserId: userID}, "TokenId", "UserId")
+	if err != nil {
+		return err
+	}
+	if len(pats) == 0 {
+		return ErrTokenNotFound
+	}
+	pat := pats[0]
+	if pat.RevokedAt.Valid {
+		return nil
+	}
+	pat.RevokedAt = sql.NullTime{Time: time.Now().UTC(), Valid: true}
+	return services.UpdateObjectDB(pat, []string{"RevokedAt"}, []string{"TokenId"})
+}
+
+// Builds a principal from a bearer token and records when it was last used
+func authenticateAccessToken(token string) (helper.Principal, error) {
+	if !strings.HasPrefix(token, accessTokenPrefix) {
+		return helper.Principal{}, ErrNotAuthorized
+	}
+	pats, err := services.LoadObjectDB(&services.DB_PersonalAccessTokens{TokenHash: hashToken(token)}, "TokenHash")
+	if err != nil {
+		return helper.Principal{}, err
+	}
+	if len(pats) == 0 {
+		return helper.Principal{}, ErrNotAuthorized
+	}
+	pat := pats[0]
+	now := time.Now().UTC()
+	if pat.RevokedAt.Valid || now.After(pat.ExpiresAt.UTC()) {
+		return helper.Principal{}, ErrNotAuthorized
+	}
+
+	user, err := loadUser(pat.UserId)
+	if err != nil {
+		return helper.Principal{}, err
+	}
+	if user.DisabledAt.Valid {
+		return helper.Principal{}, ErrAccountDisabled
+	}
+
+	//Only write last used about once a minute so busy scripts don't update the row every request
+	if !pat.LastUsedAt.Valid || now.Sub(pat.LastUsedAt.Time.UTC()) > accessTokenUsedInterval {
+		pat.LastUsedAt = sql.NullTime{Time: now, Valid: true}
+		services.UpdateObjectDB(pat, []string{"LastUsedAt"}, []string{"TokenId"})
+	}
+
+	return helper.Principal{
+		UserID:     user.UserId,
+		Username:   user.Username,
+		Role:       normalizeRole(user.Role),
+		AuthMethod: helper.AuthMethodToken,
+		TokenID:    pat.TokenId,
+		Scopes:     strings.Split(pat.Scopes, ","),
+	}, nil
+}
+
+// Validates, de-duplicates and sorts the requested scopes
+func normalizeScopes(scopes []string) ([]string, error) {
+	seen := map[string]bool{}
+	var result []string
+	for _, scope := range scopes {
+		scope = strings.TrimSpace(scope)
+		valid := false
+		for _, s := range AccessTokenScopes {
+			if s == scope {
+				valid = true
+			}
+		}
+		if !valid {
+			return nil, ErrInvalidScope
+		}
+		if !seen[scope] {
+			seen[scope] = true
+			result = append(result, scope)
+		}
+	}
+	if len(result) == 0 {
+		return nil, ErrInvalidScope
+	}
+	sort.Strings(result)
+	return result, nil
+}
+
+func accessTokenInfo(pat services.DB_PersonalAccessTokens) AccessTokenInfo {
+	info := AccessTokenInfo{
+		TokenId:     pat.TokenId,
+		Name:        pat.Name,
+		TokenPrefix: pat.TokenPrefix,
+		Scopes:      strings.Split(pat.Scopes, ","),
+		CreatedAt:   pat.CreatedAt,
+		ExpiresAt:   pat.ExpiresAt,
+		Revoked:     pat.RevokedAt.Valid,
+	}
+	if pat.LastUsedAt.Valid {
+		t := pat.LastUsedAt.Time
+		info.LastUsedAt = &t
+	}
+	return info
+}
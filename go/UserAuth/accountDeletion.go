@@ -0,0 +1,369 @@
+/*
+------------------------------------------------------------------
+FILE NAME:     accountDeletion.go
+PROJECT:       CashflowAnalysis
+Date Created:  Oct-19-2026
+--------------------------------------------------------------------
+DESCRIPTION:
+Self-service account deletion. A re-authenticated user schedules deletion,
+which can be cancelled until the grace period ends. The deletion worker then
+removes the users Plaid items, erases every row they own, revokes their
+sessions and stores (and emails) a deletion receipt.
+--------------------------------------------------------------------
+$HISTORY:
+
+Oct-19-2026   Created initial file.
+Oct-19-2026   Also erases the users passkeys
+Oct-19-2026   A recent passkey or single sign-on login can confirm the deletion instead of the password
+Oct-19-2026   The data is erased and the deletion completed in one transaction, using
+-             services.EraseRowsTx()
+Oct-19-2026   Also erases pending passkey challenges
+Oct-19-2026   Confirming with password and 2FA code goes through reauthenticate() and its attempt limit
+Oct-19-2026   processAccountDeletion() returns the error when the failed item removal can't be recorded
+------------------------------------------------------------------
+*/
+package userauth
+
+import (
+	services "cashflowanalysis/Services/DBContext"
+	mailer "cashflowanalysis/Services/Mailer"
+	accData "cashflowanalysis/UserBankAccountData"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	DefaultDeletionGraceDays = 7
+	//After this many failed attempts to remove Plaid items the data is erased anyway
+	maxItemRemovalAttempts = 5
+)
+
+var (
+	ErrDeletionAlreadyScheduled = errors.New("account deletion is already scheduled")
+	ErrNoDeletionScheduled      = errors.New("no account deletion is scheduled")
+	ErrReauthenticationRequired = errors.New("log in again or enter your password to continue")
+)
+
+// Pending deletion details returned to the user
+type AccountDeletionInfo struct {
+	DeletionId   int       `json:"deletion_id"`
+	RequestedAt  time.Time `json:"requested_at"`
+	ScheduledFor time.Time `json:"scheduled_for"`
+}
+
+// Record of what was erased, stored with the deletion and emailed to the user
+type DeletionReceipt struct {
+	ReceiptId         string         `json:"receipt_id"`
+	UserId            int            `json:"user_id"`
+	RequestedAt       time.Time      `json:"requested_at"`
+	CompletedAt       time.Time      `json:"completed_at"`
+	PlaidItemsRemoved int            `json:"plaid_items_removed"`
+	PlaidItemsFailed  []string       `json:"plaid_items_failed"`
+	RowsDeleted       map[string]int `json:"rows_deleted"`
+}
+
+// Schedules deletion of the logged in users account after re-authenticating with
+// their password (and a 2FA code when enabled). Without a password the session must
+// come from a login within RecentAuthWindow, so passkey and single sign-on users can
+// log in again instead. With a grace period of 0 days the account is erased
+// immediately and a receipt is returned
+func ScheduleAccountDeletion(r *http.Request, w http.ResponseWriter, password string, code string) (AccountDeletionInfo, *DeletionReceipt, error) {
+	user, err := AuthenticatedUser(r)
+	if err != nil {
+		return AccountDeletionInfo{}, nil, err
+	}
+	if err := confirmPresence(r, &user, password, code); err != nil {
+		return AccountDeletionInfo{}, nil, err
+	}
+
+	pending, err := pendingDeletion(user.UserId)
+	if err != nil {
+		return AccountDeletionInfo{}, nil, err
+	}
+	if pending != nil {
+		return AccountDeletionInfo{}, nil, ErrDeletionAlreadyScheduled
+	}
+
+	now := time.Now().UTC()
+	graceDays := deletionGraceDays()
+	deletion := services.DB_AccountDeletions{
+		UserId:       user.UserId,
+		RequestedAt:  now,
+		ScheduledFor: now.AddDate(0, 0, graceDays),
+		CancelledAt:  sql.NullTime{Valid: false},
+		CompletedAt:  sql.NullTime{Valid: false},
+	}
+	deletion.DeletionId, err = services.CreateObjectDB(deletion)
+	if err != nil {
+		return AccountDeletionInfo{}, nil, err
+	}
+	info := deletionInfo(deletion)
+
+	if graceDays == 0 {
+		receipt, err := processAccountDeletion(deletion)
+		if err == nil {
+			_ = setSessionCookies(w, "", DeleteCookieExpiry)
+		}
+		return info, receipt, err
+	}
+
+	if user.Email.Valid {
+		err = mailer.Default().Send(mailer.Message{
+			To:      []string{user.Email.String},
+			Subject: "Your CashflowAnalysis account is scheduled for deletion",
+			Body: fmt.Sprintf("Hi %s,\n\nYour account and all of its data will be permanently deleted on %s.\n\nIf you did not request this, log in before then and cancel the deletion from your account settings.\n",
+				user.Username, deletion.ScheduledFor.Format("Jan 2, 2006 15:04 MST")),
+		})
+		if err != nil {
+			log.Println("could not send deletion scheduled email:", err)
+		}
+	}
+	return info, nil, nil
+}
+
+// Confirms the user is present, either by password and 2FA code or by a recent login
+func confirmPresence(r *http.Request, user *services.DB_Users, password string, code string) error {
+	if password == "" {
+		session, err := authenticatedSession(r)
+		if err != nil {
+			return err
+		}
+		if !recentlyAuthenticated(session) {
+			return ErrReauthenticationRequired
+		}
+		return nil
+	}
+	if !user.TOTPEnabled {
+		if !checkPasswordHash(password, user.PasswordHash) {
+			return ErrInvalidCredentials
+		}
+		return nil
+	}
+	reauthenticated, err := reauthenticate(user.UserId, password, code)
+	if err != nil {
+		return err
+	}
+	*user = reauthenticated
+	return nil
+}
+
+// Returns the logged in users pending deletion, nil when none is scheduled
+func AccountDeletionStatus(userID int) (*AccountDeletionInfo, error) {
+	pending, err := pendingDeletion(userID)
+	if err != nil || pending == nil {
+		return nil, err
+	}
+	info := deletionInfo(*pending)
+	return &info, nil
+}
+
+// Cancels the users pending deletion during the grace period
+func CancelAccountDeletion(userID int) error {
+	pending, err := pendingDeletion(userID)
+	if err != nil {
+		return err
+	}
+	if pending == nil {
+		return ErrNoDeletionScheduled
+	}
+	pending.CancelledAt = sql.NullTime{Time: time.Now().UTC(), Valid: true}
+	return services.UpdateObjectDB(*pending, []string{"CancelledAt"}, []string{"DeletionId"})
+}
+
+// Runs ProcessDueAccountDeletions() every interval, for use as a background goroutine
+func RunAccountDeletionWorker(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if err := ProcessDueAccountDeletions(); err != nil {
+			log.Println("account deletion worker:", err)
+		}
+		<-ticker.C
+	}
+}
+
+// Erases every account whose grace period has ended
+func ProcessDueAccountDeletions() error {
+	due, err := services.QueryObjectDB(&services.DB_AccountDeletions{},
+		"CancelledAt IS NULL AND CompletedAt IS NULL AND ScheduledFor <= @Now ORDER BY ScheduledFor",
+		sql.Named("Now", time.Now().UTC()))
+	if err != nil {
+		return err
+	}
+	for _, deletion := range due {
+		if _, err := processAccountDeletion(deletion); err != nil {
+			log.Println("could not delete account for deletion", deletion.DeletionId, ":", err)
+		}
+	}
+	return nil
+}
+
+// Disables the account, removes Plaid items, erases all user rows in dependency
+// order and records the receipt. Plaid failures are retried on later runs before
+// the data is erased regardless
+func processAccountDeletion(deletion services.DB_AccountDeletions) (*DeletionReceipt, error) {
+	receipt := DeletionReceipt{
+		UserId:      deletion.UserId,
+		RequestedAt: deletion.RequestedAt,
+		RowsDeleted: map[string]int{},
+	}
+	var err error
+	if receipt.ReceiptId, err = randomToken(12); err != nil {
+		return nil, err
+	}
+
+	user, err := loadUser(deletion.UserId)
+	userExists := err == nil
+	if err != nil && !errors.Is(err, ErrNotAuthorized) {
+		return nil, err
+	}
+
+	if userExists {
+		//Lock the account first so nothing can be added while it is erased
+		if !user.DisabledAt.Valid {
+			user.DisabledAt = sql.NullTime{Time: time.Now().UTC(), Valid: true}
+			if err := services.UpdateObjectDB(user, []string{"DisabledAt"}, []string{"UserId"}); err != nil {
+				return nil, err
+			}
+		}
+		if err := revokeUserSessions(user.UserId, 0); err != nil {
+			return nil, err
+		}
+	}
+
+	receipt.PlaidItemsRemoved, receipt.PlaidItemsFailed, err = accData.RemoveUserItems(deletion.UserId)
+	if err != nil {
+		return nil, err
+	}
+	if len(receipt.PlaidItemsFailed) > 0 && deletion.Attempts+1 < maxItemRemovalAttempts {
+		deletion.Attempts++
+		deletion.LastError = "could not remove plaid items: " + strings.Join(receipt.PlaidItemsFailed, ", ")
+		//Without the attempt recorded the deletion would retry the removal forever
+		if err := services.UpdateObjectDB(deletion, []string{"Attempts", "LastError"}, []string{"DeletionId"}); err != nil {
+			return nil, fmt.Errorf("%s, could not record the attempt: %w", deletion.LastError, err)
+		}
+		return nil, errors.New(deletion.LastError)
+	}
+
+	//Either everything is erased and the deletion completed, or nothing is and a later run retries
+	err = services.RunInTransactionDB(func(tx *services.TxDB) error {
+		counts := map[string]int{}
+		if err := accData.EraseUserBankData(tx, deletion.UserId, counts); err != nil {
+			return err
+		}
+		if err := eraseUserAuthData(tx, deletion.UserId, counts); err != nil {
+			return err
+		}
+		receipt.RowsDeleted = counts
+		receipt.CompletedAt = time.Now().UTC()
+		encoded, err := json.Marshal(receipt)
+		if err != nil {
+			return err
+		}
+		deletion.CompletedAt = sql.NullTime{Time: receipt.CompletedAt, Valid: true}
+		deletion.ReceiptId = receipt.ReceiptId
+		deletion.Receipt = string(encoded)
+		deletion.LastError = ""
+		return tx.UpdateObject(deletion, []string{"CompletedAt", "ReceiptId", "Receipt", "LastError"}, []string{"DeletionId"})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if userExists && user.Email.Valid {
+		sendDeletionReceipt(user, receipt)
+	}
+	return &receipt, nil
+}
+
+// Deletes the users authentication rows and finally the user inside tx
+func eraseUserAuthData(tx *services.TxDB, userID int, counts map[string]int) error {
+	if err := services.EraseRowsTx(tx, counts, "PersonalAccessTokens", &services.DB_PersonalAccessTokens{UserId: userID}, "UserId"); err != nil {
+		return err
+	}
+	if err := services.EraseRowsTx(tx, counts, "PasswordResetTokens", &services.DB_PasswordResetTokens{UserId: userID}, "UserId"); err != nil {
+		return err
+	}
+	if err := services.EraseRowsTx(tx, counts, "RecoveryCodes", &services.DB_RecoveryCodes{UserId: userID}, "UserId"); err != nil {
+		return err
+	}
+	if err := services.EraseRowsTx(tx, counts, "MFAChallenges", &services.DB_MFAChallenges{UserId: userID}, "UserId"); err != nil {
+		return err
+	}
+	if err := services.EraseRowsTx(tx, counts, "WebAuthnChallenges", &services.DB_WebAuthnChallenges{UserId: userID}, "UserId"); err != nil {
+		return err
+	}
+	if err := services.EraseRowsTx(tx, counts, "WebAuthnCredentials", &services.DB_WebAuthnCredentials{UserId: userID}, "UserId"); err != nil {
+		return err
+	}
+	if err := services.EraseRowsTx(tx, counts, "UserIdentities", &services.DB_UserIdentities{UserId: userID}, "UserId"); err != nil {
+		return err
+	}
+	if err := services.EraseRowsTx(tx, counts, "Sessions", &services.DB_Sessions{UserId: userID}, "UserId"); err != nil {
+		return err
+	}
+	return services.EraseRowsTx(tx, counts, "Users", &services.DB_Users{UserId: userID}, "UserId")
+}
+
+func sendDeletionReceipt(user services.DB_Users, receipt DeletionReceipt) {
+	var body strings.Builder
+	fmt.Fprintf(&body, "Hi %s,\n\nYour CashflowAnalysis account has been deleted.\n\n", user.Username)
+	fmt.Fprintf(&body, "Receipt: %s\nRequested: %s\nCompleted: %s\n\n", receipt.ReceiptId,
+		receipt.RequestedAt.Format(time.RFC1123), receipt.CompletedAt.Format(time.RFC1123))
+	fmt.Fprintf(&body, "Bank connections removed: %d\n", receipt.PlaidItemsRemoved)
+	if len(receipt.PlaidItemsFailed) > 0 {
+		fmt.Fprintf(&body, "Bank connections that could not be removed at Plaid (their data was still deleted): %d\n", len(receipt.PlaidItemsFailed))
+	}
+	body.WriteString("\nRecords deleted:\n")
+	for table, count := range receipt.RowsDeleted {
+		fmt.Fprintf(&body, "  %s: %d\n", table, count)
+	}
+
+	err := mailer.Default().Send(mailer.Message{
+		To:      []string{user.Email.String},
+		Subject: "Your CashflowAnalysis account has been deleted",
+		Body:    body.String(),
+	})
+	if err != nil {
+		log.Println("could not send deletion receipt:", err)
+	}
+}
+
+// Loads the users deletion that has not been cancelled or completed
+func pendingDeletion(userID int) (*services.DB_AccountDeletions, error) {
+	deletions, err := services.LoadObjectDB(&services.DB_AccountDeletions{UserId: userID}, "UserId")
+	if err != nil {
+		return nil, err
+	}
+	for _, d := range deletions {
+		if !d.CancelledAt.Valid && !d.CompletedAt.Valid {
+			return &d, nil
+		}
+	}
+	return nil, nil
+}
+
+func deletionInfo(deletion services.DB_AccountDeletions) AccountDeletionInfo {
+	return AccountDeletionInfo{
+		DeletionId:   deletion.DeletionId,
+		RequestedAt:  deletion.RequestedAt,
+		ScheduledFor: deletion.ScheduledFor,
+	}
+}
+
+// Days between requesting deletion and the data being erased, DELETION_GRACE_DAYS
+func deletionGraceDays() int {
+	days, err := strconv.Atoi(os.Getenv("DELETION_GRACE_DAYS"))
+	if err != nil || days < 0 {
+		return DefaultDeletionGraceDays
+	}
+	return days
+}
@@ -0,0 +1,164 @@
+/*
+------------------------------------------------------------------
+FILE NAME:     admin.go
+PROJECT:       CashflowAnalysis
+Date Created:  Oct-19-2026
+--------------------------------------------------------------------
+DESCRIPTION:
+User management operations for support staff and administrators:
+searching users, deactivating/reactivating accounts, forcing a logout
+and changing roles.
+--------------------------------------------------------------------
+$HISTORY:
+
+Oct-19-2026   Created initial file.
+------------------------------------------------------------------
+*/
+package userauth
+
+import (
+	services "cashflowanalysis/Services/DBContext"
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+)
+
+var (
+	ErrUserNotFound     = errors.New("user not found")
+	ErrInvalidRole      = errors.New("role must be user, support or admin")
+	ErrCannotTargetSelf = errors.New("administrators cannot change their own account")
+)
+
+// User details safe to show to support staff (no password or 2FA secrets)
+type UserSummary struct {
+	UserId      int        `json:"user_id"`
+	Username    string     `json:"username"`
+	Email       string     `json:"email"`
+	Role        string     `json:"role"`
+	IsActive    bool       `json:"is_active"`
+	Disabled    bool       `json:"disabled"`
+	DisabledAt  *time.Time `json:"disabled_at"`
+	TOTPEnabled bool       `json:"totp_enabled"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
+
+// Lists users ordered by id. search matches the start of the username or email
+func ListUsers(search string, limit int, offset int) ([]UserSummary, error) {
+	if limit <= 0 || limit > 200 {
+		limit = 50
+	}
+	if offset < 0 {
+		offset = 0
+	}
+
+	clause := "1 = 1"
+	args := []interface{}{sql.Named("Limit", limit), sql.Named("Offset", offset)}
+	if search = strings.TrimSpace(search); search != "" {
+		clause = "(Username LIKE @Search ESCAPE '\\' OR Email LIKE @Search ESCAPE '\\')"
+		args = append(args, sql.Named("Search", escapeLike(search)+"%"))
+	}
+	clause += " ORDER BY UserId OFFSET @Offset ROWS FETCH NEXT @Limit ROWS ONLY"
+
+	users, err := services.QueryObjectDB(&services.DB_Users{}, clause, args...)
+	if err != nil {
+		return nil, err
+	}
+	summaries := []UserSummary{}
+	for _, u := range users {
+		summaries = append(summaries, summarizeUser(u))
+	}
+	return summaries, nil
+}
+
+// Returns a single users summary
+func GetUserSummary(userID int) (UserSummary, error) {
+	user, err := loadUser(userID)
+	if err != nil {
+		return UserSummary{}, ErrUserNotFound
+	}
+	return summarizeUser(user), nil
+}
+
+// Deactivates or reactivates an account. Deactivating revokes all of the users sessions
+func SetUserDisabled(adminID int, userID int, disabled bool) error {
+	if adminID == userID {
+		return ErrCannotTargetSelf
+	}
+	user, err := loadUser(userID)
+	if err != nil {
+		return ErrUserNotFound
+	}
+	if disabled {
+		user.DisabledAt = sql.NullTime{Time: time.Now().UTC(), Valid: true}
+	} else {
+		user.DisabledAt = sql.NullTime{Valid: false}
+	}
+	user.UpdatedAt = time.Now().UTC()
+	err = services.UpdateObjectDB(user, []string{"DisabledAt", "UpdatedAt"}, []string{"UserId"})
+	if err != nil {
+		return err
+	}
+	if disabled {
+		return revokeUserSessions(userID, 0)
+	}
+	return nil
+}
+
+// Revokes every session of the user. Returns the number of sessions revoked
+func ForceLogout(userID int) (int, error) {
+	if _, err := loadUser(userID); err != nil {
+		return 0, ErrUserNotFound
+	}
+	sessions, err := activeSessions(userID)
+	if err != nil {
+		return 0, err
+	}
+	if err := revokeUserSessions(userID, 0); err != nil {
+		return 0, err
+	}
+	return len(sessions), nil
+}
+
+// Changes the role of a user
+func SetUserRole(adminID int, userID int, role string) error {
+	if !ValidRole(role) {
+		return ErrInvalidRole
+	}
+	if adminID == userID {
+		return ErrCannotTargetSelf
+	}
+	user, err := loadUser(userID)
+	if err != nil {
+		return ErrUserNotFound
+	}
+	user.Role = role
+	user.UpdatedAt = time.Now().UTC()
+	return services.UpdateObjectDB(user, []string{"Role", "UpdatedAt"}, []string{"UserId"})
+}
+
+func summarizeUser(u services.DB_Users) UserSummary {
+	summary := UserSummary{
+		UserId:      u.UserId,
+		Username:    u.Username,
+		Email:       u.Email.String,
+		Role:        normalizeRole(u.Role),
+		IsActive:    u.IsActive,
+		Disabled:    u.DisabledAt.Valid,
+		TOTPEnabled: u.TOTPEnabled,
+		CreatedAt:   u.CreatedAt,
+		UpdatedAt:   u.UpdatedAt,
+	}
+	if u.DisabledAt.Valid {
+		t := u.DisabledAt.Time
+		summary.DisabledAt = &t
+	}
+	return summary
+}
+
+// Escapes LIKE wildcards so user input is matched literally
+func escapeLike(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`, `[`, `\[`)
+	return r.Replace(s)
+}
@@ -13,6 +13,21 @@ $HISTORY:
 Dec-24-2025   Created initial file.
 Jan-06-2025   Minor updating for the updated DBContext handlers
 Jan-28-2026   Updated to use new DBContext functions and structs
+Oct-19-2026   AuthorizeUser() now returns an AuthResult and issues a pending-MFA challenge
+-             when 2FA is enabled. Added AuthenticatedUser()
+Oct-19-2026   CreateNewUser() accepts an optional email. Added revokeUserSessions() and currentSessionID()
+Oct-19-2026   Sessions record IP address and user agent. IsActive is derived from the users active sessions
+-             Moved revokeUserSessions() and currentSessionID() to sessions.go
+Oct-19-2026   Session cookies are set with setSessionCookies() which also issues the CSRF token
+Oct-19-2026   Disabled accounts can't log in. New users get the user role
+Oct-19-2026   Logins, logouts and session refreshes are recorded as security events
+Oct-19-2026   Replaced SessionDuration with idle and absolute timeouts. AuthorizeUser() takes rememberMe.
+-             CheckUserAuthorization() returns whether the session is still active
+Oct-19-2026   Moved password hashing to passwordHash.go. Outdated password hashes are upgraded on login
+Oct-19-2026   CreateNewUser() requires an email and sends a verification link
+Oct-19-2026   setSessionCookies() issues a new random CSRF token for the session
+Oct-19-2026   activateSession() returns its error and AuthorizeUser() fails the login when no session was stored
+Oct-19-2026   CreateNewUser() rejects passwords shorter than minPasswordLength
 ------------------------------------------------------------------
 */
 package userauth
@@ -20,45 +35,103 @@ package userauth
 import (
 	cookies "cashflowanalysis/CookieHandler"
 	services "cashflowanalysis/Services/DBContext"
+	helper "cashflowanalysis/Services/Helpers"
 	"database/sql"
+	"errors"
+	"log"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
-
-	"golang.org/x/crypto/bcrypt"
 )
 
-const SessionDuration = 2 * time.Hour
-
 var DeleteCookieExpiry = time.Unix(0, 0).UTC()
 
-func sessionExpiry() time.Time {
-	return time.Now().UTC().Add(SessionDuration)
-}
+var ErrNotAuthorized = errors.New("not authorized")
+
+// Outcome of the password step of logging in
+type AuthResult int
+
+const (
+	AuthFailed AuthResult = iota
+	AuthSucceeded
+	//Password was correct but a second factor is required, see CompleteMFALogin()
+	AuthMFARequired
+)
 
 // Authorizes user to access protected pages, creates cookie on the client side
 // sets user to active and creates session in the database
 // Prevents users from accessing public pages (i.e. default, login, sign up)
-func AuthorizeUser(w http.ResponseWriter, username string, password string) bool {
+// If the user has 2FA enabled only a short lived pending-MFA challenge is issued
+// rememberMe asks for a longer lived session, see sessionPolicyFor()
+func AuthorizeUser(r *http.Request, w http.ResponseWriter, username string, password string, rememberMe bool) AuthResult {
 
 	user := services.DB_Users{
 		Username: username,
 	}
 	users, err := services.LoadObjectDB[services.DB_Users](&user, "Username")
 	if err != nil {
-		return false
+		return AuthFailed
 	}
 	if len(users) > 0 {
 		user = users[0]
 	}
+	event := helper.SecurityEvent{UserID: user.UserId, Username: username, Type: helper.EventLogin}
 	if !checkPasswordHash(password, user.PasswordHash) {
-		return false
+		event.Detail = "invalid credentials"
+		helper.RecordSecurityEvent(r, event)
+		return AuthFailed
 	}
+	if user.DisabledAt.Valid {
+		event.Detail = "account disabled"
+		helper.RecordSecurityEvent(r, event)
+		return AuthFailed
+	}
+	upgradePasswordHash(user, password)
 
-	sessionId, expiry := activateSession(user)
+	if user.TOTPEnabled {
+		if !beginMFAChallenge(w, user, rememberMe) {
+			event.Detail = "could not start 2FA challenge"
+			helper.RecordSecurityEvent(r, event)
+			return AuthFailed
+		}
+		event.Success = true
+		event.Detail = "password accepted, 2FA required"
+		helper.RecordSecurityEvent(r, event)
+		return AuthMFARequired
+	}
 
-	_ = cookies.SetCookie(w, "session-id", strconv.Itoa(sessionId), expiry)
-	return true
+	sessionId, expiry, err := activateSession(user, r, rememberMe)
+	if err == nil {
+		err = setSessionCookies(w, strconv.Itoa(sessionId), expiry)
+	}
+	if err != nil {
+		log.Println("could not start session for user", user.UserId, ":", err)
+		event.Detail = "could not start session"
+		helper.RecordSecurityEvent(r, event)
+		return AuthFailed
+	}
+	event.Success = true
+	event.Detail = "session " + strconv.Itoa(sessionId)
+	helper.RecordSecurityEvent(r, event)
+	return AuthSucceeded
+}
+
+// Returns the user tied to the session-id cookie if the session has not been
+// revoked or expired
+func AuthenticatedUser(r *http.Request) (services.DB_Users, error) {
+	session, err := authenticatedSession(r)
+	if err != nil {
+		return services.DB_Users{}, err
+	}
+	user, err := loadUser(session.UserId)
+	if err != nil {
+		return user, err
+	}
+	if user.DisabledAt.Valid {
+		return services.DB_Users{}, ErrAccountDisabled
+	}
+	return user, nil
 }
 
 // Unauthorizes user to access protected pages, deletes cookie and sets user to inactive and
@@ -96,20 +169,27 @@ func UnauthorizeUser(r *http.Request, w http.ResponseWriter) bool {
 		user = users[0]
 	}
 	// Delete session-id cookie (expire in past)
-	_ = cookies.SetCookie(w, "session-id", "", DeleteCookieExpiry)
+	_ = setSessionCookies(w, "", DeleteCookieExpiry)
 
 	success := unactivateSession(user, session)
+	helper.RecordSecurityEvent(r, helper.SecurityEvent{
+		UserID:   user.UserId,
+		Username: user.Username,
+		Type:     helper.EventLogout,
+		Success:  success,
+		Detail:   "session " + strconv.Itoa(session.SessionId),
+	})
 	if !success {
 		//If there was an unsuccessfull unactivation in the database we need to reactivate the user (FOR NOW)
 		//Need to develop a more specific error use case
-		_ = cookies.SetCookie(w, "session-id", strconv.Itoa(session.SessionId), session.ExpiresAt)
+		_ = setSessionCookies(w, strconv.Itoa(session.SessionId), session.ExpiresAt)
 	}
 	return true
 }
 
 // Checks the session-id cookie for being tampered with. If changed by user unathurize the user
-// If the cookie is expired unauthorize user
-// If user is confirmed authorized and cookie is under 30 minutes from expiring reset expiration time
+// If the session has passed its idle or absolute timeout unauthorize user
+// If user is confirmed authorized record the activity, which pushes back the idle timeout
 func CheckUserAuthorization(r *http.Request, w http.ResponseWriter) (bool, error) {
 	sessionID, err := cookies.GetCookie(r, "session-id")
 	if err != nil {
@@ -125,104 +205,161 @@ func CheckUserAuthorization(r *http.Request, w http.ResponseWriter) (bool, error
 	if err != nil {
 		return false, err
 	}
-	if len(sessions) > 0 {
-		session = sessions[0]
+	if len(sessions) == 0 {
+		_ = setSessionCookies(w, "", DeleteCookieExpiry)
+		return false, nil
 	}
+	session = sessions[0]
 
-	//True if Revoked At time is null
-	if !session.RevokedAt.Valid {
-		expiresAt := session.ExpiresAt.UTC()
-		now := time.Now().UTC()
-
-		timeRemaining := expiresAt.Sub(now)
-		//true if cookie is expired
-		if timeRemaining < 0 {
-			_ = UnauthorizeUser(r, w)
+	if !sessionIsActive(session) {
+		if session.RevokedAt.Valid {
+			_ = setSessionCookies(w, "", DeleteCookieExpiry)
 			return false, nil
-		} else if timeRemaining < 30*time.Minute {
-			//refresh cookie and session expiry
-			newExpiry := sessionExpiry()
-			_ = cookies.SetCookie(w, "session-id", sessionID, newExpiry)
-			session.ExpiresAt = newExpiry
-			services.UpdateObjectDB(session, []string{"ExpiresAt"}, []string{"SessionId"})
-			return true, nil
 		}
+		helper.RecordSecurityEvent(r, helper.SecurityEvent{
+			UserID: session.UserId,
+			Type:   helper.EventSessionRefresh,
+			Detail: "session " + sessionID + " ended by " + sessionEndReason(session),
+		})
+		_ = UnauthorizeUser(r, w)
+		return false, nil
 	}
 
-	return false, nil
+	previousExpiry := session.ExpiresAt
+	session = touchSession(session)
+	if session.ExpiresAt.After(previousExpiry) {
+		helper.RecordSecurityEvent(r, helper.SecurityEvent{
+			UserID:  session.UserId,
+			Type:    helper.EventSessionRefresh,
+			Success: true,
+			Detail:  "session " + sessionID + " idle timeout extended to " + session.ExpiresAt.Format(time.RFC3339),
+		})
+	}
+	return true, nil
 }
 
-// Updates user to active and creates active session in database
-func activateSession(user services.DB_Users) (int, time.Time) {
-	user.IsActive = true
-	user.UpdatedAt = time.Now().UTC()
-	services.UpdateObjectDB(user, []string{"IsActive", "UpdatedAt"}, []string{"UserId"})
+// Describes why an inactive session ended
+func sessionEndReason(session services.DB_Sessions) string {
+	if !time.Now().UTC().Before(absoluteExpiry(session)) {
+		return "absolute timeout"
+	}
+	return "idle timeout"
+}
+
+// Sets the encrypted session-id cookie and a csrf-token cookie with a new token
+// for the session. An empty sessionID deletes both
+func setSessionCookies(w http.ResponseWriter, sessionID string, expiry time.Time) error {
+	token := ""
+	if sessionID != "" {
+		var err error
+		token, err = issueCSRFToken(sessionID)
+		if err != nil {
+			return err
+		}
+	}
+	cookies.SetCSRFCookie(w, token, expiry)
+	return cookies.SetCookie(w, "session-id", sessionID, expiry)
+}
 
-	expiry := sessionExpiry()
+// Creates active session in database with the device it was created from
+// and updates the user to active. Remember-me sessions get the longer timeouts.
+// Returns the session id and the absolute expiry, used for the cookie expiry
+func activateSession(user services.DB_Users, r *http.Request, rememberMe bool) (int, time.Time, error) {
 	createdAt := time.Now().UTC()
+	policy := sessionPolicyFor(rememberMe)
+	absolute := createdAt.Add(policy.Absolute)
+	userAgent := helper.UserAgent(r)
 
 	nullRevoke := sql.NullTime{Valid: false}
 	sessionId, err := services.CreateObjectDB(services.DB_Sessions{
-		SessionId: 0,
-		UserId:    user.UserId,
-		CreatedAt: createdAt,
-		ExpiresAt: expiry,
-		RevokedAt: nullRevoke,
+		SessionId:         0,
+		UserId:            user.UserId,
+		CreatedAt:         createdAt,
+		ExpiresAt:         idleExpiry(createdAt, absolute, policy),
+		RevokedAt:         nullRevoke,
+		IPAddress:         sql.NullString{String: helper.ClientIP(r), Valid: true},
+		UserAgent:         sql.NullString{String: userAgent, Valid: true},
+		DeviceName:        sql.NullString{String: helper.DeviceName(userAgent), Valid: true},
+		LastSeenAt:        createdAt,
+		AbsoluteExpiresAt: absolute,
+		RememberMe:        rememberMe,
 	})
 	if err != nil {
-		// Handle error
+		return 0, time.Time{}, err
 	}
-	return sessionId, expiry
+	refreshUserActiveState(user.UserId)
+	return sessionId, absolute, nil
 }
 
-// Updates user to inactive and updates RevokedAt time for session in database
+// Updates RevokedAt time for session in database and updates the users active state
 func unactivateSession(user services.DB_Users, session services.DB_Sessions) bool {
-	user.IsActive = false
-	user.UpdatedAt = time.Now().UTC()
-	err := services.UpdateObjectDB(user, []string{"IsActive", "UpdatedAt"}, []string{"UserId"})
-	if err != nil {
-		// Handle error
-		return false
-	}
-
 	//Add revoked time
 	session.RevokedAt = sql.NullTime{Time: time.Now().UTC(), Valid: true}
 
-	err = services.UpdateObjectDB(session, []string{"RevokedAt"}, []string{"SessionId"})
+	err := services.UpdateObjectDB(session, []string{"RevokedAt"}, []string{"SessionId"})
 	if err != nil {
 		// Handle error
 		return false
 	}
 
-	return true
+	return refreshUserActiveState(user.UserId) == nil
 }
 
 // Adds new user to the database, hashes password before storing
-func CreateNewUser(username string, password string) error {
+// An email is required and a verification link is sent to it. Bank linking is
+// unavailable until the email is verified, see EmailVerified()
+func CreateNewUser(username string, email string, password string) error {
+	email = strings.TrimSpace(email)
+	if email == "" {
+		return ErrEmailRequired
+	}
+	//Checked before the email so a weak password is reported without a database lookup
+	if len(password) < minPasswordLength {
+		return ErrWeakPassword
+	}
+	if err := validateNewEmail(email); err != nil {
+		return err
+	}
+
 	hashedPassword := hashPassword(password)
-	_, err := services.CreateObjectDB(services.DB_Users{
+	if hashedPassword == "" {
+		return ErrPasswordHashFailed
+	}
+	user := services.DB_Users{
 		UserId:       0,
 		Username:     username,
+		Email:        sql.NullString{String: email, Valid: true},
 		PasswordHash: hashedPassword,
+		Role:         RoleUser,
 		IsActive:     true,
 		CreatedAt:    time.Now().UTC(),
 		UpdatedAt:    time.Now().UTC(),
-	})
-	return err
-}
-
-// Hashes and encrypts user password
-func hashPassword(password string) string {
-	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	}
+	var err error
+	user.UserId, err = services.CreateObjectDB(user)
 	if err != nil {
-		// Handle error
-		return ""
+		return err
 	}
-	return string(hash)
+	//The user can ask for another link, so a failed send does not fail the signup
+	if err := sendVerificationEmail(user); err != nil {
+		log.Println("could not send verification email:", err)
+	}
+	return nil
 }
 
-// Compare user given password and hashed password from database
-func checkPasswordHash(password, hash string) bool {
-	err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
-	return err == nil
+// Re-hashes the password with the current policy when the stored hash uses an
+// outdated algorithm or weaker parameters. Only called after the password was verified
+func upgradePasswordHash(user services.DB_Users, password string) {
+	if !passwordNeedsRehash(user.PasswordHash) {
+		return
+	}
+	hash := hashPassword(password)
+	if hash == "" {
+		return
+	}
+	user.PasswordHash = hash
+	user.UpdatedAt = time.Now().UTC()
+	if err := services.UpdateObjectDB(user, []string{"PasswordHash", "UpdatedAt"}, []string{"UserId"}); err != nil {
+		log.Println("could not upgrade password hash:", err)
+	}
 }
@@ -0,0 +1,276 @@
+/*
+------------------------------------------------------------------
+FILE NAME:     emailVerification.go
+PROJECT:       CashflowAnalysis
+Date Created:  Oct-19-2026
+--------------------------------------------------------------------
+DESCRIPTION:
+Email address verification. New users get a signed link that expires after
+EmailVerificationDuration. The token is <payload>.<signature> where the
+payload holds the user id, email and expiry and the signature is an
+HMAC-SHA256 with EMAIL_VERIFICATION_KEY, so nothing is stored until the
+link is used. Changing the email invalidates links sent to the old address.
+Resending is limited to one email per VerificationResendCooldown and
+MaxVerificationEmailsPerDay per user.
+--------------------------------------------------------------------
+$HISTORY:
+
+Oct-19-2026   Created initial file.
+------------------------------------------------------------------
+*/
+package userauth
+
+import (
+	services "cashflowanalysis/Services/DBContext"
+	helper "cashflowanalysis/Services/Helpers"
+	mailer "cashflowanalysis/Services/Mailer"
+	"crypto/hmac"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/mail"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	EmailVerificationDuration   = 24 * time.Hour
+	VerificationResendCooldown  = time.Minute
+	MaxVerificationEmailsPerDay = 5
+)
+
+var (
+	ErrEmailRequired            = errors.New("an email address is required")
+	ErrEmailInUse               = errors.New("that email address is already in use")
+	ErrEmailAlreadyVerified     = errors.New("email address is already verified")
+	ErrInvalidVerificationToken = errors.New("verification link is invalid or has expired")
+	ErrVerificationRateLimited  = errors.New("too many verification emails, please try again later")
+	ErrMissingVerificationKey   = errors.New("EMAIL_VERIFICATION_KEY is not set or is not a 32 byte hex string")
+)
+
+// Verification state returned to the logged in user
+type EmailVerificationInfo struct {
+	Email      string     `json:"email"`
+	Verified   bool       `json:"verified"`
+	VerifiedAt *time.Time `json:"verified_at"`
+	LastSentAt *time.Time `json:"last_sent_at"`
+}
+
+// Signed contents of a verification link
+type verificationPayload struct {
+	UserID    int    `json:"uid"`
+	Email     string `json:"email"`
+	ExpiresAt int64  `json:"exp"`
+}
+
+// Returns the verification state of the logged in user
+func EmailVerificationStatus(r *http.Request) (EmailVerificationInfo, error) {
+	user, err := AuthenticatedUser(r)
+	if err != nil {
+		return EmailVerificationInfo{}, ErrNotAuthorized
+	}
+	info := EmailVerificationInfo{
+		Email:    user.Email.String,
+		Verified: user.Email.Valid && user.EmailVerifiedAt.Valid,
+	}
+	if user.EmailVerifiedAt.Valid {
+		t := user.EmailVerifiedAt.Time
+		info.VerifiedAt = &t
+	}
+	if user.VerificationSentAt.Valid {
+		t := user.VerificationSentAt.Time
+		info.LastSentAt = &t
+	}
+	return info, nil
+}
+
+// Returns true when the user has confirmed their email address
+func EmailVerified(userID int) (bool, error) {
+	user, err := loadUser(userID)
+	if err != nil {
+		return false, err
+	}
+	return user.Email.Valid && user.EmailVerifiedAt.Valid, nil
+}
+
+// Sends the logged in user a new verification link. When email is given and the
+// current address is not verified yet, the address is changed first
+func ResendVerificationEmail(r *http.Request, email string) error {
+	user, err := AuthenticatedUser(r)
+	if err != nil {
+		return ErrNotAuthorized
+	}
+	if user.Email.Valid && user.EmailVerifiedAt.Valid {
+		return ErrEmailAlreadyVerified
+	}
+
+	email = strings.TrimSpace(email)
+	if email != "" && email != user.Email.String {
+		if err := validateNewEmail(email); err != nil {
+			return err
+		}
+		user.Email = sql.NullString{String: email, Valid: true}
+		user.UpdatedAt = time.Now().UTC()
+		if err := services.UpdateObjectDB(user, []string{"Email", "UpdatedAt"}, []string{"UserId"}); err != nil {
+			return err
+		}
+	}
+	if !user.Email.Valid {
+		return ErrEmailRequired
+	}
+	return sendVerificationEmail(user)
+}
+
+// Marks the email in a verification link as verified. Using a link again after
+// it worked is not an error
+func VerifyEmail(r *http.Request, token string) error {
+	payload, err := parseVerificationToken(token)
+	if err != nil {
+		helper.RecordSecurityEvent(r, helper.SecurityEvent{Type: helper.EventEmailVerification, Detail: err.Error()})
+		return err
+	}
+	event := helper.SecurityEvent{UserID: payload.UserID, Type: helper.EventEmailVerification}
+	user, err := loadUser(payload.UserID)
+	if err != nil {
+		event.Detail = "user not found"
+		helper.RecordSecurityEvent(r, event)
+		return ErrInvalidVerificationToken
+	}
+	event.Username = user.Username
+	//The link was sent to an address the user has since changed
+	if !user.Email.Valid || !strings.EqualFold(user.Email.String, payload.Email) {
+		event.Detail = "email changed since the link was sent"
+		helper.RecordSecurityEvent(r, event)
+		return ErrInvalidVerificationToken
+	}
+	if user.EmailVerifiedAt.Valid {
+		return nil
+	}
+
+	user.EmailVerifiedAt = sql.NullTime{Time: time.Now().UTC(), Valid: true}
+	user.UpdatedAt = time.Now().UTC()
+	if err := services.UpdateObjectDB(user, []string{"EmailVerifiedAt", "UpdatedAt"}, []string{"UserId"}); err != nil {
+		return err
+	}
+	event.Success = true
+	event.Detail = user.Email.String
+	helper.RecordSecurityEvent(r, event)
+	return nil
+}
+
+// Emails a verification link to the user, subject to the resend limits
+func sendVerificationEmail(user services.DB_Users) error {
+	now := time.Now().UTC()
+	if user.VerificationSentAt.Valid && now.Sub(user.VerificationSentAt.Time.UTC()) < VerificationResendCooldown {
+		return ErrVerificationRateLimited
+	}
+	if !user.VerificationWindowStart.Valid || now.Sub(user.VerificationWindowStart.Time.UTC()) >= 24*time.Hour {
+		user.VerificationWindowStart = sql.NullTime{Time: now, Valid: true}
+		user.VerificationSendCount = 0
+	}
+	if user.VerificationSendCount >= MaxVerificationEmailsPerDay {
+		return ErrVerificationRateLimited
+	}
+
+	token, err := signVerificationToken(verificationPayload{
+		UserID:    user.UserId,
+		Email:     user.Email.String,
+		ExpiresAt: now.Add(EmailVerificationDuration).Unix(),
+	})
+	if err != nil {
+		return err
+	}
+
+	//Count the attempt before sending so failed sends can't be retried without limit
+	user.VerificationSentAt = sql.NullTime{Time: now, Valid: true}
+	user.VerificationSendCount++
+	err = services.UpdateObjectDB(user, []string{"VerificationSentAt", "VerificationWindowStart", "VerificationSendCount"}, []string{"UserId"})
+	if err != nil {
+		return err
+	}
+
+	link := appBaseURL() + "/verify-email?token=" + url.QueryEscape(token)
+	return mailer.Default().Send(mailer.Message{
+		To:      []string{user.Email.String},
+		Subject: "Confirm your CashflowAnalysis email address",
+		Body: fmt.Sprintf("Hi %s,\n\nPlease confirm your email address using the link below. It expires in %d hours.\n\n%s\n\nYou will be able to link bank accounts once your email is confirmed. If you did not sign up you can ignore this email.\n",
+			user.Username, int(EmailVerificationDuration.Hours()), link),
+	})
+}
+
+// Checks the email is well formed and not used by another account
+func validateNewEmail(email string) error {
+	address, err := mail.ParseAddress(email)
+	if err != nil || address.Address != email {
+		return ErrInvalidEmail
+	}
+	users, err := services.LoadObjectDB(&services.DB_Users{Email: sql.NullString{String: email, Valid: true}}, "Email")
+	if err != nil {
+		return err
+	}
+	if len(users) > 0 {
+		return ErrEmailInUse
+	}
+	return nil
+}
+
+func signVerificationToken(payload verificationPayload) (string, error) {
+	key, err := verificationKey()
+	if err != nil {
+		return "", err
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return "", err
+	}
+	encoded := base64.RawURLEncoding.EncodeToString(body)
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(encoded))
+	return encoded + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil)), nil
+}
+
+// Checks the signature and expiry of a verification token
+func parseVerificationToken(token string) (verificationPayload, error) {
+	var payload verificationPayload
+	key, err := verificationKey()
+	if err != nil {
+		return payload, err
+	}
+	encoded, signature, ok := strings.Cut(strings.TrimSpace(token), ".")
+	if !ok {
+		return payload, ErrInvalidVerificationToken
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(signature)
+	if err != nil {
+		return payload, ErrInvalidVerificationToken
+	}
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(encoded))
+	if !hmac.Equal(sig, mac.Sum(nil)) {
+		return payload, ErrInvalidVerificationToken
+	}
+	body, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil || json.Unmarshal(body, &payload) != nil {
+		return payload, ErrInvalidVerificationToken
+	}
+	if time.Now().Unix() > payload.ExpiresAt {
+		return payload, ErrInvalidVerificationToken
+	}
+	return payload, nil
+}
+
+// Loads the key used to sign verification links
+func verificationKey() ([]byte, error) {
+	key, err := hex.DecodeString(os.Getenv("EMAIL_VERIFICATION_KEY"))
+	if err != nil || len(key) != 32 {
+		return nil, ErrMissingVerificationKey
+	}
+	return key, nil
+}
@@ -0,0 +1,107 @@
+/*
+------------------------------------------------------------------
+FILE NAME:     helper.go
+PROJECT:       CashflowAnalysis
+Date Created:  Oct-19-2026
+--------------------------------------------------------------------
+DESCRIPTION:
+Helper functions for user authentication: encrypting secrets at rest,
+generating random tokens and hashing one time codes.
+--------------------------------------------------------------------
+$HISTORY:
+
+Oct-19-2026   Created initial file.
+------------------------------------------------------------------
+*/
+package userauth
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"io"
+	"os"
+	"strings"
+)
+
+var ErrMissingEncryptionKey = errors.New("MFA_ENCRYPTION_KEY is not set or is not a 32 byte hex string")
+
+// Loads the key used to encrypt secrets stored in the database (i.e. TOTP secrets)
+func encryptionKey() ([]byte, error) {
+	key, err := hex.DecodeString(os.Getenv("MFA_ENCRYPTION_KEY"))
+	if err != nil || len(key) != 32 {
+		return nil, ErrMissingEncryptionKey
+	}
+	return key, nil
+}
+
+// Encrypts a secret with AES-GCM, the nonce is prepended to the cipher text
+func encryptSecret(plaintext string) (string, error) {
+	key, err := encryptionKey()
+	if err != nil {
+		return "", err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return "", err
+	}
+	aesGCM, err := cipher.NewGCM(block)
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, aesGCM.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return "", err
+	}
+	sealed := aesGCM.Seal(nonce, nonce, []byte(plaintext), nil)
+	return base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// Decrypts a secret created by encryptSecret()
+func decryptSecret(encrypted string) (string, error) {
+	key, err := encryptionKey()
+	if err != nil {
+		return "", err
+	}
+	sealed, err := base64.StdEncoding.DecodeString(encrypted)
+	if err != nil {
+		return "", err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return "", err
+	}
+	aesGCM, err := cipher.NewGCM(block)
+	if err != nil {
+		return "", err
+	}
+	if len(sealed) < aesGCM.NonceSize() {
+		return "", errors.New("encrypted secret is too short")
+	}
+	nonce, ciphertext := sealed[:aesGCM.NonceSize()], sealed[aesGCM.NonceSize():]
+	plaintext, err := aesGCM.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return "", err
+	}
+	return string(plaintext), nil
+}
+
+// Returns a url safe random token with the given number of random bytes
+func randomToken(size int) (string, error) {
+	b := make([]byte, size)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// Hashes a high entropy token (recovery code, reset token, etc.) for storage.
+// These are random values so a fast hash is sufficient, unlike passwords
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(strings.TrimSpace(token)))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,244 @@
+/*
+------------------------------------------------------------------
+FILE NAME:     impersonation.go
+PROJECT:       CashflowAnalysis
+Date Created:  Oct-19-2026
+--------------------------------------------------------------------
+DESCRIPTION:
+Admin impersonation for support. An admin opens a read-only session as
+another user for at most IMPERSONATION_DURATION (default 30 minutes). The
+admins own session id is kept in the impersonator-session-id cookie and is
+restored when the impersonation ends. The impersonation session is a normal
+DB_Sessions row with ImpersonatorUserId set, so the user can see it in their
+session list. Start and end are recorded as security events and the server
+records every request made while impersonating.
+--------------------------------------------------------------------
+$HISTORY:
+
+Oct-19-2026   Created initial file.
+------------------------------------------------------------------
+*/
+package userauth
+
+import (
+	cookies "cashflowanalysis/CookieHandler"
+	services "cashflowanalysis/Services/DBContext"
+	helper "cashflowanalysis/Services/Helpers"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	DefaultImpersonationDuration = 30 * time.Minute
+	impersonatorCookie           = "impersonator-session-id"
+	maxImpersonationReasonLen    = 200
+)
+
+var (
+	ErrCannotImpersonate    = errors.New("that user can't be impersonated")
+	ErrImpersonationReason  = errors.New("a reason is required to impersonate a user")
+	ErrAlreadyImpersonating = errors.New("end the current impersonation first")
+	ErrNotImpersonating     = errors.New("this session is not an impersonation")
+)
+
+// Impersonation details returned to the admin
+type ImpersonationInfo struct {
+	UserID               int       `json:"user_id"`
+	Username             string    `json:"username"`
+	ImpersonatorID       int       `json:"impersonator_id"`
+	ImpersonatorUsername string    `json:"impersonator_username"`
+	Reason               string    `json:"reason"`
+	ExpiresAt            time.Time `json:"expires_at"`
+}
+
+// Replaces the admins session cookie with a read-only session as the target user.
+// The admin must be logged in with a session, not a token or another impersonation
+func StartImpersonation(r *http.Request, w http.ResponseWriter, targetUserID int, reason string) (ImpersonationInfo, error) {
+	admin, ok := helper.GetPrincipal(r)
+	if !ok || admin.AuthMethod != helper.AuthMethodSession || !HasRole(admin, RoleAdmin) {
+		return ImpersonationInfo{}, ErrNotAuthorized
+	}
+	if admin.IsImpersonation() {
+		return ImpersonationInfo{}, ErrAlreadyImpersonating
+	}
+	reason = strings.TrimSpace(reason)
+	if reason == "" {
+		return ImpersonationInfo{}, ErrImpersonationReason
+	}
+	if len(reason) > maxImpersonationReasonLen {
+		reason = reason[:maxImpersonationReasonLen]
+	}
+
+	target, err := loadUser(targetUserID)
+	if err != nil {
+		return ImpersonationInfo{}, ErrUserNotFound
+	}
+	event := helper.SecurityEvent{UserID: target.UserId, Username: target.Username, Type: helper.EventImpersonationStart}
+	//Admins can't impersonate themselves, other admins or disabled accounts
+	if target.UserId == admin.UserID || normalizeRole(target.Role) == RoleAdmin || target.DisabledAt.Valid {
+		event.Detail = impersonationDetail(admin.Username, admin.UserID, "refused: "+reason)
+		helper.RecordSecurityEvent(r, event)
+		return ImpersonationInfo{}, ErrCannotImpersonate
+	}
+
+	adminSessions, err := services.LoadObjectDB(&services.DB_Sessions{SessionId: admin.SessionID}, "SessionId")
+	if err != nil {
+		return ImpersonationInfo{}, err
+	}
+	if len(adminSessions) == 0 {
+		return ImpersonationInfo{}, ErrNotAuthorized
+	}
+	adminSession := adminSessions[0]
+
+	now := time.Now().UTC()
+	absolute := now.Add(durationFromEnv("IMPERSONATION_DURATION", DefaultImpersonationDuration))
+	//The impersonation can't outlive the admins own session
+	if adminExpiry := absoluteExpiry(adminSession); adminExpiry.Before(absolute) {
+		absolute = adminExpiry
+	}
+	userAgent := helper.UserAgent(r)
+	session := services.DB_Sessions{
+		UserId:              target.UserId,
+		CreatedAt:           now,
+		ExpiresAt:           idleExpiry(now, absolute, sessionPolicyFor(false)),
+		RevokedAt:           sql.NullTime{Valid: false},
+		IPAddress:           sql.NullString{String: helper.ClientIP(r), Valid: true},
+		UserAgent:           sql.NullString{String: userAgent, Valid: true},
+		DeviceName:          sql.NullString{String: helper.DeviceName(userAgent), Valid: true},
+		LastSeenAt:          now,
+		AbsoluteExpiresAt:   absolute,
+		RememberMe:          false,
+		ImpersonatorUserId:  admin.UserID,
+		ImpersonationReason: sql.NullString{String: reason, Valid: true},
+	}
+	session.SessionId, err = services.CreateObjectDB(session)
+	if err != nil {
+		return ImpersonationInfo{}, err
+	}
+
+	if err := cookies.SetCookie(w, impersonatorCookie, strconv.Itoa(adminSession.SessionId), absoluteExpiry(adminSession)); err != nil {
+		return ImpersonationInfo{}, err
+	}
+	if err := setSessionCookies(w, strconv.Itoa(session.SessionId), absolute); err != nil {
+		return ImpersonationInfo{}, err
+	}
+
+	event.Success = true
+	event.Detail = impersonationDetail(admin.Username, admin.UserID, "session "+strconv.Itoa(session.SessionId)+": "+reason)
+	helper.RecordSecurityEvent(r, event)
+	return ImpersonationInfo{
+		UserID:               target.UserId,
+		Username:             target.Username,
+		ImpersonatorID:       admin.UserID,
+		ImpersonatorUsername: admin.Username,
+		Reason:               reason,
+		ExpiresAt:            absolute,
+	}, nil
+}
+
+// Ends the impersonation in the session-id cookie and restores the admins own
+// session. Works after the impersonation has expired
+func EndImpersonation(r *http.Request, w http.ResponseWriter) error {
+	sessionID := currentSessionID(r)
+	if sessionID == 0 {
+		return ErrNotImpersonating
+	}
+	sessions, err := services.LoadObjectDB(&services.DB_Sessions{SessionId: sessionID}, "SessionId")
+	if err != nil {
+		return err
+	}
+	if len(sessions) == 0 || sessions[0].ImpersonatorUserId == 0 {
+		return ErrNotImpersonating
+	}
+	session := sessions[0]
+	if !session.RevokedAt.Valid {
+		session.RevokedAt = sql.NullTime{Time: time.Now().UTC(), Valid: true}
+		if err := services.UpdateObjectDB(session, []string{"RevokedAt"}, []string{"SessionId"}); err != nil {
+			return err
+		}
+	}
+
+	impersonator, _ := loadUser(session.ImpersonatorUserId)
+	target, _ := loadUser(session.UserId)
+	helper.RecordSecurityEvent(r, helper.SecurityEvent{
+		UserID:   session.UserId,
+		Username: target.Username,
+		Type:     helper.EventImpersonationEnd,
+		Success:  true,
+		Detail:   impersonationDetail(impersonator.Username, session.ImpersonatorUserId, "session "+strconv.Itoa(session.SessionId)),
+	})
+
+	//Go back to the admins session if it is still valid, otherwise log out
+	restored := false
+	if raw, err := cookies.GetCookie(r, impersonatorCookie); err == nil {
+		adminSessionID, _ := strconv.Atoi(raw)
+		adminSessions, err := services.LoadObjectDB(&services.DB_Sessions{SessionId: adminSessionID}, "SessionId")
+		if err == nil && len(adminSessions) > 0 && adminSessions[0].UserId == session.ImpersonatorUserId && sessionIsActive(adminSessions[0]) {
+			restored = setSessionCookies(w, raw, absoluteExpiry(adminSessions[0])) == nil
+		}
+	}
+	_ = cookies.SetCookie(w, impersonatorCookie, "", DeleteCookieExpiry)
+	if !restored {
+		_ = setSessionCookies(w, "", DeleteCookieExpiry)
+	}
+	return nil
+}
+
+// Returns the impersonation the principal belongs to
+func CurrentImpersonation(p helper.Principal) (ImpersonationInfo, error) {
+	if !p.IsImpersonation() {
+		return ImpersonationInfo{}, ErrNotImpersonating
+	}
+	sessions, err := services.LoadObjectDB(&services.DB_Sessions{SessionId: p.SessionID}, "SessionId")
+	if err != nil {
+		return ImpersonationInfo{}, err
+	}
+	if len(sessions) == 0 {
+		return ImpersonationInfo{}, ErrNotImpersonating
+	}
+	return ImpersonationInfo{
+		UserID:               p.UserID,
+		Username:             p.Username,
+		ImpersonatorID:       p.ImpersonatorID,
+		ImpersonatorUsername: p.ImpersonatorUsername,
+		Reason:               sessions[0].ImpersonationReason.String,
+		ExpiresAt:            absoluteExpiry(sessions[0]),
+	}, nil
+}
+
+// Records a request made while impersonating. allowed is false for requests
+// that were blocked because they would change data
+func RecordImpersonatedRequest(r *http.Request, p helper.Principal, allowed bool) {
+	detail := r.Method + " " + r.URL.Path
+	if !allowed {
+		detail += " (blocked, read-only)"
+	}
+	helper.RecordSecurityEvent(r, helper.SecurityEvent{
+		UserID:   p.UserID,
+		Username: p.Username,
+		Type:     helper.EventImpersonatedRequest,
+		Success:  allowed,
+		Detail:   impersonationDetail(p.ImpersonatorUsername, p.ImpersonatorID, detail),
+	})
+}
+
+// Adds the admins principal details to an impersonation session principal.
+// Fails when the admin has since been disabled or lost the admin role
+func impersonatorPrincipal(p helper.Principal, session services.DB_Sessions) (helper.Principal, error) {
+	admin, err := loadUser(session.ImpersonatorUserId)
+	if err != nil || admin.DisabledAt.Valid || normalizeRole(admin.Role) != RoleAdmin {
+		return helper.Principal{}, ErrNotAuthorized
+	}
+	p.ImpersonatorID = admin.UserId
+	p.ImpersonatorUsername = admin.Username
+	return p, nil
+}
+
+func impersonationDetail(adminUsername string, adminID int, detail string) string {
+	return fmt.Sprintf("admin %s (%d) %s", adminUsername, adminID, detail)
+}
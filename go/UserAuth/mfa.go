@@ -0,0 +1,430 @@
+/*
+------------------------------------------------------------------
+FILE NAME:     mfa.go
+PROJECT:       CashflowAnalysis
+Date Created:  Oct-19-2026
+--------------------------------------------------------------------
+DESCRIPTION:
+Two-factor authentication with TOTP. Handles enrollment and confirmation,
+the second login step after a pending-MFA challenge is issued by AuthorizeUser(),
+one time recovery codes, and disabling 2FA.
+
+Every second factor check first reserves an attempt on the user, so a new
+challenge or parallel requests don't give more guesses. maxMFAFailures attempts
+without a success lock the second factor for MFALockoutDuration.
+--------------------------------------------------------------------
+$HISTORY:
+
+Oct-19-2026   Created initial file.
+Oct-19-2026   Session cookie is set with setSessionCookies()
+Oct-19-2026   Disabled accounts can't complete the MFA login step
+Oct-19-2026   MFA login attempts are recorded as security events
+Oct-19-2026   The challenge carries the remember-me choice through to the session
+Oct-19-2026   Second factor attempts are limited per user with a lockout. Challenge attempts,
+-             TOTP steps and recovery codes are used with conditional updates so parallel
+-             requests can't use the same attempt or code twice
+Oct-19-2026   CompleteMFALogin() fails when the session could not be stored
+------------------------------------------------------------------
+*/
+package userauth
+
+import (
+	cookies "cashflowanalysis/CookieHandler"
+	services "cashflowanalysis/Services/DBContext"
+	helper "cashflowanalysis/Services/Helpers"
+	"crypto/rand"
+	"database/sql"
+	"encoding/base32"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	MFAChallengeDuration = 5 * time.Minute
+	MFALockoutDuration   = 15 * time.Minute
+	maxMFAAttempts       = 5
+	maxMFAFailures       = 10
+	recoveryCodeCount    = 10
+	mfaPendingCookie     = "mfa-pending"
+)
+
+var (
+	ErrInvalidCredentials      = errors.New("invalid credentials")
+	ErrMFAAlreadyEnabled       = errors.New("two-factor authentication is already enabled")
+	ErrMFANotEnabled           = errors.New("two-factor authentication is not enabled")
+	ErrMFAEnrollmentNotStarted = errors.New("two-factor enrollment has not been started")
+	ErrMFALocked               = errors.New("too many invalid two-factor codes, try again later")
+)
+
+// Generates a new TOTP secret for the user and stores it (encrypted) unconfirmed.
+// 2FA is not enforced until ConfirmTOTPEnrollment() is called with a valid code.
+// Returns the base32 secret and the otpauth URI for the QR code
+func BeginTOTPEnrollment(userID int) (string, string, error) {
+	user, err := loadUser(userID)
+	if err != nil {
+		return "", "", err
+	}
+	if user.TOTPEnabled {
+		return "", "", ErrMFAAlreadyEnabled
+	}
+
+	secret, err := generateTOTPSecret()
+	if err != nil {
+		return "", "", err
+	}
+	encrypted, err := encryptSecret(secret)
+	if err != nil {
+		return "", "", err
+	}
+
+	user.TOTPSecret = sql.NullString{String: encrypted, Valid: true}
+	user.TOTPLastUsedStep = 0
+	user.UpdatedAt = time.Now().UTC()
+	err = services.UpdateObjectDB(user, []string{"TOTPSecret", "TOTPLastUsedStep", "UpdatedAt"}, []string{"UserId"})
+	if err != nil {
+		return "", "", err
+	}
+
+	return secret, totpURI(user.Username, secret), nil
+}
+
+// Confirms enrollment with a code from the authenticator app, enables 2FA
+// and returns a fresh set of recovery codes (only shown to the user once)
+func ConfirmTOTPEnrollment(userID int, code string) ([]string, error) {
+	user, err := loadUser(userID)
+	if err != nil {
+		return nil, err
+	}
+	if user.TOTPEnabled {
+		return nil, ErrMFAAlreadyEnabled
+	}
+	if !user.TOTPSecret.Valid {
+		return nil, ErrMFAEnrollmentNotStarted
+	}
+	if err := checkTOTP(&user, code); err != nil {
+		return nil, err
+	}
+
+	user.TOTPEnabled = true
+	user.UpdatedAt = time.Now().UTC()
+	err = services.UpdateObjectDB(user, []string{"TOTPEnabled", "UpdatedAt"}, []string{"UserId"})
+	if err != nil {
+		return nil, err
+	}
+
+	return replaceRecoveryCodes(user.UserId)
+}
+
+// Second step of the login flow. Validates the TOTP or recovery code against the
+// pending-MFA challenge and creates the full session-id session on success
+func CompleteMFALogin(r *http.Request, w http.ResponseWriter, code string) bool {
+	challengeID, err := cookies.GetCookie(r, mfaPendingCookie)
+	if err != nil {
+		return false
+	}
+	intChallengeId, _ := strconv.Atoi(challengeID)
+	challenges, err := services.LoadObjectDB(&services.DB_MFAChallenges{ChallengeId: intChallengeId}, "ChallengeId")
+	if err != nil || len(challenges) == 0 {
+		return false
+	}
+	challenge := challenges[0]
+
+	now := time.Now().UTC()
+	if challenge.ConsumedAt.Valid || now.After(challenge.ExpiresAt.UTC()) || challenge.Attempts >= maxMFAAttempts {
+		_ = cookies.SetCookie(w, mfaPendingCookie, "", DeleteCookieExpiry)
+		return false
+	}
+
+	user, err := loadUser(challenge.UserId)
+	if err != nil || user.DisabledAt.Valid {
+		return false
+	}
+
+	event := helper.SecurityEvent{UserID: user.UserId, Username: user.Username, Type: helper.EventLoginMFA}
+	//Both attempt counts are taken before the code is checked
+	if err := reserveChallengeAttempt(challenge); err != nil {
+		if !errors.Is(err, ErrInvalidCredentials) {
+			log.Println("could not count MFA challenge attempt:", err)
+		}
+		return false
+	}
+	if err := reserveMFAAttempt(user.UserId); err != nil {
+		event.Detail = err.Error()
+		helper.RecordSecurityEvent(r, event)
+		return false
+	}
+	if err := verifySecondFactor(&user, code); err != nil {
+		event.Detail = "invalid code"
+		helper.RecordSecurityEvent(r, event)
+		return false
+	}
+
+	challenge.ConsumedAt = sql.NullTime{Time: now, Valid: true}
+	consumed, err := services.UpdateWhereDB(challenge, []string{"ConsumedAt"}, "ChallengeId = @ChallengeId AND ConsumedAt IS NULL")
+	if err != nil || consumed == 0 {
+		return false
+	}
+	_ = cookies.SetCookie(w, mfaPendingCookie, "", DeleteCookieExpiry)
+	if err := clearMFAFailures(user.UserId); err != nil {
+		log.Println("could not reset MFA attempts:", err)
+	}
+
+	sessionId, expiry, err := activateSession(user, r, challenge.RememberMe)
+	if err == nil {
+		err = setSessionCookies(w, strconv.Itoa(sessionId), expiry)
+	}
+	if err != nil {
+		log.Println("could not start session for user", user.UserId, ":", err)
+		event.Detail = "could not start session"
+		helper.RecordSecurityEvent(r, event)
+		return false
+	}
+	event.Success = true
+	event.Detail = "session " + strconv.Itoa(sessionId)
+	helper.RecordSecurityEvent(r, event)
+	return true
+}
+
+// Turns off 2FA after re-authenticating with the password and a current code
+func DisableTOTP(userID int, password string, code string) error {
+	user, err := reauthenticate(userID, password, code)
+	if err != nil {
+		return err
+	}
+
+	user.TOTPEnabled = false
+	user.TOTPSecret = sql.NullString{Valid: false}
+	user.TOTPLastUsedStep = 0
+	user.UpdatedAt = time.Now().UTC()
+	err = services.UpdateObjectDB(user, []string{"TOTPEnabled", "TOTPSecret", "TOTPLastUsedStep", "UpdatedAt"}, []string{"UserId"})
+	if err != nil {
+		return err
+	}
+
+	return services.DeleteObjectDB(services.DB_RecoveryCodes{UserId: userID}, "UserId")
+}
+
+// Invalidates all existing recovery codes and returns a new set after re-authenticating
+func RegenerateRecoveryCodes(userID int, password string, code string) ([]string, error) {
+	user, err := reauthenticate(userID, password, code)
+	if err != nil {
+		return nil, err
+	}
+	return replaceRecoveryCodes(user.UserId)
+}
+
+// Creates the pending-MFA challenge and cookie used between the password and code steps
+func beginMFAChallenge(w http.ResponseWriter, user services.DB_Users, rememberMe bool) bool {
+	now := time.Now().UTC()
+	expiry := now.Add(MFAChallengeDuration)
+	challengeId, err := services.CreateObjectDB(services.DB_MFAChallenges{
+		UserId:     user.UserId,
+		Attempts:   0,
+		CreatedAt:  now,
+		ExpiresAt:  expiry,
+		ConsumedAt: sql.NullTime{Valid: false},
+		RememberMe: rememberMe,
+	})
+	if err != nil {
+		return false
+	}
+	return cookies.SetCookie(w, mfaPendingCookie, strconv.Itoa(challengeId), expiry) == nil
+}
+
+// Confirms the password and second factor before sensitive 2FA changes.
+// Both count against the users second factor attempts
+func reauthenticate(userID int, password string, code string) (services.DB_Users, error) {
+	user, err := loadUser(userID)
+	if err != nil {
+		return user, err
+	}
+	if !user.TOTPEnabled {
+		return user, ErrMFANotEnabled
+	}
+	if err := reserveMFAAttempt(user.UserId); err != nil {
+		return user, err
+	}
+	if !checkPasswordHash(password, user.PasswordHash) {
+		return user, ErrInvalidCredentials
+	}
+	if err := verifySecondFactor(&user, code); err != nil {
+		return user, err
+	}
+	return user, clearMFAFailures(user.UserId)
+}
+
+// Counts an attempt on the challenge. Only one of several parallel requests
+// can take each attempt, the others are rejected
+func reserveChallengeAttempt(challenge services.DB_MFAChallenges) error {
+	previous := challenge.Attempts
+	challenge.Attempts++
+	reserved, err := services.UpdateWhereDB(challenge, []string{"Attempts"},
+		"ChallengeId = @ChallengeId AND Attempts = @Previous AND ConsumedAt IS NULL", sql.Named("Previous", previous))
+	if err != nil {
+		return err
+	}
+	if reserved == 0 {
+		return ErrInvalidCredentials
+	}
+	return nil
+}
+
+// Counts a second factor attempt against the user before the code is checked.
+// The attempt that reaches maxMFAFailures also starts the lockout, a success
+// clears it with clearMFAFailures(). Returns ErrMFALocked while locked out
+func reserveMFAAttempt(userID int) error {
+	//The count is only written if no other request changed it since it was read
+	for retry := 0; retry < 3; retry++ {
+		user, err := loadUser(userID)
+		if err != nil {
+			return err
+		}
+		now := time.Now().UTC()
+		if user.MFALockedUntil.Valid && now.Before(user.MFALockedUntil.Time) {
+			return ErrMFALocked
+		}
+
+		previous := user.MFAFailedAttempts
+		user.MFAFailedAttempts++
+		setValues := []string{"MFAFailedAttempts"}
+		if user.MFAFailedAttempts >= maxMFAFailures {
+			user.MFAFailedAttempts = 0
+			user.MFALockedUntil = sql.NullTime{Time: now.Add(MFALockoutDuration), Valid: true}
+			setValues = append(setValues, "MFALockedUntil")
+		}
+		reserved, err := services.UpdateWhereDB(user, setValues, "UserId = @UserId AND MFAFailedAttempts = @Previous", sql.Named("Previous", previous))
+		if err != nil {
+			return err
+		}
+		if reserved == 1 {
+			return nil
+		}
+	}
+	return ErrMFALocked
+}
+
+// Resets the users second factor attempts after a success
+func clearMFAFailures(userID int) error {
+	user := services.DB_Users{UserId: userID, MFAFailedAttempts: 0, MFALockedUntil: sql.NullTime{Valid: false}}
+	return services.UpdateObjectDB(user, []string{"MFAFailedAttempts", "MFALockedUntil"}, []string{"UserId"})
+}
+
+// Accepts either a TOTP code or an unused recovery code. Returns
+// ErrInvalidCredentials when neither matches
+func verifySecondFactor(user *services.DB_Users, code string) error {
+	err := checkTOTP(user, code)
+	if !errors.Is(err, ErrInvalidCredentials) {
+		return err
+	}
+	return useRecoveryCode(user.UserId, code)
+}
+
+// Validates a TOTP code and records its time step so it cannot be replayed.
+// The step is only recorded if it is newer than the stored one, so the same
+// code sent in parallel is accepted once
+func checkTOTP(user *services.DB_Users, code string) error {
+	if !user.TOTPSecret.Valid {
+		return ErrInvalidCredentials
+	}
+	secret, err := decryptSecret(user.TOTPSecret.String)
+	if err != nil {
+		return err
+	}
+	step, ok := validateTOTP(secret, code, user.TOTPLastUsedStep)
+	if !ok {
+		return ErrInvalidCredentials
+	}
+	updated := *user
+	updated.TOTPLastUsedStep = step
+	recorded, err := services.UpdateWhereDB(updated, []string{"TOTPLastUsedStep"}, "UserId = @UserId AND TOTPLastUsedStep < @Step", sql.Named("Step", step))
+	if err != nil {
+		return err
+	}
+	if recorded == 0 {
+		return ErrInvalidCredentials
+	}
+	user.TOTPLastUsedStep = step
+	return nil
+}
+
+// Marks the matching recovery code as used. Returns ErrInvalidCredentials if
+// no unused code matches or another request used it first
+func useRecoveryCode(userID int, code string) error {
+	codeHash := hashToken(normalizeRecoveryCode(code))
+	codes, err := services.LoadObjectDB(&services.DB_RecoveryCodes{UserId: userID, CodeHash: codeHash}, "UserId", "CodeHash")
+	if err != nil {
+		return err
+	}
+	for _, rc := range codes {
+		if rc.UsedAt.Valid {
+			continue
+		}
+		rc.UsedAt = sql.NullTime{Time: time.Now().UTC(), Valid: true}
+		used, err := services.UpdateWhereDB(rc, []string{"UsedAt"}, "RecoveryCodeId = @RecoveryCodeId AND UsedAt IS NULL")
+		if err != nil {
+			return err
+		}
+		if used == 1 {
+			return nil
+		}
+	}
+	return ErrInvalidCredentials
+}
+
+// Deletes the users recovery codes and stores hashes of a newly generated set
+func replaceRecoveryCodes(userID int) ([]string, error) {
+	err := services.DeleteObjectDB(services.DB_RecoveryCodes{UserId: userID}, "UserId")
+	if err != nil {
+		return nil, err
+	}
+
+	codes := make([]string, 0, recoveryCodeCount)
+	for i := 0; i < recoveryCodeCount; i++ {
+		code, err := generateRecoveryCode()
+		if err != nil {
+			return nil, err
+		}
+		_, err = services.CreateObjectDB(services.DB_RecoveryCodes{
+			UserId:    userID,
+			CodeHash:  hashToken(normalizeRecoveryCode(code)),
+			CreatedAt: time.Now().UTC(),
+			UsedAt:    sql.NullTime{Valid: false},
+		})
+		if err != nil {
+			return nil, err
+		}
+		codes = append(codes, code)
+	}
+	return codes, nil
+}
+
+// Recovery codes are formatted as xxxx-xxxx from 40 random bits
+func generateRecoveryCode() (string, error) {
+	b := make([]byte, 5)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	code := strings.ToLower(base32.StdEncoding.EncodeToString(b))
+	return code[:4] + "-" + code[4:8], nil
+}
+
+// Recovery codes are accepted with or without the dash and in any case
+func normalizeRecoveryCode(code string) string {
+	return strings.ToLower(strings.ReplaceAll(strings.TrimSpace(code), "-", ""))
+}
+
+// Loads a user by id
+func loadUser(userID int) (services.DB_Users, error) {
+	users, err := services.LoadObjectDB(&services.DB_Users{UserId: userID}, "UserId")
+	if err != nil {
+		return services.DB_Users{}, err
+	}
+	if len(users) == 0 {
+		return services.DB_Users{}, ErrNotAuthorized
+	}
+	return users[0], nil
+}
@@ -0,0 +1,393 @@
+/*
+------------------------------------------------------------------
+FILE NAME:     oidc.go
+PROJECT:       CashflowAnalysis
+Date Created:  Oct-19-2026
+--------------------------------------------------------------------
+DESCRIPTION:
+Single sign-on with an OpenID Connect identity provider using the
+authorization code flow with PKCE. Identities are matched to users by
+the providers issuer and subject, logged in users can link an identity
+to their account and new users can optionally be provisioned on first login.
+A successful login creates the same session-id session as AuthorizeUser().
+--------------------------------------------------------------------
+$HISTORY:
+
+Oct-19-2026   Created initial file.
+Oct-19-2026   SSO logins are recorded as security events
+Oct-19-2026   BeginOIDCLogin() takes the remember-me choice
+Oct-19-2026   Users provisioned with a verified email from the identity provider start verified
+Oct-19-2026   The login fails when the session could not be stored
+------------------------------------------------------------------
+*/
+package userauth
+
+import (
+	cookies "cashflowanalysis/CookieHandler"
+	services "cashflowanalysis/Services/DBContext"
+	helper "cashflowanalysis/Services/Helpers"
+	"crypto/sha256"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	OIDCStateDuration = 10 * time.Minute
+	oidcStateCookie   = "oidc-state"
+	maxUsernameLen    = 50
+)
+
+var (
+	ErrOIDCInvalidState      = errors.New("single sign-on request is invalid or has expired")
+	ErrOIDCProviderError     = errors.New("identity provider returned an error")
+	ErrOIDCIdentityNotLinked = errors.New("no account is linked to this identity")
+	ErrOIDCIdentityInUse     = errors.New("identity is already linked to another account")
+	ErrOIDCEmailInUse        = errors.New("an account with this email already exists, log in and link the identity from your account settings")
+)
+
+var usernameInvalidChars = regexp.MustCompile(`[^A-Za-z0-9._-]`)
+
+// Identity details returned to the user
+type IdentityInfo struct {
+	IdentityId  int        `json:"identity_id"`
+	Issuer      string     `json:"issuer"`
+	Subject     string     `json:"subject"`
+	Email       string     `json:"email"`
+	CreatedAt   time.Time  `json:"created_at"`
+	LastLoginAt *time.Time `json:"last_login_at"`
+}
+
+// Outcome of the callback, tells the server where to send the browser
+type OIDCResult struct {
+	//Login created a session
+	LoggedIn bool
+	//Login needs a second factor, see CompleteMFALogin()
+	MFARequired bool
+	//Identity was linked to the logged in user
+	Linked      bool
+	RedirectURL string
+}
+
+// State kept in the encrypted oidc-state cookie between the redirect and the callback
+type oidcState struct {
+	State        string `json:"state"`
+	Nonce        string `json:"nonce"`
+	CodeVerifier string `json:"code_verifier"`
+	LinkUserID   int    `json:"link_user_id"`
+	RememberMe   bool   `json:"remember_me"`
+}
+
+// Starts a login (linkUserID 0) or an account link and returns the providers
+// authorization url to redirect the browser to
+func BeginOIDCLogin(w http.ResponseWriter, linkUserID int, rememberMe bool) (string, error) {
+	cfg, err := loadOIDCConfig()
+	if err != nil {
+		return "", err
+	}
+	doc, err := oidcDiscover(cfg)
+	if err != nil {
+		return "", err
+	}
+
+	st := oidcState{LinkUserID: linkUserID, RememberMe: rememberMe}
+	if st.State, err = randomToken(32); err != nil {
+		return "", err
+	}
+	if st.Nonce, err = randomToken(32); err != nil {
+		return "", err
+	}
+	if st.CodeVerifier, err = randomToken(32); err != nil {
+		return "", err
+	}
+	encoded, err := json.Marshal(st)
+	if err != nil {
+		return "", err
+	}
+	if err := cookies.SetCookie(w, oidcStateCookie, string(encoded), time.Now().UTC().Add(OIDCStateDuration)); err != nil {
+		return "", err
+	}
+
+	challenge := sha256.Sum256([]byte(st.CodeVerifier))
+	query := url.Values{}
+	query.Set("response_type", "code")
+	query.Set("client_id", cfg.ClientID)
+	query.Set("redirect_uri", cfg.RedirectURI)
+	query.Set("scope", cfg.Scopes)
+	query.Set("state", st.State)
+	query.Set("nonce", st.Nonce)
+	query.Set("code_challenge", base64.RawURLEncoding.EncodeToString(challenge[:]))
+	query.Set("code_challenge_method", "S256")
+
+	separator := "?"
+	if strings.Contains(doc.AuthorizationEndpoint, "?") {
+		separator = "&"
+	}
+	return doc.AuthorizationEndpoint + separator + query.Encode(), nil
+}
+
+// Handles the providers redirect back to the app. Validates the state, exchanges
+// the code, validates the ID token and then logs in or links the identity
+func CompleteOIDCLogin(r *http.Request, w http.ResponseWriter) (OIDCResult, error) {
+	result, user, err := completeOIDCLogin(r, w)
+	event := helper.SecurityEvent{UserID: user.UserId, Username: user.Username, Type: helper.EventLoginSSO, Success: err == nil}
+	switch {
+	case err != nil:
+		event.Detail = err.Error()
+	case result.Linked:
+		event.Detail = "identity linked"
+	case result.MFARequired:
+		event.Detail = "identity accepted, 2FA required"
+	}
+	helper.RecordSecurityEvent(r, event)
+	return result, err
+}
+
+func completeOIDCLogin(r *http.Request, w http.ResponseWriter) (OIDCResult, services.DB_Users, error) {
+	cfg, err := loadOIDCConfig()
+	if err != nil {
+		return OIDCResult{}, services.DB_Users{}, err
+	}
+
+	//The state cookie is single use whatever the outcome
+	raw, cookieErr := cookies.GetCookie(r, oidcStateCookie)
+	_ = cookies.SetCookie(w, oidcStateCookie, "", DeleteCookieExpiry)
+	if cookieErr != nil {
+		return OIDCResult{}, services.DB_Users{}, ErrOIDCInvalidState
+	}
+	var st oidcState
+	if err := json.Unmarshal([]byte(raw), &st); err != nil || st.State == "" {
+		return OIDCResult{}, services.DB_Users{}, ErrOIDCInvalidState
+	}
+
+	query := r.URL.Query()
+	if subtle.ConstantTimeCompare([]byte(query.Get("state")), []byte(st.State)) != 1 {
+		return OIDCResult{}, services.DB_Users{}, ErrOIDCInvalidState
+	}
+	if query.Get("error") != "" {
+		return OIDCResult{}, services.DB_Users{}, fmt.Errorf("%w: %s", ErrOIDCProviderError, query.Get("error"))
+	}
+	code := query.Get("code")
+	if code == "" {
+		return OIDCResult{}, services.DB_Users{}, ErrOIDCInvalidState
+	}
+
+	doc, err := oidcDiscover(cfg)
+	if err != nil {
+		return OIDCResult{}, services.DB_Users{}, err
+	}
+	rawIDToken, err := oidcExchangeCode(cfg, doc, code, st.CodeVerifier)
+	if err != nil {
+		return OIDCResult{}, services.DB_Users{}, err
+	}
+	claims, err := oidcValidateIDToken(cfg, doc, rawIDToken, st.Nonce)
+	if err != nil {
+		return OIDCResult{}, services.DB_Users{}, err
+	}
+
+	if st.LinkUserID != 0 {
+		//The link must finish in the same users session that started it
+		current, err := AuthenticatedUser(r)
+		if err != nil || current.UserId != st.LinkUserID {
+			return OIDCResult{}, services.DB_Users{}, ErrOIDCInvalidState
+		}
+		if err := linkIdentity(st.LinkUserID, doc.Issuer, claims); err != nil {
+			return OIDCResult{}, current, err
+		}
+		return OIDCResult{Linked: true, RedirectURL: cfg.PostLoginRedirect}, current, nil
+	}
+
+	user, err := identityUser(cfg, doc.Issuer, claims)
+	if err != nil {
+		return OIDCResult{}, services.DB_Users{}, err
+	}
+	if user.DisabledAt.Valid {
+		return OIDCResult{}, user, ErrAccountDisabled
+	}
+
+	if user.TOTPEnabled {
+		if !beginMFAChallenge(w, user, st.RememberMe) {
+			return OIDCResult{}, user, errors.New("could not start 2FA challenge")
+		}
+		return OIDCResult{MFARequired: true, RedirectURL: appBaseURL() + "/login?mfa_required=true"}, user, nil
+	}
+
+	sessionId, expiry, err := activateSession(user, r, st.RememberMe)
+	if err != nil {
+		return OIDCResult{}, user, err
+	}
+	if err := setSessionCookies(w, strconv.Itoa(sessionId), expiry); err != nil {
+		return OIDCResult{}, user, err
+	}
+	return OIDCResult{LoggedIn: true, RedirectURL: cfg.PostLoginRedirect}, user, nil
+}
+
+// Lists the identities linked to the user
+func ListIdentities(userID int) ([]IdentityInfo, error) {
+	identities, err := services.LoadObjectDB(&services.DB_UserIdentities{UserId: userID}, "UserId")
+	if err != nil {
+		return nil, err
+	}
+	infos := []IdentityInfo{}
+	for _, identity := range identities {
+		info := IdentityInfo{
+			IdentityId: identity.IdentityId,
+			Issuer:     identity.Issuer,
+			Subject:    identity.Subject,
+			Email:      identity.Email,
+			CreatedAt:  identity.CreatedAt,
+		}
+		if identity.LastLoginAt.Valid {
+			t := identity.LastLoginAt.Time
+			info.LastLoginAt = &t
+		}
+		infos = append(infos, info)
+	}
+	return infos, nil
+}
+
+// Returns the user linked to the identity, provisioning a new user when allowed
+func identityUser(cfg oidcConfig, issuer string, claims *oidcIDTokenClaims) (services.DB_Users, error) {
+	identities, err := services.LoadObjectDB(&services.DB_UserIdentities{Issuer: issuer, Subject: claims.Subject}, "Issuer", "Subject")
+	if err != nil {
+		return services.DB_Users{}, err
+	}
+	if len(identities) > 0 {
+		identity := identities[0]
+		identity.LastLoginAt = sql.NullTime{Time: time.Now().UTC(), Valid: true}
+		services.UpdateObjectDB(identity, []string{"LastLoginAt"}, []string{"IdentityId"})
+		return loadUser(identity.UserId)
+	}
+
+	if !cfg.AutoProvision {
+		return services.DB_Users{}, ErrOIDCIdentityNotLinked
+	}
+	return provisionOIDCUser(issuer, claims)
+}
+
+// Links the identity to an existing user. An identity can only belong to one user
+func linkIdentity(userID int, issuer string, claims *oidcIDTokenClaims) error {
+	identities, err := services.LoadObjectDB(&services.DB_UserIdentities{Issuer: issuer, Subject: claims.Subject}, "Issuer", "Subject")
+	if err != nil {
+		return err
+	}
+	if len(identities) > 0 {
+		if identities[0].UserId != userID {
+			return ErrOIDCIdentityInUse
+		}
+		return nil
+	}
+	_, err = services.CreateObjectDB(services.DB_UserIdentities{
+		UserId:      userID,
+		Issuer:      issuer,
+		Subject:     claims.Subject,
+		Email:       verifiedEmail(claims),
+		CreatedAt:   time.Now().UTC(),
+		LastLoginAt: sql.NullTime{Valid: false},
+	})
+	return err
+}
+
+// Creates a user without a password for a new identity and links it. Users
+// provisioned this way can only log in through the identity provider until
+// they set a password with the reset password flow
+func provisionOIDCUser(issuer string, claims *oidcIDTokenClaims) (services.DB_Users, error) {
+	email := verifiedEmail(claims)
+	nullEmail := sql.NullString{Valid: false}
+	if email != "" {
+		//Never attach an identity to an existing account just because the email matches
+		existing, err := services.LoadObjectDB(&services.DB_Users{Email: sql.NullString{String: email, Valid: true}}, "Email")
+		if err != nil {
+			return services.DB_Users{}, err
+		}
+		if len(existing) > 0 {
+			return services.DB_Users{}, ErrOIDCEmailInUse
+		}
+		nullEmail = sql.NullString{String: email, Valid: true}
+	}
+
+	username, err := availableUsername(claims)
+	if err != nil {
+		return services.DB_Users{}, err
+	}
+	now := time.Now().UTC()
+	user := services.DB_Users{
+		Username:     username,
+		Email:        nullEmail,
+		PasswordHash: "",
+		Role:         RoleUser,
+		IsActive:     false,
+		CreatedAt:    now,
+		UpdatedAt:    now,
+	}
+	//The identity provider already verified the email
+	if nullEmail.Valid {
+		user.EmailVerifiedAt = sql.NullTime{Time: now, Valid: true}
+	}
+	user.UserId, err = services.CreateObjectDB(user)
+	if err != nil {
+		return services.DB_Users{}, err
+	}
+	_, err = services.CreateObjectDB(services.DB_UserIdentities{
+		UserId:      user.UserId,
+		Issuer:      issuer,
+		Subject:     claims.Subject,
+		Email:       email,
+		CreatedAt:   now,
+		LastLoginAt: sql.NullTime{Time: now, Valid: true},
+	})
+	if err != nil {
+		return services.DB_Users{}, err
+	}
+	return user, nil
+}
+
+// Picks an unused username based on the identity's preferred username or email
+func availableUsername(claims *oidcIDTokenClaims) (string, error) {
+	base := claims.PreferredUsername
+	if base == "" {
+		base = strings.SplitN(verifiedEmail(claims), "@", 2)[0]
+	}
+	base = usernameInvalidChars.ReplaceAllString(base, "")
+	if base == "" {
+		base = "user"
+	}
+	if len(base) > maxUsernameLen-6 {
+		base = base[:maxUsernameLen-6]
+	}
+
+	candidate := base
+	for i := 2; i < 1000; i++ {
+		users, err := services.LoadObjectDB(&services.DB_Users{Username: candidate}, "Username")
+		if err != nil {
+			return "", err
+		}
+		if len(users) == 0 {
+			return candidate, nil
+		}
+		candidate = base + strconv.Itoa(i)
+	}
+	return "", errors.New("could not find an available username")
+}
+
+// Email from the ID token, only when the provider says it has been verified
+func verifiedEmail(claims *oidcIDTokenClaims) string {
+	if !claims.EmailVerified {
+		return ""
+	}
+	return strings.TrimSpace(claims.Email)
+}
+
+// Frontend url the browser is sent to when single sign-on fails
+func OIDCErrorURL(reason string) string {
+	return appBaseURL() + "/login?sso_error=" + url.QueryEscape(reason)
+}
@@ -0,0 +1,264 @@
+/*
+------------------------------------------------------------------
+FILE NAME:     oidcProvider.go
+PROJECT:       CashflowAnalysis
+Date Created:  Oct-19-2026
+--------------------------------------------------------------------
+DESCRIPTION:
+OpenID Connect provider configuration: settings from the environment,
+discovery document and signing key (JWKS) caching, the authorization
+code exchange and ID token validation.
+--------------------------------------------------------------------
+$HISTORY:
+
+Oct-19-2026   Created initial file.
+------------------------------------------------------------------
+*/
+package userauth
+
+import (
+	helper "cashflowanalysis/Services/Helpers"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	oidcDiscoveryTTL   = 1 * time.Hour
+	oidcJWKSMinRefresh = 1 * time.Minute
+	oidcClockLeeway    = 1 * time.Minute
+)
+
+var ErrOIDCNotConfigured = errors.New("single sign-on is not configured")
+
+type oidcConfig struct {
+	Issuer            string
+	ClientID          string
+	ClientSecret      string
+	RedirectURI       string
+	Scopes            string
+	PostLoginRedirect string
+	AutoProvision     bool
+}
+
+type oidcDiscovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+type oidcIDTokenClaims struct {
+	jwt.RegisteredClaims
+	Nonce             string `json:"nonce"`
+	AuthorizedParty   string `json:"azp"`
+	Email             string `json:"email"`
+	EmailVerified     bool   `json:"email_verified"`
+	PreferredUsername string `json:"preferred_username"`
+	Name              string `json:"name"`
+}
+
+// Discovery document and signing keys are cached between logins
+var oidcCache struct {
+	sync.Mutex
+	issuer        string
+	discovery     *oidcDiscovery
+	discoveredAt  time.Time
+	keys          map[string]interface{}
+	keysFetchedAt time.Time
+}
+
+var oidcHTTPClient = &http.Client{Timeout: 10 * time.Second}
+
+// Reads the provider settings from the environment
+func loadOIDCConfig() (oidcConfig, error) {
+	cfg := oidcConfig{
+		Issuer:            strings.TrimRight(os.Getenv("OIDC_ISSUER"), "/"),
+		ClientID:          os.Getenv("OIDC_CLIENT_ID"),
+		ClientSecret:      os.Getenv("OIDC_CLIENT_SECRET"),
+		RedirectURI:       os.Getenv("OIDC_REDIRECT_URI"),
+		Scopes:            os.Getenv("OIDC_SCOPES"),
+		PostLoginRedirect: os.Getenv("OIDC_POST_LOGIN_REDIRECT"),
+		AutoProvision:     strings.EqualFold(os.Getenv("OIDC_AUTO_PROVISION"), "true"),
+	}
+	if cfg.Issuer == "" || cfg.ClientID == "" || cfg.RedirectURI == "" {
+		return cfg, ErrOIDCNotConfigured
+	}
+	if cfg.Scopes == "" {
+		cfg.Scopes = "openid email profile"
+	}
+	if cfg.PostLoginRedirect == "" {
+		cfg.PostLoginRedirect = appBaseURL() + "/dashboard"
+	}
+	return cfg, nil
+}
+
+// Loads (or returns the cached) discovery document for the issuer
+func oidcDiscover(cfg oidcConfig) (*oidcDiscovery, error) {
+	oidcCache.Lock()
+	defer oidcCache.Unlock()
+
+	if oidcCache.discovery != nil && oidcCache.issuer == cfg.Issuer && time.Since(oidcCache.discoveredAt) < oidcDiscoveryTTL {
+		return oidcCache.discovery, nil
+	}
+
+	var doc oidcDiscovery
+	if err := getJSON(cfg.Issuer+"/.well-known/openid-configuration", &doc); err != nil {
+		return nil, fmt.Errorf("oidc discovery: %w", err)
+	}
+	if strings.TrimRight(doc.Issuer, "/") != cfg.Issuer {
+		return nil, fmt.Errorf("oidc discovery: issuer %q does not match configured issuer %q", doc.Issuer, cfg.Issuer)
+	}
+	if doc.AuthorizationEndpoint == "" || doc.TokenEndpoint == "" || doc.JWKSURI == "" {
+		return nil, errors.New("oidc discovery: document is missing required endpoints")
+	}
+
+	if oidcCache.issuer != cfg.Issuer {
+		oidcCache.keys = nil
+	}
+	oidcCache.issuer = cfg.Issuer
+	oidcCache.discovery = &doc
+	oidcCache.discoveredAt = time.Now()
+	return &doc, nil
+}
+
+// Returns the signing key with the given key id, refreshing the JWKS when the
+// key is unknown (the provider may have rotated keys)
+func oidcSigningKey(doc *oidcDiscovery, kid string) (interface{}, error) {
+	oidcCache.Lock()
+	defer oidcCache.Unlock()
+
+	if key, ok := oidcCache.keys[kid]; ok {
+		return key, nil
+	}
+	if oidcCache.keys != nil && time.Since(oidcCache.keysFetchedAt) < oidcJWKSMinRefresh {
+		return nil, fmt.Errorf("oidc: unknown signing key %q", kid)
+	}
+
+	var set helper.JWKSet
+	if err := getJSON(doc.JWKSURI, &set); err != nil {
+		return nil, fmt.Errorf("oidc jwks: %w", err)
+	}
+	keys := map[string]interface{}{}
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		pub, err := k.PublicKey()
+		if err != nil {
+			continue
+		}
+		keys[k.Kid] = pub
+	}
+	oidcCache.keys = keys
+	oidcCache.keysFetchedAt = time.Now()
+
+	key, ok := keys[kid]
+	if !ok {
+		//Providers with a single key sometimes omit the kid from the token header
+		if kid == "" && len(keys) == 1 {
+			for _, k := range keys {
+				return k, nil
+			}
+		}
+		return nil, fmt.Errorf("oidc: unknown signing key %q", kid)
+	}
+	return key, nil
+}
+
+// Exchanges the authorization code (with the PKCE verifier) for an ID token
+func oidcExchangeCode(cfg oidcConfig, doc *oidcDiscovery, code string, verifier string) (string, error) {
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", cfg.RedirectURI)
+	form.Set("code_verifier", verifier)
+	form.Set("client_id", cfg.ClientID)
+
+	req, err := http.NewRequest(http.MethodPost, doc.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if cfg.ClientSecret != "" {
+		req.SetBasicAuth(url.QueryEscape(cfg.ClientID), url.QueryEscape(cfg.ClientSecret))
+	}
+
+	resp, err := oidcHTTPClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("oidc token endpoint returned %d: %s", resp.StatusCode, string(body))
+	}
+
+	var tokenResp struct {
+		IDToken string `json:"id_token"`
+	}
+	if err := json.Unmarshal(body, &tokenResp); err != nil {
+		return "", err
+	}
+	if tokenResp.IDToken == "" {
+		return "", errors.New("oidc token response did not include an id_token")
+	}
+	return tokenResp.IDToken, nil
+}
+
+// Validates the ID token signature, issuer, audience, expiry and nonce
+func oidcValidateIDToken(cfg oidcConfig, doc *oidcDiscovery, rawIDToken string, nonce string) (*oidcIDTokenClaims, error) {
+	parser := jwt.NewParser(
+		jwt.WithValidMethods([]string{"RS256", "RS384", "RS512", "PS256", "ES256", "ES384", "ES512"}),
+		jwt.WithIssuer(doc.Issuer),
+		jwt.WithAudience(cfg.ClientID),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+		jwt.WithLeeway(oidcClockLeeway),
+	)
+
+	claims := &oidcIDTokenClaims{}
+	_, err := parser.ParseWithClaims(rawIDToken, claims, func(t *jwt.Token) (interface{}, error) {
+		kid, _ := t.Header["kid"].(string)
+		return oidcSigningKey(doc, kid)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("oidc: invalid id_token: %w", err)
+	}
+
+	if claims.Subject == "" {
+		return nil, errors.New("oidc: id_token has no subject")
+	}
+	if claims.Nonce == "" || claims.Nonce != nonce {
+		return nil, errors.New("oidc: id_token nonce does not match")
+	}
+	if len(claims.Audience) > 1 && claims.AuthorizedParty != cfg.ClientID {
+		return nil, errors.New("oidc: id_token azp does not match client id")
+	}
+	return claims, nil
+}
+
+func getJSON(url string, out interface{}) error {
+	resp, err := oidcHTTPClient.Get(url)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s returned %d", url, resp.StatusCode)
+	}
+	return json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(out)
+}
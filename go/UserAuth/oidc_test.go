@@ -0,0 +1,403 @@
+/*
+------------------------------------------------------------------
+FILE NAME:     oidc_test.go
+PROJECT:       CashflowAnalysis
+Date Created:  Oct-19-2026
+--------------------------------------------------------------------
+DESCRIPTION:
+Single sign-on against a mock identity provider served by httptest. The
+provider serves discovery, JWKS and token responses, checks the PKCE
+verifier like a real provider and signs ID tokens with a test RSA key.
+Covers the happy path up to the validated claims and the state, PKCE,
+nonce, audience, issuer and signature rejections. Every rejection happens
+before a user is looked up, so no database is needed.
+--------------------------------------------------------------------
+$HISTORY:
+
+Oct-19-2026   Created initial file.
+Oct-19-2026   Sets COOKIE_SECRET_KEY now that the cookie key comes from the environment
+------------------------------------------------------------------
+*/
+package userauth
+
+import (
+	cookies "cashflowanalysis/CookieHandler"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	testOIDCClientID    = "cashflow-test"
+	testOIDCRedirectURI = "http://localhost:3000/api/oidc/callback/"
+	testOIDCKeyID       = "test-key"
+	testCookieSecretKey = "000102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f"
+)
+
+// What the user consented to at the mock provider, looked up by the code
+type mockAuthorization struct {
+	challenge string
+	nonce     string
+	//Changes the ID token claims before signing, to build invalid tokens
+	tamper func(claims jwt.MapClaims)
+}
+
+type mockIdP struct {
+	server *httptest.Server
+	key    *rsa.PrivateKey
+	//Signs ID tokens when set, to fake a token signed with a key the provider never published
+	signingKey *rsa.PrivateKey
+	mu         sync.Mutex
+	codes      map[string]mockAuthorization
+	//Verifiers the token endpoint received
+	verifiers []string
+}
+
+// Starts the mock provider and points the OIDC settings at it
+func newMockIdP(t *testing.T) *mockIdP {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	idp := &mockIdP{key: key, codes: map[string]mockAuthorization{}}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", idp.discovery)
+	mux.HandleFunc("/jwks", idp.jwks)
+	mux.HandleFunc("/token", idp.token)
+	idp.server = httptest.NewServer(mux)
+	t.Cleanup(idp.server.Close)
+
+	t.Setenv("COOKIE_SECRET_KEY", testCookieSecretKey)
+	t.Setenv("OIDC_ISSUER", idp.server.URL)
+	t.Setenv("OIDC_CLIENT_ID", testOIDCClientID)
+	t.Setenv("OIDC_CLIENT_SECRET", "")
+	t.Setenv("OIDC_REDIRECT_URI", testOIDCRedirectURI)
+	t.Setenv("OIDC_AUTO_PROVISION", "false")
+
+	oidcCache.Lock()
+	oidcCache.issuer, oidcCache.discovery, oidcCache.keys = "", nil, nil
+	oidcCache.Unlock()
+	return idp
+}
+
+func (idp *mockIdP) discovery(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]string{
+		"issuer":                 idp.server.URL,
+		"authorization_endpoint": idp.server.URL + "/authorize",
+		"token_endpoint":         idp.server.URL + "/token",
+		"jwks_uri":               idp.server.URL + "/jwks",
+	})
+}
+
+func (idp *mockIdP) jwks(w http.ResponseWriter, r *http.Request) {
+	pub := idp.key.PublicKey
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"keys": []map[string]string{{
+			"kty": "RSA",
+			"kid": testOIDCKeyID,
+			"alg": "RS256",
+			"use": "sig",
+			"n":   base64.RawURLEncoding.EncodeToString(pub.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes()),
+		}},
+	})
+}
+
+// Token endpoint. Rejects the code unless the PKCE verifier hashes to the challenge
+func (idp *mockIdP) token(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil || r.PostForm.Get("grant_type") != "authorization_code" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_request"})
+		return
+	}
+	if r.PostForm.Get("client_id") != testOIDCClientID || r.PostForm.Get("redirect_uri") != testOIDCRedirectURI {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_client"})
+		return
+	}
+
+	idp.mu.Lock()
+	auth, ok := idp.codes[r.PostForm.Get("code")]
+	delete(idp.codes, r.PostForm.Get("code"))
+	verifier := r.PostForm.Get("code_verifier")
+	idp.verifiers = append(idp.verifiers, verifier)
+	idp.mu.Unlock()
+
+	sum := sha256.Sum256([]byte(verifier))
+	if !ok || base64.RawURLEncoding.EncodeToString(sum[:]) != auth.challenge {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant"})
+		return
+	}
+
+	now := time.Now()
+	claims := jwt.MapClaims{
+		"iss":                idp.server.URL,
+		"sub":                "user-123",
+		"aud":                testOIDCClientID,
+		"exp":                now.Add(5 * time.Minute).Unix(),
+		"iat":                now.Unix(),
+		"nonce":              auth.nonce,
+		"email":              "jane@example.com",
+		"email_verified":     true,
+		"preferred_username": "jane",
+	}
+	if auth.tamper != nil {
+		auth.tamper(claims)
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = testOIDCKeyID
+	signingKey := idp.key
+	if idp.signingKey != nil {
+		signingKey = idp.signingKey
+	}
+	signed, err := token.SignedString(signingKey)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"id_token": signed, "token_type": "Bearer"})
+}
+
+// Plays the user approving the login at the provider and returns the callback query
+func (idp *mockIdP) authorize(t *testing.T, authURL string, tamper func(jwt.MapClaims)) url.Values {
+	t.Helper()
+	u, err := url.Parse(authURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	query := u.Query()
+	if query.Get("code_challenge_method") != "S256" || query.Get("code_challenge") == "" {
+		t.Fatalf("authorization request has no S256 code challenge: %s", authURL)
+	}
+	if query.Get("client_id") != testOIDCClientID || query.Get("redirect_uri") != testOIDCRedirectURI {
+		t.Fatalf("authorization request has the wrong client: %s", authURL)
+	}
+	if query.Get("nonce") == "" || query.Get("state") == "" {
+		t.Fatalf("authorization request has no nonce or state: %s", authURL)
+	}
+
+	code, err := randomToken(16)
+	if err != nil {
+		t.Fatal(err)
+	}
+	idp.mu.Lock()
+	idp.codes[code] = mockAuthorization{challenge: query.Get("code_challenge"), nonce: query.Get("nonce"), tamper: tamper}
+	idp.mu.Unlock()
+	return url.Values{"code": {code}, "state": {query.Get("state")}}
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(body)
+}
+
+// Starts a login and returns the providers authorization url and the oidc-state cookie
+func beginTestLogin(t *testing.T) (string, *http.Cookie) {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	authURL, err := BeginOIDCLogin(rec, 0, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range rec.Result().Cookies() {
+		if c.Name == oidcStateCookie {
+			return authURL, c
+		}
+	}
+	t.Fatal("BeginOIDCLogin did not set the oidc-state cookie")
+	return "", nil
+}
+
+// Sends the browser back to the callback with the state cookie, when given
+func callbackRequest(query url.Values, stateCookie *http.Cookie) *http.Request {
+	r := httptest.NewRequest(http.MethodGet, "/api/oidc/callback/?"+query.Encode(), nil)
+	if stateCookie != nil {
+		r.AddCookie(stateCookie)
+	}
+	return r
+}
+
+// Decrypts the oidc-state cookie
+func readTestState(t *testing.T, stateCookie *http.Cookie) oidcState {
+	t.Helper()
+	raw, err := cookies.GetCookie(callbackRequest(nil, stateCookie), oidcStateCookie)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var st oidcState
+	if err := json.Unmarshal([]byte(raw), &st); err != nil {
+		t.Fatal(err)
+	}
+	return st
+}
+
+func TestOIDCLoginValidatesIDToken(t *testing.T) {
+	idp := newMockIdP(t)
+	authURL, stateCookie := beginTestLogin(t)
+	query := idp.authorize(t, authURL, nil)
+	st := readTestState(t, stateCookie)
+
+	cfg, err := loadOIDCConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc, err := oidcDiscover(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rawIDToken, err := oidcExchangeCode(cfg, doc, query.Get("code"), st.CodeVerifier)
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims, err := oidcValidateIDToken(cfg, doc, rawIDToken, st.Nonce)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claims.Subject != "user-123" || verifiedEmail(claims) != "jane@example.com" {
+		t.Fatalf("unexpected claims: subject %q, email %q", claims.Subject, verifiedEmail(claims))
+	}
+}
+
+func TestOIDCCallbackRejectsState(t *testing.T) {
+	idp := newMockIdP(t)
+	authURL, stateCookie := beginTestLogin(t)
+	query := idp.authorize(t, authURL, nil)
+
+	tests := []struct {
+		name   string
+		query  url.Values
+		cookie *http.Cookie
+	}{
+		{"wrong state", url.Values{"code": {query.Get("code")}, "state": {"not-the-state"}}, stateCookie},
+		{"missing state", url.Values{"code": {query.Get("code")}}, stateCookie},
+		{"missing state cookie", query, nil},
+		{"tampered state cookie", query, &http.Cookie{Name: oidcStateCookie, Value: stateCookie.Value[:len(stateCookie.Value)-4] + "AAAA"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := completeOIDCLogin(callbackRequest(tt.query, tt.cookie), httptest.NewRecorder())
+			if !errors.Is(err, ErrOIDCInvalidState) {
+				t.Fatalf("got %v, want ErrOIDCInvalidState", err)
+			}
+		})
+	}
+
+	idp.mu.Lock()
+	defer idp.mu.Unlock()
+	if len(idp.verifiers) != 0 {
+		t.Fatalf("code was exchanged %d times for rejected callbacks", len(idp.verifiers))
+	}
+}
+
+func TestOIDCCallbackClearsStateCookie(t *testing.T) {
+	idp := newMockIdP(t)
+	authURL, stateCookie := beginTestLogin(t)
+	query := idp.authorize(t, authURL, nil)
+	query.Set("state", "not-the-state")
+
+	rec := httptest.NewRecorder()
+	_, _, _ = completeOIDCLogin(callbackRequest(query, stateCookie), rec)
+	for _, c := range rec.Result().Cookies() {
+		if c.Name == oidcStateCookie && c.Expires.After(time.Now()) {
+			t.Fatal("oidc-state cookie was not cleared after a failed callback")
+		}
+	}
+}
+
+func TestOIDCCallbackProviderError(t *testing.T) {
+	newMockIdP(t)
+	authURL, stateCookie := beginTestLogin(t)
+	u, _ := url.Parse(authURL)
+
+	query := url.Values{"error": {"access_denied"}, "state": {u.Query().Get("state")}}
+	_, _, err := completeOIDCLogin(callbackRequest(query, stateCookie), httptest.NewRecorder())
+	if !errors.Is(err, ErrOIDCProviderError) {
+		t.Fatalf("got %v, want ErrOIDCProviderError", err)
+	}
+}
+
+func TestOIDCCallbackRejectsWrongPKCEVerifier(t *testing.T) {
+	idp := newMockIdP(t)
+	authURL, stateCookie := beginTestLogin(t)
+	query := idp.authorize(t, authURL, nil)
+
+	//Same state and nonce, but a verifier that does not hash to the challenge
+	st := readTestState(t, stateCookie)
+	st.CodeVerifier = "a-different-verifier-of-reasonable-length-000000"
+	encoded, _ := json.Marshal(st)
+	rec := httptest.NewRecorder()
+	if err := cookies.SetCookie(rec, oidcStateCookie, string(encoded), time.Now().Add(time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	forged := rec.Result().Cookies()[0]
+
+	_, _, err := completeOIDCLogin(callbackRequest(query, forged), httptest.NewRecorder())
+	if err == nil || !strings.Contains(err.Error(), "invalid_grant") {
+		t.Fatalf("got %v, want the provider to reject the verifier", err)
+	}
+	idp.mu.Lock()
+	defer idp.mu.Unlock()
+	if len(idp.verifiers) != 1 || idp.verifiers[0] != st.CodeVerifier {
+		t.Fatalf("token endpoint received verifiers %v", idp.verifiers)
+	}
+}
+
+func TestOIDCCallbackRejectsIDToken(t *testing.T) {
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		tamper     func(jwt.MapClaims)
+		signingKey *rsa.PrivateKey
+		want       string
+		wantErr    error
+	}{
+		{name: "nonce mismatch", tamper: func(c jwt.MapClaims) { c["nonce"] = "replayed-nonce" }, want: "nonce does not match"},
+		{name: "missing nonce", tamper: func(c jwt.MapClaims) { delete(c, "nonce") }, want: "nonce does not match"},
+		{name: "wrong audience", tamper: func(c jwt.MapClaims) { c["aud"] = "another-client" }, wantErr: jwt.ErrTokenInvalidAudience},
+		{name: "azp mismatch", tamper: func(c jwt.MapClaims) {
+			c["aud"] = []string{testOIDCClientID, "another-client"}
+			c["azp"] = "another-client"
+		}, want: "azp does not match"},
+		{name: "wrong issuer", tamper: func(c jwt.MapClaims) { c["iss"] = "https://evil.example.com" }, wantErr: jwt.ErrTokenInvalidIssuer},
+		{name: "expired", tamper: func(c jwt.MapClaims) { c["exp"] = time.Now().Add(-time.Hour).Unix() }, wantErr: jwt.ErrTokenExpired},
+		{name: "no subject", tamper: func(c jwt.MapClaims) { delete(c, "sub") }, want: "no subject"},
+		{name: "unknown signing key", signingKey: otherKey, wantErr: jwt.ErrTokenSignatureInvalid},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			idp := newMockIdP(t)
+			idp.signingKey = tt.signingKey
+			authURL, stateCookie := beginTestLogin(t)
+			query := idp.authorize(t, authURL, tt.tamper)
+
+			_, _, err := completeOIDCLogin(callbackRequest(query, stateCookie), httptest.NewRecorder())
+			if err == nil {
+				t.Fatal("invalid id_token was accepted")
+			}
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Fatalf("got %v, want %v", err, tt.wantErr)
+			}
+			if tt.want != "" && !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("got %v, want an error containing %q", err, tt.want)
+			}
+		})
+	}
+}
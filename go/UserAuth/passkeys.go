@@ -0,0 +1,606 @@
+/*
+------------------------------------------------------------------
+FILE NAME:     passkeys.go
+PROJECT:       CashflowAnalysis
+Date Created:  Oct-19-2026
+--------------------------------------------------------------------
+DESCRIPTION:
+Passwordless sign-in with passkeys (WebAuthn). A logged in user registers
+one or more passkeys, each with a friendly name. Signing in uses a
+discoverable credential so no username is needed, and a successful
+assertion creates the same session as AuthorizeUser().
+
+Each ceremony is two calls: Begin returns the options for
+navigator.credentials.create()/get() and keeps the challenge server side
+in DB_WebAuthnChallenges for PasskeyCeremonyDuration, with only its id in
+an encrypted cookie. Finish takes the browsers response as the request
+body and deletes the challenge, so a response can't be replayed. User verification is required so a passkey
+counts as both factors and no TOTP code is asked for.
+
+WEBAUTHN_RP_ID defaults to the host of APP_BASE_URL and WEBAUTHN_ORIGINS
+(comma separated) defaults to APP_BASE_URL
+--------------------------------------------------------------------
+$HISTORY:
+
+Oct-19-2026   Created initial file.
+Oct-19-2026   The ceremony is stored server side and deleted on first use instead of
+-             living only in the cookie
+Oct-19-2026   The passkey login fails when the session could not be stored
+------------------------------------------------------------------
+*/
+package userauth
+
+import (
+	cookies "cashflowanalysis/CookieHandler"
+	services "cashflowanalysis/Services/DBContext"
+	helper "cashflowanalysis/Services/Helpers"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/webauthn"
+)
+
+const (
+	PasskeyCeremonyDuration = 5 * time.Minute
+	maxPasskeyNameLength    = 64
+
+	passkeyRegistrationCookie = "passkey-registration"
+	passkeyLoginCookie        = "passkey-login"
+)
+
+var (
+	ErrPasskeyNotFound        = errors.New("passkey not found")
+	ErrPasskeyNameRequired    = errors.New("passkey name is required")
+	ErrPasskeyCeremonyExpired = errors.New("passkey request expired, please try again")
+	ErrPasskeyRejected        = errors.New("passkey could not be verified")
+	ErrPasskeyInUse           = errors.New("this passkey is already registered")
+	ErrPasskeyCloned          = errors.New("passkey signature counter went backwards, the authenticator may have been cloned")
+	ErrLastSignInMethod       = errors.New("this is your only way to sign in, set a password before removing it")
+)
+
+// Passkey details returned to the user
+type PasskeyInfo struct {
+	PasskeyId  int        `json:"passkey_id"`
+	Name       string     `json:"name"`
+	Synced     bool       `json:"synced"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+}
+
+// Kept in DB_WebAuthnChallenges between the Begin and Finish calls
+type passkeyCeremony struct {
+	Session    webauthn.SessionData
+	UserID     int
+	Name       string
+	RememberMe bool
+}
+
+// Adapts a user and their stored passkeys to webauthn.User
+type passkeyUser struct {
+	user        services.DB_Users
+	credentials []services.DB_WebAuthnCredentials
+}
+
+// Keeps passkey ceremonies between the Begin and Finish calls. take removes the
+// challenge so only the first caller gets it
+type passkeyChallengeStore interface {
+	save(challenge services.DB_WebAuthnChallenges) (int, error)
+	take(challengeID int, ceremony string) (services.DB_WebAuthnChallenges, bool, error)
+}
+
+type dbPasskeyChallenges struct{}
+
+var passkeyChallenges passkeyChallengeStore = dbPasskeyChallenges{}
+
+func (dbPasskeyChallenges) save(challenge services.DB_WebAuthnChallenges) (int, error) {
+	return services.CreateObjectDB(challenge)
+}
+
+func (dbPasskeyChallenges) take(challengeID int, ceremony string) (services.DB_WebAuthnChallenges, bool, error) {
+	taken, err := services.TakeObjectDB(&services.DB_WebAuthnChallenges{ChallengeId: challengeID, Ceremony: ceremony}, "ChallengeId", "Ceremony")
+	if err != nil || len(taken) == 0 {
+		return services.DB_WebAuthnChallenges{}, false, err
+	}
+	return taken[0], true, nil
+}
+
+func (u passkeyUser) WebAuthnID() []byte {
+	handle, _ := base64.RawURLEncoding.DecodeString(u.user.WebAuthnUserHandle.String)
+	return handle
+}
+
+func (u passkeyUser) WebAuthnName() string {
+	return u.user.Username
+}
+
+func (u passkeyUser) WebAuthnDisplayName() string {
+	return u.user.Username
+}
+
+func (u passkeyUser) WebAuthnCredentials() []webauthn.Credential {
+	credentials := make([]webauthn.Credential, 0, len(u.credentials))
+	for _, stored := range u.credentials {
+		credential, err := storedToCredential(stored)
+		if err != nil {
+			log.Println("skipping unreadable passkey", stored.PasskeyId, err)
+			continue
+		}
+		credentials = append(credentials, credential)
+	}
+	return credentials
+}
+
+// Starts registering a new passkey for the logged in user
+func BeginPasskeyRegistration(r *http.Request, w http.ResponseWriter, name string) (*protocol.CredentialCreation, error) {
+	user, err := AuthenticatedUser(r)
+	if err != nil {
+		return nil, ErrNotAuthorized
+	}
+	name = strings.TrimSpace(name)
+	if name == "" {
+		name = "Passkey"
+	}
+	if len(name) > maxPasskeyNameLength {
+		name = name[:maxPasskeyNameLength]
+	}
+
+	rp, err := relyingParty()
+	if err != nil {
+		return nil, err
+	}
+	if user, err = ensureUserHandle(user); err != nil {
+		return nil, err
+	}
+	pu, err := loadPasskeyUser(user)
+	if err != nil {
+		return nil, err
+	}
+
+	creation, session, err := rp.BeginRegistration(pu,
+		webauthn.WithExclusions(webauthn.Credentials(pu.WebAuthnCredentials()).CredentialDescriptors()),
+		webauthn.WithAuthenticatorSelection(protocol.AuthenticatorSelection{
+			ResidentKey:        protocol.ResidentKeyRequirementRequired,
+			RequireResidentKey: protocol.ResidentKeyRequired(),
+			UserVerification:   protocol.VerificationRequired,
+		}),
+	)
+	if err != nil {
+		return nil, err
+	}
+	if err := saveCeremony(w, passkeyRegistrationCookie, passkeyCeremony{Session: *session, UserID: user.UserId, Name: name}); err != nil {
+		return nil, err
+	}
+	return creation, nil
+}
+
+// Verifies the browsers navigator.credentials.create() response (the request body)
+// and stores the new passkey
+func FinishPasskeyRegistration(r *http.Request, w http.ResponseWriter) (PasskeyInfo, error) {
+	ceremony, err := takeCeremony(r, w, passkeyRegistrationCookie)
+	if err != nil {
+		return PasskeyInfo{}, err
+	}
+	user, err := AuthenticatedUser(r)
+	if err != nil || user.UserId != ceremony.UserID {
+		return PasskeyInfo{}, ErrNotAuthorized
+	}
+	event := helper.SecurityEvent{UserID: user.UserId, Username: user.Username, Type: helper.EventPasskeyRegistration}
+
+	rp, err := relyingParty()
+	if err != nil {
+		return PasskeyInfo{}, err
+	}
+	pu, err := loadPasskeyUser(user)
+	if err != nil {
+		return PasskeyInfo{}, err
+	}
+	credential, err := rp.FinishRegistration(pu, ceremony.Session, r)
+	if err != nil {
+		event.Detail = protocolErrorDetail(err)
+		helper.RecordSecurityEvent(r, event)
+		return PasskeyInfo{}, ErrPasskeyRejected
+	}
+
+	credentialID := base64.RawURLEncoding.EncodeToString(credential.ID)
+	existing, err := services.LoadObjectDB(&services.DB_WebAuthnCredentials{CredentialID: credentialID}, "CredentialID")
+	if err != nil {
+		return PasskeyInfo{}, err
+	}
+	if len(existing) > 0 {
+		event.Detail = "credential already registered"
+		helper.RecordSecurityEvent(r, event)
+		return PasskeyInfo{}, ErrPasskeyInUse
+	}
+
+	transports := make([]string, 0, len(credential.Transport))
+	for _, t := range credential.Transport {
+		transports = append(transports, string(t))
+	}
+	stored := services.DB_WebAuthnCredentials{
+		UserId:          user.UserId,
+		Name:            ceremony.Name,
+		CredentialID:    credentialID,
+		PublicKey:       base64.RawURLEncoding.EncodeToString(credential.PublicKey),
+		AttestationType: credential.AttestationType,
+		AAGUID:          hex.EncodeToString(credential.Authenticator.AAGUID),
+		Transports:      strings.Join(transports, ","),
+		SignCount:       int64(credential.Authenticator.SignCount),
+		BackupEligible:  credential.Flags.BackupEligible,
+		BackupState:     credential.Flags.BackupState,
+		CreatedAt:       time.Now().UTC(),
+		LastUsedAt:      sql.NullTime{Valid: false},
+	}
+	stored.PasskeyId, err = services.CreateObjectDB(stored)
+	if err != nil {
+		return PasskeyInfo{}, err
+	}
+	event.Success = true
+	event.Detail = "passkey " + strconv.Itoa(stored.PasskeyId) + " (" + stored.Name + ")"
+	helper.RecordSecurityEvent(r, event)
+	return passkeyInfo(stored), nil
+}
+
+// Starts a passkey sign-in. No username is needed, the authenticator offers the
+// passkeys it holds for this site
+func BeginPasskeyLogin(w http.ResponseWriter, rememberMe bool) (*protocol.CredentialAssertion, error) {
+	rp, err := relyingParty()
+	if err != nil {
+		return nil, err
+	}
+	assertion, session, err := rp.BeginDiscoverableLogin(webauthn.WithUserVerification(protocol.VerificationRequired))
+	if err != nil {
+		return nil, err
+	}
+	if err := saveCeremony(w, passkeyLoginCookie, passkeyCeremony{Session: *session, RememberMe: rememberMe}); err != nil {
+		return nil, err
+	}
+	return assertion, nil
+}
+
+// Verifies the browsers navigator.credentials.get() response (the request body)
+// and logs the user in the same way as AuthorizeUser()
+func FinishPasskeyLogin(r *http.Request, w http.ResponseWriter) error {
+	ceremony, err := takeCeremony(r, w, passkeyLoginCookie)
+	if err != nil {
+		return err
+	}
+	rp, err := relyingParty()
+	if err != nil {
+		return err
+	}
+	event := helper.SecurityEvent{Type: helper.EventLoginPasskey}
+
+	found, credential, err := verifyPasskeyLogin(rp, r, ceremony, loadPasskeyUserByHandle)
+	if err != nil {
+		event.UserID = found.user.UserId
+		event.Username = found.user.Username
+		event.Detail = protocolErrorDetail(err)
+		helper.RecordSecurityEvent(r, event)
+		return ErrPasskeyRejected
+	}
+	user := found.user
+	event.UserID = user.UserId
+	event.Username = user.Username
+
+	credentialID := base64.RawURLEncoding.EncodeToString(credential.ID)
+	var stored services.DB_WebAuthnCredentials
+	for _, c := range found.credentials {
+		if c.CredentialID == credentialID {
+			stored = c
+		}
+	}
+	if stored.PasskeyId == 0 {
+		return ErrPasskeyRejected
+	}
+	if credential.Authenticator.CloneWarning {
+		event.Detail = "sign count did not increase for passkey " + strconv.Itoa(stored.PasskeyId)
+		helper.RecordSecurityEvent(r, event)
+		return ErrPasskeyCloned
+	}
+	if user.DisabledAt.Valid {
+		event.Detail = "account disabled"
+		helper.RecordSecurityEvent(r, event)
+		return ErrAccountDisabled
+	}
+
+	stored.SignCount = int64(credential.Authenticator.SignCount)
+	stored.BackupState = credential.Flags.BackupState
+	stored.LastUsedAt = sql.NullTime{Time: time.Now().UTC(), Valid: true}
+	if err := services.UpdateObjectDB(stored, []string{"SignCount", "BackupState", "LastUsedAt"}, []string{"PasskeyId"}); err != nil {
+		return err
+	}
+
+	sessionId, expiry, err := activateSession(user, r, ceremony.RememberMe)
+	if err == nil {
+		err = setSessionCookies(w, strconv.Itoa(sessionId), expiry)
+	}
+	if err != nil {
+		event.Detail = "could not start session"
+		helper.RecordSecurityEvent(r, event)
+		return err
+	}
+	event.Success = true
+	event.Detail = "session " + strconv.Itoa(sessionId) + ", passkey " + strconv.Itoa(stored.PasskeyId)
+	helper.RecordSecurityEvent(r, event)
+	return nil
+}
+
+// Checks the assertion in the request body against the ceremony. lookup finds
+// the user by the user handle the authenticator returned
+func verifyPasskeyLogin(rp *webauthn.WebAuthn, r *http.Request, ceremony passkeyCeremony, lookup func(handle string) (passkeyUser, error)) (passkeyUser, *webauthn.Credential, error) {
+	var found passkeyUser
+	handler := func(rawID, userHandle []byte) (webauthn.User, error) {
+		var err error
+		found, err = lookup(base64.RawURLEncoding.EncodeToString(userHandle))
+		return found, err
+	}
+	_, credential, err := rp.FinishPasskeyLogin(handler, ceremony.Session, r)
+	return found, credential, err
+}
+
+// Lists the passkeys of the logged in user
+func ListPasskeys(r *http.Request) ([]PasskeyInfo, error) {
+	user, err := AuthenticatedUser(r)
+	if err != nil {
+		return nil, ErrNotAuthorized
+	}
+	stored, err := services.LoadObjectDB(&services.DB_WebAuthnCredentials{UserId: user.UserId}, "UserId")
+	if err != nil {
+		return nil, err
+	}
+	infos := []PasskeyInfo{}
+	for _, s := range stored {
+		infos = append(infos, passkeyInfo(s))
+	}
+	return infos, nil
+}
+
+// Changes the friendly name of one of the users passkeys
+func RenamePasskey(r *http.Request, passkeyID int, name string) error {
+	user, err := AuthenticatedUser(r)
+	if err != nil {
+		return ErrNotAuthorized
+	}
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return ErrPasskeyNameRequired
+	}
+	if len(name) > maxPasskeyNameLength {
+		name = name[:maxPasskeyNameLength]
+	}
+	stored, err := services.LoadObjectDB(&services.DB_WebAuthnCredentials{PasskeyId: passkeyID, UserId: user.UserId}, "PasskeyId", "UserId")
+	if err != nil {
+		return err
+	}
+	if len(stored) == 0 {
+		return ErrPasskeyNotFound
+	}
+	stored[0].Name = name
+	return services.UpdateObjectDB(stored[0], []string{"Name"}, []string{"PasskeyId"})
+}
+
+// Removes one of the users passkeys. The last passkey can't be removed when the
+// user has no password or linked identity to sign in with
+func RemovePasskey(r *http.Request, passkeyID int) error {
+	user, err := AuthenticatedUser(r)
+	if err != nil {
+		return ErrNotAuthorized
+	}
+	stored, err := services.LoadObjectDB(&services.DB_WebAuthnCredentials{UserId: user.UserId}, "UserId")
+	if err != nil {
+		return err
+	}
+	var target *services.DB_WebAuthnCredentials
+	for i := range stored {
+		if stored[i].PasskeyId == passkeyID {
+			target = &stored[i]
+		}
+	}
+	if target == nil {
+		return ErrPasskeyNotFound
+	}
+	if len(stored) == 1 && user.PasswordHash == "" {
+		identities, err := services.LoadObjectDB(&services.DB_UserIdentities{UserId: user.UserId}, "UserId")
+		if err != nil {
+			return err
+		}
+		if len(identities) == 0 {
+			return ErrLastSignInMethod
+		}
+	}
+
+	if err := services.DeleteObjectDB(services.DB_WebAuthnCredentials{PasskeyId: passkeyID}, "PasskeyId"); err != nil {
+		return err
+	}
+	helper.RecordSecurityEvent(r, helper.SecurityEvent{
+		UserID:   user.UserId,
+		Username: user.Username,
+		Type:     helper.EventPasskeyRemoval,
+		Success:  true,
+		Detail:   "passkey " + strconv.Itoa(passkeyID) + " (" + target.Name + ")",
+	})
+	return nil
+}
+
+// Builds the relying party from WEBAUTHN_RP_ID, WEBAUTHN_RP_NAME and WEBAUTHN_ORIGINS
+func relyingParty() (*webauthn.WebAuthn, error) {
+	rpID := os.Getenv("WEBAUTHN_RP_ID")
+	if rpID == "" {
+		base, err := url.Parse(appBaseURL())
+		if err != nil {
+			return nil, err
+		}
+		rpID = base.Hostname()
+	}
+	rpName := os.Getenv("WEBAUTHN_RP_NAME")
+	if rpName == "" {
+		rpName = "CashflowAnalysis"
+	}
+	origins := []string{}
+	for _, origin := range strings.Split(os.Getenv("WEBAUTHN_ORIGINS"), ",") {
+		if origin = strings.TrimRight(strings.TrimSpace(origin), "/"); origin != "" {
+			origins = append(origins, origin)
+		}
+	}
+	if len(origins) == 0 {
+		origins = []string{appBaseURL()}
+	}
+
+	timeout := webauthn.TimeoutConfig{Enforce: true, Timeout: PasskeyCeremonyDuration, TimeoutUVD: PasskeyCeremonyDuration}
+	return webauthn.New(&webauthn.Config{
+		RPID:          rpID,
+		RPDisplayName: rpName,
+		RPOrigins:     origins,
+		Timeouts:      webauthn.TimeoutsConfig{Login: timeout, Registration: timeout},
+	})
+}
+
+// Gives the user a random WebAuthn user handle if they don't have one yet
+func ensureUserHandle(user services.DB_Users) (services.DB_Users, error) {
+	if user.WebAuthnUserHandle.Valid {
+		return user, nil
+	}
+	handle, err := randomToken(32)
+	if err != nil {
+		return user, err
+	}
+	user.WebAuthnUserHandle = sql.NullString{String: handle, Valid: true}
+	return user, services.UpdateObjectDB(user, []string{"WebAuthnUserHandle"}, []string{"UserId"})
+}
+
+func loadPasskeyUserByHandle(handle string) (passkeyUser, error) {
+	users, err := services.LoadObjectDB(&services.DB_Users{WebAuthnUserHandle: sql.NullString{String: handle, Valid: true}}, "WebAuthnUserHandle")
+	if err != nil {
+		return passkeyUser{}, err
+	}
+	if len(users) == 0 {
+		return passkeyUser{}, ErrPasskeyNotFound
+	}
+	return loadPasskeyUser(users[0])
+}
+
+func loadPasskeyUser(user services.DB_Users) (passkeyUser, error) {
+	credentials, err := services.LoadObjectDB(&services.DB_WebAuthnCredentials{UserId: user.UserId}, "UserId")
+	if err != nil {
+		return passkeyUser{}, err
+	}
+	return passkeyUser{user: user, credentials: credentials}, nil
+}
+
+// Rebuilds the library credential from a stored passkey
+func storedToCredential(stored services.DB_WebAuthnCredentials) (webauthn.Credential, error) {
+	id, err := base64.RawURLEncoding.DecodeString(stored.CredentialID)
+	if err != nil {
+		return webauthn.Credential{}, err
+	}
+	publicKey, err := base64.RawURLEncoding.DecodeString(stored.PublicKey)
+	if err != nil {
+		return webauthn.Credential{}, err
+	}
+	aaguid, err := hex.DecodeString(stored.AAGUID)
+	if err != nil {
+		return webauthn.Credential{}, err
+	}
+	var transports []protocol.AuthenticatorTransport
+	for _, t := range strings.Split(stored.Transports, ",") {
+		if t != "" {
+			transports = append(transports, protocol.AuthenticatorTransport(t))
+		}
+	}
+	return webauthn.Credential{
+		ID:              id,
+		PublicKey:       publicKey,
+		AttestationType: stored.AttestationType,
+		Transport:       transports,
+		Flags: webauthn.CredentialFlags{
+			BackupEligible: stored.BackupEligible,
+			BackupState:    stored.BackupState,
+		},
+		Authenticator: webauthn.Authenticator{
+			AAGUID:    aaguid,
+			SignCount: uint32(stored.SignCount),
+		},
+	}, nil
+}
+
+// Stores the ceremony server side and puts its id in the ceremony cookie. The
+// cookie name doubles as the ceremony type so one can't be finished as the other
+func saveCeremony(w http.ResponseWriter, cookieName string, ceremony passkeyCeremony) error {
+	encoded, err := json.Marshal(ceremony)
+	if err != nil {
+		return err
+	}
+	now := time.Now().UTC()
+	expiry := now.Add(PasskeyCeremonyDuration)
+	challengeId, err := passkeyChallenges.save(services.DB_WebAuthnChallenges{
+		UserId:      ceremony.UserID,
+		Ceremony:    cookieName,
+		SessionData: string(encoded),
+		CreatedAt:   now,
+		ExpiresAt:   expiry,
+	})
+	if err != nil {
+		return err
+	}
+	return cookies.SetCookie(w, cookieName, strconv.Itoa(challengeId), expiry)
+}
+
+// Takes the ceremony named by the cookie out of the store. Both the cookie and
+// the stored challenge are single use whatever the outcome
+func takeCeremony(r *http.Request, w http.ResponseWriter, cookieName string) (passkeyCeremony, error) {
+	var ceremony passkeyCeremony
+	raw, err := cookies.GetCookie(r, cookieName)
+	_ = cookies.SetCookie(w, cookieName, "", DeleteCookieExpiry)
+	if err != nil {
+		return ceremony, ErrPasskeyCeremonyExpired
+	}
+	challengeId, err := strconv.Atoi(raw)
+	if err != nil {
+		return ceremony, ErrPasskeyCeremonyExpired
+	}
+	challenge, ok, err := passkeyChallenges.take(challengeId, cookieName)
+	if err != nil {
+		return ceremony, err
+	}
+	if !ok || time.Now().UTC().After(challenge.ExpiresAt.UTC()) {
+		return ceremony, ErrPasskeyCeremonyExpired
+	}
+	if err := json.Unmarshal([]byte(challenge.SessionData), &ceremony); err != nil || ceremony.Session.Challenge == "" {
+		return ceremony, ErrPasskeyCeremonyExpired
+	}
+	return ceremony, nil
+}
+
+// Protocol errors carry a more useful message in their details
+func protocolErrorDetail(err error) string {
+	var protocolErr *protocol.Error
+	if errors.As(err, &protocolErr) && protocolErr.Details != "" {
+		return protocolErr.Details
+	}
+	return err.Error()
+}
+
+func passkeyInfo(stored services.DB_WebAuthnCredentials) PasskeyInfo {
+	info := PasskeyInfo{
+		PasskeyId: stored.PasskeyId,
+		Name:      stored.Name,
+		Synced:    stored.BackupState,
+		CreatedAt: stored.CreatedAt,
+	}
+	if stored.LastUsedAt.Valid {
+		t := stored.LastUsedAt.Time
+		info.LastUsedAt = &t
+	}
+	return info
+}
@@ -0,0 +1,337 @@
+/*
+------------------------------------------------------------------
+FILE NAME:     passkeys_test.go
+PROJECT:       CashflowAnalysis
+Date Created:  Oct-19-2026
+--------------------------------------------------------------------
+DESCRIPTION:
+Passkey sign-in against a software authenticator. The authenticator holds
+a P-256 key and signs assertions the way a browser and platform
+authenticator would. The challenge store is swapped for a memory store and
+the user lookup is passed in, so no database is needed. Covers the happy
+path, replaying a finished ceremony, answering another ceremonies
+challenge, missing user verification and expired ceremonies.
+--------------------------------------------------------------------
+$HISTORY:
+
+Oct-19-2026   Created initial file.
+Oct-19-2026   Sets COOKIE_SECRET_KEY now that the cookie key comes from the environment
+------------------------------------------------------------------
+*/
+package userauth
+
+import (
+	"bytes"
+	services "cashflowanalysis/Services/DBContext"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-webauthn/webauthn/protocol/webauthncbor"
+	"github.com/go-webauthn/webauthn/protocol/webauthncose"
+)
+
+const (
+	testPasskeyRPID   = "localhost"
+	testPasskeyOrigin = "http://localhost:3000"
+	testPasskeyUserID = 7
+
+	//Authenticator data flags
+	flagUserPresent  = 0x01
+	flagUserVerified = 0x04
+)
+
+// Memory stand-in for DB_WebAuthnChallenges
+type memoryPasskeyChallenges struct {
+	mu     sync.Mutex
+	nextID int
+	rows   map[int]services.DB_WebAuthnChallenges
+}
+
+func (m *memoryPasskeyChallenges) save(challenge services.DB_WebAuthnChallenges) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.nextID++
+	challenge.ChallengeId = m.nextID
+	m.rows[m.nextID] = challenge
+	return m.nextID, nil
+}
+
+func (m *memoryPasskeyChallenges) take(challengeID int, ceremony string) (services.DB_WebAuthnChallenges, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	challenge, ok := m.rows[challengeID]
+	if !ok || challenge.Ceremony != ceremony {
+		return services.DB_WebAuthnChallenges{}, false, nil
+	}
+	delete(m.rows, challengeID)
+	return challenge, true, nil
+}
+
+// Platform authenticator holding one discoverable credential
+type softAuthenticator struct {
+	key          *ecdsa.PrivateKey
+	credentialID []byte
+	userHandle   []byte
+	signCount    uint32
+}
+
+func newSoftAuthenticator(t *testing.T) *softAuthenticator {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := &softAuthenticator{key: key, credentialID: make([]byte, 32), userHandle: make([]byte, 32)}
+	if _, err := rand.Read(a.credentialID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := rand.Read(a.userHandle); err != nil {
+		t.Fatal(err)
+	}
+	return a
+}
+
+// The passkey as FinishPasskeyRegistration() would have stored it
+func (a *softAuthenticator) stored(t *testing.T) services.DB_WebAuthnCredentials {
+	t.Helper()
+	pub, err := a.key.PublicKey.ECDH()
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw := pub.Bytes()
+	coseKey, err := webauthncbor.Marshal(webauthncose.EC2PublicKeyData{
+		PublicKeyData: webauthncose.PublicKeyData{
+			KeyType:   int64(webauthncose.EllipticKey),
+			Algorithm: int64(webauthncose.AlgES256),
+		},
+		Curve:  int64(webauthncose.P256),
+		XCoord: raw[1:33],
+		YCoord: raw[33:],
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return services.DB_WebAuthnCredentials{
+		PasskeyId:       1,
+		UserId:          testPasskeyUserID,
+		Name:            "Software key",
+		CredentialID:    base64.RawURLEncoding.EncodeToString(a.credentialID),
+		PublicKey:       base64.RawURLEncoding.EncodeToString(coseKey),
+		AttestationType: "none",
+		AAGUID:          "00000000000000000000000000000000",
+		Transports:      "internal",
+		CreatedAt:       time.Now().UTC(),
+	}
+}
+
+// The navigator.credentials.get() response to challenge, as the browser posts it
+func (a *softAuthenticator) assert(t *testing.T, challenge []byte, flags byte) []byte {
+	t.Helper()
+	clientData, err := json.Marshal(map[string]interface{}{
+		"type":        "webauthn.get",
+		"challenge":   base64.RawURLEncoding.EncodeToString(challenge),
+		"origin":      testPasskeyOrigin,
+		"crossOrigin": false,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a.signCount++
+	rpIDHash := sha256.Sum256([]byte(testPasskeyRPID))
+	authData := append(rpIDHash[:], flags)
+	authData = binary.BigEndian.AppendUint32(authData, a.signCount)
+
+	clientDataHash := sha256.Sum256(clientData)
+	digest := sha256.Sum256(append(append([]byte{}, authData...), clientDataHash[:]...))
+	signature, err := ecdsa.SignASN1(rand.Reader, a.key, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	encode := base64.RawURLEncoding.EncodeToString
+	body, err := json.Marshal(map[string]interface{}{
+		"id":    encode(a.credentialID),
+		"rawId": encode(a.credentialID),
+		"type":  "public-key",
+		"response": map[string]string{
+			"clientDataJSON":    encode(clientData),
+			"authenticatorData": encode(authData),
+			"signature":         encode(signature),
+			"userHandle":        encode(a.userHandle),
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return body
+}
+
+// Finds the authenticators user by the returned user handle, as loadPasskeyUserByHandle() does
+func (a *softAuthenticator) lookup(t *testing.T) func(handle string) (passkeyUser, error) {
+	stored := a.stored(t)
+	return func(handle string) (passkeyUser, error) {
+		if handle != base64.RawURLEncoding.EncodeToString(a.userHandle) {
+			return passkeyUser{}, ErrPasskeyNotFound
+		}
+		user := services.DB_Users{
+			UserId:             testPasskeyUserID,
+			Username:           "passkey-user",
+			WebAuthnUserHandle: sql.NullString{String: handle, Valid: true},
+		}
+		return passkeyUser{user: user, credentials: []services.DB_WebAuthnCredentials{stored}}, nil
+	}
+}
+
+func usePasskeyTestEnv(t *testing.T) *memoryPasskeyChallenges {
+	t.Helper()
+	t.Setenv("COOKIE_SECRET_KEY", testCookieSecretKey)
+	t.Setenv("WEBAUTHN_RP_ID", testPasskeyRPID)
+	t.Setenv("WEBAUTHN_ORIGINS", testPasskeyOrigin)
+	store := &memoryPasskeyChallenges{rows: map[int]services.DB_WebAuthnChallenges{}}
+	previous := passkeyChallenges
+	passkeyChallenges = store
+	t.Cleanup(func() { passkeyChallenges = previous })
+	return store
+}
+
+// Runs BeginPasskeyLogin() and returns the challenge and the ceremony cookie
+func beginTestPasskeyLogin(t *testing.T, rememberMe bool) ([]byte, *http.Cookie) {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	assertion, err := BeginPasskeyLogin(rec, rememberMe)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range rec.Result().Cookies() {
+		if c.Name == passkeyLoginCookie {
+			return assertion.Response.Challenge, c
+		}
+	}
+	t.Fatal("no passkey-login cookie was set")
+	return nil, nil
+}
+
+func passkeyFinishRequest(body []byte, cookie *http.Cookie) *http.Request {
+	req := httptest.NewRequest(http.MethodPost, "/api/passkeys/login/finish", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.AddCookie(cookie)
+	return req
+}
+
+// Takes the ceremony and verifies the body against it, as FinishPasskeyLogin() does
+func finishTestPasskeyLogin(t *testing.T, a *softAuthenticator, body []byte, cookie *http.Cookie) (passkeyCeremony, passkeyUser, error) {
+	t.Helper()
+	req := passkeyFinishRequest(body, cookie)
+	ceremony, err := takeCeremony(req, httptest.NewRecorder(), passkeyLoginCookie)
+	if err != nil {
+		return ceremony, passkeyUser{}, err
+	}
+	rp, err := relyingParty()
+	if err != nil {
+		t.Fatal(err)
+	}
+	found, _, err := verifyPasskeyLogin(rp, req, ceremony, a.lookup(t))
+	return ceremony, found, err
+}
+
+func TestPasskeyLoginWithSoftwareAuthenticator(t *testing.T) {
+	store := usePasskeyTestEnv(t)
+	a := newSoftAuthenticator(t)
+
+	challenge, cookie := beginTestPasskeyLogin(t, true)
+	if len(store.rows) != 1 {
+		t.Fatalf("expected the challenge to be stored server side, found %d rows", len(store.rows))
+	}
+	body := a.assert(t, challenge, flagUserPresent|flagUserVerified)
+
+	ceremony, found, err := finishTestPasskeyLogin(t, a, body, cookie)
+	if err != nil {
+		t.Fatalf("assertion was rejected: %v", protocolErrorDetail(err))
+	}
+	if found.user.UserId != testPasskeyUserID {
+		t.Fatalf("expected user %d, got %d", testPasskeyUserID, found.user.UserId)
+	}
+	if !ceremony.RememberMe {
+		t.Fatal("remember me was lost between Begin and Finish")
+	}
+	if len(store.rows) != 0 {
+		t.Fatal("the challenge was not deleted after use")
+	}
+}
+
+func TestPasskeyLoginCannotBeReplayed(t *testing.T) {
+	usePasskeyTestEnv(t)
+	a := newSoftAuthenticator(t)
+
+	challenge, cookie := beginTestPasskeyLogin(t, false)
+	body := a.assert(t, challenge, flagUserPresent|flagUserVerified)
+	if _, _, err := finishTestPasskeyLogin(t, a, body, cookie); err != nil {
+		t.Fatalf("first use was rejected: %v", protocolErrorDetail(err))
+	}
+
+	//The same signed response and cookie, captured and sent again
+	if _, _, err := finishTestPasskeyLogin(t, a, body, cookie); !errors.Is(err, ErrPasskeyCeremonyExpired) {
+		t.Fatalf("expected ErrPasskeyCeremonyExpired on replay, got %v", err)
+	}
+
+	//A fresh assertion over the old challenge is no better
+	body = a.assert(t, challenge, flagUserPresent|flagUserVerified)
+	if _, _, err := finishTestPasskeyLogin(t, a, body, cookie); !errors.Is(err, ErrPasskeyCeremonyExpired) {
+		t.Fatalf("expected ErrPasskeyCeremonyExpired for a used challenge, got %v", err)
+	}
+}
+
+func TestPasskeyLoginRejectsAnotherCeremoniesChallenge(t *testing.T) {
+	usePasskeyTestEnv(t)
+	a := newSoftAuthenticator(t)
+
+	firstChallenge, _ := beginTestPasskeyLogin(t, false)
+	_, secondCookie := beginTestPasskeyLogin(t, false)
+
+	body := a.assert(t, firstChallenge, flagUserPresent|flagUserVerified)
+	if _, _, err := finishTestPasskeyLogin(t, a, body, secondCookie); err == nil {
+		t.Fatal("an assertion over another ceremonies challenge was accepted")
+	}
+}
+
+func TestPasskeyLoginRequiresUserVerification(t *testing.T) {
+	usePasskeyTestEnv(t)
+	a := newSoftAuthenticator(t)
+
+	challenge, cookie := beginTestPasskeyLogin(t, false)
+	body := a.assert(t, challenge, flagUserPresent)
+	if _, _, err := finishTestPasskeyLogin(t, a, body, cookie); err == nil {
+		t.Fatal("an assertion without user verification was accepted")
+	}
+}
+
+func TestPasskeyLoginRejectsExpiredCeremony(t *testing.T) {
+	store := usePasskeyTestEnv(t)
+	a := newSoftAuthenticator(t)
+
+	challenge, cookie := beginTestPasskeyLogin(t, false)
+	for id, row := range store.rows {
+		row.ExpiresAt = time.Now().UTC().Add(-time.Second)
+		store.rows[id] = row
+	}
+	body := a.assert(t, challenge, flagUserPresent|flagUserVerified)
+	if _, _, err := finishTestPasskeyLogin(t, a, body, cookie); !errors.Is(err, ErrPasskeyCeremonyExpired) {
+		t.Fatalf("expected ErrPasskeyCeremonyExpired, got %v", err)
+	}
+	if len(store.rows) != 0 {
+		t.Fatal("the expired challenge was not deleted")
+	}
+}
@@ -0,0 +1,200 @@
+/*
+------------------------------------------------------------------
+FILE NAME:     password.go
+PROJECT:       CashflowAnalysis
+Date Created:  Oct-19-2026
+--------------------------------------------------------------------
+DESCRIPTION:
+Password change for logged in users and the forgot/reset password flow.
+Reset tokens are single use, expire after PasswordResetDuration and only
+their hash is stored. Both flows revoke the users other sessions on success.
+--------------------------------------------------------------------
+$HISTORY:
+
+Oct-19-2026   Created initial file.
+Oct-19-2026   Password changes and resets are recorded as security events
+------------------------------------------------------------------
+*/
+package userauth
+
+import (
+	services "cashflowanalysis/Services/DBContext"
+	helper "cashflowanalysis/Services/Helpers"
+	mailer "cashflowanalysis/Services/Mailer"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	PasswordResetDuration = 1 * time.Hour
+	minPasswordLength     = 8
+)
+
+var (
+	ErrWeakPassword       = fmt.Errorf("password must be at least %d characters", minPasswordLength)
+	ErrInvalidResetToken  = errors.New("reset token is invalid or has expired")
+	ErrInvalidEmail       = errors.New("email address is invalid")
+	ErrPasswordHashFailed = errors.New("could not hash password")
+)
+
+// Changes the password of the logged in user after checking their current password.
+// All of the users sessions except the one making the request are revoked
+func ChangePassword(r *http.Request, currentPassword string, newPassword string) error {
+	user, err := AuthenticatedUser(r)
+	if err != nil {
+		return err
+	}
+	event := helper.SecurityEvent{UserID: user.UserId, Username: user.Username, Type: helper.EventPasswordChange}
+	if !checkPasswordHash(currentPassword, user.PasswordHash) {
+		event.Detail = "current password incorrect"
+		helper.RecordSecurityEvent(r, event)
+		return ErrInvalidCredentials
+	}
+	if err := setPassword(user, newPassword); err != nil {
+		event.Detail = err.Error()
+		helper.RecordSecurityEvent(r, event)
+		return err
+	}
+	event.Success = true
+	helper.RecordSecurityEvent(r, event)
+	return revokeUserSessions(user.UserId, currentSessionID(r))
+}
+
+// Emails a reset link to the user matching the username or email given.
+// Returns nil when no user matches so callers cannot use it to discover accounts
+func RequestPasswordReset(identifier string) error {
+	identifier = strings.TrimSpace(identifier)
+	if identifier == "" {
+		return nil
+	}
+
+	users, err := services.LoadObjectDB(&services.DB_Users{Username: identifier}, "Username")
+	if err != nil {
+		return err
+	}
+	if len(users) == 0 {
+		users, err = services.LoadObjectDB(&services.DB_Users{Email: sql.NullString{String: identifier, Valid: true}}, "Email")
+		if err != nil {
+			return err
+		}
+	}
+	if len(users) == 0 || !users[0].Email.Valid {
+		return nil
+	}
+	user := users[0]
+
+	//Only the newest link should work
+	if err := expireResetTokens(user.UserId); err != nil {
+		return err
+	}
+
+	token, err := randomToken(32)
+	if err != nil {
+		return err
+	}
+	now := time.Now().UTC()
+	_, err = services.CreateObjectDB(services.DB_PasswordResetTokens{
+		UserId:    user.UserId,
+		TokenHash: hashToken(token),
+		CreatedAt: now,
+		ExpiresAt: now.Add(PasswordResetDuration),
+		UsedAt:    sql.NullTime{Valid: false},
+	})
+	if err != nil {
+		return err
+	}
+
+	link := appBaseURL() + "/reset-password?token=" + url.QueryEscape(token)
+	return mailer.Default().Send(mailer.Message{
+		To:      []string{user.Email.String},
+		Subject: "Reset your CashflowAnalysis password",
+		Body: fmt.Sprintf("Hi %s,\n\nUse the link below to reset your password. It expires in %d minutes and can only be used once.\n\n%s\n\nIf you did not request this you can ignore this email.\n",
+			user.Username, int(PasswordResetDuration.Minutes()), link),
+	})
+}
+
+// Sets a new password using an emailed reset token and revokes every session of the user
+func ResetPassword(r *http.Request, token string, newPassword string) error {
+	if len(newPassword) < minPasswordLength {
+		return ErrWeakPassword
+	}
+
+	tokens, err := services.LoadObjectDB(&services.DB_PasswordResetTokens{TokenHash: hashToken(token)}, "TokenHash")
+	if err != nil {
+		return err
+	}
+	if len(tokens) == 0 {
+		helper.RecordSecurityEvent(r, helper.SecurityEvent{Type: helper.EventPasswordReset, Detail: "unknown reset token"})
+		return ErrInvalidResetToken
+	}
+	resetToken := tokens[0]
+	now := time.Now().UTC()
+	if resetToken.UsedAt.Valid || now.After(resetToken.ExpiresAt.UTC()) {
+		helper.RecordSecurityEvent(r, helper.SecurityEvent{UserID: resetToken.UserId, Type: helper.EventPasswordReset, Detail: "reset token used or expired"})
+		return ErrInvalidResetToken
+	}
+
+	//Mark the token used before changing anything so it can't be used twice
+	resetToken.UsedAt = sql.NullTime{Time: now, Valid: true}
+	if err := services.UpdateObjectDB(resetToken, []string{"UsedAt"}, []string{"ResetTokenId"}); err != nil {
+		return err
+	}
+
+	user, err := loadUser(resetToken.UserId)
+	if err != nil {
+		return err
+	}
+	if err := setPassword(user, newPassword); err != nil {
+		return err
+	}
+	helper.RecordSecurityEvent(r, helper.SecurityEvent{UserID: user.UserId, Username: user.Username, Type: helper.EventPasswordReset, Success: true})
+	return revokeUserSessions(user.UserId, 0)
+}
+
+// Hashes and stores a new password for the user
+func setPassword(user services.DB_Users, newPassword string) error {
+	if len(newPassword) < minPasswordLength {
+		return ErrWeakPassword
+	}
+	hash := hashPassword(newPassword)
+	if hash == "" {
+		return ErrPasswordHashFailed
+	}
+	user.PasswordHash = hash
+	user.UpdatedAt = time.Now().UTC()
+	return services.UpdateObjectDB(user, []string{"PasswordHash", "UpdatedAt"}, []string{"UserId"})
+}
+
+// Marks all outstanding reset tokens for the user as used
+func expireResetTokens(userID int) error {
+	tokens, err := services.LoadObjectDB(&services.DB_PasswordResetTokens{UserId: userID}, "UserId")
+	if err != nil {
+		return err
+	}
+	for _, t := range tokens {
+		if t.UsedAt.Valid {
+			continue
+		}
+		t.UsedAt = sql.NullTime{Time: time.Now().UTC(), Valid: true}
+		if err := services.UpdateObjectDB(t, []string{"UsedAt"}, []string{"ResetTokenId"}); err != nil {
+			log.Println("could not expire reset token:", err)
+		}
+	}
+	return nil
+}
+
+// Base url of the frontend used to build links in emails
+func appBaseURL() string {
+	base := os.Getenv("APP_BASE_URL")
+	if base == "" {
+		base = "http://localhost:3000"
+	}
+	return strings.TrimRight(base, "/")
+}
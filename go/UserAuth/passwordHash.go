@@ -0,0 +1,203 @@
+/*
+------------------------------------------------------------------
+FILE NAME:     passwordHash.go
+PROJECT:       CashflowAnalysis
+Date Created:  Oct-19-2026
+--------------------------------------------------------------------
+DESCRIPTION:
+Versioned password hashes. New hashes use argon2id in the PHC string format
+($argon2id$v=19$m=<KiB>,t=<iterations>,p=<threads>$<salt>$<hash>) with
+parameters read from the environment. Older bcrypt hashes ($2a$, $2b$, $2y$)
+are still accepted and are upgraded the next time the user logs in.
+--------------------------------------------------------------------
+$HISTORY:
+
+Oct-19-2026   Created initial file. Moved hashPassword() and checkPasswordHash() here
+-             from authorizeUser.go
+------------------------------------------------------------------
+*/
+package userauth
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	HashArgon2id = "argon2id"
+	HashBcrypt   = "bcrypt"
+
+	//OWASP recommended minimum for argon2id is 19 MiB, 2 iterations, 1 thread
+	DefaultArgon2Memory      = 64 * 1024
+	DefaultArgon2Iterations  = 3
+	DefaultArgon2Parallelism = 2
+	DefaultBcryptCost        = bcrypt.DefaultCost
+
+	argon2SaltLength = 16
+	argon2KeyLength  = 32
+)
+
+var errUnknownHashFormat = errors.New("unknown password hash format")
+
+// Algorithm and parameters used for new password hashes
+type passwordHashPolicy struct {
+	Algorithm   string
+	Memory      uint32
+	Iterations  uint32
+	Parallelism uint8
+	BcryptCost  int
+}
+
+// Decoded argon2id PHC string
+type argon2Hash struct {
+	Version     int
+	Memory      uint32
+	Iterations  uint32
+	Parallelism uint8
+	Salt        []byte
+	Key         []byte
+}
+
+// Reads the hash policy from PASSWORD_HASH_ALGORITHM, ARGON2_MEMORY_KIB,
+// ARGON2_ITERATIONS, ARGON2_PARALLELISM and BCRYPT_COST
+func currentHashPolicy() passwordHashPolicy {
+	policy := passwordHashPolicy{
+		Algorithm:   HashArgon2id,
+		Memory:      uint32(intFromEnv("ARGON2_MEMORY_KIB", DefaultArgon2Memory, 8*1024, 4*1024*1024)),
+		Iterations:  uint32(intFromEnv("ARGON2_ITERATIONS", DefaultArgon2Iterations, 1, 100)),
+		Parallelism: uint8(intFromEnv("ARGON2_PARALLELISM", DefaultArgon2Parallelism, 1, 255)),
+		BcryptCost:  intFromEnv("BCRYPT_COST", DefaultBcryptCost, bcrypt.MinCost, bcrypt.MaxCost),
+	}
+	switch algorithm := strings.ToLower(os.Getenv("PASSWORD_HASH_ALGORITHM")); algorithm {
+	case "", HashArgon2id:
+	case HashBcrypt:
+		policy.Algorithm = HashBcrypt
+	default:
+		log.Printf("invalid PASSWORD_HASH_ALGORITHM %q, using %s", algorithm, HashArgon2id)
+	}
+	return policy
+}
+
+// Hashes the password with the current policy
+// Returns "" if the hash could not be generated
+func hashPassword(password string) string {
+	policy := currentHashPolicy()
+	if policy.Algorithm == HashBcrypt {
+		hash, err := bcrypt.GenerateFromPassword([]byte(password), policy.BcryptCost)
+		if err != nil {
+			log.Println("could not hash password:", err)
+			return ""
+		}
+		return string(hash)
+	}
+
+	salt := make([]byte, argon2SaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		log.Println("could not hash password:", err)
+		return ""
+	}
+	key := argon2.IDKey([]byte(password), salt, policy.Iterations, policy.Memory, policy.Parallelism, argon2KeyLength)
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s", argon2.Version, policy.Memory, policy.Iterations, policy.Parallelism,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key))
+}
+
+// Compare user given password and hashed password from database
+// The algorithm is detected from the stored hash
+func checkPasswordHash(password, hash string) bool {
+	switch {
+	case strings.HasPrefix(hash, "$argon2id$"):
+		decoded, err := decodeArgon2Hash(hash)
+		if err != nil {
+			return false
+		}
+		key := argon2.IDKey([]byte(password), decoded.Salt, decoded.Iterations, decoded.Memory, decoded.Parallelism, uint32(len(decoded.Key)))
+		return subtle.ConstantTimeCompare(key, decoded.Key) == 1
+	case isBcryptHash(hash):
+		return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil
+	}
+	return false
+}
+
+// Returns true when the stored hash was made with a different algorithm or
+// weaker parameters than the current policy
+func passwordNeedsRehash(hash string) bool {
+	policy := currentHashPolicy()
+	switch {
+	case strings.HasPrefix(hash, "$argon2id$"):
+		if policy.Algorithm != HashArgon2id {
+			return true
+		}
+		decoded, err := decodeArgon2Hash(hash)
+		if err != nil {
+			return true
+		}
+		return decoded.Version != argon2.Version ||
+			decoded.Memory < policy.Memory ||
+			decoded.Iterations < policy.Iterations ||
+			decoded.Parallelism != policy.Parallelism ||
+			len(decoded.Key) < argon2KeyLength
+	case isBcryptHash(hash):
+		if policy.Algorithm != HashBcrypt {
+			return true
+		}
+		cost, err := bcrypt.Cost([]byte(hash))
+		return err != nil || cost < policy.BcryptCost
+	}
+	return true
+}
+
+func isBcryptHash(hash string) bool {
+	return strings.HasPrefix(hash, "$2a$") || strings.HasPrefix(hash, "$2b$") || strings.HasPrefix(hash, "$2y$")
+}
+
+// Parses $argon2id$v=19$m=65536,t=3,p=2$<salt>$<hash>
+func decodeArgon2Hash(hash string) (argon2Hash, error) {
+	var decoded argon2Hash
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 || parts[1] != HashArgon2id {
+		return decoded, errUnknownHashFormat
+	}
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &decoded.Version); err != nil {
+		return decoded, errUnknownHashFormat
+	}
+	var parallelism uint32
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &decoded.Memory, &decoded.Iterations, &parallelism); err != nil {
+		return decoded, errUnknownHashFormat
+	}
+	if decoded.Memory == 0 || decoded.Iterations == 0 || parallelism == 0 || parallelism > 255 {
+		return decoded, errUnknownHashFormat
+	}
+	decoded.Parallelism = uint8(parallelism)
+
+	var err error
+	if decoded.Salt, err = base64.RawStdEncoding.DecodeString(parts[4]); err != nil {
+		return decoded, errUnknownHashFormat
+	}
+	if decoded.Key, err = base64.RawStdEncoding.DecodeString(parts[5]); err != nil || len(decoded.Key) == 0 {
+		return decoded, errUnknownHashFormat
+	}
+	return decoded, nil
+}
+
+func intFromEnv(name string, fallback int, min int, max int) int {
+	value := os.Getenv(name)
+	if value == "" {
+		return fallback
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < min || n > max {
+		log.Printf("invalid %s %q, using %d", name, value, fallback)
+		return fallback
+	}
+	return n
+}
@@ -0,0 +1,99 @@
+/*
+------------------------------------------------------------------
+FILE NAME:     roles.go
+PROJECT:       CashflowAnalysis
+Date Created:  Oct-19-2026
+--------------------------------------------------------------------
+DESCRIPTION:
+User roles and building the request principal from the session cookie.
+Roles are ranked user < support < admin, a higher role can do anything
+a lower role can.
+--------------------------------------------------------------------
+$HISTORY:
+
+Oct-19-2026   Created initial file.
+Oct-19-2026   AuthenticatePrincipal() accepts an Authorization: Bearer personal access token
+Oct-19-2026   Impersonation sessions carry the impersonating admin in the principal
+------------------------------------------------------------------
+*/
+package userauth
+
+import (
+	helper "cashflowanalysis/Services/Helpers"
+	"errors"
+	"net/http"
+	"strings"
+)
+
+const (
+	RoleUser    = "user"
+	RoleSupport = "support"
+	RoleAdmin   = "admin"
+)
+
+var ErrAccountDisabled = errors.New("account is disabled")
+
+var roleRank = map[string]int{
+	RoleUser:    1,
+	RoleSupport: 2,
+	RoleAdmin:   3,
+}
+
+// Builds the principal for the request from an Authorization: Bearer personal
+// access token or from the session-id cookie.
+// Fails if the session/token is revoked or expired or the account is disabled
+func AuthenticatePrincipal(r *http.Request) (helper.Principal, error) {
+	if token, ok := bearerToken(r); ok {
+		return authenticateAccessToken(token)
+	}
+
+	session, err := authenticatedSession(r)
+	if err != nil {
+		return helper.Principal{}, err
+	}
+	user, err := loadUser(session.UserId)
+	if err != nil {
+		return helper.Principal{}, err
+	}
+	if user.DisabledAt.Valid {
+		return helper.Principal{}, ErrAccountDisabled
+	}
+	p := helper.Principal{
+		UserID:     user.UserId,
+		Username:   user.Username,
+		Role:       normalizeRole(user.Role),
+		AuthMethod: helper.AuthMethodSession,
+		SessionID:  session.SessionId,
+	}
+	if session.ImpersonatorUserId != 0 {
+		return impersonatorPrincipal(p, session)
+	}
+	return p, nil
+}
+
+// True if the principals role is at least the minimum role given
+func HasRole(p helper.Principal, minimum string) bool {
+	return roleRank[normalizeRole(p.Role)] >= roleRank[minimum]
+}
+
+func ValidRole(role string) bool {
+	_, ok := roleRank[role]
+	return ok
+}
+
+// Users created before roles existed have no role stored and are treated as users
+func normalizeRole(role string) string {
+	if ValidRole(role) {
+		return role
+	}
+	return RoleUser
+}
+
+// Returns the token from an "Authorization: Bearer <token>" header
+func bearerToken(r *http.Request) (string, bool) {
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") || strings.TrimSpace(token) == "" {
+		return "", false
+	}
+	return strings.TrimSpace(token), true
+}
@@ -0,0 +1,104 @@
+/*
+------------------------------------------------------------------
+FILE NAME:     sessionTimeouts.go
+PROJECT:       CashflowAnalysis
+Date Created:  Oct-19-2026
+--------------------------------------------------------------------
+DESCRIPTION:
+Idle and absolute session timeouts. A session ends when it has not been
+used for the idle timeout or when it reaches its absolute lifetime, whichever
+comes first. "Remember me" sessions use their own, longer, pair of timeouts.
+Both are enforced from the DB_Sessions columns, not the cookie expiry.
+--------------------------------------------------------------------
+$HISTORY:
+
+Oct-19-2026   Created initial file.
+------------------------------------------------------------------
+*/
+package userauth
+
+import (
+	services "cashflowanalysis/Services/DBContext"
+	"log"
+	"os"
+	"time"
+)
+
+const (
+	DefaultSessionIdleTimeout        = 30 * time.Minute
+	DefaultSessionAbsoluteTimeout    = 12 * time.Hour
+	DefaultRememberMeIdleTimeout     = 7 * 24 * time.Hour
+	DefaultRememberMeAbsoluteTimeout = 30 * 24 * time.Hour
+
+	//Activity is written to the session at most this often
+	sessionTouchInterval = time.Minute
+)
+
+type sessionPolicy struct {
+	Idle     time.Duration
+	Absolute time.Duration
+}
+
+// Returns the timeouts for a normal or remember-me session. Configured with
+// SESSION_IDLE_TIMEOUT, SESSION_ABSOLUTE_TIMEOUT, REMEMBER_ME_IDLE_TIMEOUT and
+// REMEMBER_ME_ABSOLUTE_TIMEOUT as Go durations (i.e. 30m, 12h)
+func sessionPolicyFor(rememberMe bool) sessionPolicy {
+	if rememberMe {
+		return sessionPolicy{
+			Idle:     durationFromEnv("REMEMBER_ME_IDLE_TIMEOUT", DefaultRememberMeIdleTimeout),
+			Absolute: durationFromEnv("REMEMBER_ME_ABSOLUTE_TIMEOUT", DefaultRememberMeAbsoluteTimeout),
+		}
+	}
+	return sessionPolicy{
+		Idle:     durationFromEnv("SESSION_IDLE_TIMEOUT", DefaultSessionIdleTimeout),
+		Absolute: durationFromEnv("SESSION_ABSOLUTE_TIMEOUT", DefaultSessionAbsoluteTimeout),
+	}
+}
+
+// Idle deadline for a session last used at lastSeen, never past the absolute expiry
+func idleExpiry(lastSeen time.Time, absoluteExpiry time.Time, policy sessionPolicy) time.Time {
+	expiry := lastSeen.Add(policy.Idle)
+	if expiry.After(absoluteExpiry) {
+		return absoluteExpiry
+	}
+	return expiry
+}
+
+// Absolute expiry of the session. Sessions created before the column existed
+// fall back to their creation time plus the current absolute timeout
+func absoluteExpiry(session services.DB_Sessions) time.Time {
+	if session.AbsoluteExpiresAt.IsZero() {
+		return session.CreatedAt.UTC().Add(sessionPolicyFor(session.RememberMe).Absolute)
+	}
+	return session.AbsoluteExpiresAt.UTC()
+}
+
+// Records activity on the session and slides its idle deadline forward.
+// Only writes to the database about once a minute
+func touchSession(session services.DB_Sessions) services.DB_Sessions {
+	now := time.Now().UTC()
+	if now.Sub(session.LastSeenAt.UTC()) < sessionTouchInterval {
+		return session
+	}
+	session.LastSeenAt = now
+	session.AbsoluteExpiresAt = absoluteExpiry(session)
+	session.ExpiresAt = idleExpiry(now, session.AbsoluteExpiresAt, sessionPolicyFor(session.RememberMe))
+	err := services.UpdateObjectDB(session, []string{"LastSeenAt", "ExpiresAt", "AbsoluteExpiresAt"}, []string{"SessionId"})
+	if err != nil {
+		log.Println("could not update session activity:", err)
+	}
+	return session
+}
+
+func durationFromEnv(name string, fallback time.Duration) time.Duration {
+	value := os.Getenv(name)
+	if value == "" {
+		return fallback
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil || d <= 0 {
+		log.Printf("invalid %s %q, using %s", name, value, fallback)
+		return fallback
+	}
+	return d
+}
@@ -0,0 +1,281 @@
+/*
+------------------------------------------------------------------
+FILE NAME:     sessions.go
+PROJECT:       CashflowAnalysis
+Date Created:  Oct-19-2026
+--------------------------------------------------------------------
+DESCRIPTION:
+Lets users see and revoke their own sessions. Also keeps DB_Users.IsActive
+in sync with whether the user has any active session. Each session has a
+random CSRF token, only its hash is stored with the session.
+--------------------------------------------------------------------
+$HISTORY:
+
+Oct-19-2026   Created initial file. Moved revokeUserSessions() and currentSessionID()
+-             from authorizeUser.go
+Oct-19-2026   Sessions end after the idle or absolute timeout. Using a session records activity
+Oct-19-2026   SessionInfo flags admin impersonation sessions
+Oct-19-2026   Added recentlyAuthenticated() for actions that need a fresh login
+Oct-19-2026   Added ValidCSRFToken(), RenewCSRFToken() and issueCSRFToken()
+------------------------------------------------------------------
+*/
+package userauth
+
+import (
+	cookies "cashflowanalysis/CookieHandler"
+	services "cashflowanalysis/Services/DBContext"
+	"database/sql"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Sessions logged in to this recently can confirm sensitive actions without a password
+const RecentAuthWindow = 5 * time.Minute
+
+var ErrSessionNotFound = errors.New("session not found")
+
+// Session details returned to the user. Never includes anything that could be
+// used to take over the session
+type SessionInfo struct {
+	SessionId         int       `json:"session_id"`
+	CreatedAt         time.Time `json:"created_at"`
+	ExpiresAt         time.Time `json:"expires_at"`
+	AbsoluteExpiresAt time.Time `json:"absolute_expires_at"`
+	LastSeenAt        time.Time `json:"last_seen_at"`
+	RememberMe        bool      `json:"remember_me"`
+	IPAddress         string    `json:"ip_address"`
+	UserAgent         string    `json:"user_agent"`
+	DeviceName        string    `json:"device_name"`
+	Current           bool      `json:"current"`
+	//True for read-only sessions opened by an admin for support
+	Impersonation bool `json:"impersonation"`
+}
+
+// Lists the active (not revoked or expired) sessions of the logged in user
+func ListActiveSessions(r *http.Request) ([]SessionInfo, error) {
+	current, err := authenticatedSession(r)
+	if err != nil {
+		return nil, err
+	}
+	sessions, err := activeSessions(current.UserId)
+	if err != nil {
+		return nil, err
+	}
+
+	infos := []SessionInfo{}
+	for _, s := range sessions {
+		infos = append(infos, SessionInfo{
+			SessionId:         s.SessionId,
+			CreatedAt:         s.CreatedAt,
+			ExpiresAt:         s.ExpiresAt,
+			AbsoluteExpiresAt: absoluteExpiry(s),
+			LastSeenAt:        lastSeen(s),
+			RememberMe:        s.RememberMe,
+			IPAddress:         s.IPAddress.String,
+			UserAgent:         s.UserAgent.String,
+			DeviceName:        s.DeviceName.String,
+			Current:           s.SessionId == current.SessionId,
+			Impersonation:     s.ImpersonatorUserId != 0,
+		})
+	}
+	return infos, nil
+}
+
+// Revokes one of the logged in users sessions. The session must belong to them
+func RevokeSession(r *http.Request, sessionID int) error {
+	current, err := authenticatedSession(r)
+	if err != nil {
+		return err
+	}
+	sessions, err := services.LoadObjectDB(&services.DB_Sessions{SessionId: sessionID, UserId: current.UserId}, "SessionId", "UserId")
+	if err != nil {
+		return err
+	}
+	if len(sessions) == 0 {
+		return ErrSessionNotFound
+	}
+	session := sessions[0]
+	if !session.RevokedAt.Valid {
+		session.RevokedAt = sql.NullTime{Time: time.Now().UTC(), Valid: true}
+		err = services.UpdateObjectDB(session, []string{"RevokedAt"}, []string{"SessionId"})
+		if err != nil {
+			return err
+		}
+	}
+	return refreshUserActiveState(current.UserId)
+}
+
+// Revokes every session of the logged in user except the one making the request.
+// Returns the number of sessions revoked
+func RevokeOtherSessions(r *http.Request) (int, error) {
+	current, err := authenticatedSession(r)
+	if err != nil {
+		return 0, err
+	}
+	sessions, err := activeSessions(current.UserId)
+	if err != nil {
+		return 0, err
+	}
+	if err := revokeUserSessions(current.UserId, current.SessionId); err != nil {
+		return 0, err
+	}
+	return len(sessions) - 1, nil
+}
+
+// Loads the session from the session-id cookie if it has not been revoked or expired
+// and records the activity, which pushes back the idle timeout
+func authenticatedSession(r *http.Request) (services.DB_Sessions, error) {
+	intSessionId := currentSessionID(r)
+	if intSessionId == 0 {
+		return services.DB_Sessions{}, ErrNotAuthorized
+	}
+	sessions, err := services.LoadObjectDB(&services.DB_Sessions{SessionId: intSessionId}, "SessionId")
+	if err != nil {
+		return services.DB_Sessions{}, err
+	}
+	if len(sessions) == 0 || !sessionIsActive(sessions[0]) {
+		return services.DB_Sessions{}, ErrNotAuthorized
+	}
+	return touchSession(sessions[0]), nil
+}
+
+// Loads all sessions of the user that have not been revoked or expired
+func activeSessions(userID int) ([]services.DB_Sessions, error) {
+	sessions, err := services.LoadObjectDB(&services.DB_Sessions{UserId: userID}, "UserId")
+	if err != nil {
+		return nil, err
+	}
+	var active []services.DB_Sessions
+	for _, s := range sessions {
+		if sessionIsActive(s) {
+			active = append(active, s)
+		}
+	}
+	return active, nil
+}
+
+// A session is active until it is revoked, passes its absolute expiry or has been
+// idle for longer than the idle timeout
+func sessionIsActive(session services.DB_Sessions) bool {
+	if session.RevokedAt.Valid {
+		return false
+	}
+	now := time.Now().UTC()
+	absolute := absoluteExpiry(session)
+	idle := idleExpiry(lastSeen(session), absolute, sessionPolicyFor(session.RememberMe))
+	return now.Before(absolute) && now.Before(idle) && now.Before(session.ExpiresAt.UTC())
+}
+
+// When the session was last used, its creation time if it has never been touched
+func lastSeen(session services.DB_Sessions) time.Time {
+	if session.LastSeenAt.IsZero() {
+		return session.CreatedAt.UTC()
+	}
+	return session.LastSeenAt.UTC()
+}
+
+// Sets DB_Users.IsActive to whether the user has at least one active session
+func refreshUserActiveState(userID int) error {
+	sessions, err := activeSessions(userID)
+	if err != nil {
+		return err
+	}
+	user := services.DB_Users{
+		UserId:    userID,
+		IsActive:  len(sessions) > 0,
+		UpdatedAt: time.Now().UTC(),
+	}
+	return services.UpdateObjectDB(user, []string{"IsActive", "UpdatedAt"}, []string{"UserId"})
+}
+
+// Revokes every active session of the user except exceptSessionID (0 revokes all)
+func revokeUserSessions(userID int, exceptSessionID int) error {
+	sessions, err := services.LoadObjectDB(&services.DB_Sessions{UserId: userID}, "UserId")
+	if err != nil {
+		return err
+	}
+	now := time.Now().UTC()
+	for _, session := range sessions {
+		if session.SessionId == exceptSessionID || session.RevokedAt.Valid {
+			continue
+		}
+		session.RevokedAt = sql.NullTime{Time: now, Valid: true}
+		err = services.UpdateObjectDB(session, []string{"RevokedAt"}, []string{"SessionId"})
+		if err != nil {
+			return err
+		}
+	}
+	return refreshUserActiveState(userID)
+}
+
+// Reports whether the session was created by a login (password, passkey or single
+// sign-on) within RecentAuthWindow. Impersonation sessions never count
+func recentlyAuthenticated(session services.DB_Sessions) bool {
+	if session.ImpersonatorUserId != 0 {
+		return false
+	}
+	return time.Since(session.CreatedAt) <= RecentAuthWindow
+}
+
+// Returns the session id stored in the session-id cookie, 0 if there is none
+func currentSessionID(r *http.Request) int {
+	sessionID, err := cookies.GetCookie(r, "session-id")
+	if err != nil {
+		return 0
+	}
+	intSessionId, _ := strconv.Atoi(sessionID)
+	return intSessionId
+}
+
+// Checks the X-CSRF-Token value sent with a request against the token issued
+// with the session in the session-id cookie
+func ValidCSRFToken(r *http.Request, token string) bool {
+	sessionID := currentSessionID(r)
+	if sessionID == 0 {
+		return false
+	}
+	sessions, err := services.LoadObjectDB(&services.DB_Sessions{SessionId: sessionID}, "SessionId")
+	if err != nil || len(sessions) == 0 {
+		return false
+	}
+	return cookies.ValidCSRFToken(sessions[0].CSRFTokenHash.String, token)
+}
+
+// Replaces the CSRF token of the session in the session-id cookie and sets the
+// csrf-token cookie again. Only the hash is stored so a lost token can't be
+// read back, the client gets a new one instead
+func RenewCSRFToken(r *http.Request, w http.ResponseWriter) (string, error) {
+	session, err := authenticatedSession(r)
+	if err != nil {
+		return "", err
+	}
+	token, err := issueCSRFToken(strconv.Itoa(session.SessionId))
+	if err != nil {
+		return "", err
+	}
+	cookies.SetCSRFCookie(w, token, absoluteExpiry(session))
+	return token, nil
+}
+
+// Creates a random CSRF token for the session and stores its hash, replacing
+// the previous token
+func issueCSRFToken(sessionID string) (string, error) {
+	intSessionId, err := strconv.Atoi(sessionID)
+	if err != nil {
+		return "", ErrSessionNotFound
+	}
+	token, err := cookies.NewCSRFToken()
+	if err != nil {
+		return "", err
+	}
+	session := services.DB_Sessions{
+		SessionId:     intSessionId,
+		CSRFTokenHash: sql.NullString{String: cookies.HashCSRFToken(token), Valid: true},
+	}
+	if err := services.UpdateObjectDB(session, []string{"CSRFTokenHash"}, []string{"SessionId"}); err != nil {
+		return "", err
+	}
+	return token, nil
+}
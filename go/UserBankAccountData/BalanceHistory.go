@@ -0,0 +1,256 @@
+/*
+------------------------------------------------------------------
+FILE NAME:     BalanceHistory.go
+PROJECT:       CashflowAnalysis
+Date Created:  Oct-19-2026
+--------------------------------------------------------------------
+DESCRIPTION:
+Balance history for trend charts. Every stored balance is also added to
+DB_BalanceSnapshots unless it matches the accounts latest snapshot, and a
+scheduled job refreshes each items balances from /accounts/balance/get.
+A balance series gives an accounts balance at the end of each day, week
+(starting Monday) or month of a date range, carrying the last snapshot
+forward through periods where the balance did not change.
+--------------------------------------------------------------------
+$HISTORY:
+
+Oct-19-2026   Created initial file.
+Oct-19-2026   Moved the series periods to seriesPeriods() for the portfolio value series
+------------------------------------------------------------------
+*/
+package userbankaccountdata
+
+import (
+	plaidServices "cashflowanalysis/PlaidComponents"
+	services "cashflowanalysis/Services/DBContext"
+	"database/sql"
+	"errors"
+	"log"
+	"time"
+)
+
+const (
+	ResolutionDaily   = "daily"
+	ResolutionWeekly  = "weekly"
+	ResolutionMonthly = "monthly"
+
+	//Largest number of points one series may have
+	maxSeriesPoints = 1000
+)
+
+var ErrInvalidHistoryRange = errors.New("invalid history range")
+
+// An accounts balance at the end of the period starting on Date (YYYY-MM-DD)
+type BalancePoint struct {
+	Date                   string   `json:"date"`
+	Available              *float64 `json:"available"`
+	Current                *float64 `json:"current"`
+	Limit                  *float64 `json:"limit"`
+	ISOCurrencyCode        *string  `json:"iso_currency_code"`
+	UnofficialCurrencyCode *string  `json:"unofficial_currency_code"`
+}
+
+type BalanceSeries struct {
+	AccountID  int    `json:"account_id"`
+	Resolution string `json:"resolution"`
+	From       string `json:"from"`
+	To         string `json:"to"`
+	//Periods before the accounts first snapshot are left out
+	Points []BalancePoint `json:"points"`
+}
+
+// Refreshes balances every interval. Meant to run in its own goroutine
+func RunBalanceRefreshWorker(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if err := RefreshAllBalances(interval); err != nil {
+			log.Println("balance refresh worker:", err)
+		}
+		<-ticker.C
+	}
+}
+
+// Refreshes the balances of every item not refreshed within maxAge, so a restart
+// does not call Plaid for every item again. Items that need the user to log in
+// again or were revoked are skipped, Plaid would only return the same error
+func RefreshAllBalances(maxAge time.Duration) error {
+	institutions, err := services.QueryObjectDB(&services.DB_LinkedInstitutions{},
+		"(BalancesRefreshedAt IS NULL OR BalancesRefreshedAt < @RefreshedBefore) AND (ItemStatus IS NULL OR ItemStatus NOT IN (@LoginRequired, @Revoked))",
+		sql.Named("RefreshedBefore", time.Now().UTC().Add(-maxAge)),
+		sql.Named("LoginRequired", ItemStatusLoginRequired),
+		sql.Named("Revoked", ItemStatusRevoked))
+	if err != nil {
+		return err
+	}
+	for _, ins := range institutions {
+		if err := RefreshInstitutionBalances(ins); err != nil {
+			log.Println("could not refresh balances of linked institution", ins.LinkedInstitutionID, ":", err)
+		}
+	}
+	return nil
+}
+
+// Loads the items current balances from Plaid and stores them, adding the
+// balances that changed to the balance history
+func RefreshInstitutionBalances(ins services.DB_LinkedInstitutions) error {
+	plaidAccounts, err := plaidServices.Balance(itemHandle(ins))
+	if err != nil {
+		RecordItemError(ins, err)
+		return err
+	}
+	ins = RecordItemSuccess(ins)
+
+	now := time.Now().UTC()
+	return services.RunInTransactionDB(func(tx *services.TxDB) error {
+		if _, _, err := refreshAccountRows(tx, ins.LinkedInstitutionID, plaidAccounts, now); err != nil {
+			return err
+		}
+		ins.BalancesRefreshedAt = sql.NullTime{Time: now, Valid: true}
+		return tx.UpdateObject(ins, []string{"BalancesRefreshedAt"}, []string{"LinkedInstitutionID"})
+	})
+}
+
+// Adds the balance to the accounts history unless it matches the latest snapshot
+func appendBalanceSnapshot(tx *services.TxDB, ab services.DB_AccountBalance, capturedAt time.Time) error {
+	snapshot := services.DB_BalanceSnapshots{
+		LinkedInstitutionID:    ab.LinkedInstitutionID,
+		AccountID:              ab.AccountID,
+		Available:              ab.Available,
+		CurrentAmount:          ab.CurrentAmount,
+		LimitAmount:            ab.LimitAmount,
+		ISOCurrencyCode:        ab.ISOCurrencyCode,
+		UnofficialCurrencyCode: ab.UnofficialCurrencyCode,
+		CapturedAt:             capturedAt,
+	}
+	latest, err := services.QueryObjectTx(tx, &services.DB_BalanceSnapshots{},
+		"AccountID = @AccountID ORDER BY CapturedAt DESC OFFSET 0 ROWS FETCH NEXT 1 ROWS ONLY",
+		sql.Named("AccountID", ab.AccountID))
+	if err != nil {
+		return err
+	}
+	if len(latest) > 0 && sameBalance(latest[0], snapshot) {
+		return nil
+	}
+	_, err = tx.CreateObject(snapshot)
+	return err
+}
+
+func sameBalance(a services.DB_BalanceSnapshots, b services.DB_BalanceSnapshots) bool {
+	return equalPtr(a.Available, b.Available) &&
+		equalPtr(a.CurrentAmount, b.CurrentAmount) &&
+		equalPtr(a.LimitAmount, b.LimitAmount) &&
+		equalPtr(a.ISOCurrencyCode, b.ISOCurrencyCode) &&
+		equalPtr(a.UnofficialCurrencyCode, b.UnofficialCurrencyCode)
+}
+
+func equalPtr[T comparable](a *T, b *T) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+// Returns the accounts balance at the end of each period from the period
+// containing from through the one containing to. The user must own the
+// account or have it shared with them
+func BalanceHistory(userID int, accountID int, from time.Time, to time.Time, resolution string) (BalanceSeries, error) {
+	series := BalanceSeries{AccountID: accountID, Resolution: resolution, Points: []BalancePoint{}}
+	periods, err := seriesPeriods(from, to, resolution)
+	if err != nil {
+		return series, err
+	}
+	start, end := periods[0], nextPeriod(periods[len(periods)-1], resolution)
+	series.From = start.Format(time.DateOnly)
+	series.To = end.AddDate(0, 0, -1).Format(time.DateOnly)
+
+	accessible, err := AccessibleAccountIDs(userID)
+	if err != nil {
+		return series, err
+	}
+	if !accessible[accountID] {
+		return series, ErrAccountNotFound
+	}
+
+	//The balance going into the range, then every change within it
+	before, err := services.QueryObjectDB(&services.DB_BalanceSnapshots{},
+		"AccountID = @AccountID AND CapturedAt < @Start ORDER BY CapturedAt DESC OFFSET 0 ROWS FETCH NEXT 1 ROWS ONLY",
+		sql.Named("AccountID", accountID),
+		sql.Named("Start", start))
+	if err != nil {
+		return series, err
+	}
+	within, err := services.QueryObjectDB(&services.DB_BalanceSnapshots{},
+		"AccountID = @AccountID AND CapturedAt >= @Start AND CapturedAt < @End ORDER BY CapturedAt",
+		sql.Named("AccountID", accountID),
+		sql.Named("Start", start),
+		sql.Named("End", end))
+	if err != nil {
+		return series, err
+	}
+	snapshots := append(before, within...)
+
+	next := 0
+	var current *services.DB_BalanceSnapshots
+	for _, p := range periods {
+		periodEnd := nextPeriod(p, resolution)
+		for next < len(snapshots) && snapshots[next].CapturedAt.Before(periodEnd) {
+			current = &snapshots[next]
+			next++
+		}
+		if current == nil {
+			continue
+		}
+		series.Points = append(series.Points, BalancePoint{
+			Date:                   p.Format(time.DateOnly),
+			Available:              current.Available,
+			Current:                current.CurrentAmount,
+			Limit:                  current.LimitAmount,
+			ISOCurrencyCode:        current.ISOCurrencyCode,
+			UnofficialCurrencyCode: current.UnofficialCurrencyCode,
+		})
+	}
+	return series, nil
+}
+
+// Returns the start of every period from the one containing from through the one containing to
+func seriesPeriods(from time.Time, to time.Time, resolution string) ([]time.Time, error) {
+	start := periodStart(from, resolution)
+	end := periodStart(to, resolution)
+	if start.IsZero() || end.Before(start) {
+		return nil, ErrInvalidHistoryRange
+	}
+	var periods []time.Time
+	for p := start; !p.After(end); p = nextPeriod(p, resolution) {
+		if len(periods) == maxSeriesPoints {
+			return nil, ErrInvalidHistoryRange
+		}
+		periods = append(periods, p)
+	}
+	return periods, nil
+}
+
+// Returns the start (UTC midnight) of the period containing t, zero for an unknown resolution
+func periodStart(t time.Time, resolution string) time.Time {
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	switch resolution {
+	case ResolutionDaily:
+		return day
+	case ResolutionWeekly:
+		//Weeks start on Monday
+		return day.AddDate(0, 0, -(int(day.Weekday())+6)%7)
+	case ResolutionMonthly:
+		return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+	}
+	return time.Time{}
+}
+
+func nextPeriod(start time.Time, resolution string) time.Time {
+	switch resolution {
+	case ResolutionWeekly:
+		return start.AddDate(0, 0, 7)
+	case ResolutionMonthly:
+		return start.AddDate(0, 1, 0)
+	}
+	return start.AddDate(0, 0, 1)
+}
@@ -0,0 +1,162 @@
+/*
+------------------------------------------------------------------
+FILE NAME:     EraseAccountData.go
+PROJECT:       CashflowAnalysis
+Date Created:  Oct-19-2026
+--------------------------------------------------------------------
+DESCRIPTION:
+Removes a users Plaid items and erases all of their bank and widget board
+data when their account is deleted
+--------------------------------------------------------------------
+$HISTORY:
+
+Oct-19-2026   Created initial file.
+Oct-19-2026   Also erases institution grants given or received by the user and other users
+-             widget links to the users shared accounts
+Oct-19-2026   Item removals are recorded as security events
+Oct-19-2026   RemoveUserItems() passes each institutions item handle to Plaid
+Oct-19-2026   Also erases synced transactions
+Oct-19-2026   Also erases balance history
+Oct-19-2026   Also erases holdings and investment transactions. Securities are shared market data and kept
+Oct-19-2026   Also erases credit card, student loan and mortgage details
+Oct-19-2026   Also erases recurring streams
+Oct-19-2026   EraseUserBankData() runs inside the callers transaction and erases each table
+-             with services.EraseRowsTx()
+Oct-19-2026   Failed item removals are logged with log.Println()
+------------------------------------------------------------------
+*/
+package userbankaccountdata
+
+import (
+	plaidServices "cashflowanalysis/PlaidComponents"
+	services "cashflowanalysis/Services/DBContext"
+	helper "cashflowanalysis/Services/Helpers"
+	"log"
+)
+
+// Calls Plaid /item/remove for every access token stored for the user.
+// Returns the number of items removed and the item ids that could not be removed
+func RemoveUserItems(userID int) (int, []string, error) {
+	institutions, err := services.LoadObjectDB(&services.DB_LinkedInstitutions{UserID: userID}, "UserID")
+	if err != nil {
+		return 0, nil, err
+	}
+	removed := 0
+	failed := []string{}
+	for _, ins := range institutions {
+		event := helper.SecurityEvent{UserID: userID, Type: helper.EventItemRemoval, Detail: "item " + ins.ItemID + " (account deletion)"}
+		if err := plaidServices.RemoveItem(itemHandle(ins)); err != nil {
+			log.Println("could not remove plaid item", ins.ItemID, err)
+			helper.RecordSecurityEvent(nil, event)
+			failed = append(failed, ins.ItemID)
+			continue
+		}
+		event.Success = true
+		helper.RecordSecurityEvent(nil, event)
+		removed++
+	}
+	return removed, failed, nil
+}
+
+// Deletes the users widget board tree, balances, accounts and institutions, children
+// before parents, inside tx. Adds the number of rows deleted per table to counts
+func EraseUserBankData(tx *services.TxDB, userID int, counts map[string]int) error {
+	boards, err := services.LoadObjectTx(tx, &services.DB_WidgetBoard{UserID: userID}, "UserID")
+	if err != nil {
+		return err
+	}
+	for _, board := range boards {
+		rows, err := services.LoadObjectTx(tx, &services.DB_WidgetBoardRows{WidgetBoardID: board.WidgetBoardID}, "WidgetBoardID")
+		if err != nil {
+			return err
+		}
+		for _, row := range rows {
+			widgets, err := services.LoadObjectTx(tx, &services.DB_Widgets{RowID: row.RowID}, "RowID")
+			if err != nil {
+				return err
+			}
+			for _, widget := range widgets {
+				if err := services.EraseRowsTx(tx, counts, "WidgetLinkedAccounts", &services.DB_WidgetLinkedAccounts{WidgetID: widget.WidgetID}, "WidgetID"); err != nil {
+					return err
+				}
+			}
+			if err := services.EraseRowsTx(tx, counts, "Widgets", &services.DB_Widgets{RowID: row.RowID}, "RowID"); err != nil {
+				return err
+			}
+		}
+		if err := services.EraseRowsTx(tx, counts, "WidgetBoardRows", &services.DB_WidgetBoardRows{WidgetBoardID: board.WidgetBoardID}, "WidgetBoardID"); err != nil {
+			return err
+		}
+		if err := services.EraseRowsTx(tx, counts, "WidgetBoard", &services.DB_WidgetBoard{WidgetBoardID: board.WidgetBoardID}, "WidgetBoardID"); err != nil {
+			return err
+		}
+	}
+
+	//Grants go before the institutions so shared users lose access before the data disappears
+	if err := services.EraseRowsTx(tx, counts, "InstitutionGrants", &services.DB_InstitutionGrants{OwnerUserId: userID}, "OwnerUserId"); err != nil {
+		return err
+	}
+	if err := services.EraseRowsTx(tx, counts, "InstitutionGrants", &services.DB_InstitutionGrants{GranteeUserId: userID}, "GranteeUserId"); err != nil {
+		return err
+	}
+
+	institutions, err := services.LoadObjectTx(tx, &services.DB_LinkedInstitutions{UserID: userID}, "UserID")
+	if err != nil {
+		return err
+	}
+	for _, ins := range institutions {
+		if err := eraseInstitutionData(tx, ins.LinkedInstitutionID, counts); err != nil {
+			return err
+		}
+
+		accounts, err := services.LoadObjectTx(tx, &services.DB_LinkedAccounts{LinkedInstitutionID: ins.LinkedInstitutionID}, "LinkedInstitutionID")
+		if err != nil {
+			return err
+		}
+		//Widgets of users the accounts were shared with may still link to them
+		for _, acc := range accounts {
+			if err := services.EraseRowsTx(tx, counts, "WidgetLinkedAccounts", &services.DB_WidgetLinkedAccounts{LinkedAccountID: acc.AccountID}, "LinkedAccountID"); err != nil {
+				return err
+			}
+		}
+		if err := services.EraseRowsTx(tx, counts, "LinkedAccounts", &services.DB_LinkedAccounts{LinkedInstitutionID: ins.LinkedInstitutionID}, "LinkedInstitutionID"); err != nil {
+			return err
+		}
+		if err := services.EraseRowsTx(tx, counts, "LinkedInstitutions", &services.DB_LinkedInstitutions{LinkedInstitutionID: ins.LinkedInstitutionID}, "LinkedInstitutionID"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Deletes the synced Plaid data stored for the institution, APRs before their credit cards
+func eraseInstitutionData(tx *services.TxDB, id int, counts map[string]int) error {
+	if err := services.EraseRowsTx(tx, counts, "Transactions", &services.DB_Transactions{LinkedInstitutionID: id}, "LinkedInstitutionID"); err != nil {
+		return err
+	}
+	if err := services.EraseRowsTx(tx, counts, "AccountBalance", &services.DB_AccountBalance{LinkedInstitutionID: id}, "LinkedInstitutionID"); err != nil {
+		return err
+	}
+	if err := services.EraseRowsTx(tx, counts, "RecurringStreams", &services.DB_RecurringStreams{LinkedInstitutionID: id}, "LinkedInstitutionID"); err != nil {
+		return err
+	}
+	if err := services.EraseRowsTx(tx, counts, "Holdings", &services.DB_Holdings{LinkedInstitutionID: id}, "LinkedInstitutionID"); err != nil {
+		return err
+	}
+	if err := services.EraseRowsTx(tx, counts, "InvestmentTransactions", &services.DB_InvestmentTransactions{LinkedInstitutionID: id}, "LinkedInstitutionID"); err != nil {
+		return err
+	}
+	if err := services.EraseRowsTx(tx, counts, "CreditCardAPRs", &services.DB_CreditCardAPRs{LinkedInstitutionID: id}, "LinkedInstitutionID"); err != nil {
+		return err
+	}
+	if err := services.EraseRowsTx(tx, counts, "CreditCardLiabilities", &services.DB_CreditCardLiabilities{LinkedInstitutionID: id}, "LinkedInstitutionID"); err != nil {
+		return err
+	}
+	if err := services.EraseRowsTx(tx, counts, "StudentLoans", &services.DB_StudentLoans{LinkedInstitutionID: id}, "LinkedInstitutionID"); err != nil {
+		return err
+	}
+	if err := services.EraseRowsTx(tx, counts, "Mortgages", &services.DB_Mortgages{LinkedInstitutionID: id}, "LinkedInstitutionID"); err != nil {
+		return err
+	}
+	return services.EraseRowsTx(tx, counts, "BalanceSnapshots", &services.DB_BalanceSnapshots{LinkedInstitutionID: id}, "LinkedInstitutionID")
+}
@@ -0,0 +1,142 @@
+/*
+------------------------------------------------------------------
+FILE NAME:     InstitutionAccess.go
+PROJECT:       CashflowAnalysis
+Date Created:  Oct-19-2026
+--------------------------------------------------------------------
+DESCRIPTION:
+Works out which linked institutions and accounts a user can see, either
+because they own them or because the owner shared them through an accepted
+grant. Grants are read on every call so a revoked grant takes effect on the
+next request.
+--------------------------------------------------------------------
+$HISTORY:
+
+Oct-19-2026   Created initial file.
+------------------------------------------------------------------
+*/
+package userbankaccountdata
+
+import (
+	services "cashflowanalysis/Services/DBContext"
+)
+
+const (
+	//Grantee can see accounts, balances and transactions and use them in their widgets
+	AccessView = "view"
+	//Grantee can also maintain the institution connection (i.e. re-link or refresh it)
+	AccessManage = "manage"
+	//The user linked the institution themselves
+	AccessOwner = "owner"
+)
+
+// An institution the user can access and which of its accounts they can see
+type InstitutionAccess struct {
+	Institution services.DB_LinkedInstitutions
+	AccessLevel string
+	OwnerUserId int
+	//nil when every account of the institution is accessible
+	AccountIDs map[int]bool
+}
+
+// Returns true when the account is accessible through this institution
+func (a InstitutionAccess) IncludesAccount(accountID int) bool {
+	return a.AccountIDs == nil || a.AccountIDs[accountID]
+}
+
+// Lists the institutions the user owns followed by the ones shared with them
+func AccessibleInstitutions(userID int) ([]InstitutionAccess, error) {
+	owned, err := services.LoadObjectDB(&services.DB_LinkedInstitutions{UserID: userID}, "UserID")
+	if err != nil {
+		return nil, err
+	}
+	var access []InstitutionAccess
+	for _, ins := range owned {
+		access = append(access, InstitutionAccess{
+			Institution: ins,
+			AccessLevel: AccessOwner,
+			OwnerUserId: userID,
+		})
+	}
+
+	grants, err := services.LoadObjectDB(&services.DB_InstitutionGrants{GranteeUserId: userID, Status: GrantAccepted}, "GranteeUserId", "Status")
+	if err != nil {
+		return nil, err
+	}
+	shared := map[int]*InstitutionAccess{}
+	var order []int
+	for _, grant := range grants {
+		entry, ok := shared[grant.LinkedInstitutionID]
+		if !ok {
+			institutions, err := services.LoadObjectDB(&services.DB_LinkedInstitutions{LinkedInstitutionID: grant.LinkedInstitutionID}, "LinkedInstitutionID")
+			if err != nil {
+				return nil, err
+			}
+			//Skip grants whose institution was unlinked or changed hands
+			if len(institutions) == 0 || institutions[0].UserID != grant.OwnerUserId {
+				continue
+			}
+			entry = &InstitutionAccess{
+				Institution: institutions[0],
+				AccessLevel: grant.AccessLevel,
+				OwnerUserId: grant.OwnerUserId,
+				AccountIDs:  map[int]bool{},
+			}
+			shared[grant.LinkedInstitutionID] = entry
+			order = append(order, grant.LinkedInstitutionID)
+		}
+		if grant.LinkedAccountID == 0 {
+			entry.AccountIDs = nil
+		} else if entry.AccountIDs != nil {
+			entry.AccountIDs[grant.LinkedAccountID] = true
+		}
+		if grant.AccessLevel == AccessManage {
+			entry.AccessLevel = AccessManage
+		}
+	}
+	for _, id := range order {
+		access = append(access, *shared[id])
+	}
+	return access, nil
+}
+
+// Returns the users access level for the institution, "" when they have none
+func InstitutionAccessLevel(userID int, linkedInstitutionID int) (string, error) {
+	access, err := AccessibleInstitutions(userID)
+	if err != nil {
+		return "", err
+	}
+	for _, a := range access {
+		if a.Institution.LinkedInstitutionID == linkedInstitutionID {
+			return a.AccessLevel, nil
+		}
+	}
+	return "", nil
+}
+
+// Returns true when the user owns the institution or was granted manage access to it
+func CanManageInstitution(userID int, linkedInstitutionID int) (bool, error) {
+	level, err := InstitutionAccessLevel(userID, linkedInstitutionID)
+	return level == AccessOwner || level == AccessManage, err
+}
+
+// Returns the ids of every account the user can see
+func AccessibleAccountIDs(userID int) (map[int]bool, error) {
+	access, err := AccessibleInstitutions(userID)
+	if err != nil {
+		return nil, err
+	}
+	ids := map[int]bool{}
+	for _, a := range access {
+		accounts, err := services.LoadObjectDB(&services.DB_LinkedAccounts{LinkedInstitutionID: a.Institution.LinkedInstitutionID}, "LinkedInstitutionID")
+		if err != nil {
+			return nil, err
+		}
+		for _, acc := range accounts {
+			if a.IncludesAccount(acc.AccountID) {
+				ids[acc.AccountID] = true
+			}
+		}
+	}
+	return ids, nil
+}
@@ -0,0 +1,299 @@
+/*
+------------------------------------------------------------------
+FILE NAME:     InstitutionGrants.go
+PROJECT:       CashflowAnalysis
+Date Created:  Oct-19-2026
+--------------------------------------------------------------------
+DESCRIPTION:
+Household sharing. The owner of a linked institution invites another user
+to view or manage the whole institution or specific accounts. The invitee
+accepts or declines, and either side can revoke the grant at any time.
+--------------------------------------------------------------------
+$HISTORY:
+
+Oct-19-2026   Created initial file.
+------------------------------------------------------------------
+*/
+package userbankaccountdata
+
+import (
+	services "cashflowanalysis/Services/DBContext"
+	mailer "cashflowanalysis/Services/Mailer"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+const (
+	GrantPending  = "pending"
+	GrantAccepted = "accepted"
+	GrantDeclined = "declined"
+	GrantRevoked  = "revoked"
+)
+
+var (
+	ErrInstitutionNotFound = errors.New("linked institution not found")
+	ErrAccountNotFound     = errors.New("account not found")
+	ErrInviteeNotFound     = errors.New("no user found with that username or email")
+	ErrInvalidAccessLevel  = errors.New("access level must be view or manage")
+	ErrCannotShareWithSelf = errors.New("you cannot share with yourself")
+	ErrGrantExists         = errors.New("that user already has or has been invited to this access")
+	ErrGrantNotFound       = errors.New("grant not found")
+)
+
+// Grant details returned to the owner and the grantee
+type GrantInfo struct {
+	GrantId             int        `json:"grant_id"`
+	OwnerUsername       string     `json:"owner_username"`
+	GranteeUsername     string     `json:"grantee_username"`
+	LinkedInstitutionID int        `json:"linked_institution_id"`
+	InstitutionName     string     `json:"institution_name"`
+	LinkedAccountID     int        `json:"linked_account_id"`
+	AccountName         string     `json:"account_name"`
+	AccessLevel         string     `json:"access_level"`
+	Status              string     `json:"status"`
+	InvitedAt           time.Time  `json:"invited_at"`
+	RespondedAt         *time.Time `json:"responded_at"`
+}
+
+// Invites another user (by username or email) to the owners institution. With no
+// accountIDs the whole institution is shared, otherwise one grant is made per account
+func InviteToInstitution(ownerID int, invitee string, linkedInstitutionID int, accountIDs []int, accessLevel string) ([]GrantInfo, error) {
+	if accessLevel != AccessView && accessLevel != AccessManage {
+		return nil, ErrInvalidAccessLevel
+	}
+	institutions, err := services.LoadObjectDB(&services.DB_LinkedInstitutions{LinkedInstitutionID: linkedInstitutionID, UserID: ownerID}, "LinkedInstitutionID", "UserID")
+	if err != nil {
+		return nil, err
+	}
+	if len(institutions) == 0 {
+		return nil, ErrInstitutionNotFound
+	}
+	institution := institutions[0]
+
+	accounts, err := services.LoadObjectDB(&services.DB_LinkedAccounts{LinkedInstitutionID: linkedInstitutionID}, "LinkedInstitutionID")
+	if err != nil {
+		return nil, err
+	}
+	accountNames := map[int]string{}
+	for _, acc := range accounts {
+		accountNames[acc.AccountID] = acc.Name
+	}
+	for _, id := range accountIDs {
+		if _, ok := accountNames[id]; !ok {
+			return nil, ErrAccountNotFound
+		}
+	}
+
+	grantee, err := findUser(invitee)
+	if err != nil {
+		return nil, err
+	}
+	if grantee.UserId == ownerID {
+		return nil, ErrCannotShareWithSelf
+	}
+
+	if len(accountIDs) == 0 {
+		accountIDs = []int{0}
+	}
+	existing, err := services.LoadObjectDB(&services.DB_InstitutionGrants{GranteeUserId: grantee.UserId, LinkedInstitutionID: linkedInstitutionID}, "GranteeUserId", "LinkedInstitutionID")
+	if err != nil {
+		return nil, err
+	}
+	for _, grant := range existing {
+		if grant.Status != GrantPending && grant.Status != GrantAccepted {
+			continue
+		}
+		for _, id := range accountIDs {
+			if grant.LinkedAccountID == id {
+				return nil, ErrGrantExists
+			}
+		}
+	}
+
+	owner, err := loadUser(ownerID)
+	if err != nil {
+		return nil, err
+	}
+	infos := []GrantInfo{}
+	for _, id := range accountIDs {
+		grant := services.DB_InstitutionGrants{
+			OwnerUserId:         ownerID,
+			GranteeUserId:       grantee.UserId,
+			LinkedInstitutionID: linkedInstitutionID,
+			LinkedAccountID:     id,
+			AccessLevel:         accessLevel,
+			Status:              GrantPending,
+			InvitedAt:           time.Now().UTC(),
+			RespondedAt:         sql.NullTime{Valid: false},
+			RevokedAt:           sql.NullTime{Valid: false},
+		}
+		grant.GrantId, err = services.CreateObjectDB(grant)
+		if err != nil {
+			return nil, err
+		}
+		infos = append(infos, grantInfo(grant, owner.Username, grantee.Username, institution.InstitutionName, accountNames[id]))
+	}
+
+	if grantee.Email.Valid {
+		err = mailer.Default().Send(mailer.Message{
+			To:      []string{grantee.Email.String},
+			Subject: fmt.Sprintf("%s shared %s with you on CashflowAnalysis", owner.Username, institution.InstitutionName),
+			Body: fmt.Sprintf("Hi %s,\n\n%s invited you to %s access to %s. Log in and open your sharing settings to accept or decline.\n",
+				grantee.Username, owner.Username, accessLevel, institution.InstitutionName),
+		})
+		if err != nil {
+			log.Println("could not send sharing invitation email:", err)
+		}
+	}
+	return infos, nil
+}
+
+// Lists the grants the user has given to others
+func ListGrantsGiven(ownerID int) ([]GrantInfo, error) {
+	grants, err := services.LoadObjectDB(&services.DB_InstitutionGrants{OwnerUserId: ownerID}, "OwnerUserId")
+	if err != nil {
+		return nil, err
+	}
+	return grantInfos(grants)
+}
+
+// Lists the grants and pending invitations the user has received
+func ListGrantsReceived(granteeID int) ([]GrantInfo, error) {
+	grants, err := services.LoadObjectDB(&services.DB_InstitutionGrants{GranteeUserId: granteeID}, "GranteeUserId")
+	if err != nil {
+		return nil, err
+	}
+	return grantInfos(grants)
+}
+
+// Accepts or declines a pending invitation sent to the user
+func RespondToGrant(granteeID int, grantID int, accept bool) error {
+	grants, err := services.LoadObjectDB(&services.DB_InstitutionGrants{GrantId: grantID, GranteeUserId: granteeID}, "GrantId", "GranteeUserId")
+	if err != nil {
+		return err
+	}
+	if len(grants) == 0 || grants[0].Status != GrantPending {
+		return ErrGrantNotFound
+	}
+	grant := grants[0]
+	grant.Status = GrantDeclined
+	if accept {
+		grant.Status = GrantAccepted
+	}
+	grant.RespondedAt = sql.NullTime{Time: time.Now().UTC(), Valid: true}
+	return services.UpdateObjectDB(grant, []string{"Status", "RespondedAt"}, []string{"GrantId"})
+}
+
+// Revokes a grant. Either the owner or the grantee may revoke it
+func RevokeGrant(userID int, grantID int) error {
+	grants, err := services.LoadObjectDB(&services.DB_InstitutionGrants{GrantId: grantID}, "GrantId")
+	if err != nil {
+		return err
+	}
+	if len(grants) == 0 || (grants[0].OwnerUserId != userID && grants[0].GranteeUserId != userID) {
+		return ErrGrantNotFound
+	}
+	grant := grants[0]
+	if grant.Status == GrantRevoked || grant.Status == GrantDeclined {
+		return nil
+	}
+	grant.Status = GrantRevoked
+	grant.RevokedAt = sql.NullTime{Time: time.Now().UTC(), Valid: true}
+	return services.UpdateObjectDB(grant, []string{"Status", "RevokedAt"}, []string{"GrantId"})
+}
+
+// Finds a user by username, then by email
+func findUser(identifier string) (services.DB_Users, error) {
+	identifier = strings.TrimSpace(identifier)
+	if identifier == "" {
+		return services.DB_Users{}, ErrInviteeNotFound
+	}
+	users, err := services.LoadObjectDB(&services.DB_Users{Username: identifier}, "Username")
+	if err != nil {
+		return services.DB_Users{}, err
+	}
+	if len(users) == 0 {
+		users, err = services.LoadObjectDB(&services.DB_Users{Email: sql.NullString{String: identifier, Valid: true}}, "Email")
+		if err != nil {
+			return services.DB_Users{}, err
+		}
+	}
+	if len(users) == 0 || users[0].DisabledAt.Valid {
+		return services.DB_Users{}, ErrInviteeNotFound
+	}
+	return users[0], nil
+}
+
+func loadUser(userID int) (services.DB_Users, error) {
+	users, err := services.LoadObjectDB(&services.DB_Users{UserId: userID}, "UserId")
+	if err != nil {
+		return services.DB_Users{}, err
+	}
+	if len(users) == 0 {
+		return services.DB_Users{}, ErrInviteeNotFound
+	}
+	return users[0], nil
+}
+
+// Adds usernames and institution/account names to the grants
+func grantInfos(grants []services.DB_InstitutionGrants) ([]GrantInfo, error) {
+	usernames := map[int]string{}
+	username := func(userID int) string {
+		if name, ok := usernames[userID]; ok {
+			return name
+		}
+		user, err := loadUser(userID)
+		if err == nil {
+			usernames[userID] = user.Username
+		}
+		return usernames[userID]
+	}
+
+	infos := []GrantInfo{}
+	for _, grant := range grants {
+		institutionName := ""
+		institutions, err := services.LoadObjectDB(&services.DB_LinkedInstitutions{LinkedInstitutionID: grant.LinkedInstitutionID}, "LinkedInstitutionID")
+		if err != nil {
+			return nil, err
+		}
+		if len(institutions) > 0 {
+			institutionName = institutions[0].InstitutionName
+		}
+		accountName := ""
+		if grant.LinkedAccountID != 0 {
+			accounts, err := services.LoadObjectDB(&services.DB_LinkedAccounts{AccountID: grant.LinkedAccountID}, "AccountID")
+			if err != nil {
+				return nil, err
+			}
+			if len(accounts) > 0 {
+				accountName = accounts[0].Name
+			}
+		}
+		infos = append(infos, grantInfo(grant, username(grant.OwnerUserId), username(grant.GranteeUserId), institutionName, accountName))
+	}
+	return infos, nil
+}
+
+func grantInfo(grant services.DB_InstitutionGrants, owner string, grantee string, institutionName string, accountName string) GrantInfo {
+	info := GrantInfo{
+		GrantId:             grant.GrantId,
+		OwnerUsername:       owner,
+		GranteeUsername:     grantee,
+		LinkedInstitutionID: grant.LinkedInstitutionID,
+		InstitutionName:     institutionName,
+		LinkedAccountID:     grant.LinkedAccountID,
+		AccountName:         accountName,
+		AccessLevel:         grant.AccessLevel,
+		Status:              grant.Status,
+		InvitedAt:           grant.InvitedAt,
+	}
+	if grant.RespondedAt.Valid {
+		t := grant.RespondedAt.Time
+		info.RespondedAt = &t
+	}
+	return info
+}
@@ -0,0 +1,92 @@
+/*
+------------------------------------------------------------------
+FILE NAME:     InstitutionHealth.go
+PROJECT:       CashflowAnalysis
+Date Created:  Oct-19-2026
+--------------------------------------------------------------------
+DESCRIPTION:
+Summaries of a users linked institutions and the health of their Plaid
+items for support staff. Access tokens are never included.
+--------------------------------------------------------------------
+$HISTORY:
+
+Oct-19-2026   Created initial file.
+Oct-19-2026   Item health is read with the institutions item handle
+Oct-19-2026   The health check updates the stored item status, which is returned with the last
+-             successful sync and consent expiry
+------------------------------------------------------------------
+*/
+package userbankaccountdata
+
+import (
+	plaidServices "cashflowanalysis/PlaidComponents"
+	services "cashflowanalysis/Services/DBContext"
+	"time"
+)
+
+type InstitutionHealth struct {
+	LinkedInstitutionID int       `json:"linked_institution_id"`
+	InstitutionName     string    `json:"institution_name"`
+	InstitutionID       string    `json:"institution_id"`
+	ItemID              string    `json:"item_id"`
+	AccountCount        int       `json:"account_count"`
+	Status              string    `json:"status"`
+	ErrorCode           string    `json:"error_code"`
+	CreatedAt           time.Time `json:"created_at"`
+	UpdatedAt           time.Time `json:"updated_at"`
+	//Stored item status, see ItemStatus.go
+	ItemStatus           string     `json:"item_status"`
+	LastErrorCode        *string    `json:"last_error_code"`
+	LastSuccessfulSyncAt *time.Time `json:"last_successful_sync_at"`
+	ConsentExpiresAt     *time.Time `json:"consent_expires_at"`
+	NewAccountsAvailable bool       `json:"new_accounts_available"`
+}
+
+// Lists the users linked institutions and asks Plaid for the current state of each item
+func RetrieveInstitutionHealth(userID int) ([]InstitutionHealth, error) {
+	institutions, err := services.LoadObjectDB(&services.DB_LinkedInstitutions{UserID: userID}, "UserID")
+	if err != nil {
+		return nil, err
+	}
+
+	health := []InstitutionHealth{}
+	for _, ins := range institutions {
+		accounts, err := services.LoadObjectDB(&services.DB_LinkedAccounts{LinkedInstitutionID: ins.LinkedInstitutionID}, "LinkedInstitutionID")
+		if err != nil {
+			return nil, err
+		}
+
+		h := InstitutionHealth{
+			LinkedInstitutionID: ins.LinkedInstitutionID,
+			InstitutionName:     ins.InstitutionName,
+			InstitutionID:       ins.InstitutionID,
+			ItemID:              ins.ItemID,
+			AccountCount:        len(accounts),
+			Status:              "healthy",
+			CreatedAt:           ins.CreatedAt,
+			UpdatedAt:           ins.UpdatedAt,
+		}
+		errorCode, err := plaidServices.ItemHealth(itemHandle(ins))
+		switch {
+		case err != nil:
+			h.Status = "unknown"
+		case errorCode != "":
+			h.Status = "error"
+			h.ErrorCode = errorCode
+			ins = recordItemErrorCode(ins, "", errorCode)
+		default:
+			ins = RecordItemSuccess(ins)
+		}
+		h.ItemStatus = ItemStatus(ins)
+		h.LastErrorCode = ins.LastErrorCode
+		h.NewAccountsAvailable = ins.NewAccountsAvailable
+		if ins.TransactionsSyncedAt.Valid {
+			h.LastSuccessfulSyncAt = &ins.TransactionsSyncedAt.Time
+		}
+		if ins.ConsentExpiresAt.Valid {
+			h.ConsentExpiresAt = &ins.ConsentExpiresAt.Time
+		}
+		health = append(health, h)
+	}
+	return health, nil
+}
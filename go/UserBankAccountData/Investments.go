@@ -0,0 +1,476 @@
+/*
+------------------------------------------------------------------
+FILE NAME:     Investments.go
+PROJECT:       CashflowAnalysis
+Date Created:  Oct-19-2026
+--------------------------------------------------------------------
+DESCRIPTION:
+Investment holdings, securities and investment transactions of items
+linked with the investments product. A sync stores the days holdings
+snapshot, replacing an earlier one of the same day, and the investment
+transactions since the last sync. Syncs run on a schedule and when Plaid
+sends a holdings or investments transactions webhook. Holdings and the
+portfolio value over time are read for the accounts linked to a widget.
+--------------------------------------------------------------------
+$HISTORY:
+
+Oct-19-2026   Created initial file.
+Oct-19-2026   SyncAllInvestments() finds the items to sync with institutionsDue()
+------------------------------------------------------------------
+*/
+package userbankaccountdata
+
+import (
+	plaidServices "cashflowanalysis/PlaidComponents"
+	services "cashflowanalysis/Services/DBContext"
+	"database/sql"
+	"errors"
+	"log"
+	"time"
+
+	plaid "github.com/plaid/plaid-go/v31/plaid"
+)
+
+const (
+	//Plaid returns up to 24 months of investment transactions
+	investmentHistoryMonths = 24
+	//Investment transactions can post days late, each sync looks back this far from the last one
+	investmentSyncOverlap = 30 * 24 * time.Hour
+)
+
+var ErrWidgetNotFound = errors.New("widget not found")
+
+// Counts of what an investments sync stored
+type InvestmentSyncResult struct {
+	LinkedInstitutionID int `json:"linked_institution_id"`
+	Holdings            int `json:"holdings"`
+	TransactionsAdded   int `json:"transactions_added"`
+	TransactionsUpdated int `json:"transactions_updated"`
+}
+
+// A holding of an account linked to the widget, with its security
+type InvestmentHolding struct {
+	AccountID              int      `json:"account_id"`
+	AccountName            string   `json:"account_name"`
+	SecurityID             int      `json:"security_id"`
+	SecurityName           *string  `json:"security_name"`
+	TickerSymbol           *string  `json:"ticker_symbol"`
+	SecurityType           *string  `json:"security_type"`
+	Quantity               float64  `json:"quantity"`
+	CostBasis              *float64 `json:"cost_basis"`
+	InstitutionPrice       float64  `json:"institution_price"`
+	InstitutionValue       float64  `json:"institution_value"`
+	ISOCurrencyCode        *string  `json:"iso_currency_code"`
+	UnofficialCurrencyCode *string  `json:"unofficial_currency_code"`
+	//Date (YYYY-MM-DD) of the snapshot the holding is from
+	AsOf string `json:"as_of"`
+}
+
+// The latest holdings of a widgets accounts. Totals are by currency code
+type WidgetHoldings struct {
+	WidgetID       int                 `json:"widget_id"`
+	Holdings       []InvestmentHolding `json:"holdings"`
+	TotalValue     map[string]float64  `json:"total_value"`
+	TotalCostBasis map[string]float64  `json:"total_cost_basis"`
+}
+
+// The value of a widgets holdings at the end of the period starting on Date, by currency code
+type PortfolioPoint struct {
+	Date  string             `json:"date"`
+	Value map[string]float64 `json:"value"`
+}
+
+type PortfolioSeries struct {
+	WidgetID   int    `json:"widget_id"`
+	Resolution string `json:"resolution"`
+	From       string `json:"from"`
+	To         string `json:"to"`
+	//Periods before the first holdings snapshot are left out
+	Points []PortfolioPoint `json:"points"`
+}
+
+// Syncs investments every interval. Meant to run in its own goroutine
+func RunInvestmentSyncWorker(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if err := SyncAllInvestments(interval); err != nil {
+			log.Println("investment sync worker:", err)
+		}
+		<-ticker.C
+	}
+}
+
+// Syncs the investments of every item with the investments product not synced within maxAge
+func SyncAllInvestments(maxAge time.Duration) error {
+	institutions, err := institutionsDue(plaid.PRODUCTS_INVESTMENTS, "InvestmentsSyncedAt", maxAge)
+	if err != nil {
+		return err
+	}
+	for _, ins := range institutions {
+		if _, err := SyncInstitutionInvestments(ins); err != nil {
+			log.Println("could not sync investments of linked institution", ins.LinkedInstitutionID, ":", err)
+		}
+	}
+	return nil
+}
+
+// Stores the items current holdings as the days snapshot and the investment
+// transactions since the last sync, or of the last 24 months on the first sync
+func SyncInstitutionInvestments(ins services.DB_LinkedInstitutions) (InvestmentSyncResult, error) {
+	result := InvestmentSyncResult{LinkedInstitutionID: ins.LinkedInstitutionID}
+	item := itemHandle(ins)
+	now := time.Now().UTC()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+
+	holdings, err := plaidServices.InvestmentHoldings(item)
+	if err != nil {
+		RecordItemError(ins, err)
+		return result, err
+	}
+	start := today.AddDate(0, -investmentHistoryMonths, 0)
+	if ins.InvestmentsSyncedAt.Valid && ins.InvestmentsSyncedAt.Time.Add(-investmentSyncOverlap).After(start) {
+		start = ins.InvestmentsSyncedAt.Time.Add(-investmentSyncOverlap)
+	}
+	transactions, txSecurities, err := plaidServices.InvestmentTransactions(item, start.Format(time.DateOnly), today.Format(time.DateOnly))
+	if err != nil {
+		RecordItemError(ins, err)
+		return result, err
+	}
+	ins = RecordItemSuccess(ins)
+
+	err = services.RunInTransactionDB(func(tx *services.TxDB) error {
+		if _, _, err := refreshAccountRows(tx, ins.LinkedInstitutionID, holdings.GetAccounts(), now); err != nil {
+			return err
+		}
+		accountIDs, err := plaidAccountIDs(tx, ins.LinkedInstitutionID, holdings.GetAccounts())
+		if err != nil {
+			return err
+		}
+		securityIDs, err := upsertSecurities(tx, append(holdings.GetSecurities(), txSecurities...), now)
+		if err != nil {
+			return err
+		}
+
+		if err := tx.DeleteObject(services.DB_Holdings{LinkedInstitutionID: ins.LinkedInstitutionID, SnapshotDate: today}, "LinkedInstitutionID", "SnapshotDate"); err != nil {
+			return err
+		}
+		for _, h := range holdings.GetHoldings() {
+			accountID, knownAccount := accountIDs[h.AccountId]
+			securityID, knownSecurity := securityIDs[h.SecurityId]
+			if !knownAccount || !knownSecurity {
+				continue
+			}
+			if _, err := tx.CreateObject(holdingRow(ins.LinkedInstitutionID, accountID, securityID, h, today, now)); err != nil {
+				return err
+			}
+			result.Holdings++
+		}
+
+		for _, t := range transactions {
+			accountID, known := accountIDs[t.AccountId]
+			if !known {
+				continue
+			}
+			row := investmentTransactionRow(ins.LinkedInstitutionID, accountID, t, securityIDs, now)
+			added, err := upsertInvestmentTransaction(tx, row)
+			if err != nil {
+				return err
+			}
+			if added {
+				result.TransactionsAdded++
+			} else {
+				result.TransactionsUpdated++
+			}
+		}
+
+		ins.InvestmentsSyncedAt = sql.NullTime{Time: now, Valid: true}
+		return tx.UpdateObject(ins, []string{"InvestmentsSyncedAt"}, []string{"LinkedInstitutionID"})
+	})
+	return result, err
+}
+
+// Inserts new securities and refreshes the close price of known ones. Returns
+// the row ids by Plaid security id
+func upsertSecurities(tx *services.TxDB, securities []plaid.Security, now time.Time) (map[string]int, error) {
+	ids := map[string]int{}
+	for _, sec := range securities {
+		if _, seen := ids[sec.SecurityId]; seen {
+			continue
+		}
+		row := services.DB_Securities{
+			PlaidSecurityID:        sec.SecurityId,
+			Name:                   sec.Name.Get(),
+			TickerSymbol:           sec.TickerSymbol.Get(),
+			Type:                   sec.Type.Get(),
+			ISIN:                   sec.Isin.Get(),
+			CUSIP:                  sec.Cusip.Get(),
+			IsCashEquivalent:       sec.GetIsCashEquivalent(),
+			ClosePrice:             sec.ClosePrice.Get(),
+			ClosePriceAsOf:         sec.ClosePriceAsOf.Get(),
+			ISOCurrencyCode:        sec.IsoCurrencyCode.Get(),
+			UnofficialCurrencyCode: sec.UnofficialCurrencyCode.Get(),
+			UpdatedAt:              now,
+		}
+		existing, err := services.LoadObjectTx(tx, &services.DB_Securities{PlaidSecurityID: sec.SecurityId}, "PlaidSecurityID")
+		if err != nil {
+			return nil, err
+		}
+		if len(existing) == 0 {
+			if row.SecurityID, err = tx.CreateObject(row); err != nil {
+				return nil, err
+			}
+		} else {
+			row.SecurityID = existing[0].SecurityID
+			if err := tx.UpdateObject(row, []string{}, []string{"SecurityID"}); err != nil {
+				return nil, err
+			}
+		}
+		ids[sec.SecurityId] = row.SecurityID
+	}
+	return ids, nil
+}
+
+func holdingRow(linkedInstitutionID int, accountID int, securityID int, h plaid.Holding, snapshotDate time.Time, now time.Time) services.DB_Holdings {
+	return services.DB_Holdings{
+		LinkedInstitutionID:    linkedInstitutionID,
+		AccountID:              accountID,
+		SecurityID:             securityID,
+		SnapshotDate:           snapshotDate,
+		Quantity:               h.Quantity,
+		CostBasis:              h.CostBasis.Get(),
+		InstitutionPrice:       h.InstitutionPrice,
+		InstitutionPriceAsOf:   h.InstitutionPriceAsOf.Get(),
+		InstitutionValue:       h.InstitutionValue,
+		ISOCurrencyCode:        h.IsoCurrencyCode.Get(),
+		UnofficialCurrencyCode: h.UnofficialCurrencyCode.Get(),
+		CreatedAt:              now,
+	}
+}
+
+func investmentTransactionRow(linkedInstitutionID int, accountID int, t plaid.InvestmentTransaction, securityIDs map[string]int, now time.Time) services.DB_InvestmentTransactions {
+	row := services.DB_InvestmentTransactions{
+		LinkedInstitutionID:          linkedInstitutionID,
+		AccountID:                    accountID,
+		PlaidInvestmentTransactionID: t.InvestmentTransactionId,
+		Name:                         t.Name,
+		Quantity:                     t.Quantity,
+		Amount:                       t.Amount,
+		Price:                        t.Price,
+		Fees:                         t.Fees.Get(),
+		Type:                         string(t.Type),
+		Subtype:                      string(t.Subtype),
+		ISOCurrencyCode:              t.IsoCurrencyCode.Get(),
+		UnofficialCurrencyCode:       t.UnofficialCurrencyCode.Get(),
+		CreatedAt:                    now,
+		UpdatedAt:                    now,
+	}
+	row.Date, _ = time.Parse(time.DateOnly, t.Date)
+	if plaidSecurityID := t.SecurityId.Get(); plaidSecurityID != nil {
+		if securityID, ok := securityIDs[*plaidSecurityID]; ok {
+			row.SecurityID = &securityID
+		}
+	}
+	return row
+}
+
+// Inserts the investment transaction or updates the row with the same Plaid id. Returns true when inserted
+func upsertInvestmentTransaction(tx *services.TxDB, row services.DB_InvestmentTransactions) (bool, error) {
+	existing, err := services.LoadObjectTx(tx, &services.DB_InvestmentTransactions{PlaidInvestmentTransactionID: row.PlaidInvestmentTransactionID}, "PlaidInvestmentTransactionID")
+	if err != nil {
+		return false, err
+	}
+	if len(existing) == 0 {
+		_, err = tx.CreateObject(row)
+		return err == nil, err
+	}
+	row.InvestmentTransactionID = existing[0].InvestmentTransactionID
+	row.CreatedAt = existing[0].CreatedAt
+	return false, tx.UpdateObject(row, []string{}, []string{"InvestmentTransactionID"})
+}
+
+// Returns the latest holdings of the accounts linked to the users widget
+func RetrieveWidgetHoldings(userID int, widgetID int) (WidgetHoldings, error) {
+	result := WidgetHoldings{WidgetID: widgetID, Holdings: []InvestmentHolding{}, TotalValue: map[string]float64{}, TotalCostBasis: map[string]float64{}}
+	accounts, err := widgetAccounts(userID, widgetID)
+	if err != nil {
+		return result, err
+	}
+
+	securities := map[int]services.DB_Securities{}
+	for _, acc := range accounts {
+		latest, err := services.QueryObjectDB(&services.DB_Holdings{},
+			"AccountID = @AccountID ORDER BY SnapshotDate DESC OFFSET 0 ROWS FETCH NEXT 1 ROWS ONLY",
+			sql.Named("AccountID", acc.AccountID))
+		if err != nil {
+			return result, err
+		}
+		if len(latest) == 0 {
+			continue
+		}
+		holdings, err := services.LoadObjectDB(&services.DB_Holdings{AccountID: acc.AccountID, SnapshotDate: latest[0].SnapshotDate}, "AccountID", "SnapshotDate")
+		if err != nil {
+			return result, err
+		}
+		for _, h := range holdings {
+			sec, ok := securities[h.SecurityID]
+			if !ok {
+				rows, err := services.LoadObjectDB(&services.DB_Securities{SecurityID: h.SecurityID}, "SecurityID")
+				if err != nil {
+					return result, err
+				}
+				if len(rows) > 0 {
+					sec = rows[0]
+				}
+				securities[h.SecurityID] = sec
+			}
+			result.Holdings = append(result.Holdings, InvestmentHolding{
+				AccountID:              acc.AccountID,
+				AccountName:            acc.Name,
+				SecurityID:             h.SecurityID,
+				SecurityName:           sec.Name,
+				TickerSymbol:           sec.TickerSymbol,
+				SecurityType:           sec.Type,
+				Quantity:               h.Quantity,
+				CostBasis:              h.CostBasis,
+				InstitutionPrice:       h.InstitutionPrice,
+				InstitutionValue:       h.InstitutionValue,
+				ISOCurrencyCode:        h.ISOCurrencyCode,
+				UnofficialCurrencyCode: h.UnofficialCurrencyCode,
+				AsOf:                   h.SnapshotDate.Format(time.DateOnly),
+			})
+			currency := currencyCode(h.ISOCurrencyCode, h.UnofficialCurrencyCode)
+			result.TotalValue[currency] += h.InstitutionValue
+			if h.CostBasis != nil {
+				result.TotalCostBasis[currency] += *h.CostBasis
+			}
+		}
+	}
+	return result, nil
+}
+
+// Returns the value of the holdings of the accounts linked to the users widget
+// at the end of each period. An account counts with its latest snapshot in or
+// before the period
+func RetrievePortfolioValue(userID int, widgetID int, from time.Time, to time.Time, resolution string) (PortfolioSeries, error) {
+	series := PortfolioSeries{WidgetID: widgetID, Resolution: resolution, Points: []PortfolioPoint{}}
+	periods, err := seriesPeriods(from, to, resolution)
+	if err != nil {
+		return series, err
+	}
+	start, end := periods[0], nextPeriod(periods[len(periods)-1], resolution)
+	series.From = start.Format(time.DateOnly)
+	series.To = end.AddDate(0, 0, -1).Format(time.DateOnly)
+
+	accounts, err := widgetAccounts(userID, widgetID)
+	if err != nil {
+		return series, err
+	}
+
+	//Each accounts value by snapshot date, in date order
+	type snapshotValue struct {
+		date  time.Time
+		value map[string]float64
+	}
+	values := make([][]snapshotValue, len(accounts))
+	for i, acc := range accounts {
+		//Start from the snapshot going into the range
+		first := start
+		before, err := services.QueryObjectDB(&services.DB_Holdings{},
+			"AccountID = @AccountID AND SnapshotDate < @Start ORDER BY SnapshotDate DESC OFFSET 0 ROWS FETCH NEXT 1 ROWS ONLY",
+			sql.Named("AccountID", acc.AccountID),
+			sql.Named("Start", start))
+		if err != nil {
+			return series, err
+		}
+		if len(before) > 0 {
+			first = before[0].SnapshotDate
+		}
+		holdings, err := services.QueryObjectDB(&services.DB_Holdings{},
+			"AccountID = @AccountID AND SnapshotDate >= @First AND SnapshotDate < @End ORDER BY SnapshotDate",
+			sql.Named("AccountID", acc.AccountID),
+			sql.Named("First", first),
+			sql.Named("End", end))
+		if err != nil {
+			return series, err
+		}
+		for _, h := range holdings {
+			if n := len(values[i]); n == 0 || !values[i][n-1].date.Equal(h.SnapshotDate) {
+				values[i] = append(values[i], snapshotValue{date: h.SnapshotDate, value: map[string]float64{}})
+			}
+			values[i][len(values[i])-1].value[currencyCode(h.ISOCurrencyCode, h.UnofficialCurrencyCode)] += h.InstitutionValue
+		}
+	}
+
+	next := make([]int, len(accounts))
+	for _, p := range periods {
+		periodEnd := nextPeriod(p, resolution)
+		point := PortfolioPoint{Date: p.Format(time.DateOnly), Value: map[string]float64{}}
+		found := false
+		for i := range accounts {
+			for next[i] < len(values[i]) && values[i][next[i]].date.Before(periodEnd) {
+				next[i]++
+			}
+			if next[i] == 0 {
+				continue
+			}
+			found = true
+			for currency, value := range values[i][next[i]-1].value {
+				point.Value[currency] += value
+			}
+		}
+		if found {
+			series.Points = append(series.Points, point)
+		}
+	}
+	return series, nil
+}
+
+// Returns the accounts linked to the widget that the user can still access.
+// The widget must be on the users own widget board
+func widgetAccounts(userID int, widgetID int) ([]services.DB_LinkedAccounts, error) {
+	var ownerID int
+	err := services.RunInTransactionDB(func(tx *services.TxDB) error {
+		var err error
+		_, ownerID, err = loadWidgetOwner(tx, widgetID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if ownerID != userID {
+		return nil, ErrWidgetNotFound
+	}
+
+	accessible, err := AccessibleAccountIDs(userID)
+	if err != nil {
+		return nil, err
+	}
+	links, err := services.LoadObjectDB(&services.DB_WidgetLinkedAccounts{WidgetID: widgetID}, "WidgetID")
+	if err != nil {
+		return nil, err
+	}
+	var accounts []services.DB_LinkedAccounts
+	for _, link := range links {
+		if !accessible[link.LinkedAccountID] {
+			continue
+		}
+		rows, err := services.LoadObjectDB(&services.DB_LinkedAccounts{AccountID: link.LinkedAccountID}, "AccountID")
+		if err != nil {
+			return nil, err
+		}
+		accounts = append(accounts, rows...)
+	}
+	return accounts, nil
+}
+
+// The ISO currency code, or the unofficial one for currencies without one (i.e. crypto)
+func currencyCode(iso *string, unofficial *string) string {
+	if iso != nil {
+		return *iso
+	}
+	if unofficial != nil {
+		return *unofficial
+	}
+	return ""
+}
@@ -0,0 +1,227 @@
+/*
+------------------------------------------------------------------
+FILE NAME:     ItemStatus.go
+PROJECT:       CashflowAnalysis
+Date Created:  Oct-19-2026
+--------------------------------------------------------------------
+DESCRIPTION:
+Tracks the health of each linked institutions Plaid item. Errors from Plaid
+calls and item webhooks move the item to login_required, pending_expiration,
+revoked or error, a successful call moves a broken item back to healthy.
+Broken items are repaired with Link in update mode, after which the items
+accounts are refreshed in place so the institution is not linked twice.
+--------------------------------------------------------------------
+$HISTORY:
+
+Oct-19-2026   Created initial file.
+Oct-19-2026   CompleteItemUpdate() stores the accounts with refreshAccountRows()
+------------------------------------------------------------------
+*/
+package userbankaccountdata
+
+import (
+	plaidServices "cashflowanalysis/PlaidComponents"
+	services "cashflowanalysis/Services/DBContext"
+	"database/sql"
+	"errors"
+	"log"
+	"strconv"
+	"time"
+
+	plaid "github.com/plaid/plaid-go/v31/plaid"
+)
+
+const (
+	ItemStatusHealthy           = "healthy"
+	ItemStatusLoginRequired     = "login_required"
+	ItemStatusPendingExpiration = "pending_expiration"
+	ItemStatusRevoked           = "revoked"
+	ItemStatusError             = "error"
+)
+
+// Plaid error codes that need the user to log in to their bank again
+var loginRequiredCodes = map[string]bool{
+	"ITEM_LOGIN_REQUIRED":     true,
+	"INVALID_CREDENTIALS":     true,
+	"INVALID_MFA":             true,
+	"ITEM_LOCKED":             true,
+	"USER_SETUP_REQUIRED":     true,
+	"PASSWORD_RESET_REQUIRED": true,
+}
+
+// Plaid error codes meaning the item can no longer be used at all
+var revokedCodes = map[string]bool{
+	"USER_PERMISSION_REVOKED": true,
+	"ACCESS_NOT_GRANTED":      true,
+	"ITEM_NOT_FOUND":          true,
+}
+
+// Accounts refreshed after Link update mode and the transaction sync that followed
+type ItemUpdateResult struct {
+	LinkedInstitutionID int        `json:"linked_institution_id"`
+	AccountsAdded       int        `json:"accounts_added"`
+	AccountsUpdated     int        `json:"accounts_updated"`
+	Sync                SyncResult `json:"sync"`
+}
+
+// Returns the institutions item status, items stored before status tracking count as healthy
+func ItemStatus(ins services.DB_LinkedInstitutions) string {
+	if !ins.ItemStatus.Valid || ins.ItemStatus.String == "" {
+		return ItemStatusHealthy
+	}
+	return ins.ItemStatus.String
+}
+
+// The column value for an item status
+func itemStatusValue(status string) sql.NullString {
+	return sql.NullString{String: status, Valid: true}
+}
+
+// Records the Plaid error of a failed call on the institution and returns the
+// updated row. Errors that say nothing about the item (rate limits, outages)
+// only update LastErrorCode
+func RecordItemError(ins services.DB_LinkedInstitutions, err error) services.DB_LinkedInstitutions {
+	plaidErr, convErr := plaid.ToPlaidError(err)
+	if convErr != nil {
+		return ins
+	}
+	return recordItemErrorCode(ins, string(plaidErr.ErrorType), plaidErr.ErrorCode)
+}
+
+// Moves a broken item back to healthy after a successful Plaid call and returns
+// the updated row. A pending expiration stays until the user renews consent in update mode
+func RecordItemSuccess(ins services.DB_LinkedInstitutions) services.DB_LinkedInstitutions {
+	if status := ItemStatus(ins); status == ItemStatusHealthy || status == ItemStatusPendingExpiration {
+		return ins
+	}
+	ins.ItemStatus = itemStatusValue(ItemStatusHealthy)
+	ins.LastErrorCode = nil
+	return saveItemStatus(ins, "ItemStatus", "LastErrorCode", "ItemStatusUpdatedAt")
+}
+
+// Updates the item status from an ITEM webhook
+func applyItemWebhook(ins services.DB_LinkedInstitutions, webhook plaidServices.Webhook) {
+	errorType, errorCode := "", ""
+	if webhook.Error != nil {
+		errorType, errorCode = webhook.Error.ErrorType, webhook.Error.ErrorCode
+	}
+	switch webhook.WebhookCode {
+	case plaidServices.WebhookItemError:
+		recordItemErrorCode(ins, errorType, errorCode)
+	case plaidServices.WebhookPendingExpiration:
+		ins.ItemStatus = itemStatusValue(ItemStatusPendingExpiration)
+		if webhook.ConsentExpirationTime != nil {
+			if expires, err := time.Parse(time.RFC3339, *webhook.ConsentExpirationTime); err == nil {
+				ins.ConsentExpiresAt = sql.NullTime{Time: expires.UTC(), Valid: true}
+			}
+		}
+		saveItemStatus(ins, "ItemStatus", "ConsentExpiresAt", "ItemStatusUpdatedAt")
+	case plaidServices.WebhookUserPermissionRevoked:
+		ins.ItemStatus = itemStatusValue(ItemStatusRevoked)
+		if errorCode == "" {
+			errorCode = "USER_PERMISSION_REVOKED"
+		}
+		ins.LastErrorCode = &errorCode
+		saveItemStatus(ins, "ItemStatus", "LastErrorCode", "ItemStatusUpdatedAt")
+	case plaidServices.WebhookLoginRepaired:
+		ins.ItemStatus = itemStatusValue(ItemStatusHealthy)
+		ins.LastErrorCode = nil
+		saveItemStatus(ins, "ItemStatus", "LastErrorCode", "ItemStatusUpdatedAt")
+	case plaidServices.WebhookNewAccountsAvailable:
+		ins.NewAccountsAvailable = true
+		saveItemStatus(ins, "NewAccountsAvailable")
+	}
+}
+
+// Creates a link token that opens Link in update mode for the institutions item.
+// Account selection is turned on when Plaid reported new accounts
+func CreateUpdateLinkToken(userID int, linkedInstitutionID int, accountSelection bool) (string, error) {
+	item, access, err := loadManagedInstitution(userID, linkedInstitutionID)
+	if err != nil {
+		return "", err
+	}
+	return plaidServices.CreateUpdateLinkToken(strconv.Itoa(userID), item, accountSelection || access.Institution.NewAccountsAvailable)
+}
+
+// Refreshes the institutions accounts after the user finished Link update mode.
+// Accounts Plaid already knew are updated in place and new ones are added, the
+// item is marked healthy and its transactions are synced
+func CompleteItemUpdate(userID int, linkedInstitutionID int) (ItemUpdateResult, error) {
+	result := ItemUpdateResult{LinkedInstitutionID: linkedInstitutionID}
+	item, access, err := loadManagedInstitution(userID, linkedInstitutionID)
+	if err != nil {
+		return result, err
+	}
+	ins := access.Institution
+	plaidAccounts, err := plaidServices.Accounts(item)
+	if err != nil {
+		RecordItemError(ins, err)
+		return result, err
+	}
+
+	now := time.Now().UTC()
+	err = services.RunInTransactionDB(func(tx *services.TxDB) error {
+		var err error
+		result.AccountsAdded, result.AccountsUpdated, err = refreshAccountRows(tx, linkedInstitutionID, plaidAccounts, now)
+		if err != nil {
+			return err
+		}
+
+		ins.ItemStatus = itemStatusValue(ItemStatusHealthy)
+		ins.LastErrorCode = nil
+		ins.ConsentExpiresAt = sql.NullTime{}
+		ins.NewAccountsAvailable = false
+		ins.ItemStatusUpdatedAt = sql.NullTime{Time: now, Valid: true}
+		ins.UpdatedAt = now
+		return tx.UpdateObject(ins, []string{"ItemStatus", "LastErrorCode", "ConsentExpiresAt", "NewAccountsAvailable", "ItemStatusUpdatedAt", "UpdatedAt"}, []string{"LinkedInstitutionID"})
+	})
+	if err != nil {
+		return result, err
+	}
+
+	result.Sync, err = SyncInstitutionTransactions(ins)
+	if err != nil && !errors.Is(err, ErrSyncConflict) {
+		return result, err
+	}
+	return result, nil
+}
+
+// Loads the item and access of an institution the user owns or was granted manage access to
+func loadManagedInstitution(userID int, linkedInstitutionID int) (plaidServices.ItemHandle, InstitutionAccess, error) {
+	item, access, err := LoadPlaidItem(userID, linkedInstitutionID)
+	if err != nil {
+		return plaidServices.ItemHandle{}, InstitutionAccess{}, err
+	}
+	if access.AccessLevel != AccessOwner && access.AccessLevel != AccessManage {
+		return plaidServices.ItemHandle{}, InstitutionAccess{}, ErrManageAccessRequired
+	}
+	return item, access, nil
+}
+
+func recordItemErrorCode(ins services.DB_LinkedInstitutions, errorType string, errorCode string) services.DB_LinkedInstitutions {
+	if errorCode == "" {
+		return ins
+	}
+	ins.LastErrorCode = &errorCode
+	switch {
+	case loginRequiredCodes[errorCode]:
+		ins.ItemStatus = itemStatusValue(ItemStatusLoginRequired)
+	case revokedCodes[errorCode]:
+		ins.ItemStatus = itemStatusValue(ItemStatusRevoked)
+	case errorType == string(plaid.PLAIDERRORTYPE_ITEM_ERROR):
+		ins.ItemStatus = itemStatusValue(ItemStatusError)
+	default:
+		return saveItemStatus(ins, "LastErrorCode")
+	}
+	return saveItemStatus(ins, "ItemStatus", "LastErrorCode", "ItemStatusUpdatedAt")
+}
+
+// Saves the given item status columns. Failures are logged, the status is
+// bookkeeping and must not fail the call it was recorded for
+func saveItemStatus(ins services.DB_LinkedInstitutions, fields ...string) services.DB_LinkedInstitutions {
+	ins.ItemStatusUpdatedAt = sql.NullTime{Time: time.Now().UTC(), Valid: true}
+	if err := services.UpdateObjectDB(ins, fields, []string{"LinkedInstitutionID"}); err != nil {
+		log.Println("could not save item status of linked institution", ins.LinkedInstitutionID, ":", err)
+	}
+	return ins
+}
@@ -0,0 +1,458 @@
+/*
+------------------------------------------------------------------
+FILE NAME:     Liabilities.go
+PROJECT:       CashflowAnalysis
+Date Created:  Oct-19-2026
+--------------------------------------------------------------------
+DESCRIPTION:
+Credit card, student loan and mortgage details of items linked with the
+liabilities product, from /liabilities/get. A sync keeps one row per
+account of each kind, replaces the credit cards APRs, deletes the rows of
+accounts no longer returned and runs on a schedule and when Plaid sends a
+liabilities webhook. Upcoming payments lists the next payment due on every
+liability the user can access.
+--------------------------------------------------------------------
+$HISTORY:
+
+Oct-19-2026   Created initial file.
+Oct-19-2026   UpcomingPayments() reads account names with institutionAccountNames()
+Oct-19-2026   A sync deletes the liability and APR rows of accounts Plaid no longer returns
+------------------------------------------------------------------
+*/
+package userbankaccountdata
+
+import (
+	plaidServices "cashflowanalysis/PlaidComponents"
+	services "cashflowanalysis/Services/DBContext"
+	"database/sql"
+	"log"
+	"sort"
+	"time"
+
+	plaid "github.com/plaid/plaid-go/v31/plaid"
+)
+
+const (
+	LiabilityTypeCredit   = "credit"
+	LiabilityTypeStudent  = "student"
+	LiabilityTypeMortgage = "mortgage"
+)
+
+// The next payment due on a liability account. MinimumPayment is the next
+// monthly payment for mortgages
+type PaymentObligation struct {
+	AccountID              int      `json:"account_id"`
+	AccountName            string   `json:"account_name"`
+	InstitutionName        string   `json:"institution_name"`
+	LiabilityType          string   `json:"liability_type"`
+	DueDate                string   `json:"due_date"`
+	MinimumPayment         *float64 `json:"minimum_payment"`
+	StatementBalance       *float64 `json:"statement_balance"`
+	PastDueAmount          *float64 `json:"past_due_amount"`
+	IsOverdue              bool     `json:"is_overdue"`
+	ISOCurrencyCode        *string  `json:"iso_currency_code"`
+	UnofficialCurrencyCode *string  `json:"unofficial_currency_code"`
+}
+
+// Syncs liabilities every interval. Meant to run in its own goroutine
+func RunLiabilitySyncWorker(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if err := SyncAllLiabilities(interval); err != nil {
+			log.Println("liability sync worker:", err)
+		}
+		<-ticker.C
+	}
+}
+
+// Syncs the liabilities of every item with the liabilities product not synced within maxAge
+func SyncAllLiabilities(maxAge time.Duration) error {
+	institutions, err := institutionsDue(plaid.PRODUCTS_LIABILITIES, "LiabilitiesSyncedAt", maxAge)
+	if err != nil {
+		return err
+	}
+	for _, ins := range institutions {
+		if err := SyncInstitutionLiabilities(ins); err != nil {
+			log.Println("could not sync liabilities of linked institution", ins.LinkedInstitutionID, ":", err)
+		}
+	}
+	return nil
+}
+
+// Stores the items current credit card, student loan and mortgage details
+func SyncInstitutionLiabilities(ins services.DB_LinkedInstitutions) error {
+	resp, err := plaidServices.Liabilities(itemHandle(ins))
+	if err != nil {
+		RecordItemError(ins, err)
+		return err
+	}
+	ins = RecordItemSuccess(ins)
+
+	now := time.Now().UTC()
+	liabilities := resp.GetLiabilities()
+	return services.RunInTransactionDB(func(tx *services.TxDB) error {
+		if _, _, err := refreshAccountRows(tx, ins.LinkedInstitutionID, resp.GetAccounts(), now); err != nil {
+			return err
+		}
+		accountIDs, err := plaidAccountIDs(tx, ins.LinkedInstitutionID, resp.GetAccounts())
+		if err != nil {
+			return err
+		}
+
+		//Accounts Plaid returned each kind for, the institutions other rows of that kind are stale
+		credit, student, mortgage := map[int]bool{}, map[int]bool{}, map[int]bool{}
+		for _, l := range liabilities.GetCredit() {
+			plaidAccountID := l.AccountId.Get()
+			if plaidAccountID == nil {
+				continue
+			}
+			accountID, known := accountIDs[*plaidAccountID]
+			if !known {
+				continue
+			}
+			if err := storeCreditCard(tx, creditCardRow(ins.LinkedInstitutionID, accountID, l, now), l.GetAprs()); err != nil {
+				return err
+			}
+			credit[accountID] = true
+		}
+		for _, l := range liabilities.GetStudent() {
+			plaidAccountID := l.AccountId.Get()
+			if plaidAccountID == nil {
+				continue
+			}
+			accountID, known := accountIDs[*plaidAccountID]
+			if !known {
+				continue
+			}
+			if err := storeStudentLoan(tx, studentLoanRow(ins.LinkedInstitutionID, accountID, l, now)); err != nil {
+				return err
+			}
+			student[accountID] = true
+		}
+		for _, l := range liabilities.GetMortgage() {
+			accountID, known := accountIDs[l.AccountId]
+			if !known {
+				continue
+			}
+			if err := storeMortgage(tx, mortgageRow(ins.LinkedInstitutionID, accountID, l, now)); err != nil {
+				return err
+			}
+			mortgage[accountID] = true
+		}
+		if err := removeStaleLiabilities(tx, ins.LinkedInstitutionID, credit, student, mortgage); err != nil {
+			return err
+		}
+
+		ins.LiabilitiesSyncedAt = sql.NullTime{Time: now, Valid: true}
+		return tx.UpdateObject(ins, []string{"LiabilitiesSyncedAt"}, []string{"LinkedInstitutionID"})
+	})
+}
+
+func creditCardRow(linkedInstitutionID int, accountID int, l plaid.CreditCardLiability, now time.Time) services.DB_CreditCardLiabilities {
+	return services.DB_CreditCardLiabilities{
+		LinkedInstitutionID:    linkedInstitutionID,
+		AccountID:              accountID,
+		IsOverdue:              l.IsOverdue.Get(),
+		LastPaymentAmount:      l.LastPaymentAmount.Get(),
+		LastPaymentDate:        parsePlaidDate(l.LastPaymentDate),
+		LastStatementBalance:   l.LastStatementBalance.Get(),
+		LastStatementIssueDate: parsePlaidDate(l.LastStatementIssueDate),
+		MinimumPaymentAmount:   l.MinimumPaymentAmount.Get(),
+		NextPaymentDueDate:     parsePlaidDate(l.NextPaymentDueDate),
+		UpdatedAt:              now,
+	}
+}
+
+func studentLoanRow(linkedInstitutionID int, accountID int, l plaid.StudentLoan, now time.Time) services.DB_StudentLoans {
+	return services.DB_StudentLoans{
+		LinkedInstitutionID:        linkedInstitutionID,
+		AccountID:                  accountID,
+		LoanName:                   l.LoanName.Get(),
+		LoanStatus:                 l.LoanStatus.Type.Get(),
+		LoanStatusEndDate:          parsePlaidDate(l.LoanStatus.EndDate),
+		RepaymentPlan:              l.RepaymentPlan.Type.Get(),
+		RepaymentPlanDescription:   l.RepaymentPlan.Description.Get(),
+		Guarantor:                  l.Guarantor.Get(),
+		InterestRatePercentage:     l.InterestRatePercentage,
+		IsOverdue:                  l.IsOverdue.Get(),
+		LastPaymentAmount:          l.LastPaymentAmount.Get(),
+		LastPaymentDate:            parsePlaidDate(l.LastPaymentDate),
+		LastStatementBalance:       l.LastStatementBalance.Get(),
+		LastStatementIssueDate:     parsePlaidDate(l.LastStatementIssueDate),
+		MinimumPaymentAmount:       l.MinimumPaymentAmount.Get(),
+		NextPaymentDueDate:         parsePlaidDate(l.NextPaymentDueDate),
+		OriginationDate:            parsePlaidDate(l.OriginationDate),
+		OriginationPrincipalAmount: l.OriginationPrincipalAmount.Get(),
+		OutstandingInterestAmount:  l.OutstandingInterestAmount.Get(),
+		ExpectedPayoffDate:         parsePlaidDate(l.ExpectedPayoffDate),
+		YtdInterestPaid:            l.YtdInterestPaid.Get(),
+		YtdPrincipalPaid:           l.YtdPrincipalPaid.Get(),
+		UpdatedAt:                  now,
+	}
+}
+
+func mortgageRow(linkedInstitutionID int, accountID int, l plaid.MortgageLiability, now time.Time) services.DB_Mortgages {
+	return services.DB_Mortgages{
+		LinkedInstitutionID:        linkedInstitutionID,
+		AccountID:                  accountID,
+		LoanTypeDescription:        l.LoanTypeDescription.Get(),
+		LoanTerm:                   l.LoanTerm.Get(),
+		InterestRatePercentage:     l.InterestRate.Percentage.Get(),
+		InterestRateType:           l.InterestRate.Type.Get(),
+		OriginationDate:            parsePlaidDate(l.OriginationDate),
+		OriginationPrincipalAmount: l.OriginationPrincipalAmount.Get(),
+		MaturityDate:               parsePlaidDate(l.MaturityDate),
+		EscrowBalance:              l.EscrowBalance.Get(),
+		HasPMI:                     l.HasPmi.Get(),
+		HasPrepaymentPenalty:       l.HasPrepaymentPenalty.Get(),
+		NextMonthlyPayment:         l.NextMonthlyPayment.Get(),
+		NextPaymentDueDate:         parsePlaidDate(l.NextPaymentDueDate),
+		LastPaymentAmount:          l.LastPaymentAmount.Get(),
+		LastPaymentDate:            parsePlaidDate(l.LastPaymentDate),
+		PastDueAmount:              l.PastDueAmount.Get(),
+		CurrentLateFee:             l.CurrentLateFee.Get(),
+		YtdInterestPaid:            l.YtdInterestPaid.Get(),
+		YtdPrincipalPaid:           l.YtdPrincipalPaid.Get(),
+		UpdatedAt:                  now,
+	}
+}
+
+// Inserts or updates the accounts credit card row and replaces its APRs
+func storeCreditCard(tx *services.TxDB, row services.DB_CreditCardLiabilities, aprs []plaid.APR) error {
+	existing, err := services.LoadObjectTx(tx, &services.DB_CreditCardLiabilities{AccountID: row.AccountID}, "AccountID")
+	if err != nil {
+		return err
+	}
+	if len(existing) == 0 {
+		if _, err := tx.CreateObject(row); err != nil {
+			return err
+		}
+	} else {
+		row.CreditCardLiabilityID = existing[0].CreditCardLiabilityID
+		if err := tx.UpdateObject(row, []string{}, []string{"CreditCardLiabilityID"}); err != nil {
+			return err
+		}
+	}
+
+	if err := tx.DeleteObject(services.DB_CreditCardAPRs{AccountID: row.AccountID}, "AccountID"); err != nil {
+		return err
+	}
+	for _, apr := range aprs {
+		_, err := tx.CreateObject(services.DB_CreditCardAPRs{
+			LinkedInstitutionID:  row.LinkedInstitutionID,
+			AccountID:            row.AccountID,
+			APRType:              apr.AprType,
+			APRPercentage:        apr.AprPercentage,
+			BalanceSubjectToAPR:  apr.BalanceSubjectToApr.Get(),
+			InterestChargeAmount: apr.InterestChargeAmount.Get(),
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Inserts or updates the accounts student loan row
+func storeStudentLoan(tx *services.TxDB, row services.DB_StudentLoans) error {
+	existing, err := services.LoadObjectTx(tx, &services.DB_StudentLoans{AccountID: row.AccountID}, "AccountID")
+	if err != nil {
+		return err
+	}
+	if len(existing) == 0 {
+		_, err = tx.CreateObject(row)
+		return err
+	}
+	row.StudentLoanID = existing[0].StudentLoanID
+	return tx.UpdateObject(row, []string{}, []string{"StudentLoanID"})
+}
+
+// Inserts or updates the accounts mortgage row
+func storeMortgage(tx *services.TxDB, row services.DB_Mortgages) error {
+	existing, err := services.LoadObjectTx(tx, &services.DB_Mortgages{AccountID: row.AccountID}, "AccountID")
+	if err != nil {
+		return err
+	}
+	if len(existing) == 0 {
+		_, err = tx.CreateObject(row)
+		return err
+	}
+	row.MortgageID = existing[0].MortgageID
+	return tx.UpdateObject(row, []string{}, []string{"MortgageID"})
+}
+
+// Deletes the institutions liability rows, and credit card APRs, of accounts Plaid no
+// longer returned a liability of that kind for, i.e. a paid off loan or closed card.
+// Otherwise UpcomingPayments() keeps reporting their last due date
+func removeStaleLiabilities(tx *services.TxDB, linkedInstitutionID int, credit map[int]bool, student map[int]bool, mortgage map[int]bool) error {
+	cards, err := services.LoadObjectTx(tx, &services.DB_CreditCardLiabilities{LinkedInstitutionID: linkedInstitutionID}, "LinkedInstitutionID")
+	if err != nil {
+		return err
+	}
+	for _, card := range cards {
+		if credit[card.AccountID] {
+			continue
+		}
+		if err := tx.DeleteObject(services.DB_CreditCardAPRs{AccountID: card.AccountID}, "AccountID"); err != nil {
+			return err
+		}
+		if err := tx.DeleteObject(card, "CreditCardLiabilityID"); err != nil {
+			return err
+		}
+	}
+	//APRs are only stored with their card, these are left from cards deleted before
+	aprs, err := services.LoadObjectTx(tx, &services.DB_CreditCardAPRs{LinkedInstitutionID: linkedInstitutionID}, "LinkedInstitutionID")
+	if err != nil {
+		return err
+	}
+	for _, apr := range aprs {
+		if credit[apr.AccountID] {
+			continue
+		}
+		if err := tx.DeleteObject(apr, "CreditCardAPRID"); err != nil {
+			return err
+		}
+	}
+
+	loans, err := services.LoadObjectTx(tx, &services.DB_StudentLoans{LinkedInstitutionID: linkedInstitutionID}, "LinkedInstitutionID")
+	if err != nil {
+		return err
+	}
+	for _, loan := range loans {
+		if student[loan.AccountID] {
+			continue
+		}
+		if err := tx.DeleteObject(loan, "StudentLoanID"); err != nil {
+			return err
+		}
+	}
+
+	mortgages, err := services.LoadObjectTx(tx, &services.DB_Mortgages{LinkedInstitutionID: linkedInstitutionID}, "LinkedInstitutionID")
+	if err != nil {
+		return err
+	}
+	for _, m := range mortgages {
+		if mortgage[m.AccountID] {
+			continue
+		}
+		if err := tx.DeleteObject(m, "MortgageID"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Parses a Plaid YYYY-MM-DD date, nil when not set or malformed
+func parsePlaidDate(date plaid.NullableString) *time.Time {
+	value := date.Get()
+	if value == nil {
+		return nil
+	}
+	t, err := time.Parse(time.DateOnly, *value)
+	if err != nil {
+		return nil
+	}
+	return &t
+}
+
+// Returns the payments due within the next days on every liability the user
+// can access, overdue ones included, ordered by due date
+func UpcomingPayments(userID int, days int) ([]PaymentObligation, error) {
+	obligations := []PaymentObligation{}
+	now := time.Now().UTC()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	until := today.AddDate(0, 0, days)
+
+	institutions, err := AccessibleInstitutions(userID)
+	if err != nil {
+		return nil, err
+	}
+	for _, access := range institutions {
+		ins := access.Institution
+		names, err := institutionAccountNames(ins.LinkedInstitutionID)
+		if err != nil {
+			return nil, err
+		}
+
+		var due []PaymentObligation
+		credit, err := services.LoadObjectDB(&services.DB_CreditCardLiabilities{LinkedInstitutionID: ins.LinkedInstitutionID}, "LinkedInstitutionID")
+		if err != nil {
+			return nil, err
+		}
+		for _, l := range credit {
+			if l.NextPaymentDueDate == nil || !access.IncludesAccount(l.AccountID) {
+				continue
+			}
+			due = append(due, PaymentObligation{
+				AccountID:        l.AccountID,
+				LiabilityType:    LiabilityTypeCredit,
+				DueDate:          l.NextPaymentDueDate.Format(time.DateOnly),
+				MinimumPayment:   l.MinimumPaymentAmount,
+				StatementBalance: l.LastStatementBalance,
+				IsOverdue:        l.IsOverdue != nil && *l.IsOverdue,
+			})
+		}
+		student, err := services.LoadObjectDB(&services.DB_StudentLoans{LinkedInstitutionID: ins.LinkedInstitutionID}, "LinkedInstitutionID")
+		if err != nil {
+			return nil, err
+		}
+		for _, l := range student {
+			if l.NextPaymentDueDate == nil || !access.IncludesAccount(l.AccountID) {
+				continue
+			}
+			due = append(due, PaymentObligation{
+				AccountID:        l.AccountID,
+				LiabilityType:    LiabilityTypeStudent,
+				DueDate:          l.NextPaymentDueDate.Format(time.DateOnly),
+				MinimumPayment:   l.MinimumPaymentAmount,
+				StatementBalance: l.LastStatementBalance,
+				IsOverdue:        l.IsOverdue != nil && *l.IsOverdue,
+			})
+		}
+		mortgages, err := services.LoadObjectDB(&services.DB_Mortgages{LinkedInstitutionID: ins.LinkedInstitutionID}, "LinkedInstitutionID")
+		if err != nil {
+			return nil, err
+		}
+		for _, l := range mortgages {
+			if l.NextPaymentDueDate == nil || !access.IncludesAccount(l.AccountID) {
+				continue
+			}
+			due = append(due, PaymentObligation{
+				AccountID:      l.AccountID,
+				LiabilityType:  LiabilityTypeMortgage,
+				DueDate:        l.NextPaymentDueDate.Format(time.DateOnly),
+				MinimumPayment: l.NextMonthlyPayment,
+				PastDueAmount:  l.PastDueAmount,
+				IsOverdue:      l.PastDueAmount != nil && *l.PastDueAmount > 0,
+			})
+		}
+
+		for _, o := range due {
+			dueDate, _ := time.Parse(time.DateOnly, o.DueDate)
+			if dueDate.After(until) {
+				continue
+			}
+			//The due date only moves on once the payment posts
+			if dueDate.Before(today) {
+				o.IsOverdue = true
+			}
+			o.AccountName = names[o.AccountID]
+			o.InstitutionName = ins.InstitutionName
+			balances, err := services.LoadObjectDB(&services.DB_AccountBalance{AccountID: o.AccountID}, "AccountID")
+			if err != nil {
+				return nil, err
+			}
+			if len(balances) > 0 {
+				o.ISOCurrencyCode = balances[0].ISOCurrencyCode
+				o.UnofficialCurrencyCode = balances[0].UnofficialCurrencyCode
+			}
+			obligations = append(obligations, o)
+		}
+	}
+
+	sort.SliceStable(obligations, func(i, j int) bool {
+		return obligations[i].DueDate < obligations[j].DueDate
+	})
+	return obligations, nil
+}
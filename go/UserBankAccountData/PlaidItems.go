@@ -0,0 +1,56 @@
+/*
+------------------------------------------------------------------
+FILE NAME:     PlaidItems.go
+PROJECT:       CashflowAnalysis
+Date Created:  Oct-19-2026
+--------------------------------------------------------------------
+DESCRIPTION:
+Loads the Plaid item behind a linked institution for the user making the
+request. The item handle carries the access token, so it is only handed
+out after the users access to the institution has been checked and it is
+never returned to the client.
+--------------------------------------------------------------------
+$HISTORY:
+
+Oct-19-2026   Created initial file.
+------------------------------------------------------------------
+*/
+package userbankaccountdata
+
+import (
+	plaidServices "cashflowanalysis/PlaidComponents"
+	services "cashflowanalysis/Services/DBContext"
+	"errors"
+)
+
+var ErrManageAccessRequired = errors.New("manage access to this institution is required")
+
+// Returns the Plaid item of an institution the user owns or was granted access to
+func LoadPlaidItem(userID int, linkedInstitutionID int) (plaidServices.ItemHandle, InstitutionAccess, error) {
+	access, err := AccessibleInstitutions(userID)
+	if err != nil {
+		return plaidServices.ItemHandle{}, InstitutionAccess{}, err
+	}
+	for _, a := range access {
+		if a.Institution.LinkedInstitutionID == linkedInstitutionID {
+			return itemHandle(a.Institution), a, nil
+		}
+	}
+	return plaidServices.ItemHandle{}, InstitutionAccess{}, ErrInstitutionNotFound
+}
+
+// Returns the Plaid item of an institution the user owns or was granted manage access to
+func LoadManagedPlaidItem(userID int, linkedInstitutionID int) (plaidServices.ItemHandle, error) {
+	item, access, err := LoadPlaidItem(userID, linkedInstitutionID)
+	if err != nil {
+		return plaidServices.ItemHandle{}, err
+	}
+	if access.AccessLevel != AccessOwner && access.AccessLevel != AccessManage {
+		return plaidServices.ItemHandle{}, ErrManageAccessRequired
+	}
+	return item, nil
+}
+
+func itemHandle(ins services.DB_LinkedInstitutions) plaidServices.ItemHandle {
+	return plaidServices.ItemHandle{AccessToken: ins.AccessToken, ItemID: ins.ItemID}
+}
@@ -0,0 +1,233 @@
+/*
+------------------------------------------------------------------
+FILE NAME:     PlaidWebhooks.go
+PROJECT:       CashflowAnalysis
+Date Created:  Oct-19-2026
+--------------------------------------------------------------------
+DESCRIPTION:
+Background processing of verified Plaid webhooks. The webhook endpoint only
+queues the webhook in DB_PlaidWebhooks and answers Plaid right away, the
+worker then syncs transactions (or only the recurring streams, for a
+recurring transactions update) for transactions webhooks, investments for
+holdings and investments transactions webhooks and liabilities for
+liabilities webhooks. Item webhooks
+update the items status and are recorded in the owners security log. A
+redelivered webhook, or one for the same item and event as a webhook still
+waiting, is not queued again, and a sync that fails is retried on later runs.
+The worker claims a webhook before processing it, so a webhook arriving
+mid-sync is queued instead of being merged into one already being processed.
+--------------------------------------------------------------------
+$HISTORY:
+
+Oct-19-2026   Created initial file.
+Oct-19-2026   Item webhooks update the items status. Added LOGIN_REPAIRED
+Oct-19-2026   Holdings and investments transactions webhooks sync the items investments
+Oct-19-2026   Liabilities webhooks sync the items liabilities
+Oct-19-2026   Recurring transactions updates store the items recurring streams
+Oct-19-2026   Webhooks are claimed while processed and only coalesce with unclaimed ones
+------------------------------------------------------------------
+*/
+package userbankaccountdata
+
+import (
+	plaidServices "cashflowanalysis/PlaidComponents"
+	services "cashflowanalysis/Services/DBContext"
+	helper "cashflowanalysis/Services/Helpers"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log"
+	"time"
+)
+
+const (
+	//Times a webhook is processed before it is given up on
+	maxWebhookAttempts = 5
+	//A claim older than this is left from a worker that stopped mid-processing
+	webhookClaimTimeout = 30 * time.Minute
+)
+
+// Wakes the worker when a webhook is queued so it does not wait for the next tick
+var webhookQueued = make(chan struct{}, 1)
+
+// Queues a verified webhook for the worker. Returns false when it was already queued
+func EnqueuePlaidWebhook(body []byte, webhook plaidServices.Webhook) (bool, error) {
+	sum := sha256.Sum256(body)
+	dedupKey := hex.EncodeToString(sum[:])
+	delivered, err := services.LoadObjectDB(&services.DB_PlaidWebhooks{DedupKey: dedupKey}, "DedupKey")
+	if err != nil {
+		return false, err
+	}
+	if len(delivered) > 0 {
+		return false, nil
+	}
+
+	errorCode := ""
+	if webhook.Error != nil {
+		errorCode = webhook.Error.ErrorCode
+	}
+	//The waiting webhook already does the same work for the item. One the worker
+	//has claimed may have read the item before this webhook's update
+	waiting, err := services.QueryObjectDB(&services.DB_PlaidWebhooks{},
+		"ProcessedAt IS NULL AND ClaimedAt IS NULL AND Attempts < @MaxAttempts AND ItemID = @ItemID AND WebhookType = @WebhookType AND WebhookCode = @WebhookCode AND ErrorCode = @ErrorCode",
+		sql.Named("MaxAttempts", maxWebhookAttempts),
+		sql.Named("ItemID", webhook.ItemID),
+		sql.Named("WebhookType", webhook.WebhookType),
+		sql.Named("WebhookCode", webhook.WebhookCode),
+		sql.Named("ErrorCode", errorCode))
+	if err != nil {
+		return false, err
+	}
+	if len(waiting) > 0 {
+		return false, nil
+	}
+
+	row := services.DB_PlaidWebhooks{
+		DedupKey:    dedupKey,
+		WebhookType: webhook.WebhookType,
+		WebhookCode: webhook.WebhookCode,
+		ItemID:      webhook.ItemID,
+		ErrorCode:   errorCode,
+		Body:        string(body),
+		ReceivedAt:  time.Now().UTC(),
+	}
+	if _, err := services.CreateObjectDB(row); err != nil {
+		return false, err
+	}
+	select {
+	case webhookQueued <- struct{}{}:
+	default:
+	}
+	return true, nil
+}
+
+// Processes queued webhooks every interval, or as soon as one is queued. Meant to run in its own goroutine
+func RunPlaidWebhookWorker(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if err := ProcessPendingPlaidWebhooks(); err != nil {
+			log.Println("plaid webhook worker:", err)
+		}
+		select {
+		case <-ticker.C:
+		case <-webhookQueued:
+		}
+	}
+}
+
+// Processes every queued webhook that has attempts left, oldest first. Each webhook
+// is claimed before it is processed
+func ProcessPendingPlaidWebhooks() error {
+	pending, err := services.QueryObjectDB(&services.DB_PlaidWebhooks{},
+		"ProcessedAt IS NULL AND Attempts < @MaxAttempts AND (ClaimedAt IS NULL OR ClaimedAt < @StaleClaim) ORDER BY ReceivedAt",
+		sql.Named("MaxAttempts", maxWebhookAttempts),
+		sql.Named("StaleClaim", time.Now().UTC().Add(-webhookClaimTimeout)))
+	if err != nil {
+		return err
+	}
+	for _, row := range pending {
+		row.Attempts++
+		row.ClaimedAt = sql.NullTime{Time: time.Now().UTC(), Valid: true}
+		if err := services.UpdateObjectDB(row, []string{"Attempts", "ClaimedAt"}, []string{"WebhookID"}); err != nil {
+			return err
+		}
+		if err := processPlaidWebhook(row); err != nil {
+			log.Println("could not process plaid webhook", row.WebhookID, row.WebhookType, row.WebhookCode, ":", err)
+			row.LastError = err.Error()
+			row.ClaimedAt = sql.NullTime{Valid: false}
+			if err := services.UpdateObjectDB(row, []string{"LastError", "ClaimedAt"}, []string{"WebhookID"}); err != nil {
+				return err
+			}
+			continue
+		}
+		row.ProcessedAt = sql.NullTime{Time: time.Now().UTC(), Valid: true}
+		if err := services.UpdateObjectDB(row, []string{"ProcessedAt"}, []string{"WebhookID"}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func processPlaidWebhook(row services.DB_PlaidWebhooks) error {
+	var webhook plaidServices.Webhook
+	if err := json.Unmarshal([]byte(row.Body), &webhook); err != nil {
+		return err
+	}
+	//The item may have been unlinked since, then there is nothing left to do
+	institutions, err := services.LoadObjectDB(&services.DB_LinkedInstitutions{ItemID: row.ItemID}, "ItemID")
+	if err != nil {
+		return err
+	}
+
+	switch row.WebhookType {
+	case plaidServices.WebhookTypeTransactions:
+		if row.WebhookCode == plaidServices.WebhookRecurringTransactionsUpdate {
+			for _, ins := range institutions {
+				if _, err := SyncRecurringStreams(ins); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+		if row.WebhookCode != plaidServices.WebhookSyncUpdatesAvailable && row.WebhookCode != plaidServices.WebhookDefaultUpdate {
+			return nil
+		}
+		for _, ins := range institutions {
+			//A conflict means another sync applied the same updates
+			if _, err := SyncInstitutionTransactions(ins); err != nil && !errors.Is(err, ErrSyncConflict) {
+				return err
+			}
+		}
+	case plaidServices.WebhookTypeHoldings, plaidServices.WebhookTypeInvestmentsTransactions:
+		if row.WebhookCode != plaidServices.WebhookDefaultUpdate {
+			return nil
+		}
+		for _, ins := range institutions {
+			if _, err := SyncInstitutionInvestments(ins); err != nil {
+				return err
+			}
+		}
+	case plaidServices.WebhookTypeLiabilities:
+		if row.WebhookCode != plaidServices.WebhookDefaultUpdate {
+			return nil
+		}
+		for _, ins := range institutions {
+			if err := SyncInstitutionLiabilities(ins); err != nil {
+				return err
+			}
+		}
+	case plaidServices.WebhookTypeItem:
+		switch row.WebhookCode {
+		case plaidServices.WebhookItemError, plaidServices.WebhookPendingExpiration,
+			plaidServices.WebhookUserPermissionRevoked, plaidServices.WebhookNewAccountsAvailable,
+			plaidServices.WebhookLoginRepaired:
+		default:
+			return nil
+		}
+		for _, ins := range institutions {
+			applyItemWebhook(ins, webhook)
+			helper.RecordSecurityEvent(nil, helper.SecurityEvent{
+				UserID:  ins.UserID,
+				Type:    helper.EventItemStatus,
+				Success: row.WebhookCode == plaidServices.WebhookNewAccountsAvailable || row.WebhookCode == plaidServices.WebhookLoginRepaired,
+				Detail:  itemStatusDetail(ins, webhook),
+			})
+		}
+	}
+	return nil
+}
+
+// Describes an item webhook for the security log, i.e. "Huntington (item abc): ERROR ITEM_LOGIN_REQUIRED"
+func itemStatusDetail(ins services.DB_LinkedInstitutions, webhook plaidServices.Webhook) string {
+	detail := ins.InstitutionName + " (item " + ins.ItemID + "): " + webhook.WebhookCode
+	if webhook.Error != nil {
+		detail += " " + webhook.Error.ErrorCode
+	}
+	if webhook.ConsentExpirationTime != nil {
+		detail += " consent expires " + *webhook.ConsentExpirationTime
+	}
+	return detail
+}
@@ -0,0 +1,344 @@
+/*
+------------------------------------------------------------------
+FILE NAME:     RecurringStreams.go
+PROJECT:       CashflowAnalysis
+Date Created:  Oct-19-2026
+--------------------------------------------------------------------
+DESCRIPTION:
+Recurring income and bills Plaid detected in an items transactions, from
+/transactions/recurring/get. Streams are stored after every transactions
+sync and when Plaid sends a recurring transactions update. A sync only
+writes Plaids columns, so the name a user gave a stream and whether they
+confirmed or dismissed it are kept. Streams Plaid stops returning are
+kept as inactive rather than deleted so those edits are not lost.
+--------------------------------------------------------------------
+$HISTORY:
+
+Oct-19-2026   Created initial file.
+------------------------------------------------------------------
+*/
+package userbankaccountdata
+
+import (
+	plaidServices "cashflowanalysis/PlaidComponents"
+	services "cashflowanalysis/Services/DBContext"
+	"database/sql"
+	"errors"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	plaid "github.com/plaid/plaid-go/v31/plaid"
+)
+
+const (
+	StreamInflow  = "inflow"
+	StreamOutflow = "outflow"
+
+	ReviewUnreviewed = "unreviewed"
+	ReviewConfirmed  = "confirmed"
+	ReviewDismissed  = "dismissed"
+
+	//Longest name a user can give a stream
+	maxStreamNameLength = 100
+)
+
+// The columns a sync writes. Everything else on the row belongs to the user
+var plaidStreamColumns = []string{
+	"AccountID", "Direction", "Description", "MerchantName", "Category", "CategoryDetailed",
+	"Frequency", "AverageAmount", "LastAmount", "ISOCurrencyCode", "UnofficialCurrencyCode",
+	"FirstDate", "LastDate", "PredictedNextDate", "IsActive", "Status", "UpdatedAt",
+}
+
+var (
+	ErrStreamNotFound      = errors.New("recurring stream not found")
+	ErrInvalidStreamUpdate = errors.New("invalid recurring stream update")
+)
+
+// A recurring stream of an account the user can access. Name is the users
+// name for the stream, or the merchant name or description when not renamed
+type RecurringStream struct {
+	RecurringStreamID      int      `json:"recurring_stream_id"`
+	AccountID              int      `json:"account_id"`
+	AccountName            string   `json:"account_name"`
+	InstitutionName        string   `json:"institution_name"`
+	Direction              string   `json:"direction"`
+	Name                   string   `json:"name"`
+	DisplayName            *string  `json:"display_name"`
+	MerchantName           *string  `json:"merchant_name"`
+	Description            string   `json:"description"`
+	Category               *string  `json:"category"`
+	Frequency              string   `json:"frequency"`
+	AverageAmount          *float64 `json:"average_amount"`
+	LastAmount             *float64 `json:"last_amount"`
+	ISOCurrencyCode        *string  `json:"iso_currency_code"`
+	UnofficialCurrencyCode *string  `json:"unofficial_currency_code"`
+	FirstDate              string   `json:"first_date"`
+	LastDate               string   `json:"last_date"`
+	PredictedNextDate      *string  `json:"predicted_next_date"`
+	IsActive               bool     `json:"is_active"`
+	Status                 string   `json:"status"`
+	ReviewStatus           string   `json:"review_status"`
+}
+
+// The users streams split like Plaid returns them, each ordered by next expected date
+type UserRecurringStreams struct {
+	InflowStreams  []RecurringStream `json:"inflow_streams"`
+	OutflowStreams []RecurringStream `json:"outflow_streams"`
+}
+
+// A users change to a stream. Nil fields are left as they are, an empty
+// DisplayName goes back to the merchant name
+type StreamUpdate struct {
+	DisplayName  *string
+	ReviewStatus *string
+}
+
+// Stores the items recurring streams. Returns the number of streams Plaid returned
+func SyncRecurringStreams(ins services.DB_LinkedInstitutions) (int, error) {
+	resp, err := plaidServices.RecurringTransactions(itemHandle(ins))
+	if err != nil {
+		return 0, err
+	}
+
+	now := time.Now().UTC()
+	count := 0
+	err = services.RunInTransactionDB(func(tx *services.TxDB) error {
+		//The transactions sync before this already matched the items accounts
+		accountIDs, err := plaidAccountIDs(tx, ins.LinkedInstitutionID, nil)
+		if err != nil {
+			return err
+		}
+		existing, err := services.LoadObjectTx(tx, &services.DB_RecurringStreams{LinkedInstitutionID: ins.LinkedInstitutionID}, "LinkedInstitutionID")
+		if err != nil {
+			return err
+		}
+		rows := map[string]services.DB_RecurringStreams{}
+		for _, row := range existing {
+			rows[row.PlaidStreamID] = row
+		}
+
+		returned := map[string]bool{}
+		streams := map[string][]plaid.TransactionStream{StreamInflow: resp.GetInflowStreams(), StreamOutflow: resp.GetOutflowStreams()}
+		for _, direction := range []string{StreamInflow, StreamOutflow} {
+			for _, s := range streams[direction] {
+				accountID, known := accountIDs[s.AccountId]
+				if !known {
+					continue
+				}
+				returned[s.StreamId] = true
+				count++
+				row := recurringStreamRow(ins.LinkedInstitutionID, accountID, direction, s, now)
+				if old, ok := rows[s.StreamId]; ok {
+					row.RecurringStreamID = old.RecurringStreamID
+					if err := tx.UpdateObject(row, plaidStreamColumns, []string{"RecurringStreamID"}); err != nil {
+						return err
+					}
+					continue
+				}
+				row.ReviewStatus = ReviewUnreviewed
+				row.CreatedAt = now
+				if _, err := tx.CreateObject(row); err != nil {
+					return err
+				}
+			}
+		}
+
+		for _, row := range existing {
+			if returned[row.PlaidStreamID] || !row.IsActive {
+				continue
+			}
+			row.IsActive = false
+			row.UpdatedAt = now
+			if err := tx.UpdateObject(row, []string{"IsActive", "UpdatedAt"}, []string{"RecurringStreamID"}); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return count, err
+}
+
+func recurringStreamRow(linkedInstitutionID int, accountID int, direction string, s plaid.TransactionStream, now time.Time) services.DB_RecurringStreams {
+	row := services.DB_RecurringStreams{
+		LinkedInstitutionID:    linkedInstitutionID,
+		AccountID:              accountID,
+		PlaidStreamID:          s.StreamId,
+		Direction:              direction,
+		Description:            s.Description,
+		MerchantName:           s.MerchantName.Get(),
+		Frequency:              string(s.Frequency),
+		AverageAmount:          s.AverageAmount.Amount,
+		LastAmount:             s.LastAmount.Amount,
+		ISOCurrencyCode:        s.LastAmount.IsoCurrencyCode.Get(),
+		UnofficialCurrencyCode: s.LastAmount.UnofficialCurrencyCode.Get(),
+		PredictedNextDate:      parsePlaidDate(s.PredictedNextDate),
+		IsActive:               s.IsActive,
+		Status:                 string(s.Status),
+		UpdatedAt:              now,
+	}
+	row.FirstDate, _ = time.Parse(time.DateOnly, s.FirstDate)
+	row.LastDate, _ = time.Parse(time.DateOnly, s.LastDate)
+	if category := s.PersonalFinanceCategory.Get(); category != nil {
+		row.Category = &category.Primary
+		row.CategoryDetailed = &category.Detailed
+	}
+	return row
+}
+
+// Returns the recurring streams of every account the user can access.
+// Dismissed streams are only included when includeDismissed is set
+func RetrieveRecurringStreams(userID int, includeDismissed bool) (UserRecurringStreams, error) {
+	result := UserRecurringStreams{InflowStreams: []RecurringStream{}, OutflowStreams: []RecurringStream{}}
+	access, err := AccessibleInstitutions(userID)
+	if err != nil {
+		return result, err
+	}
+	for _, a := range access {
+		rows, err := services.LoadObjectDB(&services.DB_RecurringStreams{LinkedInstitutionID: a.Institution.LinkedInstitutionID}, "LinkedInstitutionID")
+		if err != nil {
+			return result, err
+		}
+		accountNames, err := institutionAccountNames(a.Institution.LinkedInstitutionID)
+		if err != nil {
+			return result, err
+		}
+		for _, row := range rows {
+			if !a.IncludesAccount(row.AccountID) || (row.ReviewStatus == ReviewDismissed && !includeDismissed) {
+				continue
+			}
+			stream := recurringStreamView(row, accountNames[row.AccountID], a.Institution.InstitutionName)
+			if row.Direction == StreamInflow {
+				result.InflowStreams = append(result.InflowStreams, stream)
+			} else {
+				result.OutflowStreams = append(result.OutflowStreams, stream)
+			}
+		}
+	}
+	sortStreams(result.InflowStreams)
+	sortStreams(result.OutflowStreams)
+	return result, nil
+}
+
+// Renames, confirms or dismisses a stream of an institution the user owns or
+// was granted manage access to. The change is kept through later syncs
+func UpdateRecurringStream(userID int, recurringStreamID int, update StreamUpdate) (RecurringStream, error) {
+	if update.DisplayName == nil && update.ReviewStatus == nil {
+		return RecurringStream{}, ErrInvalidStreamUpdate
+	}
+	var displayName *string
+	if update.DisplayName != nil {
+		name := strings.TrimSpace(*update.DisplayName)
+		if utf8.RuneCountInString(name) > maxStreamNameLength {
+			return RecurringStream{}, ErrInvalidStreamUpdate
+		}
+		if name != "" {
+			displayName = &name
+		}
+	}
+	if update.ReviewStatus != nil {
+		switch *update.ReviewStatus {
+		case ReviewUnreviewed, ReviewConfirmed, ReviewDismissed:
+		default:
+			return RecurringStream{}, ErrInvalidStreamUpdate
+		}
+	}
+
+	rows, err := services.LoadObjectDB(&services.DB_RecurringStreams{RecurringStreamID: recurringStreamID}, "RecurringStreamID")
+	if err != nil {
+		return RecurringStream{}, err
+	}
+	if len(rows) == 0 {
+		return RecurringStream{}, ErrStreamNotFound
+	}
+	row := rows[0]
+	_, access, err := LoadPlaidItem(userID, row.LinkedInstitutionID)
+	if errors.Is(err, ErrInstitutionNotFound) || (err == nil && !access.IncludesAccount(row.AccountID)) {
+		return RecurringStream{}, ErrStreamNotFound
+	}
+	if err != nil {
+		return RecurringStream{}, err
+	}
+	if access.AccessLevel != AccessOwner && access.AccessLevel != AccessManage {
+		return RecurringStream{}, ErrManageAccessRequired
+	}
+
+	fields := []string{"ReviewUpdatedAt"}
+	if update.DisplayName != nil {
+		row.DisplayName = displayName
+		fields = append(fields, "DisplayName")
+	}
+	if update.ReviewStatus != nil {
+		row.ReviewStatus = *update.ReviewStatus
+		fields = append(fields, "ReviewStatus")
+	}
+	row.ReviewUpdatedAt = sql.NullTime{Time: time.Now().UTC(), Valid: true}
+	if err := services.UpdateObjectDB(row, fields, []string{"RecurringStreamID"}); err != nil {
+		return RecurringStream{}, err
+	}
+
+	accountNames, err := institutionAccountNames(row.LinkedInstitutionID)
+	if err != nil {
+		return RecurringStream{}, err
+	}
+	return recurringStreamView(row, accountNames[row.AccountID], access.Institution.InstitutionName), nil
+}
+
+func recurringStreamView(row services.DB_RecurringStreams, accountName string, institutionName string) RecurringStream {
+	stream := RecurringStream{
+		RecurringStreamID:      row.RecurringStreamID,
+		AccountID:              row.AccountID,
+		AccountName:            accountName,
+		InstitutionName:        institutionName,
+		Direction:              row.Direction,
+		Name:                   row.Description,
+		DisplayName:            row.DisplayName,
+		MerchantName:           row.MerchantName,
+		Description:            row.Description,
+		Category:               row.Category,
+		Frequency:              row.Frequency,
+		AverageAmount:          row.AverageAmount,
+		LastAmount:             row.LastAmount,
+		ISOCurrencyCode:        row.ISOCurrencyCode,
+		UnofficialCurrencyCode: row.UnofficialCurrencyCode,
+		FirstDate:              row.FirstDate.Format(time.DateOnly),
+		LastDate:               row.LastDate.Format(time.DateOnly),
+		IsActive:               row.IsActive,
+		Status:                 row.Status,
+		ReviewStatus:           row.ReviewStatus,
+	}
+	if row.DisplayName != nil {
+		stream.Name = *row.DisplayName
+	} else if row.MerchantName != nil {
+		stream.Name = *row.MerchantName
+	}
+	if row.PredictedNextDate != nil {
+		next := row.PredictedNextDate.Format(time.DateOnly)
+		stream.PredictedNextDate = &next
+	}
+	return stream
+}
+
+// Orders streams by next expected date, streams without one last
+func sortStreams(streams []RecurringStream) {
+	sort.SliceStable(streams, func(i, j int) bool {
+		a, b := streams[i].PredictedNextDate, streams[j].PredictedNextDate
+		if a == nil || b == nil {
+			return a != nil
+		}
+		return *a < *b
+	})
+}
+
+func institutionAccountNames(linkedInstitutionID int) (map[int]string, error) {
+	accounts, err := services.LoadObjectDB(&services.DB_LinkedAccounts{LinkedInstitutionID: linkedInstitutionID}, "LinkedInstitutionID")
+	if err != nil {
+		return nil, err
+	}
+	names := map[int]string{}
+	for _, acc := range accounts {
+		names[acc.AccountID] = acc.Name
+	}
+	return names, nil
+}
@@ -11,6 +11,8 @@ $HISTORY:
 
 Jan-04-2026   Created initial file.
 Jan-04-2026   Added RetrieveAllUserAccountData()
+Oct-19-2026   RetrieveAllUserAccountData() includes institutions shared with the user and no longer
+-             returns access tokens
 ------------------------------------------------------------------
 */
 package userbankaccountdata
@@ -21,37 +23,51 @@ import (
 	"net/http"
 )
 
-// Retrieves the institution and account data tied to the users id
+// Retrieves the institution and account data the user owns or has been granted.
+// Also returns the users access level per linked institution id. Access tokens
+// are never returned
 func RetrieveAllUserAccountData(r *http.Request) ([]services.DB_LinkedInstitutions,
-	[]services.DB_LinkedAccounts, []services.DB_AccountBalance, error) {
+	[]services.DB_LinkedAccounts, []services.DB_AccountBalance, map[int]string, error) {
 	userID := helper.GetUserID(r)
 
-	institution := services.DB_LinkedInstitutions{
-		UserID: userID,
-	}
-	institutions, err := services.LoadObjectDB(&institution, "UserID")
+	access, err := AccessibleInstitutions(userID)
 	if err != nil {
-		return nil, nil, nil, err
+		return nil, nil, nil, nil, err
 	}
+	var institutions []services.DB_LinkedInstitutions
 	var accounts []services.DB_LinkedAccounts
 	var accountBalances []services.DB_AccountBalance
-	for _, ins := range institutions {
+	accessLevels := map[int]string{}
+	for _, a := range access {
+		ins := a.Institution
+		ins.AccessToken = ""
+		institutions = append(institutions, ins)
+		accessLevels[ins.LinkedInstitutionID] = a.AccessLevel
+
 		acc := services.DB_LinkedAccounts{
 			LinkedInstitutionID: ins.LinkedInstitutionID,
 		}
 		accs, err := services.LoadObjectDB(&acc, "LinkedInstitutionID")
 		if err != nil {
-			return nil, nil, nil, err
+			return nil, nil, nil, nil, err
+		}
+		for _, account := range accs {
+			if a.IncludesAccount(account.AccountID) {
+				accounts = append(accounts, account)
+			}
 		}
-		accounts = append(accounts, accs...)
 		accBal := services.DB_AccountBalance{
 			LinkedInstitutionID: ins.LinkedInstitutionID,
 		}
 		accBals, err := services.LoadObjectDB(&accBal, "LinkedInstitutionID")
 		if err != nil {
-			return nil, nil, nil, err
+			return nil, nil, nil, nil, err
+		}
+		for _, balance := range accBals {
+			if a.IncludesAccount(balance.AccountID) {
+				accountBalances = append(accountBalances, balance)
+			}
 		}
-		accountBalances = append(accountBalances, accBals...)
 	}
-	return institutions, accounts, accountBalances, nil
+	return institutions, accounts, accountBalances, accessLevels, nil
 }
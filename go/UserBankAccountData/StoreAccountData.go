@@ -13,6 +13,17 @@ Dec-24-2025   Created initial file.
 Jan-04-2026   Added StoreUserPlaidData()
 Jan-06-2026   Added SaveWidgetData()
 Jan-28-2026   Added methods for handling Widget Board DeleteWidgetData(), CreateWidgetRow(), DeleteWidgetRow(), and RetrieveWidgetData()
+Oct-19-2026   SaveWidgetData() only links accounts the user can access. RetrieveWidgetData() leaves out
+-             accounts whose grant was revoked and now returns each widgets linked accounts
+Oct-19-2026   StoreUserPlaidData() records the public token exchange as a security event
+Oct-19-2026   StoreUserPlaidData() loads accounts and the item with the handle from its own token
+-             exchange instead of the last one exchanged by any user
+Oct-19-2026   StoreUserPlaidData() refreshes the accounts of a re-linked institution in place
+-             and stores the items products
+Oct-19-2026   SaveWidgetData(), DeleteWidgetData(), CreateWidgetRow() and DeleteWidgetRow() only change
+-             widgets and rows on the users own widget board
+Oct-19-2026   StoreUserPlaidData() stores the institution and its accounts in one transaction and
+-             removes the new item at Plaid when they could not be stored
 
 ------------------------------------------------------------------
 */
@@ -20,6 +31,8 @@ package userbankaccountdata
 
 import (
 	helper "cashflowanalysis/Services/Helpers"
+	"errors"
+	"log"
 	"net/http"
 	"time"
 
@@ -31,77 +44,146 @@ import (
 // Then store all data into azure sql
 func StoreUserPlaidData(r *http.Request, publicToken string) bool {
 	userID := helper.GetUserID(r)
-	accessToken, err := plaidServices.GetAccessToken(publicToken)
+	event := helper.SecurityEvent{UserID: userID, Type: helper.EventPublicTokenExchange}
+	plaidItem, err := plaidServices.GetAccessToken(publicToken)
 	if err != nil {
+		event.Detail = "token exchange failed"
+		helper.RecordSecurityEvent(r, event)
 		return false
 	}
-	linkedAccounts, err := plaidServices.Accounts()
+	linkedAccounts, err := plaidServices.Accounts(plaidItem)
 	if err != nil {
+		event.Detail = "could not load accounts"
+		helper.RecordSecurityEvent(r, event)
 		return false
 	}
-	item, institution, err := plaidServices.Item()
+	item, institution, err := plaidServices.Item(plaidItem)
 	if err != nil {
+		event.Detail = "could not load item"
+		helper.RecordSecurityEvent(r, event)
 		return false
 	}
 
-	institutionId := storeInstitutionData(userID, accessToken, item.ItemId, institution)
-
-	for _, acc := range linkedAccounts {
-		storeAccountData(institutionId, acc)
+	var replaced *services.DB_LinkedInstitutions
+	err = services.RunInTransactionDB(func(tx *services.TxDB) error {
+		institutionId, old, err := storeInstitutionData(tx, userID, plaidItem.AccessToken, plaidItem.ItemID, institution, plaidServices.ItemProducts(item))
+		if err != nil {
+			return err
+		}
+		replaced = old
+		_, _, err = refreshAccountRows(tx, institutionId, linkedAccounts, time.Now().UTC())
+		return err
+	})
+	if err != nil {
+		log.Println("could not store linked institution for user", userID, ":", err)
+		//Nothing points at the new item, so it is removed instead of being billed
+		if err := plaidServices.RemoveItem(plaidItem); err != nil {
+			log.Println("could not remove unstored item", plaidItem.ItemID, err)
+		}
+		event.Detail = "could not store accounts"
+		helper.RecordSecurityEvent(r, event)
+		return false
+	}
+	//The old item is replaced, so Plaid stops billing for it and its access token stops working
+	if replaced != nil {
+		if err := plaidServices.RemoveItem(itemHandle(*replaced)); err != nil {
+			log.Println("could not remove replaced item", replaced.ItemID, err)
+		}
 	}
 
+	event.Success = true
+	event.Detail = "linked " + institution.Name + " (item " + item.ItemId + ")"
+	helper.RecordSecurityEvent(r, event)
 	return true
 }
 
-// Links bank accounts to a specific widget on the users screen
-func SaveWidgetData(widgetID int, widgetType string, accounts []services.DB_WidgetLinkedAccounts) error {
-
-	widget := services.DB_Widgets{
-		WidgetID:   widgetID,
-		WidgetType: &widgetType,
-	}
+var (
+	ErrWidgetBoardNotFound = errors.New("widget board not found")
+	ErrWidgetRowNotFound   = errors.New("widget row not found")
+)
 
-	err := services.UpdateObjectDB(widget, []string{"WidgetType"}, []string{"WidgetID"})
+// Links bank accounts to a specific widget on the users screen. The widget must
+// be on the users own widget board and every account must be owned by or shared
+// with the user
+func SaveWidgetData(userID int, widgetID int, widgetType string, accounts []services.DB_WidgetLinkedAccounts) error {
+	accessible, err := AccessibleAccountIDs(userID)
 	if err != nil {
 		return err
 	}
-
 	for _, acc := range accounts {
-		acc.CreatedAt = time.Now()
-		_, err := services.CreateObjectDB(acc)
+		if !accessible[acc.LinkedAccountID] {
+			return ErrAccountNotFound
+		}
+	}
+
+	return services.RunInTransactionDB(func(tx *services.TxDB) error {
+		_, ownerID, err := loadWidgetOwner(tx, widgetID)
 		if err != nil {
 			return err
 		}
-	}
-	return nil
+		if ownerID != userID {
+			return ErrWidgetNotFound
+		}
+
+		widget := services.DB_Widgets{
+			WidgetID:   widgetID,
+			WidgetType: &widgetType,
+		}
+		if err := tx.UpdateObject(widget, []string{"WidgetType"}, []string{"WidgetID"}); err != nil {
+			return err
+		}
+
+		for _, acc := range accounts {
+			acc.WidgetID = widgetID
+			acc.CreatedAt = time.Now()
+			if _, err := tx.CreateObject(acc); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
 }
 
-// Deletes widget and its linked accounts from the database
-func DeleteWidgetData(widgetID int) error {
-	widget := services.DB_Widgets{
-		WidgetID:   widgetID,
-		WidgetType: nil,
-	}
-	err := services.UpdateObjectDB(widget, []string{"WidgetType"}, []string{"WidgetID"})
+// Deletes widget and its linked accounts from the database. The widget must be
+// on the users own widget board
+func DeleteWidgetData(userID int, widgetID int) error {
+	return services.RunInTransactionDB(func(tx *services.TxDB) error {
+		_, ownerID, err := loadWidgetOwner(tx, widgetID)
+		if err != nil {
+			return err
+		}
+		if ownerID != userID {
+			return ErrWidgetNotFound
+		}
+
+		widget := services.DB_Widgets{
+			WidgetID:   widgetID,
+			WidgetType: nil,
+		}
+		if err := tx.UpdateObject(widget, []string{"WidgetType"}, []string{"WidgetID"}); err != nil {
+			return err
+		}
+		liAccount := services.DB_WidgetLinkedAccounts{
+			WidgetID: widgetID,
+		}
+		return tx.DeleteObject(liAccount, "WidgetID")
+	})
+}
+
+// Creates a new widget row with widgets and linked accounts. The row must be
+// for the users own widget board
+func CreateWidgetRow(userID int, row *services.DB_WidgetBoardRows) error {
+	boards, err := services.LoadObjectDB(&services.DB_WidgetBoard{WidgetBoardID: row.WidgetBoardID}, "WidgetBoardID")
 	if err != nil {
 		return err
 	}
-	liAccount := services.DB_WidgetLinkedAccounts{
-		WidgetID: widgetID,
+	if len(boards) == 0 || boards[0].UserID != userID {
+		return ErrWidgetBoardNotFound
 	}
-	err = services.DeleteObjectDB(liAccount, "WidgetID")
-	if err != nil {
-		return err
-	}
-	return nil
-}
-
-// Creates a new widget row with widgets and linked accounts
-func CreateWidgetRow(row *services.DB_WidgetBoardRows) bool {
 
 	rowID, err := services.CreateObjectDB(row)
 	if err != nil {
-		return false
+		return err
 	}
 	row.RowID = rowID
 	for i := range row.Widgets {
@@ -109,36 +191,58 @@ func CreateWidgetRow(row *services.DB_WidgetBoardRows) bool {
 		widgetID, err := services.CreateObjectDB(&row.Widgets[i])
 		row.Widgets[i].WidgetID = widgetID
 		if err != nil {
-			return false
+			return err
 		}
 	}
-	return true
+	return nil
 }
 
-// Deletes a widget row and its associated widgets
-func DeleteWidgetRow(rowID int) bool {
+// Deletes a widget row and its associated widgets. The row must be on the users
+// own widget board
+func DeleteWidgetRow(userID int, rowID int) error {
+	return services.RunInTransactionDB(func(tx *services.TxDB) error {
+		ownerID, err := loadWidgetRowOwner(tx, rowID)
+		if err != nil {
+			return err
+		}
+		if ownerID != userID {
+			return ErrWidgetRowNotFound
+		}
 
-	widget := services.DB_Widgets{
-		RowID: rowID,
-	}
-	err := services.DeleteObjectDB(widget, "RowID")
-	if err != nil {
-		return false
-	}
+		widget := services.DB_Widgets{
+			RowID: rowID,
+		}
+		if err := tx.DeleteObject(widget, "RowID"); err != nil {
+			return err
+		}
+		row := services.DB_WidgetBoardRows{
+			RowID: rowID,
+		}
+		return tx.DeleteObject(row, "RowID")
+	})
+}
 
-	row := services.DB_WidgetBoardRows{
-		RowID: rowID,
+// Loads the user whose widget board the row is on, 0 when the row doesn't exist
+func loadWidgetRowOwner(tx *services.TxDB, rowID int) (int, error) {
+	rows, err := services.LoadObjectTx(tx, &services.DB_WidgetBoardRows{RowID: rowID}, "RowID")
+	if err != nil || len(rows) == 0 {
+		return 0, err
 	}
-	err = services.DeleteObjectDB(row, "RowID")
-	if err != nil {
-		return false
+	boards, err := services.LoadObjectTx(tx, &services.DB_WidgetBoard{WidgetBoardID: rows[0].WidgetBoardID}, "WidgetBoardID")
+	if err != nil || len(boards) == 0 {
+		return 0, err
 	}
-	return true
+	return boards[0].UserID, nil
 }
 
-// Retrieves widget board data for a user
+// Retrieves widget board data for a user. Linked accounts the user can no longer
+// access (i.e. the grant was revoked) are left out
 func RetrieveWidgetData(r *http.Request) services.DB_WidgetBoard {
 	userID := helper.GetUserID(r)
+	accessible, err := AccessibleAccountIDs(userID)
+	if err != nil {
+		return services.DB_WidgetBoard{}
+	}
 	widgetBoard := services.DB_WidgetBoard{
 		UserID: userID,
 	}
@@ -156,11 +260,16 @@ func RetrieveWidgetData(r *http.Request) services.DB_WidgetBoard {
 			RowID: widgetBoard.WidgetBoardRows[i].RowID,
 		}, "RowID")
 		widgetBoard.WidgetBoardRows[i].Widgets = widgets
-		for _, w := range widgetBoard.WidgetBoardRows[i].Widgets {
+		for j := range widgetBoard.WidgetBoardRows[i].Widgets {
+			w := &widgetBoard.WidgetBoardRows[i].Widgets[j]
 			linkedAccounts, _ := services.LoadObjectDB(&services.DB_WidgetLinkedAccounts{
 				WidgetID: w.WidgetID,
 			}, "WidgetID")
-			w.LinkedAccounts = linkedAccounts
+			for _, la := range linkedAccounts {
+				if accessible[la.LinkedAccountID] {
+					w.LinkedAccounts = append(w.LinkedAccounts, la)
+				}
+			}
 		}
 	}
 
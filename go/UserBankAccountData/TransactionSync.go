@@ -0,0 +1,361 @@
+/*
+------------------------------------------------------------------
+FILE NAME:     TransactionSync.go
+PROJECT:       CashflowAnalysis
+Date Created:  Oct-19-2026
+--------------------------------------------------------------------
+DESCRIPTION:
+Incremental transaction sync. Each linked institution stores the cursor of
+its last /transactions/sync. A sync pulls every page since that cursor and
+applies the added, modified and removed transactions together with the new
+cursor in one database transaction. Transactions are keyed by Plaids
+transaction_id, so applying the same updates twice changes nothing and a
+failed sync can simply be run again. After every sync the items recurring
+streams are stored again from /transactions/recurring/get.
+--------------------------------------------------------------------
+$HISTORY:
+
+Oct-19-2026   Created initial file.
+Oct-19-2026   Sync results update the items status
+Oct-19-2026   Stores the items recurring streams after every sync
+Oct-19-2026   applySyncPages() writes through a syncStore so it can run without a database
+------------------------------------------------------------------
+*/
+package userbankaccountdata
+
+import (
+	plaidServices "cashflowanalysis/PlaidComponents"
+	services "cashflowanalysis/Services/DBContext"
+	"database/sql"
+	"errors"
+	"log"
+	"sort"
+	"time"
+
+	plaid "github.com/plaid/plaid-go/v31/plaid"
+)
+
+// Times a sync starts over when Plaid reports the transactions changed while paging
+const maxSyncAttempts = 3
+
+var ErrSyncConflict = errors.New("transactions were synced by another request, try again")
+
+// Counts of the transaction updates applied by a sync
+type SyncResult struct {
+	LinkedInstitutionID int `json:"linked_institution_id"`
+	Added               int `json:"added"`
+	Modified            int `json:"modified"`
+	Removed             int `json:"removed"`
+	//Plaid has not finished pulling the items transactions, try again later
+	NotReady bool      `json:"not_ready"`
+	SyncedAt time.Time `json:"synced_at"`
+	//Recurring streams Plaid returned after the sync
+	RecurringStreams int `json:"recurring_streams"`
+}
+
+// Syncs the transactions of an institution the user owns or was granted manage access to
+func SyncUserInstitutionTransactions(userID int, linkedInstitutionID int) (SyncResult, error) {
+	_, access, err := LoadPlaidItem(userID, linkedInstitutionID)
+	if err != nil {
+		return SyncResult{}, err
+	}
+	if access.AccessLevel != AccessOwner && access.AccessLevel != AccessManage {
+		return SyncResult{}, ErrManageAccessRequired
+	}
+	return SyncInstitutionTransactions(access.Institution)
+}
+
+// Syncs every institution the user owns or was granted manage access to
+func SyncAllUserTransactions(userID int) ([]SyncResult, error) {
+	access, err := AccessibleInstitutions(userID)
+	if err != nil {
+		return nil, err
+	}
+	results := []SyncResult{}
+	for _, a := range access {
+		if a.AccessLevel != AccessOwner && a.AccessLevel != AccessManage {
+			continue
+		}
+		result, err := SyncInstitutionTransactions(a.Institution)
+		if err != nil {
+			return results, err
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// Pulls every transaction update since the institutions stored cursor and applies
+// them and the new cursor in one database transaction
+func SyncInstitutionTransactions(ins services.DB_LinkedInstitutions) (SyncResult, error) {
+	result := SyncResult{LinkedInstitutionID: ins.LinkedInstitutionID}
+	startCursor := ""
+	if ins.TransactionsCursor != nil {
+		startCursor = *ins.TransactionsCursor
+	}
+
+	var pages []plaid.TransactionsSyncResponse
+	for attempt := 1; ; attempt++ {
+		var err error
+		pages, err = fetchSyncPages(itemHandle(ins), startCursor)
+		if err == nil {
+			break
+		}
+		if !plaidServices.IsSyncMutationError(err) || attempt == maxSyncAttempts {
+			RecordItemError(ins, err)
+			return result, err
+		}
+	}
+	RecordItemSuccess(ins)
+	if len(pages) == 0 {
+		result.NotReady = true
+		return result, nil
+	}
+
+	result.SyncedAt = time.Now().UTC()
+	err := services.RunInTransactionDB(func(tx *services.TxDB) error {
+		return applySyncPages(txSyncStore{tx: tx}, ins.LinkedInstitutionID, startCursor, pages, &result)
+	})
+	if err != nil {
+		return SyncResult{LinkedInstitutionID: ins.LinkedInstitutionID}, err
+	}
+
+	//The transactions are stored already, a failure here only leaves the streams as they were
+	if result.RecurringStreams, err = SyncRecurringStreams(ins); err != nil {
+		log.Println("could not sync recurring streams of linked institution", ins.LinkedInstitutionID, ":", err)
+	}
+	return result, nil
+}
+
+// Returns the users stored transactions, newest first. linkedInstitutionID 0
+// returns every institution the user can access. Accounts shared through a grant
+// only include their own transactions
+func RetrieveTransactions(userID int, linkedInstitutionID int) ([]services.DB_Transactions, error) {
+	access, err := AccessibleInstitutions(userID)
+	if err != nil {
+		return nil, err
+	}
+	transactions := []services.DB_Transactions{}
+	found := false
+	for _, a := range access {
+		if linkedInstitutionID != 0 && a.Institution.LinkedInstitutionID != linkedInstitutionID {
+			continue
+		}
+		found = true
+		rows, err := services.QueryObjectDB(&services.DB_Transactions{},
+			"LinkedInstitutionID = @LinkedInstitutionID ORDER BY TransactionDate DESC",
+			sql.Named("LinkedInstitutionID", a.Institution.LinkedInstitutionID))
+		if err != nil {
+			return nil, err
+		}
+		for _, row := range rows {
+			//Transactions of unknown accounts are only shown with access to the whole institution
+			if a.AccountIDs == nil || (row.AccountID != 0 && a.IncludesAccount(row.AccountID)) {
+				transactions = append(transactions, row)
+			}
+		}
+	}
+	if linkedInstitutionID != 0 && !found {
+		return nil, ErrInstitutionNotFound
+	}
+	sort.SliceStable(transactions, func(i, j int) bool {
+		return transactions[i].TransactionDate.After(transactions[j].TransactionDate)
+	})
+	return transactions, nil
+}
+
+// Pages /transactions/sync from cursor until has_more is false. Returns no pages
+// when Plaid is not ready yet
+func fetchSyncPages(item plaidServices.ItemHandle, cursor string) ([]plaid.TransactionsSyncResponse, error) {
+	var pages []plaid.TransactionsSyncResponse
+	for {
+		resp, err := plaidServices.TransactionsSync(item, cursor)
+		if err != nil {
+			return nil, err
+		}
+		if resp.GetNextCursor() == "" {
+			return nil, nil
+		}
+		pages = append(pages, resp)
+		if !resp.GetHasMore() {
+			return pages, nil
+		}
+		cursor = resp.GetNextCursor()
+	}
+}
+
+// The reads and writes applying sync pages makes. txSyncStore runs them in the
+// database transaction of the sync
+type syncStore interface {
+	institution(linkedInstitutionID int) (services.DB_LinkedInstitutions, error)
+	accountIDs(linkedInstitutionID int, plaidAccounts []plaid.AccountBase) (map[string]int, error)
+	upsertTransaction(row services.DB_Transactions) error
+	deleteTransaction(linkedInstitutionID int, transactionID string) error
+	saveCursor(ins services.DB_LinkedInstitutions) error
+}
+
+type txSyncStore struct {
+	tx *services.TxDB
+}
+
+func (s txSyncStore) institution(linkedInstitutionID int) (services.DB_LinkedInstitutions, error) {
+	institutions, err := services.LoadObjectTx(s.tx, &services.DB_LinkedInstitutions{LinkedInstitutionID: linkedInstitutionID}, "LinkedInstitutionID")
+	if err != nil {
+		return services.DB_LinkedInstitutions{}, err
+	}
+	if len(institutions) == 0 {
+		return services.DB_LinkedInstitutions{}, ErrInstitutionNotFound
+	}
+	return institutions[0], nil
+}
+
+func (s txSyncStore) accountIDs(linkedInstitutionID int, plaidAccounts []plaid.AccountBase) (map[string]int, error) {
+	return plaidAccountIDs(s.tx, linkedInstitutionID, plaidAccounts)
+}
+
+func (s txSyncStore) upsertTransaction(row services.DB_Transactions) error {
+	return upsertTransaction(s.tx, row)
+}
+
+func (s txSyncStore) deleteTransaction(linkedInstitutionID int, transactionID string) error {
+	removed := services.DB_Transactions{LinkedInstitutionID: linkedInstitutionID, TransactionID: transactionID}
+	return s.tx.DeleteObject(removed, "LinkedInstitutionID", "TransactionID")
+}
+
+func (s txSyncStore) saveCursor(ins services.DB_LinkedInstitutions) error {
+	return s.tx.UpdateObject(ins, []string{"TransactionsCursor", "TransactionsSyncedAt"}, []string{"LinkedInstitutionID"})
+}
+
+// Applies the pages fetched from startCursor and stores the cursor of the last page.
+// Fails with ErrSyncConflict when the stored cursor is no longer startCursor
+func applySyncPages(store syncStore, linkedInstitutionID int, startCursor string, pages []plaid.TransactionsSyncResponse, result *SyncResult) error {
+	ins, err := store.institution(linkedInstitutionID)
+	if err != nil {
+		return err
+	}
+	//Another sync already moved the cursor, applying these pages again would be stale
+	if ins.TransactionsCursor != nil && *ins.TransactionsCursor != startCursor {
+		return ErrSyncConflict
+	}
+
+	accountIDs, err := store.accountIDs(linkedInstitutionID, pages[len(pages)-1].GetAccounts())
+	if err != nil {
+		return err
+	}
+
+	for _, page := range pages {
+		for _, t := range page.GetAdded() {
+			if err := store.upsertTransaction(transactionRow(linkedInstitutionID, accountIDs, t, result.SyncedAt)); err != nil {
+				return err
+			}
+			result.Added++
+		}
+		for _, t := range page.GetModified() {
+			if err := store.upsertTransaction(transactionRow(linkedInstitutionID, accountIDs, t, result.SyncedAt)); err != nil {
+				return err
+			}
+			result.Modified++
+		}
+		for _, t := range page.GetRemoved() {
+			if err := store.deleteTransaction(linkedInstitutionID, t.GetTransactionId()); err != nil {
+				return err
+			}
+			result.Removed++
+		}
+	}
+
+	cursor := pages[len(pages)-1].GetNextCursor()
+	ins.TransactionsCursor = &cursor
+	ins.TransactionsSyncedAt = sql.NullTime{Time: result.SyncedAt, Valid: true}
+	return store.saveCursor(ins)
+}
+
+// Maps Plaid account ids to DB_LinkedAccounts rows. Accounts linked before the
+// Plaid account id was stored are matched once by name and mask
+func plaidAccountIDs(tx *services.TxDB, linkedInstitutionID int, plaidAccounts []plaid.AccountBase) (map[string]int, error) {
+	accounts, err := services.LoadObjectTx(tx, &services.DB_LinkedAccounts{LinkedInstitutionID: linkedInstitutionID}, "LinkedInstitutionID")
+	if err != nil {
+		return nil, err
+	}
+	ids := map[string]int{}
+	for _, acc := range accounts {
+		if acc.PlaidAccountID != nil {
+			ids[*acc.PlaidAccountID] = acc.AccountID
+		}
+	}
+	for _, acc := range accounts {
+		if acc.PlaidAccountID != nil {
+			continue
+		}
+		var match *plaid.AccountBase
+		matches := 0
+		for i := range plaidAccounts {
+			p := &plaidAccounts[i]
+			if _, known := ids[p.AccountId]; !known && p.Name == acc.Name && p.GetMask() == derefString(acc.Mask) {
+				match = p
+				matches++
+			}
+		}
+		if matches != 1 {
+			continue
+		}
+		acc.PlaidAccountID = &match.AccountId
+		if err := tx.UpdateObject(acc, []string{"PlaidAccountID"}, []string{"AccountID"}); err != nil {
+			return nil, err
+		}
+		ids[match.AccountId] = acc.AccountID
+	}
+	return ids, nil
+}
+
+// Inserts the transaction or updates the row with the same transaction id
+func upsertTransaction(tx *services.TxDB, row services.DB_Transactions) error {
+	existing, err := services.LoadObjectTx(tx, &services.DB_Transactions{TransactionID: row.TransactionID}, "TransactionID")
+	if err != nil {
+		return err
+	}
+	if len(existing) == 0 {
+		_, err = tx.CreateObject(row)
+		return err
+	}
+	row.TransactionRowID = existing[0].TransactionRowID
+	row.CreatedAt = existing[0].CreatedAt
+	return tx.UpdateObject(row, []string{}, []string{"TransactionRowID"})
+}
+
+func transactionRow(linkedInstitutionID int, accountIDs map[string]int, t plaid.Transaction, now time.Time) services.DB_Transactions {
+	row := services.DB_Transactions{
+		LinkedInstitutionID:    linkedInstitutionID,
+		AccountID:              accountIDs[t.AccountId],
+		PlaidAccountID:         t.AccountId,
+		TransactionID:          t.TransactionId,
+		Amount:                 t.Amount,
+		ISOCurrencyCode:        t.IsoCurrencyCode.Get(),
+		UnofficialCurrencyCode: t.UnofficialCurrencyCode.Get(),
+		Name:                   t.Name,
+		MerchantName:           t.MerchantName.Get(),
+		PaymentChannel:         t.PaymentChannel,
+		Pending:                t.Pending,
+		PendingTransactionID:   t.PendingTransactionId.Get(),
+		CreatedAt:              now,
+		UpdatedAt:              now,
+	}
+	row.TransactionDate, _ = time.Parse("2006-01-02", t.Date)
+	if authorized := t.AuthorizedDate.Get(); authorized != nil {
+		if d, err := time.Parse("2006-01-02", *authorized); err == nil {
+			row.AuthorizedDate = &d
+		}
+	}
+	if category := t.PersonalFinanceCategory.Get(); category != nil {
+		row.Category = &category.Primary
+		row.CategoryDetailed = &category.Detailed
+	}
+	return row
+}
+
+func derefString(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
@@ -0,0 +1,292 @@
+/*
+------------------------------------------------------------------
+FILE NAME:     TransactionSync_test.go
+PROJECT:       CashflowAnalysis
+Date Created:  Oct-19-2026
+--------------------------------------------------------------------
+DESCRIPTION:
+Applying /transactions/sync pages from the offline FakeClient to a memory
+syncStore. Covers applying the same pages twice, modified and removed
+transactions arriving after the stored cursor, and ErrSyncConflict when
+another sync moved the cursor first.
+--------------------------------------------------------------------
+$HISTORY:
+
+Oct-19-2026   Created initial file.
+------------------------------------------------------------------
+*/
+package userbankaccountdata
+
+import (
+	plaidServices "cashflowanalysis/PlaidComponents"
+	services "cashflowanalysis/Services/DBContext"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	plaid "github.com/plaid/plaid-go/v31/plaid"
+)
+
+const testLinkedInstitutionID = 7
+
+const testSyncFixture = `{
+  "test": {
+    "linked_institutions": [
+      {
+        "access_token": "access-fixture",
+        "item_id": "item-fixture",
+        "institution_name": "Test Bank",
+        "institution_id": "ins_test",
+        "accounts": [
+          {"account_id": "acc-checking", "account_balance": {"current": 110}, "name": "Checking", "subtype": "checking", "type": "depository"}
+        ],
+        "transactions": [
+          {"transaction_id": "tx-1", "account_id": "acc-checking", "amount": 12.5, "date": "2026-10-01", "name": "Coffee", "category": "FOOD_AND_DRINK"},
+          {"transaction_id": "tx-2", "account_id": "acc-checking", "amount": 40, "date": "2026-10-02", "name": "Groceries"}
+        ]
+      }
+    ]
+  }
+}`
+
+// syncStore holding one linked institution and its transactions in memory.
+// Transactions are keyed by transaction id like upsertTransaction()
+type memorySyncStore struct {
+	institution_ services.DB_LinkedInstitutions
+	accounts     map[string]int
+	transactions map[string]services.DB_Transactions
+	nextRowID    int
+}
+
+func newMemorySyncStore(item plaidServices.ItemHandle) *memorySyncStore {
+	return &memorySyncStore{
+		institution_: services.DB_LinkedInstitutions{LinkedInstitutionID: testLinkedInstitutionID, AccessToken: item.AccessToken, ItemID: item.ItemID},
+		accounts:     map[string]int{},
+		transactions: map[string]services.DB_Transactions{},
+	}
+}
+
+func (m *memorySyncStore) institution(linkedInstitutionID int) (services.DB_LinkedInstitutions, error) {
+	if linkedInstitutionID != m.institution_.LinkedInstitutionID {
+		return services.DB_LinkedInstitutions{}, ErrInstitutionNotFound
+	}
+	return m.institution_, nil
+}
+
+func (m *memorySyncStore) accountIDs(linkedInstitutionID int, plaidAccounts []plaid.AccountBase) (map[string]int, error) {
+	for _, acc := range plaidAccounts {
+		if _, ok := m.accounts[acc.AccountId]; !ok {
+			m.accounts[acc.AccountId] = len(m.accounts) + 1
+		}
+	}
+	ids := map[string]int{}
+	for plaidID, id := range m.accounts {
+		ids[plaidID] = id
+	}
+	return ids, nil
+}
+
+func (m *memorySyncStore) upsertTransaction(row services.DB_Transactions) error {
+	if existing, ok := m.transactions[row.TransactionID]; ok {
+		row.TransactionRowID = existing.TransactionRowID
+		row.CreatedAt = existing.CreatedAt
+	} else {
+		m.nextRowID++
+		row.TransactionRowID = m.nextRowID
+	}
+	m.transactions[row.TransactionID] = row
+	return nil
+}
+
+func (m *memorySyncStore) deleteTransaction(linkedInstitutionID int, transactionID string) error {
+	if row, ok := m.transactions[transactionID]; ok && row.LinkedInstitutionID == linkedInstitutionID {
+		delete(m.transactions, transactionID)
+	}
+	return nil
+}
+
+func (m *memorySyncStore) saveCursor(ins services.DB_LinkedInstitutions) error {
+	m.institution_.TransactionsCursor = ins.TransactionsCursor
+	m.institution_.TransactionsSyncedAt = ins.TransactionsSyncedAt
+	return nil
+}
+
+func (m *memorySyncStore) cursor() string {
+	if m.institution_.TransactionsCursor == nil {
+		return ""
+	}
+	return *m.institution_.TransactionsCursor
+}
+
+// Uses a FakeClient seeded from the test fixture for the test and links a new item
+func useFakePlaid(t *testing.T) (*plaidServices.FakeClient, plaidServices.ItemHandle) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "fixture.json")
+	if err := os.WriteFile(path, []byte(testSyncFixture), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	fake, err := plaidServices.NewFakeClient(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	previous := plaidServices.Client()
+	plaidServices.SetClient(fake)
+	t.Cleanup(func() { plaidServices.SetClient(previous) })
+
+	item, err := fake.ExchangePublicToken("public-fake-ins_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return fake, item
+}
+
+// Fetches every page after the stored cursor and applies them like SyncInstitutionTransactions()
+func syncFromStore(t *testing.T, store *memorySyncStore, item plaidServices.ItemHandle) (SyncResult, error) {
+	t.Helper()
+	startCursor := store.cursor()
+	pages, err := fetchSyncPages(item, startCursor)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pages) == 0 {
+		t.Fatal("fake returned no sync pages")
+	}
+	result := SyncResult{LinkedInstitutionID: testLinkedInstitutionID, SyncedAt: time.Now().UTC()}
+	err = applySyncPages(store, testLinkedInstitutionID, startCursor, pages, &result)
+	return result, err
+}
+
+func TestApplySyncPagesTwiceKeepsOneRowPerTransaction(t *testing.T) {
+	fake, item := useFakePlaid(t)
+	//More than one /transactions/sync page
+	var extra []plaid.Transaction
+	for i := 0; i < plaidServices.TransactionsSyncPageSize; i++ {
+		extra = append(extra, plaid.Transaction{
+			TransactionId: fmt.Sprintf("tx-extra-%d", i),
+			AccountId:     "acc-checking-1",
+			Amount:        1,
+			Date:          "2026-10-03",
+			Name:          "Extra",
+		})
+	}
+	if err := fake.AddTransactions(item, extra...); err != nil {
+		t.Fatal(err)
+	}
+	total := plaidServices.TransactionsSyncPageSize + 2
+
+	store := newMemorySyncStore(item)
+	pages, err := fetchSyncPages(item, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pages) != 2 {
+		t.Fatalf("got %d pages, want 2", len(pages))
+	}
+	first := SyncResult{SyncedAt: time.Now().UTC()}
+	if err := applySyncPages(store, testLinkedInstitutionID, "", pages, &first); err != nil {
+		t.Fatal(err)
+	}
+	if first.Added != total || len(store.transactions) != total {
+		t.Fatalf("added %d, stored %d, want %d", first.Added, len(store.transactions), total)
+	}
+	if store.cursor() != pages[1].GetNextCursor() {
+		t.Fatalf("cursor = %q, want the last pages cursor %q", store.cursor(), pages[1].GetNextCursor())
+	}
+	stored := store.transactions["tx-1-1"]
+	if stored.AccountID == 0 || stored.Amount != 12.5 || stored.Category == nil || *stored.Category != "FOOD_AND_DRINK" {
+		t.Fatalf("stored row %+v", stored)
+	}
+
+	//The same updates again, i.e. after Plaid resets the cursor, update the rows in place
+	store.institution_.TransactionsCursor = nil
+	second := SyncResult{SyncedAt: first.SyncedAt.Add(time.Minute)}
+	if err := applySyncPages(store, testLinkedInstitutionID, "", pages, &second); err != nil {
+		t.Fatal(err)
+	}
+	if len(store.transactions) != total || store.nextRowID != total {
+		t.Fatalf("stored %d rows with %d ids after applying the pages twice, want %d", len(store.transactions), store.nextRowID, total)
+	}
+	again := store.transactions["tx-1-1"]
+	if again.TransactionRowID != stored.TransactionRowID || !again.CreatedAt.Equal(stored.CreatedAt) || !again.UpdatedAt.Equal(second.SyncedAt) {
+		t.Fatalf("row after the second apply %+v, first %+v", again, stored)
+	}
+}
+
+func TestSyncAppliesModifiedAndRemovedTransactions(t *testing.T) {
+	fake, item := useFakePlaid(t)
+	store := newMemorySyncStore(item)
+	if _, err := syncFromStore(t, store, item); err != nil {
+		t.Fatal(err)
+	}
+	firstCursor := store.cursor()
+
+	modified := plaid.Transaction{TransactionId: "tx-1-1", AccountId: "acc-checking-1", Amount: 15, Date: "2026-10-01", Name: "Coffee and cake"}
+	if err := fake.ModifyTransactions(item, modified); err != nil {
+		t.Fatal(err)
+	}
+	if err := fake.RemoveTransactions(item, "acc-checking-1", "tx-2-1"); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := syncFromStore(t, store, item)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Added != 0 || result.Modified != 1 || result.Removed != 1 {
+		t.Fatalf("result %+v, want 1 modified and 1 removed", result)
+	}
+	if _, ok := store.transactions["tx-2-1"]; ok {
+		t.Fatal("removed transaction is still stored")
+	}
+	if row := store.transactions["tx-1-1"]; row.Amount != 15 || row.Name != "Coffee and cake" {
+		t.Fatalf("modified transaction stored as %+v", row)
+	}
+	if store.cursor() == firstCursor {
+		t.Fatal("cursor did not move")
+	}
+
+	//Removing a transaction that is already gone is not an error
+	if err := fake.RemoveTransactions(item, "acc-checking-1", "tx-2-1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := syncFromStore(t, store, item); err != nil {
+		t.Fatal(err)
+	}
+	if len(store.transactions) != 1 {
+		t.Fatalf("stored %d transactions, want 1", len(store.transactions))
+	}
+}
+
+func TestApplySyncPagesConflict(t *testing.T) {
+	fake, item := useFakePlaid(t)
+	store := newMemorySyncStore(item)
+
+	//Two syncs fetch from the same cursor, the first to apply wins
+	stale, err := fetchSyncPages(item, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := fake.AddTransactions(item, plaid.Transaction{TransactionId: "tx-3-1", AccountId: "acc-checking-1", Amount: 3, Date: "2026-10-04"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := syncFromStore(t, store, item); err != nil {
+		t.Fatal(err)
+	}
+	winnerCursor := store.cursor()
+
+	result := SyncResult{SyncedAt: time.Now().UTC()}
+	err = applySyncPages(store, testLinkedInstitutionID, "", stale, &result)
+	if !errors.Is(err, ErrSyncConflict) {
+		t.Fatalf("got %v, want ErrSyncConflict", err)
+	}
+	if store.cursor() != winnerCursor || len(store.transactions) != 3 || result.Added != 0 {
+		t.Fatalf("conflicting sync changed the store: cursor %q, %d rows, result %+v", store.cursor(), len(store.transactions), result)
+	}
+
+	if err := applySyncPages(store, testLinkedInstitutionID+1, winnerCursor, stale, &result); !errors.Is(err, ErrInstitutionNotFound) {
+		t.Fatalf("unknown institution: %v", err)
+	}
+}
@@ -0,0 +1,174 @@
+/*
+------------------------------------------------------------------
+FILE NAME:     UnlinkInstitution.go
+PROJECT:       CashflowAnalysis
+Date Created:  Oct-19-2026
+--------------------------------------------------------------------
+DESCRIPTION:
+Removes a bank connection. The Plaid item is removed first so Plaid stops
+billing for it and invalidates the access token, then the institutions
+accounts, balances, balance history, transactions, investments, liabilities,
+recurring streams, grants and widget links are deleted in one database
+transaction. Widgets that linked to the removed accounts are reported so
+the frontend can ask the user to pick new data sources.
+--------------------------------------------------------------------
+$HISTORY:
+
+Oct-19-2026   Created initial file.
+Oct-19-2026   Also deletes the institutions balance history
+Oct-19-2026   Also deletes the institutions holdings and investment transactions
+Oct-19-2026   Also deletes the institutions liabilities
+Oct-19-2026   Also deletes the institutions recurring streams
+Oct-19-2026   The institutions Plaid data is deleted with eraseInstitutionData() from EraseAccountData.go
+------------------------------------------------------------------
+*/
+package userbankaccountdata
+
+import (
+	plaidServices "cashflowanalysis/PlaidComponents"
+	services "cashflowanalysis/Services/DBContext"
+	helper "cashflowanalysis/Services/Helpers"
+	"errors"
+	"net/http"
+)
+
+var ErrOwnerRequired = errors.New("only the owner can unlink this institution")
+
+// What unlinking an institution removed
+type UnlinkResult struct {
+	LinkedInstitutionID int            `json:"linked_institution_id"`
+	InstitutionName     string         `json:"institution_name"`
+	RowsDeleted         map[string]int `json:"rows_deleted"`
+	//The users widgets that linked to one or more of the removed accounts
+	AffectedWidgets []AffectedWidget `json:"affected_widgets"`
+	//Widgets of users the institution was shared with that lost accounts
+	SharedWidgetsAffected int `json:"shared_widgets_affected"`
+}
+
+// A widget that lost data sources. RemainingAccounts 0 means it has nothing left to show
+type AffectedWidget struct {
+	WidgetID          int      `json:"widget_id"`
+	WidgetType        *string  `json:"widget_type"`
+	RemovedAccounts   []string `json:"removed_accounts"`
+	RemainingAccounts int      `json:"remaining_accounts"`
+}
+
+// Removes the Plaid item of an institution the user owns and deletes everything
+// stored for it. Running it again after a failure finishes the job, Plaid
+// reports an item that is already removed as removed
+func UnlinkInstitution(r *http.Request, userID int, linkedInstitutionID int) (UnlinkResult, error) {
+	result := UnlinkResult{LinkedInstitutionID: linkedInstitutionID, RowsDeleted: map[string]int{}, AffectedWidgets: []AffectedWidget{}}
+	institutions, err := services.LoadObjectDB(&services.DB_LinkedInstitutions{LinkedInstitutionID: linkedInstitutionID}, "LinkedInstitutionID")
+	if err != nil {
+		return result, err
+	}
+	if len(institutions) == 0 || institutions[0].UserID != userID {
+		//Users the institution is shared with learn they can't unlink it, everyone else that it doesn't exist
+		level, err := InstitutionAccessLevel(userID, linkedInstitutionID)
+		if err != nil {
+			return result, err
+		}
+		if level != "" {
+			return result, ErrOwnerRequired
+		}
+		return result, ErrInstitutionNotFound
+	}
+	ins := institutions[0]
+	result.InstitutionName = ins.InstitutionName
+
+	event := helper.SecurityEvent{UserID: userID, Type: helper.EventItemRemoval, Detail: "item " + ins.ItemID + " (unlinked " + ins.InstitutionName + ")"}
+	if err := plaidServices.RemoveItem(itemHandle(ins)); err != nil {
+		helper.RecordSecurityEvent(r, event)
+		return result, err
+	}
+	event.Success = true
+	helper.RecordSecurityEvent(r, event)
+
+	err = services.RunInTransactionDB(func(tx *services.TxDB) error {
+		return deleteInstitutionRows(tx, userID, ins, &result)
+	})
+	if err != nil {
+		return UnlinkResult{LinkedInstitutionID: linkedInstitutionID}, err
+	}
+	return result, nil
+}
+
+// Deletes the institution and its rows, children before parents, and collects the widgets that lost accounts
+func deleteInstitutionRows(tx *services.TxDB, userID int, ins services.DB_LinkedInstitutions, result *UnlinkResult) error {
+	accounts, err := services.LoadObjectTx(tx, &services.DB_LinkedAccounts{LinkedInstitutionID: ins.LinkedInstitutionID}, "LinkedInstitutionID")
+	if err != nil {
+		return err
+	}
+
+	removed := map[int][]string{}
+	var widgetIDs []int
+	for _, acc := range accounts {
+		links, err := services.LoadObjectTx(tx, &services.DB_WidgetLinkedAccounts{LinkedAccountID: acc.AccountID}, "LinkedAccountID")
+		if err != nil {
+			return err
+		}
+		for _, link := range links {
+			if _, seen := removed[link.WidgetID]; !seen {
+				widgetIDs = append(widgetIDs, link.WidgetID)
+			}
+			removed[link.WidgetID] = append(removed[link.WidgetID], acc.Name)
+		}
+		if len(links) > 0 {
+			if err := tx.DeleteObject(services.DB_WidgetLinkedAccounts{LinkedAccountID: acc.AccountID}, "LinkedAccountID"); err != nil {
+				return err
+			}
+			result.RowsDeleted["WidgetLinkedAccounts"] += len(links)
+		}
+	}
+
+	for _, widgetID := range widgetIDs {
+		widget, ownerID, err := loadWidgetOwner(tx, widgetID)
+		if err != nil {
+			return err
+		}
+		if ownerID != userID {
+			result.SharedWidgetsAffected++
+			continue
+		}
+		remaining, err := services.LoadObjectTx(tx, &services.DB_WidgetLinkedAccounts{WidgetID: widgetID}, "WidgetID")
+		if err != nil {
+			return err
+		}
+		result.AffectedWidgets = append(result.AffectedWidgets, AffectedWidget{
+			WidgetID:          widgetID,
+			WidgetType:        widget.WidgetType,
+			RemovedAccounts:   removed[widgetID],
+			RemainingAccounts: len(remaining),
+		})
+	}
+
+	//Balances and transactions go before the accounts they reference
+	id := ins.LinkedInstitutionID
+	if err := eraseInstitutionData(tx, id, result.RowsDeleted); err != nil {
+		return err
+	}
+	if err := services.EraseRowsTx(tx, result.RowsDeleted, "LinkedAccounts", &services.DB_LinkedAccounts{LinkedInstitutionID: id}, "LinkedInstitutionID"); err != nil {
+		return err
+	}
+	if err := services.EraseRowsTx(tx, result.RowsDeleted, "InstitutionGrants", &services.DB_InstitutionGrants{LinkedInstitutionID: id}, "LinkedInstitutionID"); err != nil {
+		return err
+	}
+	return services.EraseRowsTx(tx, result.RowsDeleted, "LinkedInstitutions", &services.DB_LinkedInstitutions{LinkedInstitutionID: id}, "LinkedInstitutionID")
+}
+
+// Loads a widget and the user whose widget board it is on
+func loadWidgetOwner(tx *services.TxDB, widgetID int) (services.DB_Widgets, int, error) {
+	widgets, err := services.LoadObjectTx(tx, &services.DB_Widgets{WidgetID: widgetID}, "WidgetID")
+	if err != nil || len(widgets) == 0 {
+		return services.DB_Widgets{}, 0, err
+	}
+	rows, err := services.LoadObjectTx(tx, &services.DB_WidgetBoardRows{RowID: widgets[0].RowID}, "RowID")
+	if err != nil || len(rows) == 0 {
+		return widgets[0], 0, err
+	}
+	boards, err := services.LoadObjectTx(tx, &services.DB_WidgetBoard{WidgetBoardID: rows[0].WidgetBoardID}, "WidgetBoardID")
+	if err != nil || len(boards) == 0 {
+		return widgets[0], 0, err
+	}
+	return widgets[0], boards[0].UserID, nil
+}
@@ -13,19 +13,39 @@ $HISTORY:
 Jan-04-2026   Created initial file.
 Jan-04-2026   Added storeInstitutionData() and storeAccountData()
 Jan-28-2026   Updated to use new DBContext functions and structs
+Oct-19-2026   storeAccountData() stores the Plaid account id. Re-linking an institution also deletes its
+-             synced transactions since the new item starts a new sync
+Oct-19-2026   Split the account and balance rows out of storeAccountData() so update mode can refresh
+-             accounts in place with updateAccountRows(). Re-linking resets the item status
+Oct-19-2026   Re-linking keeps the institutions accounts and balances and refreshes them in place with
+-             refreshAccountRows(), which replaced storeAccountData(). Balance changes are added to the
+-             balance history
+Oct-19-2026   storeInstitutionData() stores the items Plaid products
+Oct-19-2026   Added institutionsDue() and hasProduct() for the product sync jobs
+Oct-19-2026   Re-linking removes the old item at Plaid and only updates the item columns instead of
+-             overwriting the whole institution row
+Oct-19-2026   storeInstitutionData() writes in the callers transaction and returns its errors. The
+-             replaced item is returned instead of being removed at Plaid inside the transaction
 ------------------------------------------------------------------
 */
 package userbankaccountdata
 
 import (
+	plaidServices "cashflowanalysis/PlaidComponents"
 	services "cashflowanalysis/Services/DBContext"
+	"database/sql"
+	"fmt"
+	"log"
+	"strings"
 	"time"
 
 	plaid "github.com/plaid/plaid-go/v31/plaid"
 )
 
-// Stores the users Institution data
-func storeInstitutionData(userID int, accessToken string, itemId string, institution plaid.Institution) int {
+// Stores the users Institution data in the transaction. When the institution was linked
+// before, its row is moved to the new item and the replaced item is returned so the caller
+// can remove it at Plaid once the transaction commits
+func storeInstitutionData(tx *services.TxDB, userID int, accessToken string, itemId string, institution plaid.Institution, products string) (int, *services.DB_LinkedInstitutions, error) {
 
 	li := services.DB_LinkedInstitutions{
 		LinkedInstitutionID: 0,
@@ -36,46 +56,127 @@ func storeInstitutionData(userID int, accessToken string, itemId string, institu
 		InstitutionID:       institution.InstitutionId,
 		CreatedAt:           time.Now().UTC(),
 		UpdatedAt:           time.Now().UTC(),
+		ItemStatus:          itemStatusValue(ItemStatusHealthy),
+		Products:            &products,
 	}
 
-	db_lis, _ := services.LoadObjectDB(&li, "UserID", "InstitutionID")
-	var db_li services.DB_LinkedInstitutions
-	if len(db_lis) > 0 {
-		db_li = db_lis[0]
+	db_lis, err := services.LoadObjectTx(tx, &li, "UserID", "InstitutionID")
+	if err != nil {
+		return 0, nil, err
 	}
-	if db_li.LinkedInstitutionID == 0 {
-		li.LinkedInstitutionID, _ = services.CreateObjectDB(li)
-	} else {
-		li.LinkedInstitutionID = db_li.LinkedInstitutionID
-		_ = services.UpdateObjectDB(li, []string{}, []string{"UserID", "InstitutionID"})
-		//Once the database has embedded foreign keys to delete LinkedAccounts and AccountBalance
-		//after LinkedInstitution is deleted this will not be necessary
-		deletela := services.DB_LinkedAccounts{
-			LinkedInstitutionID: db_li.LinkedInstitutionID,
+	if len(db_lis) == 0 {
+		id, err := tx.CreateObject(li)
+		if err != nil {
+			return 0, nil, err
 		}
-		services.DeleteObjectDB(deletela, "LinkedInstitutionID")
+		return id, nil, nil
+	}
+	db_li := db_lis[0]
+	li.LinkedInstitutionID = db_li.LinkedInstitutionID
+	//The sync cursor, status and products belonged to the old item, the rest of the row is kept
+	if err := tx.UpdateObject(li, []string{"AccessToken", "ItemID", "TransactionsCursor", "ItemStatus", "LastErrorCode", "Products", "UpdatedAt"}, []string{"LinkedInstitutionID"}); err != nil {
+		return 0, nil, err
+	}
+	//The accounts are kept so their balance history and widget links survive, refreshAccountRows()
+	//matches them to the new items accounts by name and mask
+	unmatched := services.DB_LinkedAccounts{
+		LinkedInstitutionID: db_li.LinkedInstitutionID,
+		PlaidAccountID:      nil,
+	}
+	if err := tx.UpdateObject(unmatched, []string{"PlaidAccountID", "LinkedInstitutionID"}, []string{"LinkedInstitutionID"}); err != nil {
+		return 0, nil, err
+	}
 
-		deleteab := services.DB_AccountBalance{
-			LinkedInstitutionID: db_li.LinkedInstitutionID,
+	//The update above cleared the sync cursor, the new item syncs its transactions from the start
+	deletetx := services.DB_Transactions{
+		LinkedInstitutionID: db_li.LinkedInstitutionID,
+	}
+	if err := tx.DeleteObject(deletetx, "LinkedInstitutionID"); err != nil {
+		return 0, nil, err
+	}
+
+	//The old item is replaced, so Plaid stops billing for it and its access token stops working
+	if db_li.ItemID != itemId {
+		return li.LinkedInstitutionID, &db_li, nil
+	}
+	return li.LinkedInstitutionID, nil, nil
+}
+
+// Stores the institutions accounts and balances from Plaid. Accounts already
+// stored are updated in place and new ones are added. Returns the number added and updated
+func refreshAccountRows(tx *services.TxDB, linkedInstitutionID int, plaidAccounts []plaid.AccountBase, now time.Time) (int, int, error) {
+	accountIDs, err := plaidAccountIDs(tx, linkedInstitutionID, plaidAccounts)
+	if err != nil {
+		return 0, 0, err
+	}
+	added, updated := 0, 0
+	for _, acc := range plaidAccounts {
+		accountID, known := accountIDs[acc.AccountId]
+		if !known {
+			if _, err := createAccountRows(tx, linkedInstitutionID, acc, now); err != nil {
+				return added, updated, err
+			}
+			added++
+			continue
+		}
+		if err := updateAccountRows(tx, linkedInstitutionID, accountID, acc, now); err != nil {
+			return added, updated, err
 		}
-		services.DeleteObjectDB(deleteab, "LinkedInstitutionID")
-		//-------//
+		updated++
 	}
+	return added, updated, nil
+}
 
-	return li.LinkedInstitutionID
+// Adds a new Plaid account and its balance to the institution
+func createAccountRows(tx *services.TxDB, linkedInstitutionID int, acc plaid.AccountBase, now time.Time) (int, error) {
+	accountID, err := tx.CreateObject(linkedAccountRow(linkedInstitutionID, acc, now))
+	if err != nil {
+		return 0, err
+	}
+	ab := accountBalanceRow(linkedInstitutionID, accountID, acc, now)
+	if _, err := tx.CreateObject(ab); err != nil {
+		return 0, err
+	}
+	return accountID, appendBalanceSnapshot(tx, ab, now)
 }
 
-// Stores the users account data associated with the Institution ID
-func storeAccountData(linkedInstitutionID int, acc plaid.AccountBase) bool {
+// Overwrites a stored account and its balance with Plaids current data, keeping the
+// row ids so widgets linked to the account keep working. A changed balance is
+// added to the accounts balance history
+func updateAccountRows(tx *services.TxDB, linkedInstitutionID int, accountID int, acc plaid.AccountBase, now time.Time) error {
+	la := linkedAccountRow(linkedInstitutionID, acc, now)
+	la.AccountID = accountID
+	if err := tx.UpdateObject(la, []string{"Name", "Mask", "OfficialName", "Subtype", "Type", "VerificationStatus", "HolderCategory", "PlaidAccountID", "UpdatedAt"}, []string{"AccountID"}); err != nil {
+		return err
+	}
+
+	ab := accountBalanceRow(linkedInstitutionID, accountID, acc, now)
+	balances, err := services.LoadObjectTx(tx, &services.DB_AccountBalance{AccountID: accountID}, "AccountID")
+	if err != nil {
+		return err
+	}
+	if len(balances) == 0 {
+		_, err = tx.CreateObject(ab)
+	} else {
+		ab.AccountBalanceID = balances[0].AccountBalanceID
+		err = tx.UpdateObject(ab, []string{"Available", "CurrentAmount", "LimitAmount", "ISOCurrencyCode", "UnofficialCurrencyCode", "AccountLastUpdatedAt", "UpdatedAt"}, []string{"AccountBalanceID"})
+	}
+	if err != nil {
+		return err
+	}
+	return appendBalanceSnapshot(tx, ab, now)
+}
 
+func linkedAccountRow(linkedInstitutionID int, acc plaid.AccountBase, now time.Time) services.DB_LinkedAccounts {
 	la := services.DB_LinkedAccounts{
 		AccountID:           0,
 		LinkedInstitutionID: linkedInstitutionID,
 		Name:                acc.Name,
 		Type:                string(acc.Type),
 		VerificationStatus:  acc.VerificationStatus,
-		CreatedAt:           time.Now().UTC(),
-		UpdatedAt:           time.Now().UTC(),
+		CreatedAt:           now,
+		UpdatedAt:           now,
+		PlaidAccountID:      &acc.AccountId,
 	}
 
 	if acc.Mask.IsSet() {
@@ -92,15 +193,16 @@ func storeAccountData(linkedInstitutionID int, acc plaid.AccountBase) bool {
 		s := string(*acc.HolderCategory.Get())
 		la.HolderCategory = &s
 	}
+	return la
+}
 
-	la.AccountID, _ = services.CreateObjectDB(la)
-
+func accountBalanceRow(linkedInstitutionID int, accountID int, acc plaid.AccountBase, now time.Time) services.DB_AccountBalance {
 	ab := services.DB_AccountBalance{
 		AccountBalanceID:    0,
 		LinkedInstitutionID: linkedInstitutionID,
-		AccountID:           la.AccountID,
-		CreatedAt:           time.Now().UTC(),
-		UpdatedAt:           time.Now().UTC(),
+		AccountID:           accountID,
+		CreatedAt:           now,
+		UpdatedAt:           now,
 	}
 
 	if acc.Balances.Available.IsSet() {
@@ -127,8 +229,51 @@ func storeAccountData(linkedInstitutionID int, acc plaid.AccountBase) bool {
 		v := acc.Balances.GetLastUpdatedDatetime()
 		ab.AccountLastUpdatedAt = &v
 	}
+	return ab
+}
 
-	ab.AccountID, _ = services.CreateObjectDB(ab)
+// Loads the institutions whose item has the product and whose syncedAtColumn is
+// older than maxAge or not set. Items that need the user to log in again or were
+// revoked are left out. Products of items linked before they were stored are loaded from Plaid
+func institutionsDue(product plaid.Products, syncedAtColumn string, maxAge time.Duration) ([]services.DB_LinkedInstitutions, error) {
+	institutions, err := services.QueryObjectDB(&services.DB_LinkedInstitutions{},
+		fmt.Sprintf("(%[1]s IS NULL OR %[1]s < @SyncedBefore) AND (ItemStatus IS NULL OR ItemStatus NOT IN (@LoginRequired, @Revoked))", syncedAtColumn),
+		sql.Named("SyncedBefore", time.Now().UTC().Add(-maxAge)),
+		sql.Named("LoginRequired", ItemStatusLoginRequired),
+		sql.Named("Revoked", ItemStatusRevoked))
+	if err != nil {
+		return nil, err
+	}
+	var due []services.DB_LinkedInstitutions
+	for _, ins := range institutions {
+		if ins.Products == nil {
+			products, err := plaidServices.LoadItemProducts(itemHandle(ins))
+			if err != nil {
+				RecordItemError(ins, err)
+				log.Println("could not load products of linked institution", ins.LinkedInstitutionID, ":", err)
+				continue
+			}
+			ins.Products = &products
+			if err := services.UpdateObjectDB(ins, []string{"Products"}, []string{"LinkedInstitutionID"}); err != nil {
+				return nil, err
+			}
+		}
+		if hasProduct(ins, product) {
+			due = append(due, ins)
+		}
+	}
+	return due, nil
+}
 
-	return true
+// Returns true when the institutions item was linked with the product
+func hasProduct(ins services.DB_LinkedInstitutions, product plaid.Products) bool {
+	if ins.Products == nil {
+		return false
+	}
+	for _, p := range strings.Split(*ins.Products, ",") {
+		if p == string(product) {
+			return true
+		}
+	}
+	return false
 }